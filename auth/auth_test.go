@@ -0,0 +1,138 @@
+package auth
+
+import (
+	"context"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	"golang.org/x/crypto/bcrypt"
+)
+
+const testSecret = "0123456789abcdef0123456789abcdef"
+
+func TestParseScope(t *testing.T) {
+	tests := []struct {
+		scope   string
+		want    Resource
+		wantErr bool
+	}{
+		{"repository:team/app:pull,push", Resource{TypeRepository, "team/app", []string{"pull", "push"}}, false},
+		{"repository:localhost:5000/app:pull", Resource{TypeRepository, "localhost:5000/app", []string{"pull"}}, false},
+		{"registry:catalog:*", CatalogResource, false},
+		{"repository:app:", Resource{TypeRepository, "app", nil}, false},
+		{"repository", Resource{}, true},
+		{"repository:pull", Resource{}, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.scope, func(t *testing.T) {
+			got, err := ParseScope(tt.scope)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("err = %v, wantErr %v", err, tt.wantErr)
+			}
+			if !tt.wantErr && !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("got %+v, want %+v", got, tt.want)
+			}
+		})
+	}
+
+	resources, err := ParseScopes([]string{"repository:a:pull repository:b:push", "registry:catalog:*"})
+	if err != nil || len(resources) != 3 {
+		t.Errorf("ParseScopes = %v, %v", resources, err)
+	}
+}
+
+func TestTokenService(t *testing.T) {
+	tokens, err := NewTokenService(testSecret, "issuer", "registry", time.Minute)
+	if err != nil {
+		t.Fatalf("NewTokenService failed: %v", err)
+	}
+	access := []Resource{{Type: TypeRepository, Name: "team/app", Actions: []string{ActionPull}}}
+
+	token, expires, err := tokens.Issue("alice", access)
+	if err != nil {
+		t.Fatalf("Issue failed: %v", err)
+	}
+	if time.Until(expires) > time.Minute {
+		t.Errorf("expires = %v, want within a minute", expires)
+	}
+
+	claims, err := tokens.Verify(token)
+	if err != nil {
+		t.Fatalf("Verify failed: %v", err)
+	}
+	if claims.Subject != "alice" {
+		t.Errorf("subject = %q", claims.Subject)
+	}
+	if !claims.Allows(TypeRepository, "team/app", ActionPull) {
+		t.Error("token should allow pull on team/app")
+	}
+	if claims.Allows(TypeRepository, "team/app", ActionPush) || claims.Allows(TypeRepository, "other", ActionPull) {
+		t.Error("token should not allow actions it was not granted")
+	}
+
+	t.Run("tampered", func(t *testing.T) {
+		parts := strings.Split(token, ".")
+		parts[2] = strings.Repeat("A", len(parts[2]))
+		if _, err := tokens.Verify(strings.Join(parts, ".")); err == nil {
+			t.Error("expected tampered token to be rejected")
+		}
+	})
+
+	t.Run("other service", func(t *testing.T) {
+		other, _ := NewTokenService(testSecret, "issuer", "elsewhere", time.Minute)
+		if _, err := other.Verify(token); err == nil {
+			t.Error("expected token for another audience to be rejected")
+		}
+	})
+
+	t.Run("expired", func(t *testing.T) {
+		short, _ := NewTokenService(testSecret, "issuer", "registry", time.Nanosecond)
+		expired, _, _ := short.Issue("alice", access)
+		time.Sleep(time.Second)
+		if _, err := tokens.Verify(expired); err == nil {
+			t.Error("expected expired token to be rejected")
+		}
+	})
+
+	if _, err := NewTokenService("short", "issuer", "registry", time.Minute); err == nil {
+		t.Error("expected short secret to be rejected")
+	}
+}
+
+func TestCredentials(t *testing.T) {
+	hash, _ := bcrypt.GenerateFromPassword([]byte("hunter2"), bcrypt.MinCost)
+	creds, err := NewCredentials(map[string]string{"alice": string(hash)})
+	if err != nil {
+		t.Fatalf("NewCredentials failed: %v", err)
+	}
+	if !creds.Authenticate("alice", "hunter2") {
+		t.Error("valid credentials rejected")
+	}
+	if creds.Authenticate("alice", "wrong") || creds.Authenticate("bob", "hunter2") {
+		t.Error("invalid credentials accepted")
+	}
+
+	if _, err := NewCredentials(map[string]string{"alice": "plaintext"}); err == nil {
+		t.Error("expected non-bcrypt hash to be rejected")
+	}
+}
+
+func TestDefaultAuthorizer(t *testing.T) {
+	ctx := context.Background()
+	resource := Resource{Type: TypeRepository, Name: "app", Actions: []string{ActionPull, ActionPush}}
+
+	if got := (DefaultAuthorizer{}).Authorize(ctx, "alice", resource); !reflect.DeepEqual(got, resource.Actions) {
+		t.Errorf("authenticated user granted %v", got)
+	}
+	if got := (DefaultAuthorizer{}).Authorize(ctx, "", resource); len(got) != 0 {
+		t.Errorf("anonymous user granted %v", got)
+	}
+	if got := (DefaultAuthorizer{AnonymousPull: true}).Authorize(ctx, "", resource); !reflect.DeepEqual(got, []string{ActionPull}) {
+		t.Errorf("anonymous pull granted %v", got)
+	}
+	if got := (DefaultAuthorizer{AnonymousPull: true}).Authorize(ctx, "", CatalogResource); len(got) != 0 {
+		t.Errorf("anonymous catalog granted %v", got)
+	}
+}
@@ -0,0 +1,31 @@
+package auth
+
+import "context"
+
+// Authorizer decides which of the actions requested on a resource a user is granted.
+type Authorizer interface {
+	// Authorize returns the requested actions user may perform on resource.
+	// user is empty for anonymous requests.
+	Authorize(ctx context.Context, user string, resource Resource) []string
+}
+
+// DefaultAuthorizer grants authenticated users every requested action and
+// anonymous users pull access to repositories when AnonymousPull is set.
+type DefaultAuthorizer struct {
+	AnonymousPull bool
+}
+
+// Authorize implements Authorizer.
+func (a DefaultAuthorizer) Authorize(ctx context.Context, user string, resource Resource) []string {
+	if user != "" {
+		return resource.Actions
+	}
+	if a.AnonymousPull && resource.Type == TypeRepository {
+		for _, action := range resource.Actions {
+			if action == ActionPull {
+				return []string{ActionPull}
+			}
+		}
+	}
+	return nil
+}
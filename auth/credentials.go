@@ -0,0 +1,42 @@
+package auth
+
+import (
+	"fmt"
+
+	"golang.org/x/crypto/bcrypt"
+)
+
+// Credentials checks usernames and passwords against bcrypt hashes, as
+// produced by `htpasswd -nB`.
+type Credentials struct {
+	users map[string][]byte
+}
+
+// NewCredentials creates a credential store from username to bcrypt hash.
+func NewCredentials(users map[string]string) (*Credentials, error) {
+	c := &Credentials{users: make(map[string][]byte, len(users))}
+	for username, hash := range users {
+		if username == "" {
+			return nil, fmt.Errorf("username cannot be empty")
+		}
+		if _, err := bcrypt.Cost([]byte(hash)); err != nil {
+			return nil, fmt.Errorf("invalid password hash for %q: %w", username, err)
+		}
+		c.users[username] = []byte(hash)
+	}
+	return c, nil
+}
+
+// Authenticate reports whether password is correct for username.
+func (c *Credentials) Authenticate(username, password string) bool {
+	hash, ok := c.users[username]
+	if !ok {
+		// Compare anyway so unknown users take as long as known ones
+		bcrypt.CompareHashAndPassword(dummyHash, []byte(password))
+		return false
+	}
+	return bcrypt.CompareHashAndPassword(hash, []byte(password)) == nil
+}
+
+// dummyHash is compared against for unknown users.
+var dummyHash, _ = bcrypt.GenerateFromPassword([]byte("unknown user"), bcrypt.DefaultCost)
@@ -0,0 +1,304 @@
+package auth
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"sync"
+
+	"github.com/hairizuanbinnoorazman/package-universe/oci"
+	"github.com/hairizuanbinnoorazman/package-universe/storage"
+)
+
+// PolicyPath is where a policy document is kept when it is loaded from storage.
+const PolicyPath = "v2/auth/policy.json"
+
+// Role is a named set of repository actions.
+type Role struct {
+	Name    string   `json:"name" mapstructure:"name"`
+	Actions []string `json:"actions" mapstructure:"actions"`
+}
+
+// Group is a named set of users.
+type Group struct {
+	Name    string   `json:"name" mapstructure:"name"`
+	Members []string `json:"members" mapstructure:"members"`
+}
+
+// Grant gives the listed users and members of the listed groups the actions of
+// Role on repositories matching any of the Repositories patterns, as matched
+// by oci.MatchRepository. A pattern ending in "/**" matches every repository
+// nested below the namespace before it, so "platform/**" covers both
+// platform/app and platform/app/api.
+// Empty Repositories matches every repository; an admin grant on every
+// repository also allows the registry administration endpoints.
+type Grant struct {
+	Users        []string `json:"users,omitempty" mapstructure:"users"` // "*" matches every authenticated user
+	Groups       []string `json:"groups,omitempty" mapstructure:"groups"`
+	Role         string   `json:"role" mapstructure:"role"`
+	Repositories []string `json:"repositories,omitempty" mapstructure:"repositories"`
+}
+
+// PolicyDocument is an RBAC policy as written in the configuration or stored
+// at PolicyPath.
+type PolicyDocument struct {
+	Roles  []Role  `json:"roles,omitempty" mapstructure:"roles"`
+	Groups []Group `json:"groups,omitempty" mapstructure:"groups"`
+	Grants []Grant `json:"grants" mapstructure:"grants"`
+}
+
+// BuiltinRoles can be granted without being defined in a policy.
+var BuiltinRoles = []Role{
+	{Name: "reader", Actions: []string{ActionPull}},
+	{Name: "writer", Actions: []string{ActionPull, ActionPush}},
+	{Name: "maintainer", Actions: []string{ActionPull, ActionPush, ActionDelete}},
+	{Name: "admin", Actions: []string{ActionAdmin}},
+}
+
+// Policy is an Authorizer that grants users the actions of the roles granted
+// to them, or to groups they belong to, on matching repositories. Every
+// authenticated user may list the catalog, which is filtered to the
+// repositories they can pull. Anonymous users get pull on every repository
+// when anonymousPull is set and nothing otherwise.
+type Policy struct {
+	anonymousPull bool
+
+	mu      sync.RWMutex
+	grants  []compiledGrant
+	groupOf map[string][]string // user -> groups
+}
+
+// compiledGrant is a validated grant with its role resolved to actions.
+type compiledGrant struct {
+	allUsers     bool
+	users        map[string]bool
+	groups       map[string]bool
+	repositories []string
+	actions      []string
+}
+
+// NewPolicy creates a policy from a document.
+func NewPolicy(doc PolicyDocument, anonymousPull bool) (*Policy, error) {
+	p := &Policy{anonymousPull: anonymousPull}
+	if err := p.Load(doc); err != nil {
+		return nil, err
+	}
+	return p, nil
+}
+
+// Load validates doc and replaces the policy's rules with it. On error the
+// current rules are kept.
+func (p *Policy) Load(doc PolicyDocument) error {
+	roles := make(map[string][]string)
+	for _, role := range BuiltinRoles {
+		roles[role.Name] = role.Actions
+	}
+	for _, role := range doc.Roles {
+		if role.Name == "" {
+			return fmt.Errorf("role name cannot be empty")
+		}
+		if _, ok := roles[role.Name]; ok {
+			return fmt.Errorf("role %q is already defined", role.Name)
+		}
+		for _, action := range role.Actions {
+			switch action {
+			case ActionPull, ActionPush, ActionDelete, ActionAdmin:
+			default:
+				return fmt.Errorf("role %q: unknown action %q", role.Name, action)
+			}
+		}
+		roles[role.Name] = role.Actions
+	}
+
+	groupOf := make(map[string][]string)
+	groups := make(map[string]bool)
+	for _, group := range doc.Groups {
+		if group.Name == "" {
+			return fmt.Errorf("group name cannot be empty")
+		}
+		if groups[group.Name] {
+			return fmt.Errorf("group %q is already defined", group.Name)
+		}
+		groups[group.Name] = true
+		for _, member := range group.Members {
+			groupOf[member] = append(groupOf[member], group.Name)
+		}
+	}
+
+	grants := make([]compiledGrant, 0, len(doc.Grants))
+	for i, grant := range doc.Grants {
+		actions, ok := roles[grant.Role]
+		if !ok {
+			return fmt.Errorf("grant %d: unknown role %q", i, grant.Role)
+		}
+		if len(grant.Users) == 0 && len(grant.Groups) == 0 {
+			return fmt.Errorf("grant %d: no users or groups", i)
+		}
+		compiled := compiledGrant{
+			users:        make(map[string]bool),
+			groups:       make(map[string]bool),
+			repositories: grant.Repositories,
+			actions:      actions,
+		}
+		for _, user := range grant.Users {
+			if user == "*" {
+				compiled.allUsers = true
+			}
+			compiled.users[user] = true
+		}
+		for _, group := range grant.Groups {
+			if !groups[group] {
+				return fmt.Errorf("grant %d: unknown group %q", i, group)
+			}
+			compiled.groups[group] = true
+		}
+		for _, pattern := range grant.Repositories {
+			if err := oci.ValidateRepositoryPattern(pattern); err != nil {
+				return fmt.Errorf("grant %d: invalid repository pattern %q: %w", i, pattern, err)
+			}
+		}
+		grants = append(grants, compiled)
+	}
+
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.grants = grants
+	p.groupOf = groupOf
+	return nil
+}
+
+// Authorize implements Authorizer.
+func (p *Policy) Authorize(ctx context.Context, user string, resource Resource) []string {
+	switch {
+	case resource.Type == TypeRepository:
+		allowed := p.allowed(user, resource.Name)
+		var granted []string
+		for _, action := range resource.Actions {
+			if allowed[action] || (action == ActionAll && allowed[ActionAdmin]) {
+				granted = append(granted, action)
+			}
+		}
+		return granted
+	case resource.Type == CatalogResource.Type && resource.Name == CatalogResource.Name:
+		if user != "" || p.anonymousPull {
+			return resource.Actions
+		}
+	case resource.Type == AdminResource.Type && resource.Name == AdminResource.Name:
+		if user != "" && p.isAdmin(user) {
+			return resource.Actions
+		}
+	}
+	return nil
+}
+
+// allowed returns the set of actions user holds on repository name.
+func (p *Policy) allowed(user, name string) map[string]bool {
+	allowed := make(map[string]bool)
+	if p.anonymousPull {
+		allowed[ActionPull] = true
+	}
+	if user == "" {
+		return allowed
+	}
+
+	p.mu.RLock()
+	defer p.mu.RUnlock()
+	for _, grant := range p.grants {
+		if !p.appliesTo(grant, user) || !grant.matches(name) {
+			continue
+		}
+		for _, action := range grant.actions {
+			allowed[action] = true
+		}
+	}
+	if allowed[ActionAdmin] {
+		allowed[ActionPull] = true
+		allowed[ActionPush] = true
+		allowed[ActionDelete] = true
+	}
+	return allowed
+}
+
+// isAdmin reports whether user holds admin on every repository.
+func (p *Policy) isAdmin(user string) bool {
+	p.mu.RLock()
+	defer p.mu.RUnlock()
+	for _, grant := range p.grants {
+		if len(grant.repositories) > 0 || !p.appliesTo(grant, user) {
+			continue
+		}
+		for _, action := range grant.actions {
+			if action == ActionAdmin {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// appliesTo reports whether grant names user directly or through a group.
+// p.mu must be held.
+func (p *Policy) appliesTo(grant compiledGrant, user string) bool {
+	if grant.allUsers || grant.users[user] {
+		return true
+	}
+	for _, group := range p.groupOf[user] {
+		if grant.groups[group] {
+			return true
+		}
+	}
+	return false
+}
+
+// matches reports whether the grant covers repository name.
+func (g compiledGrant) matches(name string) bool {
+	if len(g.repositories) == 0 {
+		return true
+	}
+	for _, pattern := range g.repositories {
+		if oci.MatchRepository(pattern, name) {
+			return true
+		}
+	}
+	return false
+}
+
+// ReadPolicy reads the policy document stored at PolicyPath. It returns
+// storage.ErrFileNotFound when none has been stored.
+func ReadPolicy(ctx context.Context, store storage.BlobStorage) (PolicyDocument, error) {
+	rc, err := store.Download(ctx, PolicyPath)
+	if err != nil {
+		if err == storage.ErrFileNotFound {
+			return PolicyDocument{}, err
+		}
+		return PolicyDocument{}, fmt.Errorf("failed to read policy: %w", err)
+	}
+	defer rc.Close()
+
+	data, err := io.ReadAll(rc)
+	if err != nil {
+		return PolicyDocument{}, fmt.Errorf("failed to read policy: %w", err)
+	}
+	var doc PolicyDocument
+	if err := json.Unmarshal(data, &doc); err != nil {
+		return PolicyDocument{}, fmt.Errorf("failed to decode policy: %w", err)
+	}
+	return doc, nil
+}
+
+// WritePolicy validates doc and stores it at PolicyPath.
+func WritePolicy(ctx context.Context, store storage.BlobStorage, doc PolicyDocument) error {
+	if _, err := NewPolicy(doc, false); err != nil {
+		return err
+	}
+	data, err := json.MarshalIndent(doc, "", "  ")
+	if err != nil {
+		return fmt.Errorf("failed to encode policy: %w", err)
+	}
+	if err := store.Upload(ctx, PolicyPath, bytes.NewReader(data)); err != nil {
+		return fmt.Errorf("failed to store policy: %w", err)
+	}
+	return nil
+}
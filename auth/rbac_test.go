@@ -0,0 +1,143 @@
+package auth
+
+import (
+	"context"
+	"reflect"
+	"testing"
+
+	"github.com/hairizuanbinnoorazman/package-universe/storage"
+)
+
+func testPolicyDocument() PolicyDocument {
+	return PolicyDocument{
+		Roles: []Role{{Name: "publisher", Actions: []string{ActionPush}}},
+		Groups: []Group{
+			{Name: "platform", Members: []string{"alice"}},
+			{Name: "ops", Members: []string{"root"}},
+		},
+		Grants: []Grant{
+			{Groups: []string{"platform"}, Role: "reader", Repositories: []string{"platform/**"}},
+			{Users: []string{"ci"}, Role: "writer", Repositories: []string{"apps/*"}},
+			{Users: []string{"ci"}, Role: "publisher", Repositories: []string{"releases/*"}},
+			{Users: []string{"*"}, Role: "reader", Repositories: []string{"public/*"}},
+			{Groups: []string{"ops"}, Role: "admin"},
+		},
+	}
+}
+
+func TestPolicyAuthorize(t *testing.T) {
+	policy, err := NewPolicy(testPolicyDocument(), false)
+	if err != nil {
+		t.Fatalf("NewPolicy failed: %v", err)
+	}
+	ctx := context.Background()
+	all := []string{ActionPull, ActionPush, ActionDelete}
+
+	tests := []struct {
+		user       string
+		repository string
+		want       []string
+	}{
+		{"alice", "platform/base", []string{ActionPull}},
+		{"alice", "platform/app/api", []string{ActionPull}},
+		{"alice", "platform", nil},
+		{"alice", "platform-tools/base", nil},
+		{"alice", "apps/web", nil},
+		{"alice", "public/tools", []string{ActionPull}},
+		{"ci", "apps/web", []string{ActionPull, ActionPush}},
+		{"ci", "apps/web/nested", nil},
+		{"ci", "releases/web", []string{ActionPush}},
+		{"root", "anything/at/all", all},
+		{"", "public/tools", nil},
+	}
+	for _, tt := range tests {
+		t.Run(tt.user+"@"+tt.repository, func(t *testing.T) {
+			got := policy.Authorize(ctx, tt.user, Resource{Type: TypeRepository, Name: tt.repository, Actions: all})
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("granted %v, want %v", got, tt.want)
+			}
+		})
+	}
+
+	if got := policy.Authorize(ctx, "alice", CatalogResource); len(got) == 0 {
+		t.Error("authenticated user should be able to list the catalog")
+	}
+	if got := policy.Authorize(ctx, "", CatalogResource); len(got) != 0 {
+		t.Error("anonymous user should not list the catalog")
+	}
+	if got := policy.Authorize(ctx, "root", AdminResource); len(got) == 0 {
+		t.Error("registry-wide admin should be granted the admin endpoints")
+	}
+	if got := policy.Authorize(ctx, "ci", AdminResource); len(got) != 0 {
+		t.Error("non-admin should not be granted the admin endpoints")
+	}
+
+	anonymous, _ := NewPolicy(testPolicyDocument(), true)
+	if got := anonymous.Authorize(ctx, "", Resource{Type: TypeRepository, Name: "apps/web", Actions: all}); !reflect.DeepEqual(got, []string{ActionPull}) {
+		t.Errorf("anonymous pull granted %v", got)
+	}
+}
+
+func TestPolicyLoad(t *testing.T) {
+	invalid := map[string]PolicyDocument{
+		"unknown role":     {Grants: []Grant{{Users: []string{"a"}, Role: "owner"}}},
+		"unknown group":    {Grants: []Grant{{Groups: []string{"x"}, Role: "reader"}}},
+		"no subjects":      {Grants: []Grant{{Role: "reader"}}},
+		"bad pattern":      {Grants: []Grant{{Users: []string{"a"}, Role: "reader", Repositories: []string{"["}}}},
+		"unknown action":   {Roles: []Role{{Name: "r", Actions: []string{"write"}}}},
+		"redefined role":   {Roles: []Role{{Name: "reader", Actions: []string{ActionPush}}}},
+		"duplicate groups": {Groups: []Group{{Name: "g"}, {Name: "g"}}},
+	}
+	for name, doc := range invalid {
+		t.Run(name, func(t *testing.T) {
+			if _, err := NewPolicy(doc, false); err == nil {
+				t.Error("expected policy to be rejected")
+			}
+		})
+	}
+
+	// A failed reload keeps the current rules
+	policy, _ := NewPolicy(testPolicyDocument(), false)
+	if err := policy.Load(invalid["unknown role"]); err == nil {
+		t.Fatal("expected Load to fail")
+	}
+	resource := Resource{Type: TypeRepository, Name: "apps/web", Actions: []string{ActionPush}}
+	if got := policy.Authorize(context.Background(), "ci", resource); len(got) != 1 {
+		t.Errorf("rules lost after failed reload: granted %v", got)
+	}
+
+	if err := policy.Load(PolicyDocument{}); err != nil {
+		t.Fatalf("Load failed: %v", err)
+	}
+	if got := policy.Authorize(context.Background(), "ci", resource); len(got) != 0 {
+		t.Errorf("rules kept after reload: granted %v", got)
+	}
+}
+
+func TestPolicyStorage(t *testing.T) {
+	store, err := storage.NewLocalStorage(t.TempDir())
+	if err != nil {
+		t.Fatalf("failed to create storage: %v", err)
+	}
+	ctx := context.Background()
+
+	if _, err := ReadPolicy(ctx, store); err != storage.ErrFileNotFound {
+		t.Errorf("ReadPolicy before write = %v, want ErrFileNotFound", err)
+	}
+
+	doc := testPolicyDocument()
+	if err := WritePolicy(ctx, store, doc); err != nil {
+		t.Fatalf("WritePolicy failed: %v", err)
+	}
+	got, err := ReadPolicy(ctx, store)
+	if err != nil {
+		t.Fatalf("ReadPolicy failed: %v", err)
+	}
+	if !reflect.DeepEqual(got, doc) {
+		t.Errorf("ReadPolicy = %+v, want %+v", got, doc)
+	}
+
+	if err := WritePolicy(ctx, store, PolicyDocument{Grants: []Grant{{Role: "reader"}}}); err == nil {
+		t.Error("expected invalid policy to be rejected")
+	}
+}
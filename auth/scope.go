@@ -0,0 +1,93 @@
+// Package auth implements the Docker registry token authentication flow:
+// parsing access scopes, checking client credentials, deciding which actions
+// a user is granted and issuing and verifying the signed tokens that carry them.
+package auth
+
+import (
+	"fmt"
+	"strings"
+)
+
+// Actions that can be granted on a repository.
+const (
+	ActionPull   = "pull"
+	ActionPush   = "push"
+	ActionDelete = "delete"
+	// ActionAdmin grants every repository action and, on every repository,
+	// the registry administration endpoints.
+	ActionAdmin = "admin"
+	// ActionAll grants every action, as used by the registry:catalog:* scope.
+	ActionAll = "*"
+)
+
+// Resource types used in scopes.
+const (
+	TypeRepository = "repository"
+	TypeRegistry   = "registry"
+)
+
+// CatalogResource is the scope required to list the repository catalog.
+var CatalogResource = Resource{Type: TypeRegistry, Name: "catalog", Actions: []string{ActionAll}}
+
+// AdminResource is the scope required by the registry administration endpoints.
+var AdminResource = Resource{Type: TypeRegistry, Name: "admin", Actions: []string{ActionAll}}
+
+// Resource is a set of actions on a named resource, written as a scope such
+// as "repository:team/app:pull,push".
+type Resource struct {
+	Type    string   `json:"type"`
+	Name    string   `json:"name"`
+	Actions []string `json:"actions"`
+}
+
+// ParseScope parses a single scope. The name may itself contain colons
+// (e.g. a registry host with a port), so the type is taken up to the first
+// colon and the actions after the last.
+func ParseScope(scope string) (Resource, error) {
+	typ, rest, ok := strings.Cut(scope, ":")
+	if !ok {
+		return Resource{}, fmt.Errorf("invalid scope %q", scope)
+	}
+	i := strings.LastIndex(rest, ":")
+	if i <= 0 || typ == "" {
+		return Resource{}, fmt.Errorf("invalid scope %q", scope)
+	}
+
+	var actions []string
+	for _, action := range strings.Split(rest[i+1:], ",") {
+		if action = strings.TrimSpace(action); action != "" {
+			actions = append(actions, action)
+		}
+	}
+	return Resource{Type: typ, Name: rest[:i], Actions: actions}, nil
+}
+
+// ParseScopes parses the space-separated scopes of one or more scope parameters.
+func ParseScopes(values []string) ([]Resource, error) {
+	var resources []Resource
+	for _, value := range values {
+		for _, scope := range strings.Fields(value) {
+			resource, err := ParseScope(scope)
+			if err != nil {
+				return nil, err
+			}
+			resources = append(resources, resource)
+		}
+	}
+	return resources, nil
+}
+
+// String formats the resource as a scope.
+func (r Resource) String() string {
+	return r.Type + ":" + r.Name + ":" + strings.Join(r.Actions, ",")
+}
+
+// Allows reports whether the resource grants action.
+func (r Resource) Allows(action string) bool {
+	for _, a := range r.Actions {
+		if a == action || a == ActionAll {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,120 @@
+package auth
+
+import (
+	"crypto/rand"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/golang-jwt/jwt/v5"
+)
+
+// ErrInvalidToken is returned when a token is malformed, badly signed, expired
+// or issued for another service.
+var ErrInvalidToken = errors.New("invalid token")
+
+// Claims are the JWT claims of a registry token. Access lists the resources
+// and actions the bearer was granted.
+type Claims struct {
+	jwt.RegisteredClaims
+	Access []Resource `json:"access"`
+}
+
+// Allows reports whether the claims grant action on the resource of type typ named name.
+func (c *Claims) Allows(typ, name, action string) bool {
+	for _, resource := range c.Access {
+		if resource.Type == typ && resource.Name == name && resource.Allows(action) {
+			return true
+		}
+	}
+	return false
+}
+
+// TokenService issues and verifies HMAC-SHA256 signed registry tokens.
+type TokenService struct {
+	secret  []byte
+	issuer  string
+	service string
+	expiry  time.Duration
+}
+
+// NewTokenService creates a token service. Tokens are signed with secret,
+// name issuer as their issuer and service as their audience, and expire after expiry.
+func NewTokenService(secret, issuer, service string, expiry time.Duration) (*TokenService, error) {
+	if len(secret) < 32 {
+		return nil, fmt.Errorf("token signing secret must be at least 32 bytes")
+	}
+	if expiry <= 0 {
+		return nil, fmt.Errorf("token expiry must be positive")
+	}
+	return &TokenService{
+		secret:  []byte(secret),
+		issuer:  issuer,
+		service: service,
+		expiry:  expiry,
+	}, nil
+}
+
+// Service returns the service name tokens are issued for.
+func (s *TokenService) Service() string {
+	return s.service
+}
+
+// Issue signs a token for subject granting access, and returns it with its expiry.
+func (s *TokenService) Issue(subject string, access []Resource) (string, time.Time, error) {
+	id, err := randomID()
+	if err != nil {
+		return "", time.Time{}, err
+	}
+
+	now := time.Now()
+	expires := now.Add(s.expiry)
+	if access == nil {
+		access = []Resource{}
+	}
+	claims := Claims{
+		RegisteredClaims: jwt.RegisteredClaims{
+			Issuer:    s.issuer,
+			Subject:   subject,
+			Audience:  jwt.ClaimStrings{s.service},
+			ExpiresAt: jwt.NewNumericDate(expires),
+			NotBefore: jwt.NewNumericDate(now),
+			IssuedAt:  jwt.NewNumericDate(now),
+			ID:        id,
+		},
+		Access: access,
+	}
+
+	token, err := jwt.NewWithClaims(jwt.SigningMethodHS256, claims).SignedString(s.secret)
+	if err != nil {
+		return "", time.Time{}, fmt.Errorf("failed to sign token: %w", err)
+	}
+	return token, expires, nil
+}
+
+// Verify checks a token's signature, issuer, audience and validity period and
+// returns its claims.
+func (s *TokenService) Verify(token string) (*Claims, error) {
+	var claims Claims
+	_, err := jwt.ParseWithClaims(token, &claims, func(*jwt.Token) (interface{}, error) {
+		return s.secret, nil
+	},
+		jwt.WithValidMethods([]string{jwt.SigningMethodHS256.Alg()}),
+		jwt.WithIssuer(s.issuer),
+		jwt.WithAudience(s.service),
+		jwt.WithExpirationRequired(),
+	)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrInvalidToken, err)
+	}
+	return &claims, nil
+}
+
+// randomID returns a random token identifier.
+func randomID() (string, error) {
+	var b [16]byte
+	if _, err := rand.Read(b[:]); err != nil {
+		return "", fmt.Errorf("failed to generate token ID: %w", err)
+	}
+	return fmt.Sprintf("%x", b), nil
+}
@@ -0,0 +1,85 @@
+package main
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/hairizuanbinnoorazman/package-universe/auth"
+	"github.com/hairizuanbinnoorazman/package-universe/cmd/server/handlers"
+	"github.com/hairizuanbinnoorazman/package-universe/logger"
+	"github.com/hairizuanbinnoorazman/package-universe/storage"
+)
+
+// newAuthHandlers creates the token endpoint and the registry middleware
+// described by the auth configuration. Tokens grant what authorizer allows.
+func newAuthHandlers(cfg *Config, authorizer auth.Authorizer, log logger.Logger) (*handlers.TokenHandler, *handlers.RegistryAuth, error) {
+	tokens, err := auth.NewTokenService(cfg.Auth.Secret, cfg.Auth.Issuer, cfg.Auth.Service, cfg.Auth.TokenExpiry)
+	if err != nil {
+		return nil, nil, fmt.Errorf("invalid auth configuration: %w", err)
+	}
+
+	users := make(map[string]string, len(cfg.Auth.Users))
+	for _, user := range cfg.Auth.Users {
+		if _, ok := users[user.Username]; ok {
+			return nil, nil, fmt.Errorf("duplicate auth user %q", user.Username)
+		}
+		users[user.Username] = user.PasswordHash
+	}
+	credentials, err := auth.NewCredentials(users)
+	if err != nil {
+		return nil, nil, fmt.Errorf("invalid auth.users: %w", err)
+	}
+
+	tokenHandler := &handlers.TokenHandler{
+		Tokens:      tokens,
+		Credentials: credentials,
+		Authorizer:  authorizer,
+		Logger:      log,
+	}
+	registryAuth := &handlers.RegistryAuth{
+		Tokens: tokens,
+		Realm:  cfg.Auth.Realm,
+		Logger: log,
+	}
+	return tokenHandler, registryAuth, nil
+}
+
+// newPolicy creates the RBAC policy described by the auth configuration,
+// loading it from storage when auth.rbac.source is "storage".
+func newPolicy(ctx context.Context, cfg *Config, blobStorage storage.BlobStorage, log logger.Logger) (*auth.Policy, error) {
+	switch cfg.Auth.RBAC.Source {
+	case "config":
+		policy, err := auth.NewPolicy(cfg.Auth.RBAC.Policy, cfg.Auth.AnonymousPull)
+		if err != nil {
+			return nil, fmt.Errorf("invalid auth.rbac policy: %w", err)
+		}
+		return policy, nil
+	case "storage":
+		policy, err := auth.NewPolicy(auth.PolicyDocument{}, cfg.Auth.AnonymousPull)
+		if err != nil {
+			return nil, err
+		}
+		reloadPolicy(ctx, log, policy, blobStorage)
+		return policy, nil
+	default:
+		return nil, fmt.Errorf("unknown auth.rbac.source %q", cfg.Auth.RBAC.Source)
+	}
+}
+
+// reloadPolicy replaces the policy's rules with the document in storage. The
+// current rules are kept when the document is missing or invalid.
+func reloadPolicy(ctx context.Context, log logger.Logger, policy *auth.Policy, blobStorage storage.BlobStorage) {
+	doc, err := auth.ReadPolicy(ctx, blobStorage)
+	if err == storage.ErrFileNotFound {
+		log.Warn(ctx, "no RBAC policy stored", map[string]interface{}{"path": auth.PolicyPath})
+		return
+	}
+	if err == nil {
+		err = policy.Load(doc)
+	}
+	if err != nil {
+		log.Error(ctx, "failed to load RBAC policy", map[string]interface{}{"error": err.Error()})
+		return
+	}
+	log.Debug(ctx, "RBAC policy loaded", map[string]interface{}{"grants": len(doc.Grants)})
+}
@@ -0,0 +1,41 @@
+package main
+
+import (
+	"context"
+	"time"
+)
+
+// startBackground calls fn every interval in its own goroutine and returns a
+// function that stops the loop and waits for a run in progress to finish.
+func startBackground(ctx context.Context, interval time.Duration, fn func(context.Context)) func() {
+	return startWorker(ctx, func(ctx context.Context) {
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case <-ticker.C:
+				fn(ctx)
+			}
+		}
+	})
+}
+
+// startWorker runs fn in its own goroutine until its context is cancelled and
+// returns a function that cancels it and waits for fn to return.
+func startWorker(ctx context.Context, fn func(context.Context)) func() {
+	ctx, cancel := context.WithCancel(ctx)
+	done := make(chan struct{})
+
+	go func() {
+		defer close(done)
+		fn(ctx)
+	}()
+
+	return func() {
+		cancel()
+		<-done
+	}
+}
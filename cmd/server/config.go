@@ -5,6 +5,7 @@ import (
 	"strings"
 	"time"
 
+	"github.com/hairizuanbinnoorazman/package-universe/auth"
 	"github.com/spf13/viper"
 )
 
@@ -14,14 +15,132 @@ type Config struct {
 	Storage  StorageConfig
 	Log      LogConfig
 	Registry RegistryConfig
+	Auth     AuthConfig
+	Metrics  MetricsConfig
+	Tracing  TracingConfig
+}
+
+// TracingConfig exports OpenTelemetry traces of requests and storage calls.
+type TracingConfig struct {
+	Exporter    string  // "none" or "otlp"
+	Endpoint    string  // OTLP/HTTP collector host:port, e.g. "localhost:4318"
+	Insecure    bool    // Use plain HTTP to the collector
+	ServiceName string  // Reported service.name
+	SampleRatio float64 // Fraction of new traces recorded, 0 to 1
+}
+
+// MetricsConfig exposes Prometheus metrics over HTTP. With token
+// authentication enabled, reading them needs the same access as /admin.
+type MetricsConfig struct {
+	Enabled bool
+	Path    string // Route the metrics are served on
+}
+
+// AuthConfig enables Docker token authentication on the registry API.
+type AuthConfig struct {
+	Enabled       bool
+	Realm         string        // Token endpoint URL advertised to clients; derived from the request when empty
+	Service       string        // Service name tokens are issued for
+	Issuer        string        // Issuer name written into tokens
+	Secret        string        // HMAC signing secret, at least 32 bytes
+	TokenExpiry   time.Duration // How long issued tokens are valid
+	AnonymousPull bool          // Allow pulls without credentials
+	Users         []AuthUserConfig
+	RBAC          RBACConfig
+}
+
+// RBACConfig restricts users to the repository actions granted to them by a
+// role-based policy. Without it every authenticated user has full access.
+type RBACConfig struct {
+	Enabled        bool
+	Source         string              // "config" for Policy below, or "storage" for the document at auth.PolicyPath
+	ReloadInterval time.Duration       // How often a stored policy is reloaded
+	Policy         auth.PolicyDocument // Roles, groups and grants when Source is "config"
+}
+
+// AuthUserConfig is a user allowed to log in, with a bcrypt password hash.
+type AuthUserConfig struct {
+	Username     string `mapstructure:"username"`
+	PasswordHash string `mapstructure:"password_hash"`
 }
 
 // RegistryConfig holds OCI container registry configuration.
 type RegistryConfig struct {
-	Enabled               bool
-	UploadSessionTimeout  time.Duration
-	MaxManifestSize       int64
-	MaxChunkSize          int64
+	Enabled              bool
+	UploadSessionTimeout time.Duration
+	UploadReapInterval   time.Duration
+	MaxManifestSize      int64
+	MaxChunkSize         int64
+	DeleteEnabled        bool
+	ImmutableTags        []ImmutableTagConfig
+	Retention            RetentionConfig
+	Proxy                ProxyConfig
+	Replication          ReplicationConfig
+	Quotas               []QuotaConfig
+	Notifications        NotificationsConfig
+}
+
+// NotificationsConfig lists HTTP endpoints that receive registry events.
+type NotificationsConfig struct {
+	Endpoints []NotificationEndpointConfig `mapstructure:"endpoints"`
+}
+
+// NotificationEndpointConfig describes one event receiver. Only events whose
+// action is listed in Actions and whose repository matches one of the
+// Repositories globs are delivered; empty lists match everything.
+type NotificationEndpointConfig struct {
+	Name         string        `mapstructure:"name"`
+	URL          string        `mapstructure:"url"`
+	Secret       string        `mapstructure:"secret"` // HMAC-SHA256 signing secret
+	Actions      []string      `mapstructure:"actions"`
+	Repositories []string      `mapstructure:"repositories"`
+	Timeout      time.Duration `mapstructure:"timeout"`      // Per-request timeout (default 5s)
+	QueueSize    int           `mapstructure:"queue_size"`   // Events buffered before new ones are dropped (default 1000)
+	MaxAttempts  int           `mapstructure:"max_attempts"` // Delivery attempts before events are dropped (default 5)
+	Backoff      time.Duration `mapstructure:"backoff"`      // First retry delay, doubled per attempt (default 1s)
+}
+
+// QuotaConfig limits the bytes stored in one repository or, with Namespace,
+// in all repositories under a prefix. Exactly one of the two must be set.
+type QuotaConfig struct {
+	Repository string `mapstructure:"repository"`
+	Namespace  string `mapstructure:"namespace"`
+	Limit      int64  `mapstructure:"limit"` // Bytes
+}
+
+// ProxyConfig turns the registry into a pull-through cache of a remote registry.
+type ProxyConfig struct {
+	RemoteURL string // e.g. "https://registry-1.docker.io"; empty disables proxy mode
+	Username  string // Optional credentials for the remote registry
+	Password  string
+	TagTTL    time.Duration // How long a cached tag is served before it is revalidated
+	Timeout   time.Duration // Per-request timeout for the remote, including blob transfers; 0 disables it
+}
+
+// ImmutableTagConfig makes tags matching the Tag regular expression immutable in
+// repositories matching the Repository glob (empty matches every repository;
+// a trailing "/**" covers nested names).
+type ImmutableTagConfig struct {
+	Repository string `mapstructure:"repository"`
+	Tag        string `mapstructure:"tag"`
+}
+
+// ReplicationConfig lists downstream registries that receive copies of pushed images.
+type ReplicationConfig struct {
+	RetryInterval time.Duration             `mapstructure:"retry_interval"` // First retry delay, doubled per attempt
+	MaxAttempts   int                       `mapstructure:"max_attempts"`   // Attempts before a replication is dropped
+	Timeout       time.Duration             `mapstructure:"timeout"`        // Per-request timeout for targets, including blob transfers; 0 disables it
+	Targets       []ReplicationTargetConfig `mapstructure:"targets"`
+}
+
+// ReplicationTargetConfig describes one downstream registry. Only repositories
+// matching one of the Repositories globs are replicated; empty matches all.
+type ReplicationTargetConfig struct {
+	Name         string   `mapstructure:"name"`
+	URL          string   `mapstructure:"url"`
+	Username     string   `mapstructure:"username"`
+	Password     string   `mapstructure:"password"`
+	Repositories []string `mapstructure:"repositories"`
 }
 
 // ServerConfig holds HTTP server configuration.
@@ -41,6 +160,23 @@ type StorageConfig struct {
 	S3PresignExpiry time.Duration // Presigned URL expiration
 }
 
+// RetentionConfig holds tag retention rules and how often the server enforces them.
+type RetentionConfig struct {
+	Interval time.Duration         `mapstructure:"interval"` // 0 disables scheduled enforcement
+	Protect  []string              `mapstructure:"protect"`  // Tag patterns that are never deleted
+	Rules    []RetentionRuleConfig `mapstructure:"rules"`
+}
+
+// RetentionRuleConfig deletes tags matching Tag in repositories matching the
+// Repository glob (a trailing "/**" covers nested names) beyond the KeepLast
+// most recent or older than MaxAge.
+type RetentionRuleConfig struct {
+	Repository string        `mapstructure:"repository"`
+	Tag        string        `mapstructure:"tag"`
+	KeepLast   int           `mapstructure:"keep_last"`
+	MaxAge     time.Duration `mapstructure:"max_age"`
+}
+
 // LogConfig holds logging configuration.
 type LogConfig struct {
 	Level string
@@ -80,8 +216,39 @@ func LoadConfig(configPath string) (*Config, error) {
 
 	v.SetDefault("registry.enabled", true)
 	v.SetDefault("registry.upload_session_timeout", "30m")
-	v.SetDefault("registry.max_manifest_size", 10*1024*1024)  // 10MB
-	v.SetDefault("registry.max_chunk_size", 100*1024*1024)    // 100MB
+	v.SetDefault("registry.upload_reap_interval", "5m")
+	v.SetDefault("registry.max_manifest_size", 10*1024*1024) // 10MB
+	v.SetDefault("registry.max_chunk_size", 100*1024*1024)   // 100MB
+	v.SetDefault("registry.delete_enabled", false)
+	v.SetDefault("registry.retention.interval", "1h")
+	v.SetDefault("registry.proxy.remote_url", "")
+	v.SetDefault("registry.proxy.username", "")
+	v.SetDefault("registry.proxy.password", "")
+	v.SetDefault("registry.proxy.tag_ttl", "10m")
+	v.SetDefault("registry.proxy.timeout", "10m")
+	v.SetDefault("registry.replication.retry_interval", "30s")
+	v.SetDefault("registry.replication.max_attempts", 10)
+	v.SetDefault("registry.replication.timeout", "10m")
+
+	v.SetDefault("metrics.enabled", false)
+	v.SetDefault("metrics.path", "/metrics")
+
+	v.SetDefault("tracing.exporter", "none")
+	v.SetDefault("tracing.endpoint", "")
+	v.SetDefault("tracing.insecure", false)
+	v.SetDefault("tracing.service_name", "package-universe")
+	v.SetDefault("tracing.sample_ratio", 1.0)
+
+	v.SetDefault("auth.enabled", false)
+	v.SetDefault("auth.realm", "")
+	v.SetDefault("auth.service", "package-universe")
+	v.SetDefault("auth.issuer", "package-universe")
+	v.SetDefault("auth.secret", "")
+	v.SetDefault("auth.token_expiry", "5m")
+	v.SetDefault("auth.anonymous_pull", false)
+	v.SetDefault("auth.rbac.enabled", false)
+	v.SetDefault("auth.rbac.source", "config")
+	v.SetDefault("auth.rbac.reload_interval", "1m")
 
 	// Read config file
 	if err := v.ReadInConfig(); err != nil {
@@ -109,8 +276,59 @@ func LoadConfig(configPath string) (*Config, error) {
 
 	config.Registry.Enabled = v.GetBool("registry.enabled")
 	config.Registry.UploadSessionTimeout = v.GetDuration("registry.upload_session_timeout")
+	config.Registry.UploadReapInterval = v.GetDuration("registry.upload_reap_interval")
 	config.Registry.MaxManifestSize = v.GetInt64("registry.max_manifest_size")
 	config.Registry.MaxChunkSize = v.GetInt64("registry.max_chunk_size")
+	config.Registry.DeleteEnabled = v.GetBool("registry.delete_enabled")
+	if err := v.UnmarshalKey("registry.immutable_tags", &config.Registry.ImmutableTags); err != nil {
+		return nil, fmt.Errorf("failed to parse registry.immutable_tags: %w", err)
+	}
+	if err := v.UnmarshalKey("registry.retention", &config.Registry.Retention); err != nil {
+		return nil, fmt.Errorf("failed to parse registry.retention: %w", err)
+	}
+	config.Registry.Retention.Interval = v.GetDuration("registry.retention.interval")
+	config.Registry.Proxy.RemoteURL = v.GetString("registry.proxy.remote_url")
+	config.Registry.Proxy.Username = v.GetString("registry.proxy.username")
+	config.Registry.Proxy.Password = v.GetString("registry.proxy.password")
+	config.Registry.Proxy.TagTTL = v.GetDuration("registry.proxy.tag_ttl")
+	config.Registry.Proxy.Timeout = v.GetDuration("registry.proxy.timeout")
+	if err := v.UnmarshalKey("registry.replication", &config.Registry.Replication); err != nil {
+		return nil, fmt.Errorf("failed to parse registry.replication: %w", err)
+	}
+	config.Registry.Replication.RetryInterval = v.GetDuration("registry.replication.retry_interval")
+	config.Registry.Replication.Timeout = v.GetDuration("registry.replication.timeout")
+	if err := v.UnmarshalKey("registry.quotas", &config.Registry.Quotas); err != nil {
+		return nil, fmt.Errorf("failed to parse registry.quotas: %w", err)
+	}
+	if err := v.UnmarshalKey("registry.notifications", &config.Registry.Notifications); err != nil {
+		return nil, fmt.Errorf("failed to parse registry.notifications: %w", err)
+	}
+
+	config.Metrics.Enabled = v.GetBool("metrics.enabled")
+	config.Metrics.Path = v.GetString("metrics.path")
+
+	config.Tracing.Exporter = v.GetString("tracing.exporter")
+	config.Tracing.Endpoint = v.GetString("tracing.endpoint")
+	config.Tracing.Insecure = v.GetBool("tracing.insecure")
+	config.Tracing.ServiceName = v.GetString("tracing.service_name")
+	config.Tracing.SampleRatio = v.GetFloat64("tracing.sample_ratio")
+
+	config.Auth.Enabled = v.GetBool("auth.enabled")
+	config.Auth.Realm = v.GetString("auth.realm")
+	config.Auth.Service = v.GetString("auth.service")
+	config.Auth.Issuer = v.GetString("auth.issuer")
+	config.Auth.Secret = v.GetString("auth.secret")
+	config.Auth.TokenExpiry = v.GetDuration("auth.token_expiry")
+	config.Auth.AnonymousPull = v.GetBool("auth.anonymous_pull")
+	if err := v.UnmarshalKey("auth.users", &config.Auth.Users); err != nil {
+		return nil, fmt.Errorf("failed to parse auth.users: %w", err)
+	}
+	config.Auth.RBAC.Enabled = v.GetBool("auth.rbac.enabled")
+	config.Auth.RBAC.Source = v.GetString("auth.rbac.source")
+	config.Auth.RBAC.ReloadInterval = v.GetDuration("auth.rbac.reload_interval")
+	if err := v.UnmarshalKey("auth.rbac", &config.Auth.RBAC.Policy); err != nil {
+		return nil, fmt.Errorf("failed to parse auth.rbac: %w", err)
+	}
 
 	return &config, nil
 }
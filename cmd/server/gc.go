@@ -0,0 +1,88 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/hairizuanbinnoorazman/package-universe/logger"
+	"github.com/hairizuanbinnoorazman/package-universe/oci"
+	"github.com/spf13/cobra"
+)
+
+var (
+	gcDryRun         bool
+	gcDeleteUntagged bool
+	gcGracePeriod    time.Duration
+)
+
+var gcCmd = &cobra.Command{
+	Use:   "gc",
+	Short: "Remove unreferenced blobs and abandoned uploads from the registry",
+	Long: `Walks every repository's tags and manifest revisions, marks the blobs they
+reference, and deletes the rest along with upload data that is no longer being
+written. Stop the registry, or otherwise make sure nothing is pushed, while it
+runs: a manifest pushed after the mark phase can reference a blob that is then
+swept. Uploads newer than the grace period are left alone so they can still be
+resumed once the registry is back.`,
+	RunE: runGC,
+}
+
+func init() {
+	gcCmd.Flags().StringVarP(&configFile, "config", "c", "", "config file path")
+	gcCmd.Flags().BoolVar(&gcDryRun, "dry-run", false, "report what would be removed without deleting anything")
+	gcCmd.Flags().BoolVar(&gcDeleteUntagged, "delete-untagged", false, "also delete manifests that are not reachable from a tag")
+	gcCmd.Flags().DurationVar(&gcGracePeriod, "grace-period", 0, "skip content modified within this period; 0 disables it (default: registry.upload_session_timeout)")
+	rootCmd.AddCommand(gcCmd)
+}
+
+func runGC(cmd *cobra.Command, args []string) error {
+	ctx := context.Background()
+
+	cfg, err := LoadConfig(configFile)
+	if err != nil {
+		return fmt.Errorf("failed to load config: %w", err)
+	}
+
+	log := logger.NewLogrusLogger(cfg.Log.Level)
+
+	blobStorage, err := newBlobStorage(cfg)
+	if err != nil {
+		return err
+	}
+
+	gracePeriod := cfg.Registry.UploadSessionTimeout
+	if cmd.Flags().Changed("grace-period") {
+		gracePeriod = gcGracePeriod
+	}
+
+	ociStorage := oci.NewOCIStorage(blobStorage, oci.NewSessionManager(blobStorage, cfg.Registry.UploadSessionTimeout))
+	result, err := ociStorage.GarbageCollect(ctx, oci.GCOptions{
+		DryRun:         gcDryRun,
+		DeleteUntagged: gcDeleteUntagged,
+		GracePeriod:    gracePeriod,
+	})
+	if err != nil {
+		return fmt.Errorf("garbage collection failed: %w", err)
+	}
+
+	for _, manifest := range result.UnreadableManifests {
+		log.Warn(ctx, "kept every blob of a repository with an unreadable manifest", map[string]interface{}{"manifest": manifest})
+	}
+
+	log.Info(ctx, "garbage collection complete", map[string]interface{}{
+		"dry_run":           gcDryRun,
+		"manifests_deleted": result.ManifestsDeleted,
+		"blobs_deleted":     result.BlobsDeleted,
+		"uploads_deleted":   result.UploadsDeleted,
+		"bytes_reclaimed":   result.BytesReclaimed,
+	})
+
+	verb := "Deleted"
+	if gcDryRun {
+		verb = "Would delete"
+	}
+	fmt.Printf("%s %d manifests, %d blobs and %d uploads, reclaiming %d bytes\n",
+		verb, result.ManifestsDeleted, result.BlobsDeleted, result.UploadsDeleted, result.BytesReclaimed)
+	return nil
+}
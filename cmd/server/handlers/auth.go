@@ -0,0 +1,271 @@
+package handlers
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/gorilla/mux"
+	"github.com/hairizuanbinnoorazman/package-universe/auth"
+	"github.com/hairizuanbinnoorazman/package-universe/logger"
+)
+
+// TokenHandler implements the token endpoint of the Docker registry token
+// flow. Clients present Basic credentials (or none, for anonymous access) and
+// the scopes they want, and receive a signed token for the actions the
+// Authorizer grants.
+type TokenHandler struct {
+	Tokens      *auth.TokenService
+	Credentials *auth.Credentials
+	Authorizer  auth.Authorizer
+	Logger      logger.Logger
+}
+
+// tokenResponse is the token endpoint response. Both token and access_token
+// are set for compatibility with Docker and OAuth2 clients.
+type tokenResponse struct {
+	Token       string `json:"token"`
+	AccessToken string `json:"access_token"`
+	ExpiresIn   int    `json:"expires_in"`
+	IssuedAt    string `json:"issued_at"`
+}
+
+// Token handles GET /token?service=&scope= — issue a registry token.
+func (h *TokenHandler) Token(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+	query := r.URL.Query()
+
+	if service := query.Get("service"); service != "" && service != h.Tokens.Service() {
+		respondError(w, http.StatusBadRequest, fmt.Sprintf("unknown service %q", service))
+		return
+	}
+
+	requested, err := auth.ParseScopes(query["scope"])
+	if err != nil {
+		respondError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	user := ""
+	if username, password, ok := r.BasicAuth(); ok {
+		if !h.Credentials.Authenticate(username, password) {
+			h.Logger.Warn(ctx, "token request with invalid credentials", map[string]interface{}{"username": username})
+			w.Header().Set("WWW-Authenticate", fmt.Sprintf("Basic realm=%q", h.Tokens.Service()))
+			respondError(w, http.StatusUnauthorized, "invalid username or password")
+			return
+		}
+		user = username
+	}
+
+	granted := []auth.Resource{}
+	for _, resource := range requested {
+		actions := h.Authorizer.Authorize(ctx, user, resource)
+		if len(actions) > 0 {
+			granted = append(granted, auth.Resource{Type: resource.Type, Name: resource.Name, Actions: actions})
+		}
+	}
+
+	token, expires, err := h.Tokens.Issue(user, granted)
+	if err != nil {
+		h.Logger.Error(ctx, "failed to issue token", map[string]interface{}{"error": err.Error()})
+		respondError(w, http.StatusInternalServerError, "failed to issue token")
+		return
+	}
+
+	now := time.Now()
+	respondJSON(w, http.StatusOK, tokenResponse{
+		Token:       token,
+		AccessToken: token,
+		ExpiresIn:   int(expires.Sub(now).Seconds()),
+		IssuedAt:    now.UTC().Format(time.RFC3339),
+	})
+}
+
+// RegistryAuth requires a bearer token issued by TokenHandler on the registry
+// API. Each request must carry a token granting the actions its route needs
+// on the repository in the {name} route variable; otherwise it is answered
+// with a 401 challenge naming the scope to request.
+type RegistryAuth struct {
+	Tokens *auth.TokenService
+	// Realm is the URL of the token endpoint. When empty it is derived from
+	// the request as <scheme>://<host>/token.
+	Realm  string
+	Logger logger.Logger
+}
+
+// Middleware wraps the registry routes. It must be installed with the
+// router's Use so that route variables are available.
+func (a *RegistryAuth) Middleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		required := requiredAccess(r)
+
+		var claims *auth.Claims
+		if token, ok := strings.CutPrefix(r.Header.Get("Authorization"), "Bearer "); ok {
+			verified, err := a.Tokens.Verify(token)
+			if err != nil {
+				a.Logger.Debug(r.Context(), "rejected registry token", map[string]interface{}{"error": err.Error()})
+			}
+			claims = verified
+		}
+
+		if claims == nil {
+			a.challenge(w, r, required, "")
+			return
+		}
+		for _, resource := range required {
+			for _, action := range resource.Actions {
+				if !claims.Allows(resource.Type, resource.Name, action) {
+					a.challenge(w, r, required, "insufficient_scope")
+					return
+				}
+			}
+		}
+
+		next.ServeHTTP(w, r.WithContext(withClaims(r.Context(), claims)))
+	})
+}
+
+// challenge answers 401 with a Bearer challenge for the required scopes.
+func (a *RegistryAuth) challenge(w http.ResponseWriter, r *http.Request, required []auth.Resource, errorCode string) {
+	realm := a.Realm
+	if realm == "" {
+		realm = requestScheme(r) + "://" + r.Host + "/token"
+	}
+
+	challenge := fmt.Sprintf("Bearer realm=%q,service=%q", realm, a.Tokens.Service())
+	if len(required) > 0 {
+		scopes := make([]string, len(required))
+		for i, resource := range required {
+			scopes[i] = resource.String()
+		}
+		challenge += fmt.Sprintf(",scope=%q", strings.Join(scopes, " "))
+	}
+	if errorCode != "" {
+		challenge += fmt.Sprintf(",error=%q", errorCode)
+	}
+
+	w.Header().Set("WWW-Authenticate", challenge)
+	respondOCIError(w, http.StatusUnauthorized, OCIErrorUnauthorized, "authentication required")
+}
+
+// requiredAccess returns the resources and actions a registry request needs.
+// Reads need pull, uploads and manifest pushes need pull and push, deletes
+// need delete, and mounting from another repository needs pull on the source.
+// The /admin endpoints and routes wrapped with AdminRoute need registry
+// admin; the /v2/ base check only needs a valid token.
+func requiredAccess(r *http.Request) []auth.Resource {
+	name := mux.Vars(r)["name"]
+	if name == "" {
+		switch {
+		case strings.HasSuffix(r.URL.Path, "/_catalog"):
+			return []auth.Resource{auth.CatalogResource}
+		case strings.HasPrefix(r.URL.Path, "/admin/"), isAdminRoute(r.Context()):
+			return []auth.Resource{auth.AdminResource}
+		}
+		return nil
+	}
+
+	var actions []string
+	switch r.Method {
+	case http.MethodGet, http.MethodHead:
+		actions = []string{auth.ActionPull}
+	case http.MethodDelete:
+		actions = []string{auth.ActionDelete}
+		// Cancelling an upload is part of pushing
+		if strings.Contains(r.URL.Path, "/blobs/uploads/") {
+			actions = []string{auth.ActionPull, auth.ActionPush}
+		}
+	default:
+		actions = []string{auth.ActionPull, auth.ActionPush}
+	}
+	required := []auth.Resource{{Type: auth.TypeRepository, Name: name, Actions: actions}}
+
+	if from := r.URL.Query().Get("from"); from != "" && r.URL.Query().Get("mount") != "" && from != name {
+		required = append(required, auth.Resource{Type: auth.TypeRepository, Name: from, Actions: []string{auth.ActionPull}})
+	}
+	return required
+}
+
+// EnforceAccess checks every request against the current Authorizer policy
+// for the token's subject, so access revoked after a token was issued is
+// denied immediately. It must be installed after RegistryAuth.Middleware.
+func (h *OCIHandler) EnforceAccess(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if h.Authorizer == nil {
+			next.ServeHTTP(w, r)
+			return
+		}
+
+		ctx := r.Context()
+		user := requestUser(ctx)
+		for _, resource := range requiredAccess(r) {
+			granted := auth.Resource{Actions: h.Authorizer.Authorize(ctx, user, resource)}
+			for _, action := range resource.Actions {
+				if !granted.Allows(action) {
+					h.Logger.Warn(ctx, "access denied", map[string]interface{}{
+						"user":     user,
+						"resource": resource.String(),
+					})
+					respondOCIError(w, http.StatusForbidden, OCIErrorDenied, "requested access to the resource is denied")
+					return
+				}
+			}
+		}
+		next.ServeHTTP(w, r)
+	})
+}
+
+// canPull reports whether the requesting user may pull repository name.
+func (h *OCIHandler) canPull(ctx context.Context, name string) bool {
+	if h.Authorizer == nil {
+		return true
+	}
+	granted := auth.Resource{Actions: h.Authorizer.Authorize(ctx, requestUser(ctx), auth.Resource{
+		Type:    auth.TypeRepository,
+		Name:    name,
+		Actions: []string{auth.ActionPull},
+	})}
+	return granted.Allows(auth.ActionPull)
+}
+
+// requestUser returns the subject of the request's token; empty when anonymous.
+func requestUser(ctx context.Context) string {
+	if claims, ok := ClaimsFromContext(ctx); ok {
+		return claims.Subject
+	}
+	return ""
+}
+
+// adminRouteKey is the context key marking a request to an AdminRoute.
+type adminRouteKey struct{}
+
+// AdminRoute marks the requests it wraps as administration requests, so
+// RegistryAuth.Middleware and EnforceAccess require registry admin for them
+// whatever their path. It must wrap both.
+func AdminRoute(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		next.ServeHTTP(w, r.WithContext(context.WithValue(r.Context(), adminRouteKey{}, true)))
+	})
+}
+
+// isAdminRoute reports whether ctx belongs to a request wrapped with AdminRoute.
+func isAdminRoute(ctx context.Context) bool {
+	admin, _ := ctx.Value(adminRouteKey{}).(bool)
+	return admin
+}
+
+// claimsKey is the context key for the verified token claims of a request.
+type claimsKey struct{}
+
+// withClaims returns a copy of ctx carrying the request's token claims.
+func withClaims(ctx context.Context, claims *auth.Claims) context.Context {
+	return context.WithValue(ctx, claimsKey{}, claims)
+}
+
+// ClaimsFromContext returns the token claims of an authenticated request, if any.
+func ClaimsFromContext(ctx context.Context) (*auth.Claims, bool) {
+	claims, ok := ctx.Value(claimsKey{}).(*auth.Claims)
+	return claims, ok
+}
@@ -0,0 +1,303 @@
+package handlers
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gorilla/mux"
+	"github.com/hairizuanbinnoorazman/package-universe/auth"
+	"github.com/hairizuanbinnoorazman/package-universe/logger"
+	"golang.org/x/crypto/bcrypt"
+)
+
+// setupTestAuth returns a token handler with user alice (password "secret")
+// and a router serving it at /token and a few registry routes behind RegistryAuth.
+func setupTestAuth(t *testing.T) (*TokenHandler, *mux.Router) {
+	t.Helper()
+	tokens, err := auth.NewTokenService("0123456789abcdef0123456789abcdef", "test", "registry", time.Minute)
+	if err != nil {
+		t.Fatalf("failed to create token service: %v", err)
+	}
+	hash, _ := bcrypt.GenerateFromPassword([]byte("secret"), bcrypt.MinCost)
+	credentials, err := auth.NewCredentials(map[string]string{"alice": string(hash)})
+	if err != nil {
+		t.Fatalf("failed to create credentials: %v", err)
+	}
+
+	tokenHandler := &TokenHandler{
+		Tokens:      tokens,
+		Credentials: credentials,
+		Authorizer:  auth.DefaultAuthorizer{AnonymousPull: true},
+		Logger:      logger.NewTestLogger(),
+	}
+	registryAuth := &RegistryAuth{Tokens: tokens, Realm: "https://registry.test/token", Logger: logger.NewTestLogger()}
+
+	ok := func(w http.ResponseWriter, r *http.Request) { w.WriteHeader(http.StatusOK) }
+	router := mux.NewRouter()
+	router.HandleFunc("/token", tokenHandler.Token).Methods("GET")
+	v2 := router.PathPrefix("/v2").Subrouter()
+	v2.Use(registryAuth.Middleware)
+	v2.HandleFunc("/", ok).Methods("GET")
+	v2.HandleFunc("/_catalog", ok).Methods("GET")
+	v2.HandleFunc("/{name:.+}/blobs/uploads/", ok).Methods("POST")
+	v2.HandleFunc("/{name:.+}/manifests/{reference}", ok).Methods("GET", "PUT", "DELETE")
+	router.Handle("/metrics", AdminRoute(registryAuth.Middleware(http.HandlerFunc(ok)))).Methods("GET")
+
+	return tokenHandler, router
+}
+
+// requestToken fetches a token for scope, with Basic credentials unless username is empty.
+func requestToken(t *testing.T, router *mux.Router, username, password, scope string) (string, *httptest.ResponseRecorder) {
+	t.Helper()
+	req := httptest.NewRequest("GET", "/token?service=registry&scope="+scope, nil)
+	if username != "" {
+		req.SetBasicAuth(username, password)
+	}
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+
+	var resp tokenResponse
+	json.NewDecoder(w.Body).Decode(&resp)
+	return resp.Token, w
+}
+
+func TestTokenEndpoint(t *testing.T) {
+	handler, router := setupTestAuth(t)
+
+	t.Run("authenticated user", func(t *testing.T) {
+		token, w := requestToken(t, router, "alice", "secret", "repository:team/app:pull,push")
+		if w.Code != http.StatusOK {
+			t.Fatalf("status = %d, want %d", w.Code, http.StatusOK)
+		}
+		claims, err := handler.Tokens.Verify(token)
+		if err != nil {
+			t.Fatalf("issued token does not verify: %v", err)
+		}
+		if claims.Subject != "alice" || !claims.Allows(auth.TypeRepository, "team/app", auth.ActionPush) {
+			t.Errorf("claims = %+v", claims)
+		}
+	})
+
+	t.Run("anonymous pull only", func(t *testing.T) {
+		token, w := requestToken(t, router, "", "", "repository:team/app:pull,push")
+		if w.Code != http.StatusOK {
+			t.Fatalf("status = %d, want %d", w.Code, http.StatusOK)
+		}
+		claims, _ := handler.Tokens.Verify(token)
+		if claims.Allows(auth.TypeRepository, "team/app", auth.ActionPush) {
+			t.Error("anonymous token should not allow push")
+		}
+		if !claims.Allows(auth.TypeRepository, "team/app", auth.ActionPull) {
+			t.Error("anonymous token should allow pull")
+		}
+	})
+
+	t.Run("bad password", func(t *testing.T) {
+		_, w := requestToken(t, router, "alice", "wrong", "repository:team/app:pull")
+		if w.Code != http.StatusUnauthorized {
+			t.Errorf("status = %d, want %d", w.Code, http.StatusUnauthorized)
+		}
+	})
+
+	t.Run("unknown service", func(t *testing.T) {
+		req := httptest.NewRequest("GET", "/token?service=elsewhere", nil)
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, req)
+		if w.Code != http.StatusBadRequest {
+			t.Errorf("status = %d, want %d", w.Code, http.StatusBadRequest)
+		}
+	})
+}
+
+func TestRegistryAuthMiddleware(t *testing.T) {
+	_, router := setupTestAuth(t)
+
+	do := func(method, path, token string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(method, path, nil)
+		if token != "" {
+			req.Header.Set("Authorization", "Bearer "+token)
+		}
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, req)
+		return w
+	}
+
+	t.Run("no token", func(t *testing.T) {
+		w := do("GET", "/v2/team/app/manifests/latest", "")
+		if w.Code != http.StatusUnauthorized {
+			t.Fatalf("status = %d, want %d", w.Code, http.StatusUnauthorized)
+		}
+		want := `Bearer realm="https://registry.test/token",service="registry",scope="repository:team/app:pull"`
+		if got := w.Header().Get("WWW-Authenticate"); got != want {
+			t.Errorf("WWW-Authenticate = %q, want %q", got, want)
+		}
+	})
+
+	t.Run("base check", func(t *testing.T) {
+		w := do("GET", "/v2/", "")
+		if w.Code != http.StatusUnauthorized {
+			t.Fatalf("status = %d, want %d", w.Code, http.StatusUnauthorized)
+		}
+		token, _ := requestToken(t, router, "", "", "")
+		if w := do("GET", "/v2/", token); w.Code != http.StatusOK {
+			t.Errorf("status with token = %d, want %d", w.Code, http.StatusOK)
+		}
+	})
+
+	t.Run("insufficient scope", func(t *testing.T) {
+		token, _ := requestToken(t, router, "alice", "secret", "repository:team/app:pull")
+		if w := do("GET", "/v2/team/app/manifests/latest", token); w.Code != http.StatusOK {
+			t.Errorf("pull status = %d, want %d", w.Code, http.StatusOK)
+		}
+
+		w := do("PUT", "/v2/team/app/manifests/latest", token)
+		if w.Code != http.StatusUnauthorized {
+			t.Fatalf("push status = %d, want %d", w.Code, http.StatusUnauthorized)
+		}
+		if got := w.Header().Get("WWW-Authenticate"); !strings.Contains(got, `scope="repository:team/app:pull,push"`) || !strings.Contains(got, `error="insufficient_scope"`) {
+			t.Errorf("WWW-Authenticate = %q", got)
+		}
+
+		if w := do("GET", "/v2/team/other/manifests/latest", token); w.Code != http.StatusUnauthorized {
+			t.Errorf("other repository status = %d, want %d", w.Code, http.StatusUnauthorized)
+		}
+	})
+
+	t.Run("cross repository mount", func(t *testing.T) {
+		token, _ := requestToken(t, router, "alice", "secret", "repository:team/app:pull,push")
+		w := do("POST", "/v2/team/app/blobs/uploads/?mount=sha256:abc&from=team/base", token)
+		if got := w.Header().Get("WWW-Authenticate"); w.Code != http.StatusUnauthorized || !strings.Contains(got, "repository:team/base:pull") {
+			t.Errorf("status = %d, WWW-Authenticate = %q", w.Code, got)
+		}
+	})
+
+	t.Run("catalog", func(t *testing.T) {
+		token, _ := requestToken(t, router, "alice", "secret", "registry:catalog:*")
+		if w := do("GET", "/v2/_catalog", token); w.Code != http.StatusOK {
+			t.Errorf("status = %d, want %d", w.Code, http.StatusOK)
+		}
+	})
+
+	t.Run("admin route", func(t *testing.T) {
+		w := do("GET", "/metrics", "")
+		if got := w.Header().Get("WWW-Authenticate"); w.Code != http.StatusUnauthorized || !strings.Contains(got, `scope="registry:admin:*"`) {
+			t.Fatalf("status = %d, WWW-Authenticate = %q", w.Code, got)
+		}
+		token, _ := requestToken(t, router, "alice", "secret", "repository:team/app:pull")
+		if w := do("GET", "/metrics", token); w.Code != http.StatusUnauthorized {
+			t.Errorf("status without admin scope = %d, want %d", w.Code, http.StatusUnauthorized)
+		}
+		token, _ = requestToken(t, router, "alice", "secret", "registry:admin:*")
+		if w := do("GET", "/metrics", token); w.Code != http.StatusOK {
+			t.Errorf("status with admin scope = %d, want %d", w.Code, http.StatusOK)
+		}
+	})
+
+	t.Run("invalid token", func(t *testing.T) {
+		if w := do("GET", "/v2/team/app/manifests/latest", "not-a-token"); w.Code != http.StatusUnauthorized {
+			t.Errorf("status = %d, want %d", w.Code, http.StatusUnauthorized)
+		}
+	})
+}
+
+// enforceTestPolicy installs policy on handler and makes router enforce it,
+// taking the user of each request from its X-Test-User header in place of a token.
+func enforceTestPolicy(t *testing.T, handler *OCIHandler, router *mux.Router, doc auth.PolicyDocument) {
+	t.Helper()
+	policy, err := auth.NewPolicy(doc, false)
+	if err != nil {
+		t.Fatalf("failed to create policy: %v", err)
+	}
+	handler.Authorizer = policy
+	router.Use(func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			claims := &auth.Claims{}
+			claims.Subject = r.Header.Get("X-Test-User")
+			next.ServeHTTP(w, r.WithContext(withClaims(r.Context(), claims)))
+		})
+	}, handler.EnforceAccess)
+}
+
+func TestEnforceAccess(t *testing.T) {
+	handler, router := setupTestOCIHandler(t)
+	manifest := newTestManifest(t, router, "apps/web", "web")
+	pushTestManifest(t, router, "apps/web", "v1", manifest)
+
+	enforceTestPolicy(t, handler, router, auth.PolicyDocument{
+		Groups: []auth.Group{{Name: "ci", Members: []string{"builder"}}},
+		Grants: []auth.Grant{
+			{Users: []string{"viewer"}, Role: "reader", Repositories: []string{"apps/*"}},
+			{Groups: []string{"ci"}, Role: "writer", Repositories: []string{"apps/*"}},
+		},
+	})
+
+	tests := []struct {
+		user   string
+		method string
+		want   int
+	}{
+		{"viewer", "GET", http.StatusOK},
+		{"viewer", "PUT", http.StatusForbidden},
+		{"builder", "PUT", http.StatusCreated},
+		{"builder", "DELETE", http.StatusForbidden},
+		{"stranger", "GET", http.StatusForbidden},
+	}
+	for _, tt := range tests {
+		t.Run(tt.user+" "+tt.method, func(t *testing.T) {
+			req := httptest.NewRequest(tt.method, "/v2/apps/web/manifests/v1", strings.NewReader(string(manifest)))
+			req.Header.Set("Content-Type", "application/vnd.oci.image.manifest.v1+json")
+			req.Header.Set("X-Test-User", tt.user)
+			w := httptest.NewRecorder()
+			router.ServeHTTP(w, req)
+			if w.Code != tt.want {
+				t.Errorf("status = %d, want %d", w.Code, tt.want)
+			}
+		})
+	}
+}
+
+func TestEnforceAccessMountDiscovery(t *testing.T) {
+	handler, router := setupTestOCIHandler(t)
+	blobData := []byte("private layer")
+	digest := pushTestBlob(t, router, "secret/keys", blobData)
+
+	enforceTestPolicy(t, handler, router, auth.PolicyDocument{
+		Grants: []auth.Grant{
+			{Users: []string{"alice", "bob"}, Role: "writer", Repositories: []string{"apps/*"}},
+			{Users: []string{"bob"}, Role: "reader", Repositories: []string{"secret/*"}},
+		},
+	})
+
+	// Without pull on secret/keys the blob must not be discovered there
+	req := httptest.NewRequest("POST", "/v2/apps/web/blobs/uploads/?mount="+digest, nil)
+	req.Header.Set("X-Test-User", "alice")
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+	if w.Code != http.StatusAccepted {
+		t.Fatalf("mount: status = %d, want %d", w.Code, http.StatusAccepted)
+	}
+	if w.Header().Get("Docker-Upload-UUID") == "" {
+		t.Error("mount: expected a regular upload session")
+	}
+
+	req = httptest.NewRequest("GET", "/v2/apps/web/blobs/"+digest, nil)
+	req.Header.Set("X-Test-User", "alice")
+	w = httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+	if w.Code != http.StatusNotFound {
+		t.Errorf("GET: status = %d, want %d", w.Code, http.StatusNotFound)
+	}
+
+	// With pull on secret/keys the blob is mounted
+	req = httptest.NewRequest("POST", "/v2/apps/api/blobs/uploads/?mount="+digest, nil)
+	req.Header.Set("X-Test-User", "bob")
+	w = httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+	if w.Code != http.StatusCreated {
+		t.Errorf("mount with pull: status = %d, want %d", w.Code, http.StatusCreated)
+	}
+}
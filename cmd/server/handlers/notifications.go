@@ -0,0 +1,41 @@
+package handlers
+
+import (
+	"net/http"
+
+	"github.com/hairizuanbinnoorazman/package-universe/notifications"
+)
+
+// notify sends a registry event about target to the Notifier, if one is set.
+// kind is "manifests" or "blobs", the API path under which target is served.
+func (h *OCIHandler) notify(r *http.Request, action, kind string, target notifications.Target) {
+	if h.Notifier == nil {
+		return
+	}
+
+	reference := target.Digest
+	if reference == "" {
+		reference = target.Tag
+	}
+	target.URL = requestScheme(r) + "://" + r.Host + "/v2/" + target.Repository + "/" + kind + "/" + reference
+
+	h.Notifier.Notify(r.Context(), notifications.Event{
+		Action: action,
+		Target: target,
+		Request: notifications.Request{
+			Addr:      r.RemoteAddr,
+			Host:      r.Host,
+			Method:    r.Method,
+			UserAgent: r.UserAgent(),
+		},
+		Actor: notifications.Actor{Name: requestUser(r.Context())},
+	})
+}
+
+// requestScheme returns the scheme the client used, honouring a TLS-terminating proxy.
+func requestScheme(r *http.Request) string {
+	if r.TLS != nil || r.Header.Get("X-Forwarded-Proto") == "https" {
+		return "https"
+	}
+	return "http"
+}
@@ -20,28 +20,34 @@ func setupTestOCIHandler(t *testing.T) (*OCIHandler, *mux.Router) {
 		t.Fatalf("failed to create storage: %v", err)
 	}
 
-	sessions := oci.NewSessionManager(30 * time.Minute)
+	sessions := oci.NewSessionManager(store, 30*time.Minute)
 	ociStorage := oci.NewOCIStorage(store, sessions)
 
 	handler := &OCIHandler{
-		Storage: ociStorage,
-		Logger:  logger.NewTestLogger(),
+		Storage:       ociStorage,
+		Logger:        logger.NewTestLogger(),
+		DeleteEnabled: true,
 	}
 
 	router := mux.NewRouter()
 
 	// Register all OCI routes
 	router.HandleFunc("/v2/", handler.V2Check).Methods("GET")
+	router.HandleFunc("/v2/_catalog", handler.Catalog).Methods("GET")
 	router.HandleFunc("/v2/{name:.+}/blobs/uploads/", handler.InitiateBlobUpload).Methods("POST")
+	router.HandleFunc("/v2/{name:.+}/blobs/uploads/{uuid}", handler.GetBlobUploadStatus).Methods("GET")
 	router.HandleFunc("/v2/{name:.+}/blobs/uploads/{uuid}", handler.PatchBlobUpload).Methods("PATCH")
 	router.HandleFunc("/v2/{name:.+}/blobs/uploads/{uuid}", handler.CompleteBlobUpload).Methods("PUT")
 	router.HandleFunc("/v2/{name:.+}/blobs/uploads/{uuid}", handler.CancelBlobUpload).Methods("DELETE")
 	router.HandleFunc("/v2/{name:.+}/blobs/{digest}", handler.HeadBlob).Methods("HEAD")
 	router.HandleFunc("/v2/{name:.+}/blobs/{digest}", handler.GetBlob).Methods("GET")
+	router.HandleFunc("/v2/{name:.+}/blobs/{digest}", handler.DeleteBlob).Methods("DELETE")
 	router.HandleFunc("/v2/{name:.+}/manifests/{reference}", handler.HeadManifest).Methods("HEAD")
 	router.HandleFunc("/v2/{name:.+}/manifests/{reference}", handler.GetManifest).Methods("GET")
 	router.HandleFunc("/v2/{name:.+}/manifests/{reference}", handler.PutManifest).Methods("PUT")
+	router.HandleFunc("/v2/{name:.+}/manifests/{reference}", handler.DeleteManifest).Methods("DELETE")
 	router.HandleFunc("/v2/{name:.+}/tags/list", handler.TagsList).Methods("GET")
+	router.HandleFunc("/v2/{name:.+}/referrers/{digest}", handler.ListReferrers).Methods("GET")
 
 	return handler, router
 }
@@ -1,13 +1,16 @@
 package handlers
 
 import (
+	"context"
 	"errors"
 	"fmt"
 	"io"
 	"net/http"
 	"strconv"
+	"strings"
 
 	"github.com/gorilla/mux"
+	"github.com/hairizuanbinnoorazman/package-universe/notifications"
 	"github.com/hairizuanbinnoorazman/package-universe/oci"
 )
 
@@ -17,6 +20,7 @@ func (h *OCIHandler) HeadBlob(w http.ResponseWriter, r *http.Request) {
 	ctx := r.Context()
 
 	vars := mux.Vars(r)
+	name := vars["name"]
 	digestStr := vars["digest"]
 
 	digest, err := oci.ParseDigest(digestStr)
@@ -25,7 +29,7 @@ func (h *OCIHandler) HeadBlob(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	info, err := h.Storage.GetBlobInfo(ctx, digest)
+	info, err := h.Storage.GetBlobInfo(ctx, name, digest)
 	if err != nil {
 		if errors.Is(err, oci.ErrBlobNotFound) {
 			respondOCIError(w, http.StatusNotFound, OCIErrorBlobUnknown, "blob not found")
@@ -38,15 +42,19 @@ func (h *OCIHandler) HeadBlob(w http.ResponseWriter, r *http.Request) {
 
 	w.Header().Set("Content-Length", strconv.FormatInt(info.Size, 10))
 	w.Header().Set("Docker-Content-Digest", info.Digest.String())
+	w.Header().Set("Accept-Ranges", "bytes")
+	w.Header().Set("ETag", blobETag(info.Digest))
 	w.WriteHeader(http.StatusOK)
 }
 
-// GetBlob handles GET /v2/{name}/blobs/{digest} — download blob.
+// GetBlob handles GET /v2/{name}/blobs/{digest} — download blob, honouring a single
+// byte Range (and If-Range) with 206 Partial Content.
 func (h *OCIHandler) GetBlob(w http.ResponseWriter, r *http.Request) {
 	setOCIHeaders(w)
 	ctx := r.Context()
 
 	vars := mux.Vars(r)
+	name := vars["name"]
 	digestStr := vars["digest"]
 
 	digest, err := oci.ParseDigest(digestStr)
@@ -55,7 +63,40 @@ func (h *OCIHandler) GetBlob(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	rc, err := h.Storage.GetBlob(ctx, digest)
+	info, err := h.Storage.GetBlobInfo(ctx, name, digest)
+	if err != nil {
+		if errors.Is(err, oci.ErrBlobNotFound) {
+			respondOCIError(w, http.StatusNotFound, OCIErrorBlobUnknown, "blob not found")
+			return
+		}
+		h.Logger.Error(ctx, "failed to get blob info", map[string]interface{}{"error": err.Error()})
+		respondOCIError(w, http.StatusInternalServerError, OCIErrorBlobUnknown, "internal error")
+		return
+	}
+
+	etag := blobETag(digest)
+	w.Header().Set("Accept-Ranges", "bytes")
+	w.Header().Set("ETag", etag)
+
+	rangeHeader := r.Header.Get("Range")
+	if ifRange := r.Header.Get("If-Range"); ifRange != "" && ifRange != etag {
+		// The client's copy is stale, so send the whole blob
+		rangeHeader = ""
+	}
+
+	offset, length, ranged, err := parseByteRange(rangeHeader, info.Size)
+	if err != nil {
+		w.Header().Set("Content-Range", fmt.Sprintf("bytes */%d", info.Size))
+		respondOCIError(w, http.StatusRequestedRangeNotSatisfiable, OCIErrorSizeInvalid, "requested range not satisfiable")
+		return
+	}
+
+	var rc io.ReadCloser
+	if ranged {
+		rc, err = h.Storage.GetBlobRange(ctx, name, digest, offset, length)
+	} else {
+		rc, err = h.Storage.GetBlob(ctx, name, digest)
+	}
 	if err != nil {
 		if errors.Is(err, oci.ErrBlobNotFound) {
 			respondOCIError(w, http.StatusNotFound, OCIErrorBlobUnknown, "blob not found")
@@ -69,10 +110,53 @@ func (h *OCIHandler) GetBlob(w http.ResponseWriter, r *http.Request) {
 
 	w.Header().Set("Docker-Content-Digest", digest.String())
 	w.Header().Set("Content-Type", "application/octet-stream")
-	w.WriteHeader(http.StatusOK)
+	if ranged {
+		w.Header().Set("Content-Range", fmt.Sprintf("bytes %d-%d/%d", offset, offset+length-1, info.Size))
+		w.Header().Set("Content-Length", strconv.FormatInt(length, 10))
+		w.WriteHeader(http.StatusPartialContent)
+	} else {
+		w.Header().Set("Content-Length", strconv.FormatInt(info.Size, 10))
+		w.WriteHeader(http.StatusOK)
+	}
 	io.Copy(w, rc)
 }
 
+// DeleteBlob handles DELETE /v2/{name}/blobs/{digest} — delete blob.
+func (h *OCIHandler) DeleteBlob(w http.ResponseWriter, r *http.Request) {
+	setOCIHeaders(w)
+	ctx := r.Context()
+
+	if !h.DeleteEnabled {
+		respondOCIError(w, http.StatusMethodNotAllowed, OCIErrorUnsupported, "deletion is disabled")
+		return
+	}
+
+	vars := mux.Vars(r)
+	name := vars["name"]
+	digestStr := vars["digest"]
+
+	digest, err := oci.ParseDigest(digestStr)
+	if err != nil {
+		respondOCIError(w, http.StatusBadRequest, OCIErrorDigestInvalid, "invalid digest format")
+		return
+	}
+
+	err = h.Storage.DeleteBlob(ctx, name, digest)
+	if err != nil {
+		if errors.Is(err, oci.ErrBlobNotFound) {
+			respondOCIError(w, http.StatusNotFound, OCIErrorBlobUnknown, "blob not found")
+			return
+		}
+		h.Logger.Error(ctx, "failed to delete blob", map[string]interface{}{"error": err.Error()})
+		respondOCIError(w, http.StatusInternalServerError, OCIErrorBlobUnknown, "failed to delete blob")
+		return
+	}
+
+	w.WriteHeader(http.StatusAccepted)
+
+	h.notify(r, notifications.ActionDelete, "blobs", notifications.Target{Repository: name, Digest: digest.String()})
+}
+
 // InitiateBlobUpload handles POST /v2/{name}/blobs/uploads/ — start an upload.
 func (h *OCIHandler) InitiateBlobUpload(w http.ResponseWriter, r *http.Request) {
 	setOCIHeaders(w)
@@ -81,6 +165,12 @@ func (h *OCIHandler) InitiateBlobUpload(w http.ResponseWriter, r *http.Request)
 	vars := mux.Vars(r)
 	name := vars["name"]
 
+	// Check for cross-repository mount (mount=<digest>, optionally from=<repo>)
+	mountParam := r.URL.Query().Get("mount")
+	if mountParam != "" && h.mountBlob(w, r, name, mountParam, r.URL.Query().Get("from")) {
+		return
+	}
+
 	// Check for monolithic upload (digest in query param with body)
 	digestParam := r.URL.Query().Get("digest")
 	if digestParam != "" {
@@ -88,8 +178,13 @@ func (h *OCIHandler) InitiateBlobUpload(w http.ResponseWriter, r *http.Request)
 		return
 	}
 
-	uuid, err := h.Storage.InitiateUpload(ctx, name)
+	// The running digest uses the algorithm requested with digest-algorithm (sha256 by default)
+	uuid, err := h.Storage.InitiateUpload(ctx, name, r.URL.Query().Get("digest-algorithm"))
 	if err != nil {
+		if errors.Is(err, oci.ErrInvalidDigest) {
+			respondOCIError(w, http.StatusBadRequest, OCIErrorDigestInvalid, err.Error())
+			return
+		}
 		h.Logger.Error(ctx, "failed to initiate upload", map[string]interface{}{"error": err.Error()})
 		respondOCIError(w, http.StatusInternalServerError, OCIErrorBlobUploadInvalid, "failed to initiate upload")
 		return
@@ -97,10 +192,74 @@ func (h *OCIHandler) InitiateBlobUpload(w http.ResponseWriter, r *http.Request)
 
 	w.Header().Set("Location", fmt.Sprintf("/v2/%s/blobs/uploads/%s", name, uuid))
 	w.Header().Set("Docker-Upload-UUID", uuid)
-	w.Header().Set("Range", "0-0")
+	w.Header().Set("Range", uploadRange(0))
 	w.WriteHeader(http.StatusAccepted)
 }
 
+// mountBlob attempts to satisfy a mount request by linking an existing blob
+// from another repository. It returns false when the blob is unavailable so the
+// caller can fall back to a regular upload session. Without from, any
+// repository holding the blob is used (automatic content discovery); with an
+// Authorizer only repositories the user can pull are considered. A mount that
+// would exceed a quota is answered with DENIED.
+func (h *OCIHandler) mountBlob(w http.ResponseWriter, r *http.Request, name, digestStr, from string) bool {
+	ctx := r.Context()
+
+	digest, err := oci.ParseDigest(digestStr)
+	if err != nil {
+		return false
+	}
+
+	sources := []string{from}
+	if from == "" && h.Authorizer != nil {
+		sources, err = h.mountSources(r, name)
+		if err != nil {
+			h.Logger.Error(ctx, "failed to list mount sources", map[string]interface{}{"error": err.Error()})
+			return false
+		}
+	}
+
+	err = oci.ErrBlobNotFound
+	for _, source := range sources {
+		_, err = h.Storage.MountBlob(ctx, name, source, digest)
+		if !errors.Is(err, oci.ErrBlobNotFound) {
+			break
+		}
+	}
+	if err != nil {
+		if errors.Is(err, oci.ErrQuotaExceeded) {
+			respondOCIError(w, http.StatusForbidden, OCIErrorDenied, err.Error())
+			return true
+		}
+		if !errors.Is(err, oci.ErrBlobNotFound) {
+			h.Logger.Error(ctx, "failed to mount blob", map[string]interface{}{"error": err.Error()})
+		}
+		return false
+	}
+
+	w.Header().Set("Location", fmt.Sprintf("/v2/%s/blobs/%s", name, digest.String()))
+	w.Header().Set("Docker-Content-Digest", digest.String())
+	w.WriteHeader(http.StatusCreated)
+	return true
+}
+
+// mountSources returns the repositories other than name that the requesting
+// user can pull, for automatic content discovery under an Authorizer.
+func (h *OCIHandler) mountSources(r *http.Request, name string) ([]string, error) {
+	ctx := r.Context()
+	repositories, err := h.Storage.ListRepositories(ctx)
+	if err != nil {
+		return nil, err
+	}
+	sources := []string{}
+	for _, repository := range repositories {
+		if repository != name && h.canPull(ctx, repository) {
+			sources = append(sources, repository)
+		}
+	}
+	return sources, nil
+}
+
 // handleMonolithicUpload handles a single-request blob upload (POST with digest query param).
 func (h *OCIHandler) handleMonolithicUpload(w http.ResponseWriter, r *http.Request, name, digestStr string) {
 	ctx := r.Context()
@@ -111,15 +270,31 @@ func (h *OCIHandler) handleMonolithicUpload(w http.ResponseWriter, r *http.Reque
 		return
 	}
 
-	uuid, err := h.Storage.InitiateUpload(ctx, name)
+	uuid, err := h.Storage.InitiateUpload(ctx, name, expectedDigest.Algorithm)
 	if err != nil {
 		h.Logger.Error(ctx, "failed to initiate monolithic upload", map[string]interface{}{"error": err.Error()})
 		respondOCIError(w, http.StatusInternalServerError, OCIErrorBlobUploadInvalid, "failed to initiate upload")
 		return
 	}
 
+	// Drop the session and its data on any failure, even once the client has gone
+	completed := false
+	defer func() {
+		if !completed {
+			h.Storage.CancelUpload(context.WithoutCancel(ctx), uuid)
+		}
+	}()
+
+	if r.ContentLength > 0 && !h.checkUploadQuota(w, r, uuid, r.ContentLength) {
+		return
+	}
+
 	_, err = h.Storage.WriteUploadChunk(ctx, uuid, r.Body)
 	if err != nil {
+		if errors.Is(err, oci.ErrQuotaExceeded) {
+			respondOCIError(w, http.StatusForbidden, OCIErrorDenied, err.Error())
+			return
+		}
 		h.Logger.Error(ctx, "failed to write monolithic upload", map[string]interface{}{"error": err.Error()})
 		respondOCIError(w, http.StatusInternalServerError, OCIErrorBlobUploadInvalid, "failed to write data")
 		return
@@ -131,16 +306,49 @@ func (h *OCIHandler) handleMonolithicUpload(w http.ResponseWriter, r *http.Reque
 			respondOCIError(w, http.StatusBadRequest, OCIErrorDigestInvalid, "digest mismatch")
 			return
 		}
+		if errors.Is(err, oci.ErrQuotaExceeded) {
+			respondOCIError(w, http.StatusForbidden, OCIErrorDenied, err.Error())
+			return
+		}
 		h.Logger.Error(ctx, "failed to complete monolithic upload", map[string]interface{}{"error": err.Error()})
 		respondOCIError(w, http.StatusInternalServerError, OCIErrorBlobUploadInvalid, "failed to complete upload")
 		return
 	}
+	completed = true
 
 	w.Header().Set("Location", fmt.Sprintf("/v2/%s/blobs/%s", name, digest.String()))
 	w.Header().Set("Docker-Content-Digest", digest.String())
 	w.WriteHeader(http.StatusCreated)
 }
 
+// GetBlobUploadStatus handles GET /v2/{name}/blobs/uploads/{uuid} — report upload progress.
+func (h *OCIHandler) GetBlobUploadStatus(w http.ResponseWriter, r *http.Request) {
+	setOCIHeaders(w)
+	ctx := r.Context()
+
+	vars := mux.Vars(r)
+	name := vars["name"]
+	uuid := vars["uuid"]
+
+	if !h.checkUploadSession(w, r, name, uuid) {
+		return
+	}
+
+	offset, err := h.Storage.GetUploadOffset(ctx, uuid)
+	if err != nil {
+		if errors.Is(err, oci.ErrUploadNotFound) {
+			respondOCIError(w, http.StatusNotFound, OCIErrorBlobUploadUnknown, "upload not found")
+			return
+		}
+		h.Logger.Error(ctx, "failed to get upload status", map[string]interface{}{"error": err.Error()})
+		respondOCIError(w, http.StatusInternalServerError, OCIErrorBlobUploadInvalid, "failed to get upload status")
+		return
+	}
+
+	setUploadHeaders(w, name, uuid, offset)
+	w.WriteHeader(http.StatusNoContent)
+}
+
 // PatchBlobUpload handles PATCH /v2/{name}/blobs/uploads/{uuid} — chunked upload data.
 func (h *OCIHandler) PatchBlobUpload(w http.ResponseWriter, r *http.Request) {
 	setOCIHeaders(w)
@@ -150,20 +358,31 @@ func (h *OCIHandler) PatchBlobUpload(w http.ResponseWriter, r *http.Request) {
 	name := vars["name"]
 	uuid := vars["uuid"]
 
-	totalSize, err := h.Storage.WriteUploadChunk(ctx, uuid, r.Body)
+	if !h.checkUploadSession(w, r, name, uuid) || !h.checkUploadChunk(w, r, name, uuid) {
+		return
+	}
+
+	totalSize, err := h.Storage.WriteUploadChunk(ctx, uuid, h.chunkBody(w, r))
 	if err != nil {
 		if errors.Is(err, oci.ErrUploadNotFound) {
 			respondOCIError(w, http.StatusNotFound, OCIErrorBlobUploadUnknown, "upload not found")
 			return
 		}
+		var maxBytesErr *http.MaxBytesError
+		if errors.As(err, &maxBytesErr) {
+			respondOCIError(w, http.StatusRequestEntityTooLarge, OCIErrorSizeInvalid, "chunk exceeds maximum size")
+			return
+		}
+		if errors.Is(err, oci.ErrQuotaExceeded) {
+			respondOCIError(w, http.StatusForbidden, OCIErrorDenied, err.Error())
+			return
+		}
 		h.Logger.Error(ctx, "failed to write upload chunk", map[string]interface{}{"error": err.Error()})
 		respondOCIError(w, http.StatusInternalServerError, OCIErrorBlobUploadInvalid, "failed to write chunk")
 		return
 	}
 
-	w.Header().Set("Location", fmt.Sprintf("/v2/%s/blobs/uploads/%s", name, uuid))
-	w.Header().Set("Docker-Upload-UUID", uuid)
-	w.Header().Set("Range", fmt.Sprintf("0-%d", totalSize-1))
+	setUploadHeaders(w, name, uuid, totalSize)
 	w.WriteHeader(http.StatusAccepted)
 }
 
@@ -188,18 +407,34 @@ func (h *OCIHandler) CompleteBlobUpload(w http.ResponseWriter, r *http.Request)
 		return
 	}
 
+	if !h.checkUploadSession(w, r, name, uuid) {
+		return
+	}
+
 	// If there's a body, write it as the final chunk
 	if r.ContentLength > 0 || r.ContentLength == -1 {
-		_, err := h.Storage.WriteUploadChunk(ctx, uuid, r.Body)
-		if err != nil && err != oci.ErrUploadNotFound {
-			h.Logger.Error(ctx, "failed to write final chunk", map[string]interface{}{"error": err.Error()})
-			respondOCIError(w, http.StatusInternalServerError, OCIErrorBlobUploadInvalid, "failed to write final chunk")
+		if !h.checkUploadChunk(w, r, name, uuid) {
 			return
 		}
+		_, err := h.Storage.WriteUploadChunk(ctx, uuid, h.chunkBody(w, r))
 		if errors.Is(err, oci.ErrUploadNotFound) {
 			respondOCIError(w, http.StatusNotFound, OCIErrorBlobUploadUnknown, "upload not found")
 			return
 		}
+		var maxBytesErr *http.MaxBytesError
+		if errors.As(err, &maxBytesErr) {
+			respondOCIError(w, http.StatusRequestEntityTooLarge, OCIErrorSizeInvalid, "chunk exceeds maximum size")
+			return
+		}
+		if errors.Is(err, oci.ErrQuotaExceeded) {
+			respondOCIError(w, http.StatusForbidden, OCIErrorDenied, err.Error())
+			return
+		}
+		if err != nil {
+			h.Logger.Error(ctx, "failed to write final chunk", map[string]interface{}{"error": err.Error()})
+			respondOCIError(w, http.StatusInternalServerError, OCIErrorBlobUploadInvalid, "failed to write final chunk")
+			return
+		}
 	}
 
 	digest, err := h.Storage.CompleteUpload(ctx, uuid, expectedDigest)
@@ -212,6 +447,10 @@ func (h *OCIHandler) CompleteBlobUpload(w http.ResponseWriter, r *http.Request)
 			respondOCIError(w, http.StatusBadRequest, OCIErrorDigestInvalid, "digest mismatch")
 			return
 		}
+		if errors.Is(err, oci.ErrQuotaExceeded) {
+			respondOCIError(w, http.StatusForbidden, OCIErrorDenied, err.Error())
+			return
+		}
 		h.Logger.Error(ctx, "failed to complete upload", map[string]interface{}{"error": err.Error()})
 		respondOCIError(w, http.StatusInternalServerError, OCIErrorBlobUploadInvalid, "failed to complete upload")
 		return
@@ -228,8 +467,13 @@ func (h *OCIHandler) CancelBlobUpload(w http.ResponseWriter, r *http.Request) {
 	ctx := r.Context()
 
 	vars := mux.Vars(r)
+	name := vars["name"]
 	uuid := vars["uuid"]
 
+	if !h.checkUploadSession(w, r, name, uuid) {
+		return
+	}
+
 	err := h.Storage.CancelUpload(ctx, uuid)
 	if err != nil {
 		if errors.Is(err, oci.ErrUploadNotFound) {
@@ -243,3 +487,202 @@ func (h *OCIHandler) CancelBlobUpload(w http.ResponseWriter, r *http.Request) {
 
 	w.WriteHeader(http.StatusNoContent)
 }
+
+// checkUploadSession verifies that upload uuid was started in repository name,
+// writing an error response and returning false if it was not. Sessions are
+// only reachable through their own repository, whose access was authorized.
+func (h *OCIHandler) checkUploadSession(w http.ResponseWriter, r *http.Request, name, uuid string) bool {
+	ctx := r.Context()
+
+	repository, err := h.Storage.GetUploadRepository(ctx, uuid)
+	if err != nil {
+		if errors.Is(err, oci.ErrUploadNotFound) {
+			respondOCIError(w, http.StatusNotFound, OCIErrorBlobUploadUnknown, "upload not found")
+			return false
+		}
+		h.Logger.Error(ctx, "failed to get upload session", map[string]interface{}{"error": err.Error()})
+		respondOCIError(w, http.StatusInternalServerError, OCIErrorBlobUploadInvalid, "failed to get upload status")
+		return false
+	}
+	if repository != name {
+		respondOCIError(w, http.StatusNotFound, OCIErrorBlobUploadUnknown, "upload not found")
+		return false
+	}
+	return true
+}
+
+// checkUploadChunk validates a chunk's size and Content-Range against the upload's
+// current offset, writing an error response and returning false if it is rejected.
+// Chunks without a Content-Range header are appended at the current offset.
+func (h *OCIHandler) checkUploadChunk(w http.ResponseWriter, r *http.Request, name, uuid string) bool {
+	ctx := r.Context()
+
+	if h.MaxChunkSize > 0 && r.ContentLength > h.MaxChunkSize {
+		respondOCIError(w, http.StatusRequestEntityTooLarge, OCIErrorSizeInvalid, "chunk exceeds maximum size")
+		return false
+	}
+
+	// Refuse chunks of known size that would exceed a quota before writing any
+	// of them; the upload can continue with a smaller chunk
+	if r.ContentLength > 0 && !h.checkUploadQuota(w, r, uuid, r.ContentLength) {
+		return false
+	}
+
+	contentRange := r.Header.Get("Content-Range")
+	if contentRange == "" {
+		return true
+	}
+
+	start, end, err := parseContentRange(contentRange)
+	if err != nil {
+		respondOCIError(w, http.StatusBadRequest, OCIErrorBlobUploadInvalid, "invalid Content-Range header")
+		return false
+	}
+	if r.ContentLength >= 0 && r.ContentLength != end-start+1 {
+		respondOCIError(w, http.StatusBadRequest, OCIErrorBlobUploadInvalid, "Content-Range does not match Content-Length")
+		return false
+	}
+
+	offset, err := h.Storage.GetUploadOffset(ctx, uuid)
+	if err != nil {
+		if errors.Is(err, oci.ErrUploadNotFound) {
+			respondOCIError(w, http.StatusNotFound, OCIErrorBlobUploadUnknown, "upload not found")
+			return false
+		}
+		h.Logger.Error(ctx, "failed to get upload offset", map[string]interface{}{"error": err.Error()})
+		respondOCIError(w, http.StatusInternalServerError, OCIErrorBlobUploadInvalid, "failed to get upload status")
+		return false
+	}
+
+	if start != offset {
+		setUploadHeaders(w, name, uuid, offset)
+		respondOCIError(w, http.StatusRequestedRangeNotSatisfiable, OCIErrorBlobUploadInvalid,
+			fmt.Sprintf("chunk starts at %d, expected %d", start, offset))
+		return false
+	}
+
+	return true
+}
+
+// checkUploadQuota checks that size more bytes fit within the quotas of
+// upload uuid's repository, writing an error response and returning false if not.
+func (h *OCIHandler) checkUploadQuota(w http.ResponseWriter, r *http.Request, uuid string, size int64) bool {
+	ctx := r.Context()
+
+	err := h.Storage.CheckUploadQuota(ctx, uuid, size)
+	if err == nil {
+		return true
+	}
+	switch {
+	case errors.Is(err, oci.ErrQuotaExceeded):
+		respondOCIError(w, http.StatusForbidden, OCIErrorDenied, err.Error())
+	case errors.Is(err, oci.ErrUploadNotFound):
+		respondOCIError(w, http.StatusNotFound, OCIErrorBlobUploadUnknown, "upload not found")
+	default:
+		h.Logger.Error(ctx, "failed to check upload quota", map[string]interface{}{"error": err.Error()})
+		respondOCIError(w, http.StatusInternalServerError, OCIErrorBlobUploadInvalid, "failed to check quota")
+	}
+	return false
+}
+
+// chunkBody returns the request body, limited to MaxChunkSize when one is configured.
+func (h *OCIHandler) chunkBody(w http.ResponseWriter, r *http.Request) io.Reader {
+	if h.MaxChunkSize > 0 {
+		return http.MaxBytesReader(w, r.Body, h.MaxChunkSize)
+	}
+	return r.Body
+}
+
+// setUploadHeaders sets the Location, Docker-Upload-UUID and Range headers
+// describing an in-progress upload.
+func setUploadHeaders(w http.ResponseWriter, name, uuid string, size int64) {
+	w.Header().Set("Location", fmt.Sprintf("/v2/%s/blobs/uploads/%s", name, uuid))
+	w.Header().Set("Docker-Upload-UUID", uuid)
+	w.Header().Set("Range", uploadRange(size))
+}
+
+// uploadRange formats the Range header for an upload holding size bytes. An
+// empty upload is reported as "0-0", matching the registry's initial response.
+func uploadRange(size int64) string {
+	if size <= 0 {
+		return "0-0"
+	}
+	return fmt.Sprintf("0-%d", size-1)
+}
+
+// parseContentRange parses a chunk Content-Range header of the form
+// "<start>-<end>", optionally prefixed with "bytes ".
+func parseContentRange(value string) (int64, int64, error) {
+	value = strings.TrimPrefix(strings.TrimSpace(value), "bytes ")
+	startStr, endStr, ok := strings.Cut(value, "-")
+	if !ok {
+		return 0, 0, fmt.Errorf("invalid range %q", value)
+	}
+	start, err := strconv.ParseInt(startStr, 10, 64)
+	if err != nil {
+		return 0, 0, fmt.Errorf("invalid range start %q", startStr)
+	}
+	end, err := strconv.ParseInt(endStr, 10, 64)
+	if err != nil {
+		return 0, 0, fmt.Errorf("invalid range end %q", endStr)
+	}
+	if start < 0 || end < start {
+		return 0, 0, fmt.Errorf("invalid range %q", value)
+	}
+	return start, end, nil
+}
+
+// blobETag returns the strong entity tag for a blob. Blobs are content
+// addressed, so the digest identifies the representation exactly.
+func blobETag(digest oci.DigestInfo) string {
+	return strconv.Quote(digest.String())
+}
+
+// parseByteRange interprets a Range header against a blob of the given size and
+// returns the offset and length to serve. ranged is false when the header is
+// absent, malformed or requests multiple ranges, in which case the whole blob is
+// served; err is set when the range cannot be satisfied.
+func parseByteRange(header string, size int64) (offset, length int64, ranged bool, err error) {
+	spec, ok := strings.CutPrefix(header, "bytes=")
+	if !ok || strings.Contains(spec, ",") {
+		return 0, 0, false, nil
+	}
+	startStr, endStr, ok := strings.Cut(strings.TrimSpace(spec), "-")
+	if !ok {
+		return 0, 0, false, nil
+	}
+
+	if startStr == "" {
+		// Suffix range: the last N bytes
+		suffix, err := strconv.ParseInt(endStr, 10, 64)
+		if err != nil {
+			return 0, 0, false, nil
+		}
+		if suffix <= 0 || size == 0 {
+			return 0, 0, false, fmt.Errorf("unsatisfiable range %q", header)
+		}
+		if suffix > size {
+			suffix = size
+		}
+		return size - suffix, suffix, true, nil
+	}
+
+	start, err := strconv.ParseInt(startStr, 10, 64)
+	if err != nil || start < 0 {
+		return 0, 0, false, nil
+	}
+	end := size - 1
+	if endStr != "" {
+		end, err = strconv.ParseInt(endStr, 10, 64)
+		if err != nil || end < start {
+			return 0, 0, false, nil
+		}
+		if end >= size {
+			end = size - 1
+		}
+	}
+	if start >= size {
+		return 0, 0, false, fmt.Errorf("unsatisfiable range %q", header)
+	}
+	return start, end - start + 1, true, nil
+}
@@ -2,7 +2,9 @@ package handlers
 
 import (
 	"bytes"
+	"context"
 	"crypto/sha256"
+	"crypto/sha512"
 	"encoding/json"
 	"fmt"
 	"io"
@@ -10,6 +12,10 @@ import (
 	"net/http/httptest"
 	"strings"
 	"testing"
+	"time"
+
+	"github.com/hairizuanbinnoorazman/package-universe/oci"
+	"github.com/hairizuanbinnoorazman/package-universe/storage"
 )
 
 func TestBlobUploadChunked(t *testing.T) {
@@ -98,6 +104,51 @@ func TestBlobUploadMonolithic(t *testing.T) {
 	}
 }
 
+func TestBlobUploadDigestAlgorithm(t *testing.T) {
+	_, router := setupTestOCIHandler(t)
+
+	req := httptest.NewRequest("POST", "/v2/myrepo/blobs/uploads/?digest-algorithm=sha512", nil)
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+	if w.Code != http.StatusAccepted {
+		t.Fatalf("initiate: status = %d, want %d", w.Code, http.StatusAccepted)
+	}
+	location := w.Header().Get("Location")
+
+	blobData := []byte("sha512 blob data")
+	req = httptest.NewRequest("PATCH", location, bytes.NewReader(blobData))
+	w = httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+	if w.Code != http.StatusAccepted {
+		t.Fatalf("patch: status = %d, want %d", w.Code, http.StatusAccepted)
+	}
+
+	digest := fmt.Sprintf("sha512:%x", sha512.Sum512(blobData))
+	req = httptest.NewRequest("PUT", location+"?digest="+digest, nil)
+	w = httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+	if w.Code != http.StatusCreated {
+		t.Fatalf("complete: status = %d, want %d, body = %s", w.Code, http.StatusCreated, w.Body.String())
+	}
+	if w.Header().Get("Docker-Content-Digest") != digest {
+		t.Errorf("digest header = %q, want %q", w.Header().Get("Docker-Content-Digest"), digest)
+	}
+
+	req = httptest.NewRequest("GET", "/v2/myrepo/blobs/"+digest, nil)
+	w = httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+	if w.Code != http.StatusOK || !bytes.Equal(w.Body.Bytes(), blobData) {
+		t.Errorf("GET: status = %d, content match = %v", w.Code, bytes.Equal(w.Body.Bytes(), blobData))
+	}
+
+	req = httptest.NewRequest("POST", "/v2/myrepo/blobs/uploads/?digest-algorithm=md5", nil)
+	w = httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+	if w.Code != http.StatusBadRequest {
+		t.Errorf("unsupported algorithm: status = %d, want %d", w.Code, http.StatusBadRequest)
+	}
+}
+
 func TestBlobHeadNotFound(t *testing.T) {
 	_, router := setupTestOCIHandler(t)
 
@@ -215,3 +266,518 @@ func TestBlobDigestMismatch(t *testing.T) {
 		t.Errorf("status = %d, want %d", w.Code, http.StatusBadRequest)
 	}
 }
+
+func TestBlobDelete(t *testing.T) {
+	_, router := setupTestOCIHandler(t)
+
+	blobData := []byte("blob to delete")
+	digest := fmt.Sprintf("sha256:%x", sha256.Sum256(blobData))
+
+	req := httptest.NewRequest("POST", fmt.Sprintf("/v2/myrepo/blobs/uploads/?digest=%s", digest), bytes.NewReader(blobData))
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+	if w.Code != http.StatusCreated {
+		t.Fatalf("upload: status = %d, want %d", w.Code, http.StatusCreated)
+	}
+
+	req = httptest.NewRequest("DELETE", fmt.Sprintf("/v2/myrepo/blobs/%s", digest), nil)
+	w = httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+	if w.Code != http.StatusAccepted {
+		t.Fatalf("DELETE: status = %d, want %d", w.Code, http.StatusAccepted)
+	}
+
+	req = httptest.NewRequest("HEAD", fmt.Sprintf("/v2/myrepo/blobs/%s", digest), nil)
+	w = httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+	if w.Code != http.StatusNotFound {
+		t.Errorf("HEAD after delete: status = %d, want %d", w.Code, http.StatusNotFound)
+	}
+
+	req = httptest.NewRequest("DELETE", fmt.Sprintf("/v2/myrepo/blobs/%s", digest), nil)
+	w = httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+	if w.Code != http.StatusNotFound {
+		t.Errorf("second DELETE: status = %d, want %d", w.Code, http.StatusNotFound)
+	}
+	var errResp ociErrorResponse
+	json.NewDecoder(w.Body).Decode(&errResp)
+	if len(errResp.Errors) == 0 || errResp.Errors[0].Code != OCIErrorBlobUnknown {
+		t.Errorf("expected BLOB_UNKNOWN error code")
+	}
+}
+
+func TestBlobDeleteDisabled(t *testing.T) {
+	handler, router := setupTestOCIHandler(t)
+	handler.DeleteEnabled = false
+
+	req := httptest.NewRequest("DELETE", "/v2/myrepo/blobs/sha256:0000000000000000000000000000000000000000000000000000000000000000", nil)
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+
+	if w.Code != http.StatusMethodNotAllowed {
+		t.Errorf("status = %d, want %d", w.Code, http.StatusMethodNotAllowed)
+	}
+}
+
+func TestBlobMount(t *testing.T) {
+	_, router := setupTestOCIHandler(t)
+
+	blobData := []byte("shared base layer")
+	digest := pushTestBlob(t, router, "base", blobData)
+
+	tests := []struct {
+		name  string
+		query string
+	}{
+		{name: "from source repository", query: fmt.Sprintf("mount=%s&from=base", digest)},
+		{name: "automatic content discovery", query: fmt.Sprintf("mount=%s", digest)},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := httptest.NewRequest("POST", "/v2/derived/blobs/uploads/?"+tt.query, nil)
+			w := httptest.NewRecorder()
+			router.ServeHTTP(w, req)
+
+			if w.Code != http.StatusCreated {
+				t.Fatalf("status = %d, want %d, body = %s", w.Code, http.StatusCreated, w.Body.String())
+			}
+			wantLocation := fmt.Sprintf("/v2/derived/blobs/%s", digest)
+			if w.Header().Get("Location") != wantLocation {
+				t.Errorf("Location = %q, want %q", w.Header().Get("Location"), wantLocation)
+			}
+			if w.Header().Get("Docker-Content-Digest") != digest {
+				t.Errorf("Docker-Content-Digest = %q, want %q", w.Header().Get("Docker-Content-Digest"), digest)
+			}
+		})
+	}
+
+	req := httptest.NewRequest("GET", fmt.Sprintf("/v2/derived/blobs/%s", digest), nil)
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+	if w.Code != http.StatusOK || !bytes.Equal(w.Body.Bytes(), blobData) {
+		t.Errorf("GET mounted blob: status = %d", w.Code)
+	}
+}
+
+func TestBlobMountFallback(t *testing.T) {
+	_, router := setupTestOCIHandler(t)
+
+	missing := fmt.Sprintf("sha256:%x", sha256.Sum256([]byte("not uploaded")))
+	queries := []string{
+		fmt.Sprintf("mount=%s&from=base", missing),
+		"mount=not-a-digest&from=base",
+	}
+
+	for _, query := range queries {
+		req := httptest.NewRequest("POST", "/v2/derived/blobs/uploads/?"+query, nil)
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, req)
+
+		if w.Code != http.StatusAccepted {
+			t.Errorf("%s: status = %d, want %d", query, w.Code, http.StatusAccepted)
+		}
+		if w.Header().Get("Docker-Upload-UUID") == "" {
+			t.Errorf("%s: missing Docker-Upload-UUID header", query)
+		}
+	}
+}
+
+// initiateTestUpload starts an upload session and returns its Location.
+func initiateTestUpload(t *testing.T, router http.Handler, name string) string {
+	t.Helper()
+	req := httptest.NewRequest("POST", "/v2/"+name+"/blobs/uploads/", nil)
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+	if w.Code != http.StatusAccepted {
+		t.Fatalf("initiate: status = %d, want %d", w.Code, http.StatusAccepted)
+	}
+	return w.Header().Get("Location")
+}
+
+func TestBlobUploadStatus(t *testing.T) {
+	_, router := setupTestOCIHandler(t)
+	location := initiateTestUpload(t, router, "myrepo")
+
+	req := httptest.NewRequest("GET", location, nil)
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+
+	if w.Code != http.StatusNoContent {
+		t.Fatalf("status = %d, want %d", w.Code, http.StatusNoContent)
+	}
+	if w.Header().Get("Range") != "0-0" {
+		t.Errorf("Range = %q, want %q", w.Header().Get("Range"), "0-0")
+	}
+
+	req = httptest.NewRequest("PATCH", location, strings.NewReader("0123456789"))
+	w = httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+
+	req = httptest.NewRequest("GET", location, nil)
+	w = httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+
+	if w.Code != http.StatusNoContent {
+		t.Fatalf("status = %d, want %d", w.Code, http.StatusNoContent)
+	}
+	if w.Header().Get("Range") != "0-9" {
+		t.Errorf("Range = %q, want %q", w.Header().Get("Range"), "0-9")
+	}
+	if w.Header().Get("Docker-Upload-UUID") == "" {
+		t.Error("missing Docker-Upload-UUID header")
+	}
+	if w.Header().Get("Location") != location {
+		t.Errorf("Location = %q, want %q", w.Header().Get("Location"), location)
+	}
+}
+
+func TestBlobUploadStatusNotFound(t *testing.T) {
+	_, router := setupTestOCIHandler(t)
+
+	req := httptest.NewRequest("GET", "/v2/myrepo/blobs/uploads/nonexistent-uuid", nil)
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+
+	if w.Code != http.StatusNotFound {
+		t.Errorf("status = %d, want %d", w.Code, http.StatusNotFound)
+	}
+}
+
+func TestBlobUploadWrongRepository(t *testing.T) {
+	_, router := setupTestOCIHandler(t)
+	location := initiateTestUpload(t, router, "repo-b")
+	uuid := location[strings.LastIndex(location, "/")+1:]
+	other := "/v2/repo-a/blobs/uploads/" + uuid
+
+	blobData := []byte("smuggled layer")
+	digest := fmt.Sprintf("sha256:%x", sha256.Sum256(blobData))
+	requests := []struct {
+		method string
+		url    string
+		body   []byte
+	}{
+		{"GET", other, nil},
+		{"PATCH", other, blobData},
+		{"PUT", other + "?digest=" + digest, blobData},
+		{"DELETE", other, nil},
+	}
+	for _, tt := range requests {
+		req := httptest.NewRequest(tt.method, tt.url, bytes.NewReader(tt.body))
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, req)
+		if w.Code != http.StatusNotFound {
+			t.Errorf("%s through other repository: status = %d, want %d", tt.method, w.Code, http.StatusNotFound)
+		}
+		var errResp ociErrorResponse
+		json.NewDecoder(w.Body).Decode(&errResp)
+		if len(errResp.Errors) == 0 || errResp.Errors[0].Code != OCIErrorBlobUploadUnknown {
+			t.Errorf("%s through other repository: expected BLOB_UPLOAD_UNKNOWN error code", tt.method)
+		}
+	}
+
+	// The session is untouched and still usable from its own repository
+	req := httptest.NewRequest("GET", location, nil)
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+	if w.Code != http.StatusNoContent || w.Header().Get("Range") != "0-0" {
+		t.Errorf("status = %d, Range = %q, want %d and 0-0", w.Code, w.Header().Get("Range"), http.StatusNoContent)
+	}
+}
+
+func TestBlobUploadContentRange(t *testing.T) {
+	_, router := setupTestOCIHandler(t)
+	location := initiateTestUpload(t, router, "myrepo")
+
+	patch := func(body, contentRange string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest("PATCH", location, strings.NewReader(body))
+		req.Header.Set("Content-Range", contentRange)
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, req)
+		return w
+	}
+
+	if w := patch("hello", "0-4"); w.Code != http.StatusAccepted {
+		t.Fatalf("first chunk: status = %d, want %d", w.Code, http.StatusAccepted)
+	}
+
+	// Replaying or skipping ahead is rejected with the current offset
+	for _, contentRange := range []string{"0-5", "10-15"} {
+		w := patch(" world", contentRange)
+		if w.Code != http.StatusRequestedRangeNotSatisfiable {
+			t.Errorf("%s: status = %d, want %d", contentRange, w.Code, http.StatusRequestedRangeNotSatisfiable)
+		}
+		if w.Header().Get("Range") != "0-4" {
+			t.Errorf("%s: Range = %q, want %q", contentRange, w.Header().Get("Range"), "0-4")
+		}
+	}
+
+	if w := patch(" world", "bad"); w.Code != http.StatusBadRequest {
+		t.Errorf("malformed range: status = %d, want %d", w.Code, http.StatusBadRequest)
+	}
+	if w := patch(" world", "5-20"); w.Code != http.StatusBadRequest {
+		t.Errorf("length mismatch: status = %d, want %d", w.Code, http.StatusBadRequest)
+	}
+
+	w := patch(" world", "5-10")
+	if w.Code != http.StatusAccepted {
+		t.Fatalf("second chunk: status = %d, want %d", w.Code, http.StatusAccepted)
+	}
+	if w.Header().Get("Range") != "0-10" {
+		t.Errorf("Range = %q, want %q", w.Header().Get("Range"), "0-10")
+	}
+
+	// The upload is intact after the rejected chunks
+	digest := fmt.Sprintf("sha256:%x", sha256.Sum256([]byte("hello world")))
+	req := httptest.NewRequest("PUT", location+"?digest="+digest, nil)
+	w = httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+	if w.Code != http.StatusCreated {
+		t.Errorf("complete: status = %d, want %d, body = %s", w.Code, http.StatusCreated, w.Body.String())
+	}
+}
+
+func TestBlobUploadMaxChunkSize(t *testing.T) {
+	handler, router := setupTestOCIHandler(t)
+	handler.MaxChunkSize = 8
+	location := initiateTestUpload(t, router, "myrepo")
+
+	// Declared length over the limit
+	req := httptest.NewRequest("PATCH", location, strings.NewReader("0123456789"))
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+	if w.Code != http.StatusRequestEntityTooLarge {
+		t.Errorf("status = %d, want %d", w.Code, http.StatusRequestEntityTooLarge)
+	}
+
+	// Streamed body over the limit
+	req = httptest.NewRequest("PATCH", location, io.MultiReader(strings.NewReader("0123456789")))
+	req.ContentLength = -1
+	w = httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+	if w.Code != http.StatusRequestEntityTooLarge {
+		t.Errorf("streamed: status = %d, want %d", w.Code, http.StatusRequestEntityTooLarge)
+	}
+
+	// Rejected chunks leave nothing behind
+	req = httptest.NewRequest("GET", location, nil)
+	w = httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+	if w.Header().Get("Range") != "0-0" {
+		t.Errorf("Range = %q, want %q", w.Header().Get("Range"), "0-0")
+	}
+
+	req = httptest.NewRequest("PATCH", location, strings.NewReader("01234567"))
+	w = httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+	if w.Code != http.StatusAccepted {
+		t.Errorf("chunk at limit: status = %d, want %d", w.Code, http.StatusAccepted)
+	}
+}
+
+func TestBlobUploadQuotaExceeded(t *testing.T) {
+	handler, router := setupTestOCIHandler(t)
+	store, err := storage.NewLocalStorage(t.TempDir())
+	if err != nil {
+		t.Fatalf("failed to create storage: %v", err)
+	}
+	handler.Storage = oci.NewOCIStorage(store, oci.NewSessionManager(store, 30*time.Minute),
+		oci.WithQuotas(oci.Quota{Namespace: "team", Limit: 16}))
+
+	location := initiateTestUpload(t, router, "team/app")
+	req := httptest.NewRequest("PATCH", location, strings.NewReader("more than sixteen bytes"))
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+
+	if w.Code != http.StatusForbidden {
+		t.Fatalf("status = %d, want %d", w.Code, http.StatusForbidden)
+	}
+	var errResp ociErrorResponse
+	json.NewDecoder(w.Body).Decode(&errResp)
+	if len(errResp.Errors) == 0 || errResp.Errors[0].Code != OCIErrorDenied || !strings.Contains(errResp.Errors[0].Message, `namespace "team"`) {
+		t.Errorf("error = %+v, want %s naming the namespace", errResp, OCIErrorDenied)
+	}
+
+	// A chunk of declared size is refused before anything is written
+	req = httptest.NewRequest("GET", location, nil)
+	w = httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+	if w.Code != http.StatusNoContent || w.Header().Get("Range") != "0-0" {
+		t.Errorf("after refused chunk: status = %d, Range = %q", w.Code, w.Header().Get("Range"))
+	}
+
+	// A streamed chunk is cut off at the quota and abandons the upload
+	req = httptest.NewRequest("PATCH", location, io.MultiReader(strings.NewReader("more than sixteen bytes")))
+	req.ContentLength = -1
+	w = httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+	if w.Code != http.StatusForbidden {
+		t.Errorf("streamed: status = %d, want %d", w.Code, http.StatusForbidden)
+	}
+	req = httptest.NewRequest("GET", location, nil)
+	w = httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+	if w.Code != http.StatusNotFound {
+		t.Errorf("after streamed chunk: status = %d, want %d", w.Code, http.StatusNotFound)
+	}
+
+	// Uploads within the quota still succeed
+	pushTestBlob(t, router, "team/app", []byte("small"))
+}
+
+func TestBlobUploadMonolithicFailureCancelsSession(t *testing.T) {
+	handler, router := setupTestOCIHandler(t)
+	store, err := storage.NewLocalStorage(t.TempDir())
+	if err != nil {
+		t.Fatalf("failed to create storage: %v", err)
+	}
+	sessions := oci.NewSessionManager(store, 30*time.Minute)
+	handler.Storage = oci.NewOCIStorage(store, sessions, oci.WithQuotas(oci.Quota{Namespace: "team", Limit: 16}))
+
+	wrongDigest := "sha256:0000000000000000000000000000000000000000000000000000000000000000"
+	overQuota := []byte("more than sixteen bytes")
+	tests := []struct {
+		name       string
+		body       io.Reader
+		digest     string
+		wantStatus int
+	}{
+		{name: "digest mismatch", body: strings.NewReader("some data"), digest: wrongDigest, wantStatus: http.StatusBadRequest},
+		{name: "streamed over quota", body: io.MultiReader(bytes.NewReader(overQuota)), digest: fmt.Sprintf("sha256:%x", sha256.Sum256(overQuota)), wantStatus: http.StatusForbidden},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := httptest.NewRequest("POST", "/v2/team/app/blobs/uploads/?digest="+tt.digest, tt.body)
+			req.ContentLength = -1
+			w := httptest.NewRecorder()
+			router.ServeHTTP(w, req)
+
+			if w.Code != tt.wantStatus {
+				t.Fatalf("status = %d, want %d", w.Code, tt.wantStatus)
+			}
+			if active := sessions.Active(); active != 0 {
+				t.Errorf("active sessions = %d, want 0", active)
+			}
+			if uploads, _ := store.List(context.Background(), oci.UploadsDir()); len(uploads) != 0 {
+				t.Errorf("uploads = %v, want none", uploads)
+			}
+		})
+	}
+}
+
+func TestParseContentRange(t *testing.T) {
+	tests := []struct {
+		value     string
+		wantStart int64
+		wantEnd   int64
+		wantError bool
+	}{
+		{value: "0-99", wantStart: 0, wantEnd: 99},
+		{value: "bytes 100-199", wantStart: 100, wantEnd: 199},
+		{value: "5-4", wantError: true},
+		{value: "-5", wantError: true},
+		{value: "abc", wantError: true},
+		{value: "1-x", wantError: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.value, func(t *testing.T) {
+			start, end, err := parseContentRange(tt.value)
+			if tt.wantError {
+				if err == nil {
+					t.Error("expected error but got none")
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if start != tt.wantStart || end != tt.wantEnd {
+				t.Errorf("got %d-%d, want %d-%d", start, end, tt.wantStart, tt.wantEnd)
+			}
+		})
+	}
+}
+
+func TestBlobGetRange(t *testing.T) {
+	_, router := setupTestOCIHandler(t)
+	blobData := []byte("0123456789")
+	digest := pushTestBlob(t, router, "myrepo", blobData)
+	etag := fmt.Sprintf("%q", digest)
+
+	tests := []struct {
+		name             string
+		rangeHeader      string
+		ifRange          string
+		wantStatus       int
+		wantBody         string
+		wantContentRange string
+	}{
+		{name: "no range", wantStatus: http.StatusOK, wantBody: "0123456789"},
+		{name: "bounded range", rangeHeader: "bytes=2-5", wantStatus: http.StatusPartialContent, wantBody: "2345", wantContentRange: "bytes 2-5/10"},
+		{name: "open-ended range", rangeHeader: "bytes=7-", wantStatus: http.StatusPartialContent, wantBody: "789", wantContentRange: "bytes 7-9/10"},
+		{name: "suffix range", rangeHeader: "bytes=-3", wantStatus: http.StatusPartialContent, wantBody: "789", wantContentRange: "bytes 7-9/10"},
+		{name: "end past size is clamped", rangeHeader: "bytes=8-100", wantStatus: http.StatusPartialContent, wantBody: "89", wantContentRange: "bytes 8-9/10"},
+		{name: "unsatisfiable range", rangeHeader: "bytes=10-", wantStatus: http.StatusRequestedRangeNotSatisfiable, wantContentRange: "bytes */10"},
+		{name: "multiple ranges serve full blob", rangeHeader: "bytes=0-1,4-5", wantStatus: http.StatusOK, wantBody: "0123456789"},
+		{name: "matching If-Range", rangeHeader: "bytes=0-0", ifRange: etag, wantStatus: http.StatusPartialContent, wantBody: "0", wantContentRange: "bytes 0-0/10"},
+		{name: "stale If-Range serves full blob", rangeHeader: "bytes=0-0", ifRange: `"sha256:stale"`, wantStatus: http.StatusOK, wantBody: "0123456789"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := httptest.NewRequest("GET", fmt.Sprintf("/v2/myrepo/blobs/%s", digest), nil)
+			if tt.rangeHeader != "" {
+				req.Header.Set("Range", tt.rangeHeader)
+			}
+			if tt.ifRange != "" {
+				req.Header.Set("If-Range", tt.ifRange)
+			}
+			w := httptest.NewRecorder()
+			router.ServeHTTP(w, req)
+
+			if w.Code != tt.wantStatus {
+				t.Fatalf("status = %d, want %d", w.Code, tt.wantStatus)
+			}
+			if w.Header().Get("Content-Range") != tt.wantContentRange {
+				t.Errorf("Content-Range = %q, want %q", w.Header().Get("Content-Range"), tt.wantContentRange)
+			}
+			if w.Header().Get("Accept-Ranges") != "bytes" {
+				t.Errorf("Accept-Ranges = %q, want %q", w.Header().Get("Accept-Ranges"), "bytes")
+			}
+			if tt.wantStatus == http.StatusRequestedRangeNotSatisfiable {
+				return
+			}
+			if w.Body.String() != tt.wantBody {
+				t.Errorf("body = %q, want %q", w.Body.String(), tt.wantBody)
+			}
+			if w.Header().Get("Content-Length") != fmt.Sprint(len(tt.wantBody)) {
+				t.Errorf("Content-Length = %q, want %d", w.Header().Get("Content-Length"), len(tt.wantBody))
+			}
+		})
+	}
+}
+
+func TestBlobRepositoryIsolation(t *testing.T) {
+	_, router := setupTestOCIHandler(t)
+	digest := pushTestBlob(t, router, "repo-a", []byte("private layer"))
+
+	for _, method := range []string{"HEAD", "GET"} {
+		req := httptest.NewRequest(method, fmt.Sprintf("/v2/repo-b/blobs/%s", digest), nil)
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, req)
+		if w.Code != http.StatusNotFound {
+			t.Errorf("%s through other repository: status = %d, want %d", method, w.Code, http.StatusNotFound)
+		}
+	}
+
+	// Mounting from a repository that does not hold the blob starts a normal upload
+	req := httptest.NewRequest("POST", fmt.Sprintf("/v2/repo-b/blobs/uploads/?mount=%s&from=repo-c", digest), nil)
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+	if w.Code != http.StatusAccepted {
+		t.Errorf("mount from wrong repository: status = %d, want %d", w.Code, http.StatusAccepted)
+	}
+}
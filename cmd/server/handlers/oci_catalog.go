@@ -0,0 +1,49 @@
+package handlers
+
+import (
+	"net/http"
+)
+
+// catalogResponse is the OCI catalog response.
+type catalogResponse struct {
+	Repositories []string `json:"repositories"`
+}
+
+// Catalog handles GET /v2/_catalog — list repositories.
+func (h *OCIHandler) Catalog(w http.ResponseWriter, r *http.Request) {
+	setOCIHeaders(w)
+	ctx := r.Context()
+
+	n, last, err := parsePagination(r)
+	if err != nil {
+		respondOCIError(w, http.StatusBadRequest, OCIErrorPaginationInvalid, err.Error())
+		return
+	}
+
+	repositories, err := h.Storage.ListRepositories(ctx)
+	if err != nil {
+		h.Logger.Error(ctx, "failed to list repositories", map[string]interface{}{"error": err.Error()})
+		respondOCIError(w, http.StatusInternalServerError, OCIErrorNameUnknown, "failed to list repositories")
+		return
+	}
+
+	// Only list repositories the user can pull
+	if h.Authorizer != nil {
+		visible := []string{}
+		for _, name := range repositories {
+			if h.canPull(ctx, name) {
+				visible = append(visible, name)
+			}
+		}
+		repositories = visible
+	}
+
+	page, more := paginate(repositories, n, last)
+	if more && len(page) > 0 {
+		setNextLink(w, r, n, page[len(page)-1])
+	}
+
+	respondJSON(w, http.StatusOK, catalogResponse{
+		Repositories: page,
+	})
+}
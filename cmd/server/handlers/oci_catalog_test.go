@@ -0,0 +1,168 @@
+package handlers
+
+import (
+	"bytes"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"testing"
+
+	"github.com/hairizuanbinnoorazman/package-universe/auth"
+)
+
+func TestCatalog(t *testing.T) {
+	_, router := setupTestOCIHandler(t)
+
+	for _, name := range []string{"team/app", "team/app/api", "alpine", "library/nginx"} {
+		pushTestManifest(t, router, name, "latest", newTestManifest(t, router, name, name))
+	}
+
+	req := httptest.NewRequest("GET", "/v2/_catalog", nil)
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+
+	if w.Code != http.StatusOK {
+		t.Fatalf("status = %d, want %d", w.Code, http.StatusOK)
+	}
+	if w.Header().Get("Link") != "" {
+		t.Errorf("unexpected Link header: %q", w.Header().Get("Link"))
+	}
+
+	var resp catalogResponse
+	if err := json.NewDecoder(w.Body).Decode(&resp); err != nil {
+		t.Fatalf("failed to decode response: %v", err)
+	}
+	want := []string{"alpine", "library/nginx", "team/app", "team/app/api"}
+	if !reflect.DeepEqual(resp.Repositories, want) {
+		t.Errorf("repositories = %v, want %v", resp.Repositories, want)
+	}
+}
+
+func TestCatalogPagination(t *testing.T) {
+	_, router := setupTestOCIHandler(t)
+
+	for _, name := range []string{"a", "b", "c", "d", "e"} {
+		pushTestManifest(t, router, name, "latest", newTestManifest(t, router, name, name))
+	}
+
+	var all []string
+	url := "/v2/_catalog?n=2"
+	for url != "" {
+		req := httptest.NewRequest("GET", url, nil)
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, req)
+		if w.Code != http.StatusOK {
+			t.Fatalf("GET %s: status = %d", url, w.Code)
+		}
+
+		var resp catalogResponse
+		json.NewDecoder(w.Body).Decode(&resp)
+		if len(resp.Repositories) > 2 {
+			t.Fatalf("page larger than n: %v", resp.Repositories)
+		}
+		all = append(all, resp.Repositories...)
+
+		url = ""
+		if link := w.Header().Get("Link"); link != "" {
+			if !bytes.HasSuffix([]byte(link), []byte(`>; rel="next"`)) || link[0] != '<' {
+				t.Fatalf("malformed Link header: %q", link)
+			}
+			url = link[1 : len(link)-len(`>; rel="next"`)]
+		}
+	}
+
+	want := []string{"a", "b", "c", "d", "e"}
+	if !reflect.DeepEqual(all, want) {
+		t.Errorf("paginated repositories = %v, want %v", all, want)
+	}
+}
+
+func TestCatalogLast(t *testing.T) {
+	_, router := setupTestOCIHandler(t)
+
+	for _, name := range []string{"a", "b", "c"} {
+		pushTestManifest(t, router, name, "latest", newTestManifest(t, router, name, name))
+	}
+
+	req := httptest.NewRequest("GET", "/v2/_catalog?last=a", nil)
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+
+	var resp catalogResponse
+	json.NewDecoder(w.Body).Decode(&resp)
+	want := []string{"b", "c"}
+	if !reflect.DeepEqual(resp.Repositories, want) {
+		t.Errorf("repositories = %v, want %v", resp.Repositories, want)
+	}
+}
+
+func TestCatalogEmpty(t *testing.T) {
+	_, router := setupTestOCIHandler(t)
+
+	req := httptest.NewRequest("GET", "/v2/_catalog", nil)
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+
+	if w.Code != http.StatusOK {
+		t.Fatalf("status = %d, want %d", w.Code, http.StatusOK)
+	}
+
+	var resp catalogResponse
+	json.NewDecoder(w.Body).Decode(&resp)
+	if resp.Repositories == nil || len(resp.Repositories) != 0 {
+		t.Errorf("expected empty repositories list, got %v", resp.Repositories)
+	}
+}
+
+func TestCatalogInvalidN(t *testing.T) {
+	_, router := setupTestOCIHandler(t)
+
+	req := httptest.NewRequest("GET", "/v2/_catalog?n=abc", nil)
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+
+	if w.Code != http.StatusBadRequest {
+		t.Errorf("status = %d, want %d", w.Code, http.StatusBadRequest)
+	}
+}
+
+func TestCatalogFiltered(t *testing.T) {
+	handler, router := setupTestOCIHandler(t)
+	for _, name := range []string{"apps/web", "platform/base", "platform/tools", "secret/keys"} {
+		pushTestManifest(t, router, name, "latest", newTestManifest(t, router, name, name))
+	}
+
+	enforceTestPolicy(t, handler, router, auth.PolicyDocument{
+		Grants: []auth.Grant{
+			{Users: []string{"alice"}, Role: "reader", Repositories: []string{"platform/*"}},
+			{Users: []string{"alice"}, Role: "writer", Repositories: []string{"apps/*"}},
+		},
+	})
+
+	req := httptest.NewRequest("GET", "/v2/_catalog?n=2", nil)
+	req.Header.Set("X-Test-User", "alice")
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+
+	if w.Code != http.StatusOK {
+		t.Fatalf("status = %d, want %d", w.Code, http.StatusOK)
+	}
+	var resp catalogResponse
+	if err := json.NewDecoder(w.Body).Decode(&resp); err != nil {
+		t.Fatalf("failed to decode response: %v", err)
+	}
+	if want := []string{"apps/web", "platform/base"}; !reflect.DeepEqual(resp.Repositories, want) {
+		t.Errorf("repositories = %v, want %v", resp.Repositories, want)
+	}
+
+	req = httptest.NewRequest("GET", "/v2/_catalog?n=2&last=platform/base", nil)
+	req.Header.Set("X-Test-User", "alice")
+	w = httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+	resp = catalogResponse{}
+	json.NewDecoder(w.Body).Decode(&resp)
+	if want := []string{"platform/tools"}; !reflect.DeepEqual(resp.Repositories, want) {
+		t.Errorf("second page = %v, want %v", resp.Repositories, want)
+	}
+}
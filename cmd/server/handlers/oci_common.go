@@ -2,10 +2,17 @@ package handlers
 
 import (
 	"encoding/json"
+	"fmt"
 	"net/http"
+	"net/url"
+	"sort"
+	"strconv"
 
+	"github.com/hairizuanbinnoorazman/package-universe/auth"
 	"github.com/hairizuanbinnoorazman/package-universe/logger"
+	"github.com/hairizuanbinnoorazman/package-universe/notifications"
 	"github.com/hairizuanbinnoorazman/package-universe/oci"
+	"github.com/hairizuanbinnoorazman/package-universe/replication"
 )
 
 // OCI error codes per the distribution spec.
@@ -13,12 +20,14 @@ const (
 	OCIErrorBlobUnknown         = "BLOB_UNKNOWN"
 	OCIErrorBlobUploadInvalid   = "BLOB_UPLOAD_INVALID"
 	OCIErrorBlobUploadUnknown   = "BLOB_UPLOAD_UNKNOWN"
+	OCIErrorDenied              = "DENIED"
 	OCIErrorDigestInvalid       = "DIGEST_INVALID"
 	OCIErrorManifestBlobUnknown = "MANIFEST_BLOB_UNKNOWN"
 	OCIErrorManifestInvalid     = "MANIFEST_INVALID"
 	OCIErrorManifestUnknown     = "MANIFEST_UNKNOWN"
 	OCIErrorNameInvalid         = "NAME_INVALID"
 	OCIErrorNameUnknown         = "NAME_UNKNOWN"
+	OCIErrorPaginationInvalid   = "PAGINATION_NUMBER_INVALID"
 	OCIErrorSizeInvalid         = "SIZE_INVALID"
 	OCIErrorUnauthorized        = "UNAUTHORIZED"
 	OCIErrorUnsupported         = "UNSUPPORTED"
@@ -28,6 +37,27 @@ const (
 type OCIHandler struct {
 	Storage *oci.OCIStorage
 	Logger  logger.Logger
+
+	// DeleteEnabled allows manifest and blob deletion via the DELETE endpoints.
+	DeleteEnabled bool
+
+	// MaxManifestSize is the largest manifest body accepted on push; 0 means unlimited.
+	MaxManifestSize int64
+
+	// MaxChunkSize is the largest body accepted for a single upload chunk; 0 means unlimited.
+	MaxChunkSize int64
+
+	// Replicator, when set, queues every pushed manifest for replication.
+	Replicator *replication.Replicator
+
+	// Authorizer, when set, is checked on every request by EnforceAccess and
+	// limits the catalog and automatic blob mounts to repositories the user
+	// can pull.
+	Authorizer auth.Authorizer
+
+	// Notifier, when set, receives an event for every manifest push, pull and
+	// delete and every blob delete.
+	Notifier *notifications.Notifier
 }
 
 // ociError represents a single OCI error in the response.
@@ -56,3 +86,43 @@ func respondOCIError(w http.ResponseWriter, status int, code, message string) {
 func setOCIHeaders(w http.ResponseWriter) {
 	w.Header().Set("Docker-Distribution-API-Version", "registry/2.0")
 }
+
+// parsePagination reads the n and last query parameters used by list endpoints.
+// n is -1 when the client did not request a page size.
+func parsePagination(r *http.Request) (int, string, error) {
+	query := r.URL.Query()
+	last := query.Get("last")
+
+	nStr := query.Get("n")
+	if nStr == "" {
+		return -1, last, nil
+	}
+	n, err := strconv.Atoi(nStr)
+	if err != nil || n < 0 {
+		return 0, "", fmt.Errorf("invalid page size %q", nStr)
+	}
+	return n, last, nil
+}
+
+// paginate returns the entries of a sorted list that come after last, limited
+// to n entries when n is not -1, and reports whether more entries remain.
+func paginate(entries []string, n int, last string) ([]string, bool) {
+	start := 0
+	if last != "" {
+		start = sort.Search(len(entries), func(i int) bool { return entries[i] > last })
+	}
+	page := entries[start:]
+
+	if n < 0 || len(page) <= n {
+		return page, false
+	}
+	return page[:n], true
+}
+
+// setNextLink sets an RFC 5988 Link header pointing at the page after last.
+func setNextLink(w http.ResponseWriter, r *http.Request, n int, last string) {
+	query := url.Values{}
+	query.Set("n", strconv.Itoa(n))
+	query.Set("last", last)
+	w.Header().Set("Link", fmt.Sprintf("<%s?%s>; rel=\"next\"", r.URL.Path, query.Encode()))
+}
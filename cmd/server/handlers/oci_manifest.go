@@ -7,6 +7,7 @@ import (
 	"strconv"
 
 	"github.com/gorilla/mux"
+	"github.com/hairizuanbinnoorazman/package-universe/notifications"
 	"github.com/hairizuanbinnoorazman/package-universe/oci"
 )
 
@@ -61,6 +62,8 @@ func (h *OCIHandler) GetManifest(w http.ResponseWriter, r *http.Request) {
 	w.Header().Set("Docker-Content-Digest", digest.String())
 	w.WriteHeader(http.StatusOK)
 	w.Write(data)
+
+	h.notify(r, notifications.ActionPull, "manifests", manifestTarget(name, reference, contentType, digest, len(data)))
 }
 
 // PutManifest handles PUT /v2/{name}/manifests/{reference} — upload manifest.
@@ -73,12 +76,23 @@ func (h *OCIHandler) PutManifest(w http.ResponseWriter, r *http.Request) {
 	reference := vars["reference"]
 
 	contentType := r.Header.Get("Content-Type")
-	if contentType == "" {
-		contentType = "application/vnd.oci.image.manifest.v1+json"
+
+	if h.MaxManifestSize > 0 && r.ContentLength > h.MaxManifestSize {
+		respondOCIError(w, http.StatusRequestEntityTooLarge, OCIErrorSizeInvalid, "manifest exceeds maximum size")
+		return
 	}
 
-	data, err := io.ReadAll(r.Body)
+	body := r.Body
+	if h.MaxManifestSize > 0 {
+		body = http.MaxBytesReader(w, r.Body, h.MaxManifestSize)
+	}
+	data, err := io.ReadAll(body)
 	if err != nil {
+		var maxBytesErr *http.MaxBytesError
+		if errors.As(err, &maxBytesErr) {
+			respondOCIError(w, http.StatusRequestEntityTooLarge, OCIErrorSizeInvalid, "manifest exceeds maximum size")
+			return
+		}
 		h.Logger.Error(ctx, "failed to read manifest body", map[string]interface{}{"error": err.Error()})
 		respondOCIError(w, http.StatusBadRequest, OCIErrorManifestInvalid, "failed to read manifest")
 		return
@@ -86,12 +100,99 @@ func (h *OCIHandler) PutManifest(w http.ResponseWriter, r *http.Request) {
 
 	digest, err := h.Storage.PutManifest(ctx, name, reference, contentType, data)
 	if err != nil {
-		h.Logger.Error(ctx, "failed to put manifest", map[string]interface{}{"error": err.Error()})
-		respondOCIError(w, http.StatusInternalServerError, OCIErrorManifestInvalid, "failed to store manifest")
+		switch {
+		case errors.Is(err, oci.ErrManifestInvalid):
+			respondOCIError(w, http.StatusBadRequest, OCIErrorManifestInvalid, err.Error())
+		case errors.Is(err, oci.ErrManifestBlobUnknown):
+			respondOCIError(w, http.StatusBadRequest, OCIErrorManifestBlobUnknown, err.Error())
+		case errors.Is(err, oci.ErrDigestMismatch), errors.Is(err, oci.ErrInvalidDigest):
+			respondOCIError(w, http.StatusBadRequest, OCIErrorDigestInvalid, err.Error())
+		case errors.Is(err, oci.ErrTagImmutable), errors.Is(err, oci.ErrQuotaExceeded):
+			respondOCIError(w, http.StatusForbidden, OCIErrorDenied, err.Error())
+		default:
+			h.Logger.Error(ctx, "failed to put manifest", map[string]interface{}{"error": err.Error()})
+			respondOCIError(w, http.StatusInternalServerError, OCIErrorManifestInvalid, "failed to store manifest")
+		}
 		return
 	}
 
+	if h.Replicator != nil {
+		if err := h.Replicator.Enqueue(ctx, name, reference); err != nil {
+			h.Logger.Error(ctx, "failed to queue replication", map[string]interface{}{"error": err.Error()})
+		}
+	}
+
+	// Advertise the subject so clients know the referrers API indexed this manifest
+	if m, err := oci.ParseManifest(data); err == nil {
+		if subject, ok := m.SubjectDigest(); ok {
+			w.Header().Set("OCI-Subject", subject.String())
+		}
+		if mediaType, err := m.ResolveMediaType(contentType); err == nil {
+			contentType = mediaType
+		}
+	}
+
 	w.Header().Set("Location", "/v2/"+name+"/manifests/"+digest.String())
 	w.Header().Set("Docker-Content-Digest", digest.String())
 	w.WriteHeader(http.StatusCreated)
+
+	h.notify(r, notifications.ActionPush, "manifests", manifestTarget(name, reference, contentType, digest, len(data)))
+}
+
+// DeleteManifest handles DELETE /v2/{name}/manifests/{reference} — delete manifest or untag.
+func (h *OCIHandler) DeleteManifest(w http.ResponseWriter, r *http.Request) {
+	setOCIHeaders(w)
+	ctx := r.Context()
+
+	if !h.DeleteEnabled {
+		respondOCIError(w, http.StatusMethodNotAllowed, OCIErrorUnsupported, "deletion is disabled")
+		return
+	}
+
+	vars := mux.Vars(r)
+	name := vars["name"]
+	reference := vars["reference"]
+
+	err := h.Storage.DeleteManifest(ctx, name, reference)
+	if err != nil {
+		if errors.Is(err, oci.ErrManifestNotFound) {
+			respondOCIError(w, http.StatusNotFound, OCIErrorManifestUnknown, "manifest not found")
+			return
+		}
+		if errors.Is(err, oci.ErrInvalidDigest) {
+			respondOCIError(w, http.StatusBadRequest, OCIErrorDigestInvalid, "invalid digest format")
+			return
+		}
+		if errors.Is(err, oci.ErrTagImmutable) {
+			respondOCIError(w, http.StatusForbidden, OCIErrorDenied, err.Error())
+			return
+		}
+		h.Logger.Error(ctx, "failed to delete manifest", map[string]interface{}{"error": err.Error()})
+		respondOCIError(w, http.StatusInternalServerError, OCIErrorManifestUnknown, "failed to delete manifest")
+		return
+	}
+
+	w.WriteHeader(http.StatusAccepted)
+
+	target := notifications.Target{Repository: name, Tag: reference}
+	if digest, err := oci.ParseDigest(reference); err == nil {
+		target = notifications.Target{Repository: name, Digest: digest.String()}
+	}
+	h.notify(r, notifications.ActionDelete, "manifests", target)
+}
+
+// manifestTarget describes a manifest for a push or pull event. The tag is
+// set when the manifest was addressed by tag rather than digest.
+func manifestTarget(name, reference, mediaType string, digest oci.DigestInfo, size int) notifications.Target {
+	target := notifications.Target{
+		MediaType:  mediaType,
+		Size:       int64(size),
+		Length:     int64(size),
+		Digest:     digest.String(),
+		Repository: name,
+	}
+	if _, err := oci.ParseDigest(reference); err != nil {
+		target.Tag = reference
+	}
+	return target
 }
@@ -2,17 +2,60 @@ package handlers
 
 import (
 	"bytes"
+	"context"
 	"crypto/sha256"
+	"encoding/json"
 	"fmt"
 	"net/http"
 	"net/http/httptest"
+	"sync"
 	"testing"
+	"time"
+
+	"github.com/hairizuanbinnoorazman/package-universe/logger"
+	"github.com/hairizuanbinnoorazman/package-universe/notifications"
+	"github.com/hairizuanbinnoorazman/package-universe/oci"
+	"github.com/hairizuanbinnoorazman/package-universe/replication"
+	"github.com/hairizuanbinnoorazman/package-universe/storage"
 )
 
+// pushTestBlob uploads data to the repository with a monolithic upload and returns its digest.
+func pushTestBlob(t *testing.T, router http.Handler, name string, data []byte) string {
+	t.Helper()
+	digest := fmt.Sprintf("sha256:%x", sha256.Sum256(data))
+	req := httptest.NewRequest("POST", fmt.Sprintf("/v2/%s/blobs/uploads/?digest=%s", name, digest), bytes.NewReader(data))
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+	if w.Code != http.StatusCreated {
+		t.Fatalf("blob upload to %s: status = %d, body = %s", name, w.Code, w.Body.String())
+	}
+	return digest
+}
+
+// newTestManifest uploads a config blob derived from seed and returns an image
+// manifest referencing it. Different seeds produce different manifests.
+func newTestManifest(t *testing.T, router http.Handler, name, seed string) []byte {
+	t.Helper()
+	config := []byte(fmt.Sprintf(`{"seed":%q}`, seed))
+	digest := pushTestBlob(t, router, name, config)
+	return []byte(fmt.Sprintf(`{"schemaVersion":2,"mediaType":"application/vnd.oci.image.manifest.v1+json","config":{"mediaType":"application/vnd.oci.image.config.v1+json","digest":"%s","size":%d},"layers":[]}`, digest, len(config)))
+}
+
+func pushTestManifest(t *testing.T, router http.Handler, name, reference string, manifest []byte) {
+	t.Helper()
+	req := httptest.NewRequest("PUT", "/v2/"+name+"/manifests/"+reference, bytes.NewReader(manifest))
+	req.Header.Set("Content-Type", "application/vnd.oci.image.manifest.v1+json")
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+	if w.Code != http.StatusCreated {
+		t.Fatalf("PUT %s:%s: status = %d, body = %s", name, reference, w.Code, w.Body.String())
+	}
+}
+
 func TestManifestPushPull(t *testing.T) {
 	_, router := setupTestOCIHandler(t)
 
-	manifestData := []byte(`{"schemaVersion":2,"mediaType":"application/vnd.oci.image.manifest.v1+json","config":{},"layers":[]}`)
+	manifestData := newTestManifest(t, router, "myrepo/myimage", "push-pull")
 	contentType := "application/vnd.oci.image.manifest.v1+json"
 	expectedDigest := fmt.Sprintf("sha256:%x", sha256.Sum256(manifestData))
 
@@ -102,8 +145,8 @@ func TestHeadManifestNotFound(t *testing.T) {
 func TestManifestMultipleTags(t *testing.T) {
 	_, router := setupTestOCIHandler(t)
 
-	manifest1 := []byte(`{"schemaVersion":2,"tag":"v1"}`)
-	manifest2 := []byte(`{"schemaVersion":2,"tag":"v2"}`)
+	manifest1 := newTestManifest(t, router, "myrepo", "v1")
+	manifest2 := newTestManifest(t, router, "myrepo", "v2")
 	ct := "application/vnd.oci.image.manifest.v1+json"
 
 	// Push v1
@@ -140,3 +183,318 @@ func TestManifestMultipleTags(t *testing.T) {
 		t.Error("v2 manifest data mismatch")
 	}
 }
+
+func TestManifestDelete(t *testing.T) {
+	_, router := setupTestOCIHandler(t)
+
+	manifestData := newTestManifest(t, router, "myrepo", "delete")
+	ct := "application/vnd.oci.image.manifest.v1+json"
+	digest := fmt.Sprintf("sha256:%x", sha256.Sum256(manifestData))
+
+	for _, tag := range []string{"v1.0", "latest"} {
+		req := httptest.NewRequest("PUT", "/v2/myrepo/manifests/"+tag, bytes.NewReader(manifestData))
+		req.Header.Set("Content-Type", ct)
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, req)
+		if w.Code != http.StatusCreated {
+			t.Fatalf("PUT %s: status = %d", tag, w.Code)
+		}
+	}
+
+	// Delete by tag only unlinks the tag
+	req := httptest.NewRequest("DELETE", "/v2/myrepo/manifests/v1.0", nil)
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+	if w.Code != http.StatusAccepted {
+		t.Fatalf("DELETE tag: status = %d, want %d", w.Code, http.StatusAccepted)
+	}
+
+	req = httptest.NewRequest("GET", "/v2/myrepo/manifests/v1.0", nil)
+	w = httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+	if w.Code != http.StatusNotFound {
+		t.Errorf("GET deleted tag: status = %d, want %d", w.Code, http.StatusNotFound)
+	}
+
+	req = httptest.NewRequest("GET", "/v2/myrepo/manifests/latest", nil)
+	w = httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+	if w.Code != http.StatusOK {
+		t.Errorf("GET remaining tag: status = %d, want %d", w.Code, http.StatusOK)
+	}
+
+	// Delete by digest removes the manifest and its remaining tags
+	req = httptest.NewRequest("DELETE", "/v2/myrepo/manifests/"+digest, nil)
+	w = httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+	if w.Code != http.StatusAccepted {
+		t.Fatalf("DELETE digest: status = %d, want %d", w.Code, http.StatusAccepted)
+	}
+
+	for _, ref := range []string{digest, "latest"} {
+		req = httptest.NewRequest("GET", "/v2/myrepo/manifests/"+ref, nil)
+		w = httptest.NewRecorder()
+		router.ServeHTTP(w, req)
+		if w.Code != http.StatusNotFound {
+			t.Errorf("GET %s after delete: status = %d, want %d", ref, w.Code, http.StatusNotFound)
+		}
+	}
+
+	// Deleting again reports MANIFEST_UNKNOWN
+	req = httptest.NewRequest("DELETE", "/v2/myrepo/manifests/"+digest, nil)
+	w = httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+	if w.Code != http.StatusNotFound {
+		t.Errorf("second DELETE: status = %d, want %d", w.Code, http.StatusNotFound)
+	}
+	var errResp ociErrorResponse
+	json.NewDecoder(w.Body).Decode(&errResp)
+	if len(errResp.Errors) == 0 || errResp.Errors[0].Code != OCIErrorManifestUnknown {
+		t.Errorf("expected MANIFEST_UNKNOWN error code")
+	}
+}
+
+func TestManifestDeleteDisabled(t *testing.T) {
+	handler, router := setupTestOCIHandler(t)
+	handler.DeleteEnabled = false
+
+	req := httptest.NewRequest("DELETE", "/v2/myrepo/manifests/latest", nil)
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+
+	if w.Code != http.StatusMethodNotAllowed {
+		t.Errorf("status = %d, want %d", w.Code, http.StatusMethodNotAllowed)
+	}
+	var errResp ociErrorResponse
+	json.NewDecoder(w.Body).Decode(&errResp)
+	if len(errResp.Errors) == 0 || errResp.Errors[0].Code != OCIErrorUnsupported {
+		t.Errorf("expected UNSUPPORTED error code")
+	}
+}
+
+func TestManifestPutValidation(t *testing.T) {
+	handler, router := setupTestOCIHandler(t)
+	handler.MaxManifestSize = 1024
+
+	ct := "application/vnd.oci.image.manifest.v1+json"
+	missingLayer := fmt.Sprintf("sha256:%x", sha256.Sum256([]byte("missing")))
+	configDigest := pushTestBlob(t, router, "myrepo", []byte("{}"))
+
+	valid := newTestManifest(t, router, "myrepo", "valid")
+	wrongDigest := fmt.Sprintf("sha256:%x", sha256.Sum256([]byte("other")))
+
+	tests := []struct {
+		name       string
+		reference  string
+		body       []byte
+		wantStatus int
+		wantCode   string
+	}{
+		{
+			name:       "malformed JSON",
+			reference:  "latest",
+			body:       []byte(`{"schemaVersion":2,`),
+			wantStatus: http.StatusBadRequest,
+			wantCode:   OCIErrorManifestInvalid,
+		},
+		{
+			name:       "missing config",
+			reference:  "latest",
+			body:       []byte(`{"schemaVersion":2,"layers":[]}`),
+			wantStatus: http.StatusBadRequest,
+			wantCode:   OCIErrorManifestInvalid,
+		},
+		{
+			name:       "unknown layer blob",
+			reference:  "latest",
+			body:       []byte(fmt.Sprintf(`{"schemaVersion":2,"config":{"mediaType":"x","digest":"%s","size":2},"layers":[{"mediaType":"y","digest":"%s","size":7}]}`, configDigest, missingLayer)),
+			wantStatus: http.StatusBadRequest,
+			wantCode:   OCIErrorManifestBlobUnknown,
+		},
+		{
+			name:       "digest reference mismatch",
+			reference:  wrongDigest,
+			body:       valid,
+			wantStatus: http.StatusBadRequest,
+			wantCode:   OCIErrorDigestInvalid,
+		},
+		{
+			name:       "oversized manifest",
+			reference:  "latest",
+			body:       bytes.Repeat([]byte(" "), 2048),
+			wantStatus: http.StatusRequestEntityTooLarge,
+			wantCode:   OCIErrorSizeInvalid,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := httptest.NewRequest("PUT", "/v2/myrepo/manifests/"+tt.reference, bytes.NewReader(tt.body))
+			req.Header.Set("Content-Type", ct)
+			w := httptest.NewRecorder()
+			router.ServeHTTP(w, req)
+
+			if w.Code != tt.wantStatus {
+				t.Fatalf("status = %d, want %d, body = %s", w.Code, tt.wantStatus, w.Body.String())
+			}
+			var errResp ociErrorResponse
+			json.NewDecoder(w.Body).Decode(&errResp)
+			if len(errResp.Errors) == 0 || errResp.Errors[0].Code != tt.wantCode {
+				t.Errorf("expected %s error code, got %+v", tt.wantCode, errResp.Errors)
+			}
+		})
+	}
+
+	// The valid manifest is accepted when pushed by its own digest
+	digest := fmt.Sprintf("sha256:%x", sha256.Sum256(valid))
+	req := httptest.NewRequest("PUT", "/v2/myrepo/manifests/"+digest, bytes.NewReader(valid))
+	req.Header.Set("Content-Type", ct)
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+	if w.Code != http.StatusCreated {
+		t.Errorf("valid PUT by digest: status = %d, body = %s", w.Code, w.Body.String())
+	}
+}
+
+func TestManifestPutImmutableTag(t *testing.T) {
+	handler, router := setupTestOCIHandler(t)
+	store, err := storage.NewLocalStorage(t.TempDir())
+	if err != nil {
+		t.Fatalf("failed to create storage: %v", err)
+	}
+	rule, _ := oci.NewImmutableTagRule("", `^v\d+\.\d+\.\d+$`)
+	handler.Storage = oci.NewOCIStorage(store, oci.NewSessionManager(store, 30*time.Minute), oci.WithImmutableTags(rule))
+
+	first := newTestManifest(t, router, "myrepo", "first")
+	second := newTestManifest(t, router, "myrepo", "second")
+	pushTestManifest(t, router, "myrepo", "v1.0.0", first)
+	pushTestManifest(t, router, "myrepo", "v1.0.0", first)
+
+	req := httptest.NewRequest("PUT", "/v2/myrepo/manifests/v1.0.0", bytes.NewReader(second))
+	req.Header.Set("Content-Type", "application/vnd.oci.image.manifest.v1+json")
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+
+	if w.Code != http.StatusForbidden {
+		t.Fatalf("status = %d, want %d, body = %s", w.Code, http.StatusForbidden, w.Body.String())
+	}
+	var errResp ociErrorResponse
+	json.NewDecoder(w.Body).Decode(&errResp)
+	if len(errResp.Errors) == 0 || errResp.Errors[0].Code != OCIErrorDenied {
+		t.Errorf("error = %+v, want %s", errResp, OCIErrorDenied)
+	}
+
+	pushTestManifest(t, router, "myrepo", "latest", first)
+	pushTestManifest(t, router, "myrepo", "latest", second)
+
+	// Deleting the tag to push it again is denied too
+	req = httptest.NewRequest("DELETE", "/v2/myrepo/manifests/v1.0.0", nil)
+	w = httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+	if w.Code != http.StatusForbidden {
+		t.Fatalf("DELETE: status = %d, want %d", w.Code, http.StatusForbidden)
+	}
+
+	req = httptest.NewRequest("PUT", "/v2/myrepo/manifests/v1.0.0", bytes.NewReader(second))
+	req.Header.Set("Content-Type", "application/vnd.oci.image.manifest.v1+json")
+	w = httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+	if w.Code != http.StatusForbidden {
+		t.Errorf("re-push after DELETE: status = %d, want %d", w.Code, http.StatusForbidden)
+	}
+}
+
+func TestManifestPutQueuesReplication(t *testing.T) {
+	handler, router := setupTestOCIHandler(t)
+	store, err := storage.NewLocalStorage(t.TempDir())
+	if err != nil {
+		t.Fatalf("failed to create storage: %v", err)
+	}
+	target, _ := replication.NewTarget("dr", oci.NewClient("http://127.0.0.1:0", "", "", 0), []string{"apps/*"})
+	handler.Replicator = replication.NewReplicator(handler.Storage, store, logger.NewTestLogger(), []replication.Target{target}, replication.Options{})
+
+	pushTestManifest(t, router, "apps/web", "v1", newTestManifest(t, router, "apps/web", "replicated"))
+	pushTestManifest(t, router, "tools/lint", "v1", newTestManifest(t, router, "tools/lint", "skipped"))
+
+	statuses, err := handler.Replicator.Status(context.Background())
+	if err != nil {
+		t.Fatalf("Status failed: %v", err)
+	}
+	if len(statuses) != 1 || statuses[0].Pending != 1 {
+		t.Errorf("statuses = %+v, want one pending replication", statuses)
+	}
+}
+
+func TestManifestNotifications(t *testing.T) {
+	handler, router := setupTestOCIHandler(t)
+
+	var mu sync.Mutex
+	var events []notifications.Event
+	receiver := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var envelope notifications.Envelope
+		json.NewDecoder(r.Body).Decode(&envelope)
+		mu.Lock()
+		events = append(events, envelope.Events...)
+		mu.Unlock()
+	}))
+	defer receiver.Close()
+
+	notifier, err := notifications.NewNotifier([]notifications.Endpoint{{Name: "hook", URL: receiver.URL}}, notifications.Source{}, logger.NewTestLogger())
+	if err != nil {
+		t.Fatalf("NewNotifier failed: %v", err)
+	}
+	handler.Notifier = notifier
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		notifier.Run(ctx)
+	}()
+	defer func() {
+		cancel()
+		<-done
+	}()
+
+	manifest := newTestManifest(t, router, "myrepo", "notify")
+	digest := fmt.Sprintf("sha256:%x", sha256.Sum256(manifest))
+	pushTestManifest(t, router, "myrepo", "v1", manifest)
+
+	req := httptest.NewRequest(http.MethodGet, "/v2/myrepo/manifests/v1", nil)
+	router.ServeHTTP(httptest.NewRecorder(), req)
+	req = httptest.NewRequest(http.MethodDelete, "/v2/myrepo/manifests/"+digest, nil)
+	router.ServeHTTP(httptest.NewRecorder(), req)
+
+	deadline := time.Now().Add(5 * time.Second)
+	for {
+		mu.Lock()
+		n := len(events)
+		mu.Unlock()
+		if n >= 3 || time.Now().After(deadline) {
+			break
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+
+	mu.Lock()
+	defer mu.Unlock()
+	want := []struct{ action, tag string }{
+		{notifications.ActionPush, "v1"},
+		{notifications.ActionPull, "v1"},
+		{notifications.ActionDelete, ""},
+	}
+	if len(events) != len(want) {
+		t.Fatalf("received %d events, want %d", len(events), len(want))
+	}
+	for i, w := range want {
+		event := events[i]
+		if event.Action != w.action || event.Target.Tag != w.tag || event.Target.Digest != digest || event.Target.Repository != "myrepo" {
+			t.Errorf("event %d = %+v, want %s of myrepo tag %q", i, event.Target, w.action, w.tag)
+		}
+	}
+	if events[0].Target.MediaType != oci.MediaTypeImageManifest || events[0].Target.Size != int64(len(manifest)) {
+		t.Errorf("push target = %+v, want manifest media type and size", events[0].Target)
+	}
+	if events[0].Target.URL != "http://example.com/v2/myrepo/manifests/"+digest {
+		t.Errorf("push target url = %q", events[0].Target.URL)
+	}
+}
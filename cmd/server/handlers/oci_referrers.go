@@ -0,0 +1,53 @@
+package handlers
+
+import (
+	"encoding/json"
+	"net/http"
+
+	"github.com/gorilla/mux"
+	"github.com/hairizuanbinnoorazman/package-universe/oci"
+)
+
+// referrersResponse is the image index returned by the referrers API.
+type referrersResponse struct {
+	SchemaVersion int              `json:"schemaVersion"`
+	MediaType     string           `json:"mediaType"`
+	Manifests     []oci.Descriptor `json:"manifests"`
+}
+
+// ListReferrers handles GET /v2/{name}/referrers/{digest} — list manifests referring to a subject.
+func (h *OCIHandler) ListReferrers(w http.ResponseWriter, r *http.Request) {
+	setOCIHeaders(w)
+	ctx := r.Context()
+
+	vars := mux.Vars(r)
+	name := vars["name"]
+	digestStr := vars["digest"]
+
+	subject, err := oci.ParseDigest(digestStr)
+	if err != nil {
+		respondOCIError(w, http.StatusBadRequest, OCIErrorDigestInvalid, "invalid digest format")
+		return
+	}
+
+	artifactType := r.URL.Query().Get("artifactType")
+
+	descriptors, err := h.Storage.ListReferrers(ctx, name, subject, artifactType)
+	if err != nil {
+		h.Logger.Error(ctx, "failed to list referrers", map[string]interface{}{"error": err.Error()})
+		respondOCIError(w, http.StatusInternalServerError, OCIErrorManifestUnknown, "failed to list referrers")
+		return
+	}
+
+	if artifactType != "" {
+		w.Header().Set("OCI-Filters-Applied", "artifactType")
+	}
+
+	w.Header().Set("Content-Type", oci.MediaTypeImageIndex)
+	w.WriteHeader(http.StatusOK)
+	json.NewEncoder(w).Encode(referrersResponse{
+		SchemaVersion: 2,
+		MediaType:     oci.MediaTypeImageIndex,
+		Manifests:     descriptors,
+	})
+}
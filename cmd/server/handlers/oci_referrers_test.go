@@ -0,0 +1,119 @@
+package handlers
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestReferrers(t *testing.T) {
+	_, router := setupTestOCIHandler(t)
+
+	ct := "application/vnd.oci.image.manifest.v1+json"
+	subjectData := newTestManifest(t, router, "myrepo", "subject")
+	emptyDigest := pushTestBlob(t, router, "myrepo", []byte("{}"))
+	subjectDigest := fmt.Sprintf("sha256:%x", sha256.Sum256(subjectData))
+
+	req := httptest.NewRequest("PUT", "/v2/myrepo/manifests/latest", bytes.NewReader(subjectData))
+	req.Header.Set("Content-Type", ct)
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+	if w.Code != http.StatusCreated {
+		t.Fatalf("PUT subject: status = %d", w.Code)
+	}
+	if w.Header().Get("OCI-Subject") != "" {
+		t.Errorf("OCI-Subject should not be set for manifest without subject")
+	}
+
+	sigData := []byte(fmt.Sprintf(`{"schemaVersion":2,"mediaType":"application/vnd.oci.image.manifest.v1+json","artifactType":"application/vnd.example.sig","config":{"mediaType":"application/vnd.oci.empty.v1+json","digest":"%s","size":2},"subject":{"mediaType":"%s","digest":"%s","size":%d}}`, emptyDigest, ct, subjectDigest, len(subjectData)))
+	sigDigest := fmt.Sprintf("sha256:%x", sha256.Sum256(sigData))
+
+	req = httptest.NewRequest("PUT", "/v2/myrepo/manifests/"+sigDigest, bytes.NewReader(sigData))
+	req.Header.Set("Content-Type", ct)
+	w = httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+	if w.Code != http.StatusCreated {
+		t.Fatalf("PUT referrer: status = %d, body = %s", w.Code, w.Body.String())
+	}
+	if w.Header().Get("OCI-Subject") != subjectDigest {
+		t.Errorf("OCI-Subject = %q, want %q", w.Header().Get("OCI-Subject"), subjectDigest)
+	}
+
+	// List all referrers
+	req = httptest.NewRequest("GET", "/v2/myrepo/referrers/"+subjectDigest, nil)
+	w = httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+	if w.Code != http.StatusOK {
+		t.Fatalf("GET referrers: status = %d", w.Code)
+	}
+	if w.Header().Get("Content-Type") != "application/vnd.oci.image.index.v1+json" {
+		t.Errorf("content type = %q", w.Header().Get("Content-Type"))
+	}
+	if w.Header().Get("OCI-Filters-Applied") != "" {
+		t.Errorf("OCI-Filters-Applied should not be set without filter")
+	}
+
+	var resp referrersResponse
+	if err := json.NewDecoder(w.Body).Decode(&resp); err != nil {
+		t.Fatalf("failed to decode response: %v", err)
+	}
+	if resp.SchemaVersion != 2 || resp.MediaType != "application/vnd.oci.image.index.v1+json" {
+		t.Errorf("unexpected index header: %+v", resp)
+	}
+	if len(resp.Manifests) != 1 || resp.Manifests[0].Digest != sigDigest {
+		t.Fatalf("expected signature referrer, got %v", resp.Manifests)
+	}
+	if resp.Manifests[0].ArtifactType != "application/vnd.example.sig" {
+		t.Errorf("artifactType = %q", resp.Manifests[0].ArtifactType)
+	}
+
+	// Filter by artifact type that does not match
+	req = httptest.NewRequest("GET", "/v2/myrepo/referrers/"+subjectDigest+"?artifactType=application/spdx%2Bjson", nil)
+	w = httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+	if w.Code != http.StatusOK {
+		t.Fatalf("GET filtered referrers: status = %d", w.Code)
+	}
+	if w.Header().Get("OCI-Filters-Applied") != "artifactType" {
+		t.Errorf("OCI-Filters-Applied = %q, want artifactType", w.Header().Get("OCI-Filters-Applied"))
+	}
+	resp = referrersResponse{}
+	json.NewDecoder(w.Body).Decode(&resp)
+	if len(resp.Manifests) != 0 {
+		t.Errorf("expected no referrers after filtering, got %v", resp.Manifests)
+	}
+}
+
+func TestReferrersEmpty(t *testing.T) {
+	_, router := setupTestOCIHandler(t)
+
+	req := httptest.NewRequest("GET", "/v2/myrepo/referrers/sha256:0000000000000000000000000000000000000000000000000000000000000000", nil)
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+
+	if w.Code != http.StatusOK {
+		t.Fatalf("status = %d, want %d", w.Code, http.StatusOK)
+	}
+
+	var raw map[string]json.RawMessage
+	json.NewDecoder(w.Body).Decode(&raw)
+	if string(raw["manifests"]) != "[]" {
+		t.Errorf("manifests = %s, want []", raw["manifests"])
+	}
+}
+
+func TestReferrersInvalidDigest(t *testing.T) {
+	_, router := setupTestOCIHandler(t)
+
+	req := httptest.NewRequest("GET", "/v2/myrepo/referrers/not-a-digest", nil)
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+
+	if w.Code != http.StatusBadRequest {
+		t.Errorf("status = %d, want %d", w.Code, http.StatusBadRequest)
+	}
+}
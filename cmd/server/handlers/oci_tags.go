@@ -12,7 +12,7 @@ type tagsListResponse struct {
 	Tags []string `json:"tags"`
 }
 
-// TagsList handles GET /v2/{name}/tags/list — list repository tags.
+// TagsList handles GET /v2/{name}/tags/list — list repository tags in lexical order.
 func (h *OCIHandler) TagsList(w http.ResponseWriter, r *http.Request) {
 	setOCIHeaders(w)
 	ctx := r.Context()
@@ -20,6 +20,12 @@ func (h *OCIHandler) TagsList(w http.ResponseWriter, r *http.Request) {
 	vars := mux.Vars(r)
 	name := vars["name"]
 
+	n, last, err := parsePagination(r)
+	if err != nil {
+		respondOCIError(w, http.StatusBadRequest, OCIErrorPaginationInvalid, err.Error())
+		return
+	}
+
 	tags, err := h.Storage.ListTags(ctx, name)
 	if err != nil {
 		h.Logger.Error(ctx, "failed to list tags", map[string]interface{}{"error": err.Error()})
@@ -27,6 +33,11 @@ func (h *OCIHandler) TagsList(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
+	tags, more := paginate(tags, n, last)
+	if more && len(tags) > 0 {
+		setNextLink(w, r, n, tags[len(tags)-1])
+	}
+
 	if tags == nil {
 		tags = []string{}
 	}
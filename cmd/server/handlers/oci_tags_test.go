@@ -5,6 +5,7 @@ import (
 	"encoding/json"
 	"net/http"
 	"net/http/httptest"
+	"reflect"
 	"testing"
 )
 
@@ -14,7 +15,7 @@ func TestTagsList(t *testing.T) {
 	ct := "application/vnd.oci.image.manifest.v1+json"
 
 	// Push two tags
-	manifest1 := []byte(`{"schemaVersion":2,"variant":"a"}`)
+	manifest1 := newTestManifest(t, router, "myrepo", "a")
 	req := httptest.NewRequest("PUT", "/v2/myrepo/manifests/v1.0", bytes.NewReader(manifest1))
 	req.Header.Set("Content-Type", ct)
 	w := httptest.NewRecorder()
@@ -23,7 +24,7 @@ func TestTagsList(t *testing.T) {
 		t.Fatalf("PUT v1.0: status = %d, body = %s", w.Code, w.Body.String())
 	}
 
-	manifest2 := []byte(`{"schemaVersion":2,"variant":"b"}`)
+	manifest2 := newTestManifest(t, router, "myrepo", "b")
 	req = httptest.NewRequest("PUT", "/v2/myrepo/manifests/v2.0", bytes.NewReader(manifest2))
 	req.Header.Set("Content-Type", ct)
 	w = httptest.NewRecorder()
@@ -85,3 +86,76 @@ func TestTagsListEmpty(t *testing.T) {
 		t.Errorf("expected 0 tags, got %d", len(resp.Tags))
 	}
 }
+
+func TestTagsListSortedAndPaginated(t *testing.T) {
+	_, router := setupTestOCIHandler(t)
+
+	manifest := newTestManifest(t, router, "myrepo", "tags")
+	for _, tag := range []string{"nightly-3", "latest", "nightly-1", "v1.0", "nightly-2"} {
+		pushTestManifest(t, router, "myrepo", tag, manifest)
+	}
+
+	// Unpaginated listing is sorted
+	req := httptest.NewRequest("GET", "/v2/myrepo/tags/list", nil)
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+
+	var resp tagsListResponse
+	json.NewDecoder(w.Body).Decode(&resp)
+	want := []string{"latest", "nightly-1", "nightly-2", "nightly-3", "v1.0"}
+	if !reflect.DeepEqual(resp.Tags, want) {
+		t.Errorf("tags = %v, want %v", resp.Tags, want)
+	}
+
+	// First page
+	req = httptest.NewRequest("GET", "/v2/myrepo/tags/list?n=2", nil)
+	w = httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+
+	resp = tagsListResponse{}
+	json.NewDecoder(w.Body).Decode(&resp)
+	if !reflect.DeepEqual(resp.Tags, []string{"latest", "nightly-1"}) {
+		t.Errorf("first page = %v", resp.Tags)
+	}
+	wantLink := `</v2/myrepo/tags/list?last=nightly-1&n=2>; rel="next"`
+	if w.Header().Get("Link") != wantLink {
+		t.Errorf("Link = %q, want %q", w.Header().Get("Link"), wantLink)
+	}
+
+	// Last page has no Link header
+	req = httptest.NewRequest("GET", "/v2/myrepo/tags/list?n=2&last=nightly-3", nil)
+	w = httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+
+	resp = tagsListResponse{}
+	json.NewDecoder(w.Body).Decode(&resp)
+	if !reflect.DeepEqual(resp.Tags, []string{"v1.0"}) {
+		t.Errorf("last page = %v", resp.Tags)
+	}
+	if w.Header().Get("Link") != "" {
+		t.Errorf("unexpected Link header on last page: %q", w.Header().Get("Link"))
+	}
+
+	// n=0 returns an empty list
+	req = httptest.NewRequest("GET", "/v2/myrepo/tags/list?n=0", nil)
+	w = httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+
+	resp = tagsListResponse{}
+	json.NewDecoder(w.Body).Decode(&resp)
+	if w.Code != http.StatusOK || len(resp.Tags) != 0 {
+		t.Errorf("n=0: status = %d, tags = %v", w.Code, resp.Tags)
+	}
+}
+
+func TestTagsListInvalidN(t *testing.T) {
+	_, router := setupTestOCIHandler(t)
+
+	req := httptest.NewRequest("GET", "/v2/myrepo/tags/list?n=-1", nil)
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+
+	if w.Code != http.StatusBadRequest {
+		t.Errorf("status = %d, want %d", w.Code, http.StatusBadRequest)
+	}
+}
@@ -0,0 +1,57 @@
+package handlers
+
+import (
+	"net/http"
+
+	"github.com/hairizuanbinnoorazman/package-universe/logger"
+	"github.com/hairizuanbinnoorazman/package-universe/oci"
+)
+
+// QuotaHandler reports storage usage against the configured quotas.
+type QuotaHandler struct {
+	Storage *oci.OCIStorage
+	Logger  logger.Logger
+}
+
+// repositoryUsage is the storage used by one repository.
+type repositoryUsage struct {
+	Name string `json:"name"`
+	Used int64  `json:"used"`
+}
+
+// quotaUsageResponse lists every quota and the usage of every repository.
+type quotaUsageResponse struct {
+	Quotas       []oci.QuotaUsage  `json:"quotas"`
+	Repositories []repositoryUsage `json:"repositories"`
+}
+
+// Usage handles GET /admin/quotas — quota and per-repository storage usage.
+func (h *QuotaHandler) Usage(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+
+	quotas, err := h.Storage.QuotaUsage(ctx)
+	if err != nil {
+		h.Logger.Error(ctx, "failed to compute quota usage", map[string]interface{}{"error": err.Error()})
+		respondError(w, http.StatusInternalServerError, "failed to compute quota usage")
+		return
+	}
+
+	repositories, err := h.Storage.ListRepositories(ctx)
+	if err != nil {
+		h.Logger.Error(ctx, "failed to list repositories", map[string]interface{}{"error": err.Error()})
+		respondError(w, http.StatusInternalServerError, "failed to list repositories")
+		return
+	}
+	usages := make([]repositoryUsage, 0, len(repositories))
+	for _, name := range repositories {
+		used, err := h.Storage.RepositoryUsage(ctx, name)
+		if err != nil {
+			h.Logger.Error(ctx, "failed to compute repository usage", map[string]interface{}{"repository": name, "error": err.Error()})
+			respondError(w, http.StatusInternalServerError, "failed to compute repository usage")
+			return
+		}
+		usages = append(usages, repositoryUsage{Name: name, Used: used})
+	}
+
+	respondJSON(w, http.StatusOK, quotaUsageResponse{Quotas: quotas, Repositories: usages})
+}
@@ -0,0 +1,33 @@
+package handlers
+
+import (
+	"net/http"
+
+	"github.com/hairizuanbinnoorazman/package-universe/logger"
+	"github.com/hairizuanbinnoorazman/package-universe/replication"
+)
+
+// ReplicationHandler reports the state of replication to downstream registries.
+type ReplicationHandler struct {
+	Replicator *replication.Replicator
+	Logger     logger.Logger
+}
+
+// replicationStatusResponse lists the status of every replication target.
+type replicationStatusResponse struct {
+	Targets []replication.TargetStatus `json:"targets"`
+}
+
+// Status handles GET /admin/replication — per-target replication status.
+func (h *ReplicationHandler) Status(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+
+	statuses, err := h.Replicator.Status(ctx)
+	if err != nil {
+		h.Logger.Error(ctx, "failed to read replication status", map[string]interface{}{"error": err.Error()})
+		respondError(w, http.StatusInternalServerError, "failed to read replication status")
+		return
+	}
+
+	respondJSON(w, http.StatusOK, replicationStatusResponse{Targets: statuses})
+}
@@ -0,0 +1,65 @@
+package main
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/hairizuanbinnoorazman/package-universe/logger"
+	"github.com/hairizuanbinnoorazman/package-universe/oci"
+	"github.com/spf13/cobra"
+)
+
+var linkBlobsDryRun bool
+
+var linkBlobsCmd = &cobra.Command{
+	Use:   "link-blobs",
+	Short: "Link the blobs referenced by stored manifests into their repositories",
+	Long: `Walks every repository's manifest revisions and links the manifests, configs,
+layers and child manifests they reference into the repository. Blobs are only
+served from repositories that link them, so run this once after upgrading a
+registry whose data was written before repository blob links existed. Running
+it again is harmless.`,
+	RunE: runLinkBlobs,
+}
+
+func init() {
+	linkBlobsCmd.Flags().StringVarP(&configFile, "config", "c", "", "config file path")
+	linkBlobsCmd.Flags().BoolVar(&linkBlobsDryRun, "dry-run", false, "report which links would be created without creating them")
+	rootCmd.AddCommand(linkBlobsCmd)
+}
+
+func runLinkBlobs(cmd *cobra.Command, args []string) error {
+	ctx := context.Background()
+
+	cfg, err := LoadConfig(configFile)
+	if err != nil {
+		return fmt.Errorf("failed to load config: %w", err)
+	}
+
+	log := logger.NewLogrusLogger(cfg.Log.Level)
+
+	blobStorage, err := newBlobStorage(cfg)
+	if err != nil {
+		return err
+	}
+
+	ociStorage := oci.NewOCIStorage(blobStorage, oci.NewSessionManager(blobStorage, cfg.Registry.UploadSessionTimeout))
+	result, err := ociStorage.LinkManifestBlobs(ctx, linkBlobsDryRun)
+	if err != nil {
+		return fmt.Errorf("linking blobs failed: %w", err)
+	}
+
+	for _, manifest := range result.UnreadableManifests {
+		log.Warn(ctx, "could not link the blobs of an unreadable manifest", map[string]interface{}{"manifest": manifest})
+	}
+
+	verb := "Linked"
+	if linkBlobsDryRun {
+		verb = "Would link"
+	}
+	for _, link := range result.LinksCreated {
+		fmt.Printf("%s %s\n", verb, link)
+	}
+	fmt.Printf("%s %d blobs\n", verb, len(result.LinksCreated))
+	return nil
+}
@@ -0,0 +1,70 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"os"
+
+	"github.com/hairizuanbinnoorazman/package-universe/auth"
+	"github.com/hairizuanbinnoorazman/package-universe/storage"
+	"github.com/spf13/cobra"
+)
+
+var policyFile string
+
+var policyCmd = &cobra.Command{
+	Use:   "policy",
+	Short: "Show or replace the RBAC policy kept in storage",
+	Long: `Prints the RBAC policy document stored in the registry's storage, or with
+--file validates a JSON policy document and stores it. Servers using
+auth.rbac.source "storage" pick it up within auth.rbac.reload_interval.`,
+	RunE: runPolicy,
+}
+
+func init() {
+	policyCmd.Flags().StringVarP(&configFile, "config", "c", "", "config file path")
+	policyCmd.Flags().StringVarP(&policyFile, "file", "f", "", "JSON policy document to store")
+	rootCmd.AddCommand(policyCmd)
+}
+
+func runPolicy(cmd *cobra.Command, args []string) error {
+	ctx := context.Background()
+
+	cfg, err := LoadConfig(configFile)
+	if err != nil {
+		return fmt.Errorf("failed to load config: %w", err)
+	}
+
+	blobStorage, err := newBlobStorage(cfg)
+	if err != nil {
+		return err
+	}
+
+	if policyFile == "" {
+		doc, err := auth.ReadPolicy(ctx, blobStorage)
+		if err == storage.ErrFileNotFound {
+			return fmt.Errorf("no policy stored at %s", auth.PolicyPath)
+		}
+		if err != nil {
+			return err
+		}
+		data, _ := json.MarshalIndent(doc, "", "  ")
+		fmt.Println(string(data))
+		return nil
+	}
+
+	data, err := os.ReadFile(policyFile)
+	if err != nil {
+		return fmt.Errorf("failed to read policy file: %w", err)
+	}
+	var doc auth.PolicyDocument
+	if err := json.Unmarshal(data, &doc); err != nil {
+		return fmt.Errorf("failed to parse policy file: %w", err)
+	}
+	if err := auth.WritePolicy(ctx, blobStorage, doc); err != nil {
+		return err
+	}
+	fmt.Printf("Stored policy with %d roles, %d groups and %d grants\n", len(doc.Roles), len(doc.Groups), len(doc.Grants))
+	return nil
+}
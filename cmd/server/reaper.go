@@ -0,0 +1,27 @@
+package main
+
+import (
+	"context"
+
+	"github.com/hairizuanbinnoorazman/package-universe/logger"
+	"github.com/hairizuanbinnoorazman/package-universe/oci"
+)
+
+// reapUploads runs a single reaper pass and logs what it removed.
+func reapUploads(ctx context.Context, log logger.Logger, sessions *oci.SessionManager) {
+	result, err := sessions.Reap(ctx)
+	if err != nil {
+		if ctx.Err() == nil {
+			log.Error(ctx, "failed to reap upload sessions", map[string]interface{}{"error": err.Error()})
+		}
+		return
+	}
+	if result.SessionsExpired == 0 && result.OrphansDeleted == 0 {
+		return
+	}
+	log.Info(ctx, "reaped expired uploads", map[string]interface{}{
+		"sessions_expired": result.SessionsExpired,
+		"orphans_deleted":  result.OrphansDeleted,
+		"bytes_reclaimed":  result.BytesReclaimed,
+	})
+}
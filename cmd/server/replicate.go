@@ -0,0 +1,117 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"path"
+
+	"github.com/hairizuanbinnoorazman/package-universe/logger"
+	"github.com/hairizuanbinnoorazman/package-universe/oci"
+	"github.com/hairizuanbinnoorazman/package-universe/replication"
+	"github.com/hairizuanbinnoorazman/package-universe/storage"
+	"github.com/spf13/cobra"
+)
+
+var (
+	replicateRepo   string
+	replicateTarget string
+)
+
+var replicateCmd = &cobra.Command{
+	Use:   "replicate",
+	Short: "Copy existing images to the replication targets",
+	Long: `Pushes every tag of the repositories matching --repo to each replication
+target configured for them, skipping blobs the target already has. Use it to
+backfill a new target; images pushed while the server runs are replicated
+automatically.`,
+	RunE: runReplicate,
+}
+
+func init() {
+	replicateCmd.Flags().StringVarP(&configFile, "config", "c", "", "config file path")
+	replicateCmd.Flags().StringVar(&replicateRepo, "repo", "", "repository name or path glob to replicate (required)")
+	replicateCmd.Flags().StringVar(&replicateTarget, "target", "", "only replicate to the named target")
+	replicateCmd.MarkFlagRequired("repo")
+	rootCmd.AddCommand(replicateCmd)
+}
+
+func runReplicate(cmd *cobra.Command, args []string) error {
+	ctx := context.Background()
+
+	cfg, err := LoadConfig(configFile)
+	if err != nil {
+		return fmt.Errorf("failed to load config: %w", err)
+	}
+	if _, err := path.Match(replicateRepo, ""); err != nil {
+		return fmt.Errorf("invalid --repo pattern %q: %w", replicateRepo, err)
+	}
+
+	log := logger.NewLogrusLogger(cfg.Log.Level)
+
+	blobStorage, err := newBlobStorage(cfg)
+	if err != nil {
+		return err
+	}
+
+	ociStorage := oci.NewOCIStorage(blobStorage, oci.NewSessionManager(blobStorage, cfg.Registry.UploadSessionTimeout))
+	replicator, err := newReplicator(cfg, ociStorage, blobStorage, log)
+	if err != nil {
+		return err
+	}
+
+	repositories, err := ociStorage.ListRepositories(ctx)
+	if err != nil {
+		return err
+	}
+
+	replicated, failed := 0, 0
+	for _, target := range replicator.Targets() {
+		if replicateTarget != "" && target.Name != replicateTarget {
+			continue
+		}
+		for _, name := range repositories {
+			if ok, _ := path.Match(replicateRepo, name); !ok || !target.Matches(name) {
+				continue
+			}
+			tags, err := ociStorage.ListTags(ctx, name)
+			if err != nil {
+				return err
+			}
+			for _, tag := range tags {
+				if err := replicator.Replicate(ctx, target, name, tag); err != nil {
+					fmt.Printf("Failed %s:%s -> %s: %v\n", name, tag, target.Name, err)
+					failed++
+					continue
+				}
+				fmt.Printf("Replicated %s:%s -> %s\n", name, tag, target.Name)
+				replicated++
+			}
+		}
+	}
+
+	fmt.Printf("Replicated %d tags, %d failed\n", replicated, failed)
+	if failed > 0 {
+		return fmt.Errorf("%d tags failed to replicate", failed)
+	}
+	return nil
+}
+
+// newReplicator creates a replicator for the targets in the configuration.
+func newReplicator(cfg *Config, ociStorage *oci.OCIStorage, blobStorage storage.BlobStorage, log logger.Logger) (*replication.Replicator, error) {
+	var targets []replication.Target
+	for _, t := range cfg.Registry.Replication.Targets {
+		if t.URL == "" {
+			return nil, fmt.Errorf("registry.replication target %q needs a url", t.Name)
+		}
+		target, err := replication.NewTarget(t.Name, oci.NewClient(t.URL, t.Username, t.Password, cfg.Registry.Replication.Timeout), t.Repositories)
+		if err != nil {
+			return nil, fmt.Errorf("invalid registry.replication target: %w", err)
+		}
+		targets = append(targets, target)
+	}
+
+	return replication.NewReplicator(ociStorage, blobStorage, log, targets, replication.Options{
+		RetryInterval: cfg.Registry.Replication.RetryInterval,
+		MaxAttempts:   cfg.Registry.Replication.MaxAttempts,
+	}), nil
+}
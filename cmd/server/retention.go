@@ -0,0 +1,110 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"regexp"
+
+	"github.com/hairizuanbinnoorazman/package-universe/logger"
+	"github.com/hairizuanbinnoorazman/package-universe/oci"
+	"github.com/spf13/cobra"
+)
+
+var retentionDryRun bool
+
+var retentionCmd = &cobra.Command{
+	Use:   "retention",
+	Short: "Delete tags selected by the registry retention rules",
+	Long: `Evaluates registry.retention against every repository and removes the tags
+it selects. Untagged manifests and their blobs are reclaimed by a later gc run
+with --delete-untagged.`,
+	RunE: runRetention,
+}
+
+func init() {
+	retentionCmd.Flags().StringVarP(&configFile, "config", "c", "", "config file path")
+	retentionCmd.Flags().BoolVar(&retentionDryRun, "dry-run", false, "report which tags would be deleted without deleting them")
+	rootCmd.AddCommand(retentionCmd)
+}
+
+func runRetention(cmd *cobra.Command, args []string) error {
+	ctx := context.Background()
+
+	cfg, err := LoadConfig(configFile)
+	if err != nil {
+		return fmt.Errorf("failed to load config: %w", err)
+	}
+
+	log := logger.NewLogrusLogger(cfg.Log.Level)
+
+	policy, err := newRetentionPolicy(cfg)
+	if err != nil {
+		return err
+	}
+
+	immutableTags, err := newImmutableTagRules(cfg)
+	if err != nil {
+		return err
+	}
+
+	blobStorage, err := newBlobStorage(cfg)
+	if err != nil {
+		return err
+	}
+
+	// Immutable tags are exempt from retention, so the rules must be known here too
+	ociStorage := oci.NewOCIStorage(blobStorage, oci.NewSessionManager(blobStorage, cfg.Registry.UploadSessionTimeout),
+		oci.WithImmutableTags(immutableTags...))
+	result, err := applyRetention(ctx, log, ociStorage, policy, retentionDryRun)
+	if err != nil {
+		return err
+	}
+
+	verb := "Deleted"
+	if retentionDryRun {
+		verb = "Would delete"
+	}
+	for _, tag := range result.TagsDeleted {
+		fmt.Printf("%s %s\n", verb, tag)
+	}
+	fmt.Printf("%s %d tags\n", verb, len(result.TagsDeleted))
+	return nil
+}
+
+// newRetentionPolicy compiles the retention rules in the configuration.
+func newRetentionPolicy(cfg *Config) (oci.RetentionPolicy, error) {
+	var policy oci.RetentionPolicy
+	for _, rule := range cfg.Registry.Retention.Rules {
+		compiled, err := oci.NewRetentionRule(rule.Repository, rule.Tag, rule.KeepLast, rule.MaxAge)
+		if err != nil {
+			return policy, fmt.Errorf("invalid registry.retention rule: %w", err)
+		}
+		policy.Rules = append(policy.Rules, compiled)
+	}
+	for _, pattern := range cfg.Registry.Retention.Protect {
+		re, err := regexp.Compile(pattern)
+		if err != nil {
+			return policy, fmt.Errorf("invalid registry.retention protect pattern %q: %w", pattern, err)
+		}
+		policy.Protect = append(policy.Protect, re)
+	}
+	return policy, nil
+}
+
+// applyRetention enforces the policy and logs the tags it removed.
+func applyRetention(ctx context.Context, log logger.Logger, ociStorage *oci.OCIStorage, policy oci.RetentionPolicy, dryRun bool) (oci.RetentionResult, error) {
+	result, err := ociStorage.ApplyRetention(ctx, policy, dryRun)
+	if err != nil {
+		if ctx.Err() == nil {
+			log.Error(ctx, "failed to apply retention", map[string]interface{}{"error": err.Error()})
+		}
+		return result, fmt.Errorf("retention failed: %w", err)
+	}
+	if len(result.TagsDeleted) > 0 || dryRun {
+		log.Info(ctx, "retention applied", map[string]interface{}{
+			"dry_run":      dryRun,
+			"tags_deleted": result.TagsDeleted,
+		})
+	}
+	return result, nil
+}
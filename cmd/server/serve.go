@@ -6,14 +6,19 @@ import (
 	"net/http"
 	"os"
 	"os/signal"
+	"strings"
 	"syscall"
 	"time"
 
 	"github.com/gorilla/mux"
+	"github.com/hairizuanbinnoorazman/package-universe/auth"
 	"github.com/hairizuanbinnoorazman/package-universe/cmd/server/handlers"
 	"github.com/hairizuanbinnoorazman/package-universe/logger"
+	"github.com/hairizuanbinnoorazman/package-universe/metrics"
+	"github.com/hairizuanbinnoorazman/package-universe/notifications"
 	"github.com/hairizuanbinnoorazman/package-universe/oci"
 	"github.com/hairizuanbinnoorazman/package-universe/storage"
+	"github.com/hairizuanbinnoorazman/package-universe/tracing"
 	"github.com/spf13/cobra"
 )
 
@@ -47,18 +52,31 @@ func runServer(cmd *cobra.Command, args []string) error {
 		"date":    BuildDate,
 	})
 
-	// Initialize storage
-	storageConfig := map[string]interface{}{
-		"base_dir":       cfg.Storage.BaseDir,
-		"bucket":         cfg.Storage.S3Bucket,
-		"region":         cfg.Storage.S3Region,
-		"presign_expiry": cfg.Storage.S3PresignExpiry,
+	// Initialize tracing; spans are only exported when an exporter is configured
+	shutdownTracing, err := tracing.Setup(ctx, tracing.Config{
+		Exporter:    cfg.Tracing.Exporter,
+		Endpoint:    cfg.Tracing.Endpoint,
+		Insecure:    cfg.Tracing.Insecure,
+		ServiceName: cfg.Tracing.ServiceName,
+		SampleRatio: cfg.Tracing.SampleRatio,
+	}, Version)
+	if err != nil {
+		return err
+	}
+	if cfg.Tracing.Exporter == tracing.ExporterOTLP {
+		log.Info(ctx, "tracing enabled", map[string]interface{}{
+			"exporter":     cfg.Tracing.Exporter,
+			"endpoint":     cfg.Tracing.Endpoint,
+			"sample_ratio": cfg.Tracing.SampleRatio,
+		})
 	}
 
-	blobStorage, err := storage.NewBlobStorage(cfg.Storage.Type, storageConfig)
+	// Initialize storage
+	blobStorage, err := newBlobStorage(cfg)
 	if err != nil {
-		return fmt.Errorf("failed to initialize storage: %w", err)
+		return err
 	}
+	blobStorage = tracing.InstrumentStorage(blobStorage, strings.ToLower(cfg.Storage.Type))
 
 	// Log storage initialization
 	logFields := map[string]interface{}{"type": cfg.Storage.Type}
@@ -70,44 +88,191 @@ func runServer(cmd *cobra.Command, args []string) error {
 	}
 	log.Info(ctx, "storage initialized", logFields)
 
+	// Prometheus metrics, including the latency of every storage operation
+	var serverMetrics *metrics.Metrics
+	if cfg.Metrics.Enabled {
+		serverMetrics = metrics.New()
+		blobStorage = serverMetrics.InstrumentStorage(blobStorage, strings.ToLower(cfg.Storage.Type))
+	}
+
 	// Setup router
 	router := mux.NewRouter()
+	router.Use(tracing.Middleware)
+	var metricsHandler http.Handler
+	if serverMetrics != nil {
+		router.Use(serverMetrics.Middleware)
+		metricsHandler = serverMetrics.Handler()
+	}
 
 	// Health and readiness endpoints
 	router.HandleFunc("/healthz", handlers.HealthHandler).Methods("GET")
 	router.HandleFunc("/readyz", handlers.ReadyHandler).Methods("GET")
 
+	// Background tasks stopped during graceful shutdown
+	var stopBackground []func()
+
 	// OCI container registry endpoints
 	if cfg.Registry.Enabled {
-		sessionMgr := oci.NewSessionManager(cfg.Registry.UploadSessionTimeout)
-		ociStorage := oci.NewOCIStorage(blobStorage, sessionMgr)
+		sessionMgr := oci.NewSessionManager(blobStorage, cfg.Registry.UploadSessionTimeout)
+		resumed, err := sessionMgr.Rehydrate(ctx)
+		if err != nil {
+			return fmt.Errorf("failed to load upload sessions: %w", err)
+		}
+		if serverMetrics != nil {
+			serverMetrics.RegisterUploadSessions(sessionMgr)
+		}
+		immutableTags, err := newImmutableTagRules(cfg)
+		if err != nil {
+			return err
+		}
+		ociOptions := []oci.Option{oci.WithImmutableTags(immutableTags...)}
+		if len(cfg.Registry.Quotas) > 0 {
+			quotas, err := newQuotas(cfg)
+			if err != nil {
+				return err
+			}
+			ociOptions = append(ociOptions, oci.WithQuotas(quotas...))
+			log.Info(ctx, "storage quotas enabled", map[string]interface{}{"quotas": len(quotas)})
+		}
+		if cfg.Registry.Proxy.RemoteURL != "" {
+			upstream := oci.NewClient(cfg.Registry.Proxy.RemoteURL, cfg.Registry.Proxy.Username, cfg.Registry.Proxy.Password, cfg.Registry.Proxy.Timeout)
+			ociOptions = append(ociOptions, oci.WithUpstream(upstream, cfg.Registry.Proxy.TagTTL))
+			log.Info(ctx, "pull-through cache enabled", map[string]interface{}{
+				"remote_url": cfg.Registry.Proxy.RemoteURL,
+				"tag_ttl":    cfg.Registry.Proxy.TagTTL.String(),
+			})
+		}
+		ociStorage := oci.NewOCIStorage(blobStorage, sessionMgr, ociOptions...)
+		retentionPolicy, err := newRetentionPolicy(cfg)
+		if err != nil {
+			return err
+		}
 		ociHandler := &handlers.OCIHandler{
-			Storage: ociStorage,
-			Logger:  log,
+			Storage:         ociStorage,
+			Logger:          log,
+			DeleteEnabled:   cfg.Registry.DeleteEnabled,
+			MaxManifestSize: cfg.Registry.MaxManifestSize,
+			MaxChunkSize:    cfg.Registry.MaxChunkSize,
+		}
+
+		log.Info(ctx, "OCI container registry enabled", map[string]interface{}{"upload_sessions": resumed})
+
+		// Reap abandoned uploads in the background until shutdown
+		if cfg.Registry.UploadReapInterval > 0 {
+			stopBackground = append(stopBackground, startBackground(ctx, cfg.Registry.UploadReapInterval, func(ctx context.Context) {
+				reapUploads(ctx, log, sessionMgr)
+			}))
+		}
+
+		// Enforce tag retention on a schedule
+		if len(retentionPolicy.Rules) > 0 && cfg.Registry.Retention.Interval > 0 {
+			stopBackground = append(stopBackground, startBackground(ctx, cfg.Registry.Retention.Interval, func(ctx context.Context) {
+				applyRetention(ctx, log, ociStorage, retentionPolicy, false)
+			}))
+		}
+
+		// Registry API routes, behind token authentication when enabled
+		v2 := router.PathPrefix("/v2").Subrouter()
+		admin := router.PathPrefix("/admin").Subrouter()
+		if cfg.Auth.Enabled {
+			var authorizer auth.Authorizer = auth.DefaultAuthorizer{AnonymousPull: cfg.Auth.AnonymousPull}
+			if cfg.Auth.RBAC.Enabled {
+				policy, err := newPolicy(ctx, cfg, blobStorage, log)
+				if err != nil {
+					return err
+				}
+				authorizer = policy
+				ociHandler.Authorizer = policy
+				if cfg.Auth.RBAC.Source == "storage" && cfg.Auth.RBAC.ReloadInterval > 0 {
+					stopBackground = append(stopBackground, startBackground(ctx, cfg.Auth.RBAC.ReloadInterval, func(ctx context.Context) {
+						reloadPolicy(ctx, log, policy, blobStorage)
+					}))
+				}
+			}
+
+			tokenHandler, registryAuth, err := newAuthHandlers(cfg, authorizer, log)
+			if err != nil {
+				return err
+			}
+			router.HandleFunc("/token", tokenHandler.Token).Methods("GET")
+			v2.Use(registryAuth.Middleware, ociHandler.EnforceAccess)
+			admin.Use(registryAuth.Middleware, ociHandler.EnforceAccess)
+			// Metrics name repositories and routes, so only registry admins may read them
+			if metricsHandler != nil {
+				metricsHandler = handlers.AdminRoute(registryAuth.Middleware(ociHandler.EnforceAccess(metricsHandler)))
+			}
+			log.Info(ctx, "token authentication enabled", map[string]interface{}{
+				"service": cfg.Auth.Service,
+				"users":   len(cfg.Auth.Users),
+				"rbac":    cfg.Auth.RBAC.Enabled,
+			})
 		}
 
-		log.Info(ctx, "OCI container registry enabled", nil)
+		// Replicate pushed images to downstream registries
+		if len(cfg.Registry.Replication.Targets) > 0 {
+			replicator, err := newReplicator(cfg, ociStorage, blobStorage, log)
+			if err != nil {
+				return err
+			}
+			ociHandler.Replicator = replicator
+			stopBackground = append(stopBackground, startWorker(ctx, replicator.Run))
+
+			replicationHandler := &handlers.ReplicationHandler{Replicator: replicator, Logger: log}
+			admin.HandleFunc("/replication", replicationHandler.Status).Methods("GET")
+			log.Info(ctx, "replication enabled", map[string]interface{}{"targets": len(replicator.Targets())})
+		}
+
+		// Deliver registry events to webhook endpoints
+		if len(cfg.Registry.Notifications.Endpoints) > 0 {
+			notifier, err := newNotifier(cfg, log)
+			if err != nil {
+				return err
+			}
+			ociHandler.Notifier = notifier
+			stopBackground = append(stopBackground, startWorker(ctx, notifier.Run))
+			log.Info(ctx, "notifications enabled", map[string]interface{}{"endpoints": len(notifier.Endpoints())})
+		}
+
+		// Storage usage against quotas
+		if len(cfg.Registry.Quotas) > 0 {
+			quotaHandler := &handlers.QuotaHandler{Storage: ociStorage, Logger: log}
+			admin.HandleFunc("/quotas", quotaHandler.Usage).Methods("GET")
+		}
 
 		// /v2/ base route
-		router.HandleFunc("/v2/", ociHandler.V2Check).Methods("GET")
+		v2.HandleFunc("/", ociHandler.V2Check).Methods("GET")
+
+		// Catalog route
+		v2.HandleFunc("/_catalog", ociHandler.Catalog).Methods("GET")
 
 		// Blob upload routes (must be before blob routes since they have longer paths)
-		router.HandleFunc("/v2/{name:.+}/blobs/uploads/", ociHandler.InitiateBlobUpload).Methods("POST")
-		router.HandleFunc("/v2/{name:.+}/blobs/uploads/{uuid}", ociHandler.PatchBlobUpload).Methods("PATCH")
-		router.HandleFunc("/v2/{name:.+}/blobs/uploads/{uuid}", ociHandler.CompleteBlobUpload).Methods("PUT")
-		router.HandleFunc("/v2/{name:.+}/blobs/uploads/{uuid}", ociHandler.CancelBlobUpload).Methods("DELETE")
+		v2.HandleFunc("/{name:.+}/blobs/uploads/", ociHandler.InitiateBlobUpload).Methods("POST")
+		v2.HandleFunc("/{name:.+}/blobs/uploads/{uuid}", ociHandler.GetBlobUploadStatus).Methods("GET")
+		v2.HandleFunc("/{name:.+}/blobs/uploads/{uuid}", ociHandler.PatchBlobUpload).Methods("PATCH")
+		v2.HandleFunc("/{name:.+}/blobs/uploads/{uuid}", ociHandler.CompleteBlobUpload).Methods("PUT")
+		v2.HandleFunc("/{name:.+}/blobs/uploads/{uuid}", ociHandler.CancelBlobUpload).Methods("DELETE")
 
 		// Blob routes
-		router.HandleFunc("/v2/{name:.+}/blobs/{digest}", ociHandler.HeadBlob).Methods("HEAD")
-		router.HandleFunc("/v2/{name:.+}/blobs/{digest}", ociHandler.GetBlob).Methods("GET")
+		v2.HandleFunc("/{name:.+}/blobs/{digest}", ociHandler.HeadBlob).Methods("HEAD")
+		v2.HandleFunc("/{name:.+}/blobs/{digest}", ociHandler.GetBlob).Methods("GET")
+		v2.HandleFunc("/{name:.+}/blobs/{digest}", ociHandler.DeleteBlob).Methods("DELETE")
 
 		// Manifest routes
-		router.HandleFunc("/v2/{name:.+}/manifests/{reference}", ociHandler.HeadManifest).Methods("HEAD")
-		router.HandleFunc("/v2/{name:.+}/manifests/{reference}", ociHandler.GetManifest).Methods("GET")
-		router.HandleFunc("/v2/{name:.+}/manifests/{reference}", ociHandler.PutManifest).Methods("PUT")
+		v2.HandleFunc("/{name:.+}/manifests/{reference}", ociHandler.HeadManifest).Methods("HEAD")
+		v2.HandleFunc("/{name:.+}/manifests/{reference}", ociHandler.GetManifest).Methods("GET")
+		v2.HandleFunc("/{name:.+}/manifests/{reference}", ociHandler.PutManifest).Methods("PUT")
+		v2.HandleFunc("/{name:.+}/manifests/{reference}", ociHandler.DeleteManifest).Methods("DELETE")
 
 		// Tags route
-		router.HandleFunc("/v2/{name:.+}/tags/list", ociHandler.TagsList).Methods("GET")
+		v2.HandleFunc("/{name:.+}/tags/list", ociHandler.TagsList).Methods("GET")
+
+		// Referrers route
+		v2.HandleFunc("/{name:.+}/referrers/{digest}", ociHandler.ListReferrers).Methods("GET")
+	}
+
+	if metricsHandler != nil {
+		router.Handle(cfg.Metrics.Path, metricsHandler).Methods("GET")
+		log.Info(ctx, "metrics enabled", map[string]interface{}{"path": cfg.Metrics.Path})
 	}
 
 	// Create HTTP server
@@ -146,6 +311,84 @@ func runServer(cmd *cobra.Command, args []string) error {
 		return fmt.Errorf("server forced to shutdown: %w", err)
 	}
 
+	for _, stop := range stopBackground {
+		stop()
+	}
+
+	if err := shutdownTracing(shutdownCtx); err != nil {
+		log.Error(ctx, "failed to flush traces", map[string]interface{}{"error": err.Error()})
+	}
+
 	log.Info(ctx, "server stopped", nil)
 	return nil
 }
+
+// newBlobStorage creates the blob storage backend described by the configuration.
+func newBlobStorage(cfg *Config) (storage.BlobStorage, error) {
+	storageConfig := map[string]interface{}{
+		"base_dir":       cfg.Storage.BaseDir,
+		"bucket":         cfg.Storage.S3Bucket,
+		"region":         cfg.Storage.S3Region,
+		"presign_expiry": cfg.Storage.S3PresignExpiry,
+	}
+
+	blobStorage, err := storage.NewBlobStorage(cfg.Storage.Type, storageConfig)
+	if err != nil {
+		return nil, fmt.Errorf("failed to initialize storage: %w", err)
+	}
+	return blobStorage, nil
+}
+
+// newNotifier creates the notifier for the configured webhook endpoints.
+func newNotifier(cfg *Config, log logger.Logger) (*notifications.Notifier, error) {
+	var endpoints []notifications.Endpoint
+	for _, e := range cfg.Registry.Notifications.Endpoints {
+		endpoints = append(endpoints, notifications.Endpoint{
+			Name:         e.Name,
+			URL:          e.URL,
+			Secret:       e.Secret,
+			Actions:      e.Actions,
+			Repositories: e.Repositories,
+			Timeout:      e.Timeout,
+			QueueSize:    e.QueueSize,
+			MaxAttempts:  e.MaxAttempts,
+			Backoff:      e.Backoff,
+		})
+	}
+
+	source := notifications.Source{Addr: fmt.Sprintf("%s:%d", cfg.Server.Host, cfg.Server.Port)}
+	if hostname, err := os.Hostname(); err == nil {
+		source.Addr = fmt.Sprintf("%s:%d", hostname, cfg.Server.Port)
+	}
+	notifier, err := notifications.NewNotifier(endpoints, source, log)
+	if err != nil {
+		return nil, fmt.Errorf("invalid registry.notifications: %w", err)
+	}
+	return notifier, nil
+}
+
+// newQuotas validates the storage quotas in the configuration.
+func newQuotas(cfg *Config) ([]oci.Quota, error) {
+	var quotas []oci.Quota
+	for _, q := range cfg.Registry.Quotas {
+		quota, err := oci.NewQuota(q.Repository, q.Namespace, q.Limit)
+		if err != nil {
+			return nil, fmt.Errorf("invalid registry.quotas entry: %w", err)
+		}
+		quotas = append(quotas, quota)
+	}
+	return quotas, nil
+}
+
+// newImmutableTagRules compiles the immutable tag rules in the configuration.
+func newImmutableTagRules(cfg *Config) ([]oci.ImmutableTagRule, error) {
+	var rules []oci.ImmutableTagRule
+	for _, rule := range cfg.Registry.ImmutableTags {
+		compiled, err := oci.NewImmutableTagRule(rule.Repository, rule.Tag)
+		if err != nil {
+			return nil, fmt.Errorf("invalid registry.immutable_tags entry: %w", err)
+		}
+		rules = append(rules, compiled)
+	}
+	return rules, nil
+}
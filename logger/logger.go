@@ -1,6 +1,10 @@
 package logger
 
-import "context"
+import (
+	"context"
+
+	"go.opentelemetry.io/otel/trace"
+)
 
 // Logger defines the interface for structured logging with context support.
 type Logger interface {
@@ -22,3 +26,19 @@ type Logger interface {
 	// WithFields returns a new logger with the given fields added to all subsequent log entries
 	WithFields(fields map[string]interface{}) Logger
 }
+
+// traceFields returns the trace and span IDs of the span in ctx, so log
+// entries can be matched to traces, or nil when ctx carries no span.
+func traceFields(ctx context.Context) map[string]interface{} {
+	if ctx == nil {
+		return nil
+	}
+	sc := trace.SpanContextFromContext(ctx)
+	if !sc.IsValid() {
+		return nil
+	}
+	return map[string]interface{}{
+		"trace_id": sc.TraceID().String(),
+		"span_id":  sc.SpanID().String(),
+	}
+}
@@ -34,38 +34,35 @@ func NewLogrusLogger(level string) *LogrusLogger {
 
 // Debug logs a debug-level message.
 func (l *LogrusLogger) Debug(ctx context.Context, msg string, fields map[string]interface{}) {
-	if fields != nil {
-		l.entry.WithFields(fields).Debug(msg)
-	} else {
-		l.entry.Debug(msg)
-	}
+	l.entryFor(ctx, fields).Debug(msg)
 }
 
 // Info logs an info-level message.
 func (l *LogrusLogger) Info(ctx context.Context, msg string, fields map[string]interface{}) {
-	if fields != nil {
-		l.entry.WithFields(fields).Info(msg)
-	} else {
-		l.entry.Info(msg)
-	}
+	l.entryFor(ctx, fields).Info(msg)
 }
 
 // Warn logs a warning-level message.
 func (l *LogrusLogger) Warn(ctx context.Context, msg string, fields map[string]interface{}) {
-	if fields != nil {
-		l.entry.WithFields(fields).Warn(msg)
-	} else {
-		l.entry.Warn(msg)
-	}
+	l.entryFor(ctx, fields).Warn(msg)
 }
 
 // Error logs an error-level message.
 func (l *LogrusLogger) Error(ctx context.Context, msg string, fields map[string]interface{}) {
+	l.entryFor(ctx, fields).Error(msg)
+}
+
+// entryFor returns the entry for a log call, with its fields and the trace
+// and span IDs of ctx.
+func (l *LogrusLogger) entryFor(ctx context.Context, fields map[string]interface{}) *logrus.Entry {
+	entry := l.entry
 	if fields != nil {
-		l.entry.WithFields(fields).Error(msg)
-	} else {
-		l.entry.Error(msg)
+		entry = entry.WithFields(fields)
+	}
+	if trace := traceFields(ctx); trace != nil {
+		entry = entry.WithFields(trace)
 	}
+	return entry
 }
 
 // WithField returns a new logger with the given field added.
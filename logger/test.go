@@ -29,22 +29,22 @@ func NewTestLogger() *TestLogger {
 
 // Debug logs a debug-level message.
 func (l *TestLogger) Debug(ctx context.Context, msg string, fields map[string]interface{}) {
-	l.log("debug", msg, fields)
+	l.log(ctx, "debug", msg, fields)
 }
 
 // Info logs an info-level message.
 func (l *TestLogger) Info(ctx context.Context, msg string, fields map[string]interface{}) {
-	l.log("info", msg, fields)
+	l.log(ctx, "info", msg, fields)
 }
 
 // Warn logs a warning-level message.
 func (l *TestLogger) Warn(ctx context.Context, msg string, fields map[string]interface{}) {
-	l.log("warn", msg, fields)
+	l.log(ctx, "warn", msg, fields)
 }
 
 // Error logs an error-level message.
 func (l *TestLogger) Error(ctx context.Context, msg string, fields map[string]interface{}) {
-	l.log("error", msg, fields)
+	l.log(ctx, "error", msg, fields)
 }
 
 // WithField returns a new logger with the given field added.
@@ -78,7 +78,7 @@ func (l *TestLogger) WithFields(fields map[string]interface{}) Logger {
 }
 
 // log adds a log entry to the captured entries.
-func (l *TestLogger) log(level, msg string, fields map[string]interface{}) {
+func (l *TestLogger) log(ctx context.Context, level, msg string, fields map[string]interface{}) {
 	l.mu.Lock()
 	defer l.mu.Unlock()
 
@@ -92,6 +92,9 @@ func (l *TestLogger) log(level, msg string, fields map[string]interface{}) {
 			allFields[k] = v
 		}
 	}
+	for k, v := range traceFields(ctx) {
+		allFields[k] = v
+	}
 
 	l.entries = append(l.entries, LogEntry{
 		Level:   level,
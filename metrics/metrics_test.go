@@ -0,0 +1,107 @@
+package metrics
+
+import (
+	"bytes"
+	"context"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gorilla/mux"
+	"github.com/hairizuanbinnoorazman/package-universe/oci"
+	"github.com/hairizuanbinnoorazman/package-universe/storage"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+)
+
+func TestMiddleware(t *testing.T) {
+	m := New()
+	router := mux.NewRouter()
+	router.Use(m.Middleware)
+	v2 := router.PathPrefix("/v2").Subrouter()
+	v2.HandleFunc("/{name:.+}/blobs/uploads/{uuid}", func(w http.ResponseWriter, r *http.Request) {
+		io.Copy(io.Discard, r.Body)
+		w.WriteHeader(http.StatusAccepted)
+	}).Methods("PATCH")
+	v2.HandleFunc("/{name:.+}/blobs/{digest}", func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte("hello"))
+	}).Methods("GET")
+
+	for _, name := range []string{"app", "team/api"} {
+		req := httptest.NewRequest(http.MethodPatch, "/v2/"+name+"/blobs/uploads/1234", strings.NewReader("0123456789"))
+		router.ServeHTTP(httptest.NewRecorder(), req)
+	}
+	router.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, "/v2/app/blobs/sha256:abc", nil))
+
+	uploadRoute := "/v2/{name:.+}/blobs/uploads/{uuid}"
+	blobRoute := "/v2/{name:.+}/blobs/{digest}"
+	if got := testutil.ToFloat64(m.requests.WithLabelValues(uploadRoute, "PATCH", "202")); got != 2 {
+		t.Errorf("upload requests = %v, want 2", got)
+	}
+	if got := testutil.ToFloat64(m.requests.WithLabelValues(blobRoute, "GET", "200")); got != 1 {
+		t.Errorf("blob requests = %v, want 1", got)
+	}
+	if got := testutil.ToFloat64(m.uploadedBytes.WithLabelValues(uploadRoute)); got != 20 {
+		t.Errorf("uploaded bytes = %v, want 20", got)
+	}
+	if got := testutil.ToFloat64(m.downloadedBytes.WithLabelValues(blobRoute)); got != 5 {
+		t.Errorf("downloaded bytes = %v, want 5", got)
+	}
+	if got := testutil.CollectAndCount(m.requestDuration); got != 2 {
+		t.Errorf("latency series = %d, want 2", got)
+	}
+}
+
+func TestInstrumentStorage(t *testing.T) {
+	ctx := context.Background()
+	m := New()
+	local, err := storage.NewLocalStorage(t.TempDir())
+	if err != nil {
+		t.Fatalf("failed to create local storage: %v", err)
+	}
+	store := m.InstrumentStorage(local, "local")
+
+	if err := store.Upload(ctx, "a/file", bytes.NewReader([]byte("data"))); err != nil {
+		t.Fatalf("Upload failed: %v", err)
+	}
+	if _, err := store.Stat(ctx, "a/missing"); err != storage.ErrFileNotFound {
+		t.Fatalf("Stat err = %v, want ErrFileNotFound", err)
+	}
+	// A file cannot also be a directory
+	if err := store.Upload(ctx, "a/file/nested", bytes.NewReader([]byte("data"))); err == nil {
+		t.Fatal("expected Upload below a file to fail")
+	}
+
+	if got := testutil.CollectAndCount(m.storageDuration); got != 2 {
+		t.Errorf("storage latency series = %d, want 2", got)
+	}
+	if got := testutil.ToFloat64(m.storageErrors.WithLabelValues("local", "stat")); got != 0 {
+		t.Errorf("stat errors = %v, want 0 for a missing file", got)
+	}
+	if got := testutil.ToFloat64(m.storageErrors.WithLabelValues("local", "upload")); got != 1 {
+		t.Errorf("upload errors = %v, want 1", got)
+	}
+}
+
+func TestUploadSessions(t *testing.T) {
+	ctx := context.Background()
+	m := New()
+	store, err := storage.NewLocalStorage(t.TempDir())
+	if err != nil {
+		t.Fatalf("failed to create local storage: %v", err)
+	}
+	sessions := oci.NewSessionManager(store, 30*time.Minute)
+	m.RegisterUploadSessions(sessions)
+
+	first, _ := sessions.Create(ctx, "app", "sha256")
+	sessions.Create(ctx, "app", "sha256")
+	sessions.Delete(ctx, first)
+
+	rec := httptest.NewRecorder()
+	m.Handler().ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/metrics", nil))
+	if !strings.Contains(rec.Body.String(), "package_universe_upload_sessions_active 1") {
+		t.Errorf("metrics output missing active upload sessions:\n%s", rec.Body.String())
+	}
+}
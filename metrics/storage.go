@@ -0,0 +1,102 @@
+package metrics
+
+import (
+	"context"
+	"io"
+	"time"
+
+	"github.com/hairizuanbinnoorazman/package-universe/storage"
+)
+
+// instrumentedStorage decorates a BlobStorage with latency and error metrics.
+type instrumentedStorage struct {
+	next    storage.BlobStorage
+	backend string
+	metrics *Metrics
+}
+
+// InstrumentStorage wraps store so every operation is timed and its failures
+// counted under the given backend label, such as "local" or "s3". Download
+// timings cover opening the data, not reading it. ErrFileNotFound is an
+// answer rather than a failure and is not counted as an error.
+func (m *Metrics) InstrumentStorage(store storage.BlobStorage, backend string) storage.BlobStorage {
+	return &instrumentedStorage{next: store, backend: backend, metrics: m}
+}
+
+// observe records an operation that started at start and returned err.
+func (s *instrumentedStorage) observe(operation string, start time.Time, err error) {
+	s.metrics.storageDuration.WithLabelValues(s.backend, operation).Observe(time.Since(start).Seconds())
+	if err != nil && err != storage.ErrFileNotFound {
+		s.metrics.storageErrors.WithLabelValues(s.backend, operation).Inc()
+	}
+}
+
+func (s *instrumentedStorage) Upload(ctx context.Context, path string, reader io.Reader) error {
+	start := time.Now()
+	err := s.next.Upload(ctx, path, reader)
+	s.observe("upload", start, err)
+	return err
+}
+
+func (s *instrumentedStorage) Download(ctx context.Context, path string) (io.ReadCloser, error) {
+	start := time.Now()
+	rc, err := s.next.Download(ctx, path)
+	s.observe("download", start, err)
+	return rc, err
+}
+
+func (s *instrumentedStorage) DownloadRange(ctx context.Context, path string, offset, length int64) (io.ReadCloser, error) {
+	start := time.Now()
+	rc, err := s.next.DownloadRange(ctx, path, offset, length)
+	s.observe("download_range", start, err)
+	return rc, err
+}
+
+func (s *instrumentedStorage) Delete(ctx context.Context, path string) error {
+	start := time.Now()
+	err := s.next.Delete(ctx, path)
+	s.observe("delete", start, err)
+	return err
+}
+
+func (s *instrumentedStorage) Exists(ctx context.Context, path string) (bool, error) {
+	start := time.Now()
+	exists, err := s.next.Exists(ctx, path)
+	s.observe("exists", start, err)
+	return exists, err
+}
+
+func (s *instrumentedStorage) Stat(ctx context.Context, path string) (*storage.FileInfo, error) {
+	start := time.Now()
+	info, err := s.next.Stat(ctx, path)
+	s.observe("stat", start, err)
+	return info, err
+}
+
+func (s *instrumentedStorage) GetURL(ctx context.Context, path string) (string, error) {
+	start := time.Now()
+	url, err := s.next.GetURL(ctx, path)
+	s.observe("get_url", start, err)
+	return url, err
+}
+
+func (s *instrumentedStorage) List(ctx context.Context, prefix string) ([]string, error) {
+	start := time.Now()
+	names, err := s.next.List(ctx, prefix)
+	s.observe("list", start, err)
+	return names, err
+}
+
+func (s *instrumentedStorage) Append(ctx context.Context, path string, reader io.Reader) (int64, error) {
+	start := time.Now()
+	n, err := s.next.Append(ctx, path, reader)
+	s.observe("append", start, err)
+	return n, err
+}
+
+func (s *instrumentedStorage) Move(ctx context.Context, src, dst string) error {
+	start := time.Now()
+	err := s.next.Move(ctx, src, dst)
+	s.observe("move", start, err)
+	return err
+}
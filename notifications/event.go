@@ -0,0 +1,65 @@
+// Package notifications delivers registry events to HTTP endpoints using the
+// docker/distribution notification envelope. Events are queued per endpoint
+// in bounded in-memory queues and posted by a worker per endpoint, which
+// retries failed deliveries with exponential backoff.
+package notifications
+
+import "time"
+
+// EventsMediaType is the content type of a notification envelope.
+const EventsMediaType = "application/vnd.docker.distribution.events.v1+json"
+
+// Event actions.
+const (
+	ActionPush   = "push"
+	ActionPull   = "pull"
+	ActionDelete = "delete"
+)
+
+// Envelope is the body posted to endpoints.
+type Envelope struct {
+	Events []Event `json:"events"`
+}
+
+// Event describes an action on a manifest or blob.
+type Event struct {
+	ID        string    `json:"id"`
+	Timestamp time.Time `json:"timestamp"`
+	Action    string    `json:"action"`
+	Target    Target    `json:"target"`
+	Request   Request   `json:"request"`
+	Actor     Actor     `json:"actor"`
+	Source    Source    `json:"source"`
+}
+
+// Target is the manifest or blob an event is about. Pushing a tag yields a
+// push event whose target carries the tag.
+type Target struct {
+	MediaType  string `json:"mediaType,omitempty"`
+	Size       int64  `json:"size,omitempty"`
+	Digest     string `json:"digest,omitempty"`
+	Length     int64  `json:"length,omitempty"`
+	Repository string `json:"repository"`
+	URL        string `json:"url,omitempty"`
+	Tag        string `json:"tag,omitempty"`
+}
+
+// Request describes the HTTP request that caused an event.
+type Request struct {
+	ID        string `json:"id,omitempty"`
+	Addr      string `json:"addr,omitempty"`
+	Host      string `json:"host,omitempty"`
+	Method    string `json:"method"`
+	UserAgent string `json:"useragent,omitempty"`
+}
+
+// Actor is the authenticated user behind an event; empty for anonymous requests.
+type Actor struct {
+	Name string `json:"name,omitempty"`
+}
+
+// Source identifies the registry instance that emitted an event.
+type Source struct {
+	Addr       string `json:"addr,omitempty"`
+	InstanceID string `json:"instanceID,omitempty"`
+}
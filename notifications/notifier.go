@@ -0,0 +1,316 @@
+package notifications
+
+import (
+	"bytes"
+	"context"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"path"
+	"sync"
+	"time"
+
+	"github.com/hairizuanbinnoorazman/package-universe/logger"
+)
+
+// SignatureHeader carries the HMAC-SHA256 of the request body, formatted as
+// "sha256=<hex>", when an endpoint has a secret.
+const SignatureHeader = "X-Registry-Signature"
+
+// MaxBackoff caps the delay between delivery attempts.
+const MaxBackoff = time.Minute
+
+// maxBatch is the most events posted in one envelope.
+const maxBatch = 100
+
+// Endpoint is an HTTP endpoint that receives registry events.
+type Endpoint struct {
+	Name   string
+	URL    string
+	Secret string // Signs deliveries with HMAC-SHA256 when set
+	// Actions limits delivery to these event actions; empty delivers every action.
+	Actions []string
+	// Repositories are path.Match globs selecting the repositories whose
+	// events are delivered; empty delivers events of every repository.
+	Repositories []string
+	Timeout      time.Duration // Per-request timeout
+	QueueSize    int           // Events buffered before new ones are dropped
+	MaxAttempts  int           // Delivery attempts before a batch is dropped
+	Backoff      time.Duration // Delay before the first retry, doubled per attempt
+}
+
+// Matches reports whether event should be delivered to the endpoint.
+func (e Endpoint) Matches(event Event) bool {
+	if len(e.Actions) > 0 && !contains(e.Actions, event.Action) {
+		return false
+	}
+	if len(e.Repositories) == 0 {
+		return true
+	}
+	for _, pattern := range e.Repositories {
+		if ok, _ := path.Match(pattern, event.Target.Repository); ok {
+			return true
+		}
+	}
+	return false
+}
+
+// validate checks the endpoint and fills in defaults.
+func (e *Endpoint) validate() error {
+	if e.Name == "" {
+		return fmt.Errorf("notification endpoint needs a name")
+	}
+	u, err := url.Parse(e.URL)
+	if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+		return fmt.Errorf("notification endpoint %q has invalid url %q", e.Name, e.URL)
+	}
+	for _, action := range e.Actions {
+		if action != ActionPush && action != ActionPull && action != ActionDelete {
+			return fmt.Errorf("notification endpoint %q has unknown action %q", e.Name, action)
+		}
+	}
+	for _, pattern := range e.Repositories {
+		if _, err := path.Match(pattern, ""); err != nil {
+			return fmt.Errorf("notification endpoint %q has invalid repository pattern %q: %w", e.Name, pattern, err)
+		}
+	}
+	if e.Timeout <= 0 {
+		e.Timeout = 5 * time.Second
+	}
+	if e.QueueSize <= 0 {
+		e.QueueSize = 1000
+	}
+	if e.MaxAttempts <= 0 {
+		e.MaxAttempts = 5
+	}
+	if e.Backoff <= 0 {
+		e.Backoff = time.Second
+	}
+	return nil
+}
+
+// Notifier queues events for every matching endpoint. Each endpoint has its
+// own bounded queue and worker, so a slow or failing endpoint only delays
+// its own deliveries. Queued events are held in memory and are lost if the
+// server stops before they are delivered.
+type Notifier struct {
+	source Source
+	logger logger.Logger
+	sinks  []*sink
+}
+
+// sink delivers the events queued for one endpoint.
+type sink struct {
+	endpoint Endpoint
+	queue    chan Event
+	client   *http.Client
+}
+
+// NewNotifier creates a notifier that stamps events with source and delivers
+// them to endpoints once Run is started. A random instance ID is generated
+// when source has none.
+func NewNotifier(endpoints []Endpoint, source Source, log logger.Logger) (*Notifier, error) {
+	if source.InstanceID == "" {
+		source.InstanceID = newEventID()
+	}
+	n := &Notifier{source: source, logger: log}
+	names := make(map[string]bool)
+	for _, endpoint := range endpoints {
+		if err := endpoint.validate(); err != nil {
+			return nil, err
+		}
+		if names[endpoint.Name] {
+			return nil, fmt.Errorf("duplicate notification endpoint %q", endpoint.Name)
+		}
+		names[endpoint.Name] = true
+		n.sinks = append(n.sinks, &sink{
+			endpoint: endpoint,
+			queue:    make(chan Event, endpoint.QueueSize),
+			client:   &http.Client{Timeout: endpoint.Timeout},
+		})
+	}
+	return n, nil
+}
+
+// Endpoints returns the configured endpoints.
+func (n *Notifier) Endpoints() []Endpoint {
+	endpoints := make([]Endpoint, 0, len(n.sinks))
+	for _, s := range n.sinks {
+		endpoints = append(endpoints, s.endpoint)
+	}
+	return endpoints
+}
+
+// Notify queues event for every endpoint whose filters match. It never
+// blocks: when an endpoint's queue is full the event is dropped for that
+// endpoint and the drop is logged.
+func (n *Notifier) Notify(ctx context.Context, event Event) {
+	if event.ID == "" {
+		event.ID = newEventID()
+	}
+	if event.Timestamp.IsZero() {
+		event.Timestamp = time.Now().UTC()
+	}
+	event.Source = n.source
+
+	for _, s := range n.sinks {
+		if !s.endpoint.Matches(event) {
+			continue
+		}
+		select {
+		case s.queue <- event:
+		default:
+			n.logger.Error(ctx, "notification queue full, event dropped", map[string]interface{}{
+				"endpoint":   s.endpoint.Name,
+				"action":     event.Action,
+				"repository": event.Target.Repository,
+				"digest":     event.Target.Digest,
+			})
+		}
+	}
+}
+
+// Run delivers queued events until ctx is cancelled.
+func (n *Notifier) Run(ctx context.Context) {
+	var wg sync.WaitGroup
+	for _, s := range n.sinks {
+		wg.Add(1)
+		go func(s *sink) {
+			defer wg.Done()
+			n.runSink(ctx, s)
+		}(s)
+	}
+	wg.Wait()
+}
+
+// runSink posts the events queued for one endpoint, batching events that
+// arrived while the previous delivery was in progress.
+func (n *Notifier) runSink(ctx context.Context, s *sink) {
+	for {
+		var batch []Event
+		select {
+		case <-ctx.Done():
+			return
+		case event := <-s.queue:
+			batch = append(batch, event)
+		}
+	drain:
+		for len(batch) < maxBatch {
+			select {
+			case event := <-s.queue:
+				batch = append(batch, event)
+			default:
+				break drain
+			}
+		}
+		n.deliver(ctx, s, batch)
+	}
+}
+
+// deliver posts batch to the endpoint, retrying with exponential backoff
+// until it is accepted, MaxAttempts is reached or ctx is cancelled.
+func (n *Notifier) deliver(ctx context.Context, s *sink, batch []Event) {
+	body, err := json.Marshal(Envelope{Events: batch})
+	if err != nil {
+		n.logger.Error(ctx, "failed to encode notification", map[string]interface{}{"endpoint": s.endpoint.Name, "error": err.Error()})
+		return
+	}
+
+	fields := map[string]interface{}{
+		"endpoint": s.endpoint.Name,
+		"events":   len(batch),
+	}
+	for attempt := 1; ; attempt++ {
+		fields["attempts"] = attempt
+		err := s.post(ctx, body)
+		if err == nil {
+			n.logger.Debug(ctx, "notification delivered", fields)
+			return
+		}
+		if ctx.Err() != nil {
+			return
+		}
+
+		fields["error"] = err.Error()
+		if attempt >= s.endpoint.MaxAttempts {
+			n.logger.Error(ctx, "notification delivery abandoned", fields)
+			return
+		}
+		n.logger.Warn(ctx, "notification delivery failed, will retry", fields)
+		delete(fields, "error")
+
+		timer := time.NewTimer(backoff(s.endpoint.Backoff, attempt))
+		select {
+		case <-ctx.Done():
+			timer.Stop()
+			return
+		case <-timer.C:
+		}
+	}
+}
+
+// post sends one envelope; any status other than 2xx is a failure.
+func (s *sink) post(ctx context.Context, body []byte) error {
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, s.endpoint.URL, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", EventsMediaType)
+	if s.endpoint.Secret != "" {
+		req.Header.Set(SignatureHeader, Sign(s.endpoint.Secret, body))
+	}
+
+	resp, err := s.client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	io.Copy(io.Discard, io.LimitReader(resp.Body, 4096))
+
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		return fmt.Errorf("endpoint responded with status %d", resp.StatusCode)
+	}
+	return nil
+}
+
+// Sign returns the signature header value for body signed with secret.
+// Receivers verify a delivery by computing it over the raw request body.
+func Sign(secret string, body []byte) string {
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write(body)
+	return "sha256=" + hex.EncodeToString(mac.Sum(nil))
+}
+
+// backoff returns the delay after the given failed attempt.
+func backoff(initial time.Duration, attempt int) time.Duration {
+	delay := initial
+	for i := 1; i < attempt && delay < MaxBackoff; i++ {
+		delay *= 2
+	}
+	if delay > MaxBackoff {
+		delay = MaxBackoff
+	}
+	return delay
+}
+
+// newEventID returns a random identifier for an event or registry instance.
+func newEventID() string {
+	b := make([]byte, 16)
+	rand.Read(b)
+	return hex.EncodeToString(b)
+}
+
+func contains(list []string, s string) bool {
+	for _, v := range list {
+		if v == s {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,228 @@
+package notifications
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/hairizuanbinnoorazman/package-universe/logger"
+)
+
+// receiver records the envelopes posted to it, failing the first failures requests.
+type receiver struct {
+	mu         sync.Mutex
+	failures   int
+	requests   int
+	events     []Event
+	signatures []string
+	lastBody   []byte
+	server     *httptest.Server
+}
+
+func newReceiver(t *testing.T, failures int) *receiver {
+	t.Helper()
+	rec := &receiver{failures: failures}
+	rec.server = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := io.ReadAll(r.Body)
+
+		rec.mu.Lock()
+		defer rec.mu.Unlock()
+		rec.requests++
+		if rec.requests <= rec.failures {
+			w.WriteHeader(http.StatusServiceUnavailable)
+			return
+		}
+		if r.Header.Get("Content-Type") != EventsMediaType {
+			t.Errorf("Content-Type = %q, want %q", r.Header.Get("Content-Type"), EventsMediaType)
+		}
+		var envelope Envelope
+		if err := json.Unmarshal(body, &envelope); err != nil {
+			t.Errorf("failed to decode envelope: %v", err)
+		}
+		rec.events = append(rec.events, envelope.Events...)
+		rec.signatures = append(rec.signatures, r.Header.Get(SignatureHeader))
+		rec.lastBody = body
+	}))
+	t.Cleanup(rec.server.Close)
+	return rec
+}
+
+// waitForEvents waits until n events have been received and returns them.
+func (rec *receiver) waitForEvents(t *testing.T, n int) []Event {
+	t.Helper()
+	deadline := time.Now().Add(5 * time.Second)
+	for time.Now().Before(deadline) {
+		rec.mu.Lock()
+		if len(rec.events) >= n {
+			events := append([]Event(nil), rec.events...)
+			rec.mu.Unlock()
+			return events
+		}
+		rec.mu.Unlock()
+		time.Sleep(10 * time.Millisecond)
+	}
+	t.Fatalf("timed out waiting for %d events", n)
+	return nil
+}
+
+func startNotifier(t *testing.T, endpoints ...Endpoint) *Notifier {
+	t.Helper()
+	n, err := NewNotifier(endpoints, Source{Addr: "registry:8080"}, logger.NewTestLogger())
+	if err != nil {
+		t.Fatalf("NewNotifier failed: %v", err)
+	}
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		n.Run(ctx)
+	}()
+	t.Cleanup(func() {
+		cancel()
+		<-done
+	})
+	return n
+}
+
+func pushEvent(repository, tag string) Event {
+	return Event{
+		Action: ActionPush,
+		Target: Target{
+			MediaType:  "application/vnd.oci.image.manifest.v1+json",
+			Digest:     "sha256:abc",
+			Repository: repository,
+			Tag:        tag,
+		},
+		Request: Request{Method: http.MethodPut},
+	}
+}
+
+func TestNotifierDelivery(t *testing.T) {
+	rec := newReceiver(t, 0)
+	n := startNotifier(t, Endpoint{Name: "hook", URL: rec.server.URL, Secret: "s3cret"})
+
+	n.Notify(context.Background(), pushEvent("app", "v1"))
+	events := rec.waitForEvents(t, 1)
+
+	event := events[0]
+	if event.ID == "" || event.Timestamp.IsZero() {
+		t.Errorf("event should have an id and timestamp, got %+v", event)
+	}
+	if event.Action != ActionPush || event.Target.Repository != "app" || event.Target.Tag != "v1" {
+		t.Errorf("unexpected event %+v", event)
+	}
+	if event.Source.Addr != "registry:8080" || event.Source.InstanceID == "" {
+		t.Errorf("unexpected source %+v", event.Source)
+	}
+
+	rec.mu.Lock()
+	defer rec.mu.Unlock()
+	if want := Sign("s3cret", rec.lastBody); rec.signatures[0] != want {
+		t.Errorf("signature = %q, want %q", rec.signatures[0], want)
+	}
+}
+
+func TestNotifierFilters(t *testing.T) {
+	rec := newReceiver(t, 0)
+	n := startNotifier(t, Endpoint{
+		Name:         "hook",
+		URL:          rec.server.URL,
+		Actions:      []string{ActionPush, ActionDelete},
+		Repositories: []string{"team/*"},
+	})
+
+	ctx := context.Background()
+	n.Notify(ctx, pushEvent("other", "v1"))
+	pull := pushEvent("team/app", "v1")
+	pull.Action = ActionPull
+	n.Notify(ctx, pull)
+	n.Notify(ctx, pushEvent("team/app", "v2"))
+
+	events := rec.waitForEvents(t, 1)
+	time.Sleep(50 * time.Millisecond)
+	rec.mu.Lock()
+	defer rec.mu.Unlock()
+	if len(rec.events) != 1 || events[0].Target.Tag != "v2" {
+		t.Errorf("expected only the team/app:v2 push, got %+v", rec.events)
+	}
+}
+
+func TestNotifierRetry(t *testing.T) {
+	rec := newReceiver(t, 2)
+	n := startNotifier(t, Endpoint{Name: "hook", URL: rec.server.URL, Backoff: time.Millisecond})
+
+	n.Notify(context.Background(), pushEvent("app", "v1"))
+	rec.waitForEvents(t, 1)
+
+	rec.mu.Lock()
+	defer rec.mu.Unlock()
+	if rec.requests != 3 {
+		t.Errorf("requests = %d, want 3", rec.requests)
+	}
+}
+
+func TestNotifierAbandon(t *testing.T) {
+	rec := newReceiver(t, 100)
+	n := startNotifier(t, Endpoint{Name: "hook", URL: rec.server.URL, Backoff: time.Millisecond, MaxAttempts: 2})
+
+	n.Notify(context.Background(), pushEvent("app", "v1"))
+	time.Sleep(200 * time.Millisecond)
+
+	rec.mu.Lock()
+	defer rec.mu.Unlock()
+	if rec.requests != 2 {
+		t.Errorf("requests = %d, want 2", rec.requests)
+	}
+}
+
+func TestNotifierQueueFull(t *testing.T) {
+	// Without Run nothing is consumed, so the queue fills up
+	n, err := NewNotifier([]Endpoint{{Name: "hook", URL: "http://example.com", QueueSize: 2}}, Source{}, logger.NewTestLogger())
+	if err != nil {
+		t.Fatalf("NewNotifier failed: %v", err)
+	}
+	for i := 0; i < 5; i++ {
+		n.Notify(context.Background(), pushEvent("app", "v1"))
+	}
+	if queued := len(n.sinks[0].queue); queued != 2 {
+		t.Errorf("queued = %d, want 2", queued)
+	}
+}
+
+func TestNewNotifierValidation(t *testing.T) {
+	tests := map[string]Endpoint{
+		"missing name":    {URL: "http://example.com"},
+		"invalid url":     {Name: "hook", URL: "example.com"},
+		"unknown action":  {Name: "hook", URL: "http://example.com", Actions: []string{"mount"}},
+		"invalid pattern": {Name: "hook", URL: "http://example.com", Repositories: []string{"["}},
+	}
+	for name, endpoint := range tests {
+		if _, err := NewNotifier([]Endpoint{endpoint}, Source{}, logger.NewTestLogger()); err == nil {
+			t.Errorf("%s: expected endpoint to be rejected", name)
+		}
+	}
+
+	duplicate := Endpoint{Name: "hook", URL: "http://example.com"}
+	if _, err := NewNotifier([]Endpoint{duplicate, duplicate}, Source{}, logger.NewTestLogger()); err == nil {
+		t.Error("expected duplicate endpoint names to be rejected")
+	}
+}
+
+func TestBackoff(t *testing.T) {
+	tests := map[int]time.Duration{
+		1:  time.Second,
+		2:  2 * time.Second,
+		4:  8 * time.Second,
+		10: MaxBackoff,
+	}
+	for attempt, want := range tests {
+		if got := backoff(time.Second, attempt); got != want {
+			t.Errorf("backoff(%d) = %v, want %v", attempt, got, want)
+		}
+	}
+}
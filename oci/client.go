@@ -0,0 +1,472 @@
+package oci
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// manifestAccept lists the manifest media types requested from remote registries.
+var manifestAccept = strings.Join([]string{
+	MediaTypeImageManifest,
+	MediaTypeImageIndex,
+	MediaTypeDockerManifest,
+	MediaTypeDockerManifestList,
+}, ", ")
+
+// Client talks to a remote OCI distribution registry, both to pull through a
+// cache and to push replicas. When the registry answers 401 with a Bearer
+// challenge, the client fetches a token for the requested scope from the
+// challenge's realm (sending Basic credentials when configured), caches it
+// until it expires and retries the request.
+type Client struct {
+	baseURL  string
+	username string
+	password string
+	http     *http.Client
+
+	mu     sync.Mutex
+	tokens map[string]bearerToken
+}
+
+// bearerToken is a token issued for one scope.
+type bearerToken struct {
+	value   string
+	expires time.Time
+}
+
+// NewClient creates a client for the registry at baseURL, e.g.
+// "https://registry-1.docker.io". Username and password may be empty for
+// anonymous access. Timeout bounds each request, including reading its
+// response body, so a remote that stops responding cannot hang a pull or a
+// replication; zero disables it.
+func NewClient(baseURL, username, password string, timeout time.Duration) *Client {
+	return &Client{
+		baseURL:  strings.TrimSuffix(baseURL, "/"),
+		username: username,
+		password: password,
+		http:     &http.Client{Transport: tracingTransport{base: http.DefaultTransport}, Timeout: timeout},
+		tokens:   make(map[string]bearerToken),
+	}
+}
+
+// Host returns the host name of the remote registry.
+func (c *Client) Host() string {
+	u, err := url.Parse(c.baseURL)
+	if err != nil {
+		return ""
+	}
+	return u.Hostname()
+}
+
+// GetManifest fetches a manifest by tag or digest and returns its content and
+// content type. Returns ErrManifestNotFound if the registry does not have it.
+func (c *Client) GetManifest(ctx context.Context, name, reference string) ([]byte, string, error) {
+	resp, err := c.do(ctx, pullScope(name), func() (*http.Request, error) {
+		req, err := http.NewRequestWithContext(ctx, http.MethodGet, c.url(name, "manifests", reference), nil)
+		if err == nil {
+			req.Header.Set("Accept", manifestAccept)
+		}
+		return req, err
+	})
+	if err != nil {
+		return nil, "", err
+	}
+	defer resp.Body.Close()
+
+	if err := checkPullResponse(resp, http.StatusOK, ErrManifestNotFound); err != nil {
+		return nil, "", err
+	}
+	data, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return nil, "", fmt.Errorf("failed to read remote manifest: %w", err)
+	}
+	return data, resp.Header.Get("Content-Type"), nil
+}
+
+// HeadManifest returns the digest a tag or digest reference resolves to on
+// the remote registry. Returns ErrManifestNotFound if the registry does not have it.
+func (c *Client) HeadManifest(ctx context.Context, name, reference string) (DigestInfo, error) {
+	resp, err := c.do(ctx, pullScope(name), func() (*http.Request, error) {
+		req, err := http.NewRequestWithContext(ctx, http.MethodHead, c.url(name, "manifests", reference), nil)
+		if err == nil {
+			req.Header.Set("Accept", manifestAccept)
+		}
+		return req, err
+	})
+	if err != nil {
+		return DigestInfo{}, err
+	}
+	resp.Body.Close()
+
+	if err := checkPullResponse(resp, http.StatusOK, ErrManifestNotFound); err != nil {
+		return DigestInfo{}, err
+	}
+	digest, err := ParseDigest(resp.Header.Get("Docker-Content-Digest"))
+	if err != nil {
+		return DigestInfo{}, fmt.Errorf("remote registry returned no usable digest: %w", err)
+	}
+	return digest, nil
+}
+
+// GetBlob opens a blob on the remote registry and returns its content and
+// size. Returns ErrBlobNotFound if the registry does not have it.
+func (c *Client) GetBlob(ctx context.Context, name string, digest DigestInfo) (io.ReadCloser, int64, error) {
+	return c.getBlob(ctx, name, digest, "")
+}
+
+// GetBlobRange opens length bytes of a remote blob starting at offset. A
+// negative length reads to the end of the blob.
+func (c *Client) GetBlobRange(ctx context.Context, name string, digest DigestInfo, offset, length int64) (io.ReadCloser, error) {
+	rangeHeader := fmt.Sprintf("bytes=%d-", offset)
+	if length >= 0 {
+		rangeHeader += strconv.FormatInt(offset+length-1, 10)
+	}
+	rc, _, err := c.getBlob(ctx, name, digest, rangeHeader)
+	return rc, err
+}
+
+// HeadBlob returns the size of a blob on the remote registry. Returns
+// ErrBlobNotFound if the registry does not have it. When the HEAD response
+// carries no length, the size is taken from a one-byte ranged GET instead.
+func (c *Client) HeadBlob(ctx context.Context, name string, digest DigestInfo) (int64, error) {
+	resp, err := c.do(ctx, pullScope(name), func() (*http.Request, error) {
+		return http.NewRequestWithContext(ctx, http.MethodHead, c.url(name, "blobs", digest.String()), nil)
+	})
+	if err != nil {
+		return 0, err
+	}
+	resp.Body.Close()
+
+	if err := checkPullResponse(resp, http.StatusOK, ErrBlobNotFound); err != nil {
+		return 0, err
+	}
+	if resp.ContentLength >= 0 {
+		return resp.ContentLength, nil
+	}
+	return c.rangedBlobSize(ctx, name, digest)
+}
+
+// rangedBlobSize returns the size of a blob from the Content-Range of a GET
+// for its first byte, or from the Content-Length of a remote that ignores the
+// range. It fails if neither reports the size.
+func (c *Client) rangedBlobSize(ctx context.Context, name string, digest DigestInfo) (int64, error) {
+	resp, err := c.do(ctx, pullScope(name), func() (*http.Request, error) {
+		req, err := http.NewRequestWithContext(ctx, http.MethodGet, c.url(name, "blobs", digest.String()), nil)
+		if err == nil {
+			req.Header.Set("Range", "bytes=0-0")
+		}
+		return req, err
+	})
+	if err != nil {
+		return 0, err
+	}
+	defer resp.Body.Close()
+
+	switch resp.StatusCode {
+	case http.StatusOK:
+		if resp.ContentLength >= 0 {
+			return resp.ContentLength, nil
+		}
+	case http.StatusPartialContent:
+		contentRange := resp.Header.Get("Content-Range")
+		if i := strings.LastIndex(contentRange, "/"); i >= 0 {
+			if size, err := strconv.ParseInt(contentRange[i+1:], 10, 64); err == nil && size >= 0 {
+				return size, nil
+			}
+		}
+	default:
+		if err := checkPullResponse(resp, http.StatusPartialContent, ErrBlobNotFound); err != nil {
+			return 0, err
+		}
+	}
+	return 0, fmt.Errorf("remote registry did not report the size of blob %s", digest)
+}
+
+// PushBlob uploads a blob to the remote registry with a POST followed by a
+// monolithic PUT. open is called for each attempt to obtain the content.
+func (c *Client) PushBlob(ctx context.Context, name string, digest DigestInfo, size int64, open func() (io.ReadCloser, error)) error {
+	resp, err := c.do(ctx, pushScope(name), func() (*http.Request, error) {
+		return http.NewRequestWithContext(ctx, http.MethodPost, c.baseURL+"/v2/"+name+"/blobs/uploads/", nil)
+	})
+	if err != nil {
+		return err
+	}
+	resp.Body.Close()
+	if err := checkResponse(resp, http.StatusAccepted, nil); err != nil {
+		return err
+	}
+
+	location, err := resp.Request.URL.Parse(resp.Header.Get("Location"))
+	if err != nil || resp.Header.Get("Location") == "" {
+		return fmt.Errorf("remote registry returned an invalid upload location %q", resp.Header.Get("Location"))
+	}
+	query := location.Query()
+	query.Set("digest", digest.String())
+	location.RawQuery = query.Encode()
+
+	resp, err = c.do(ctx, pushScope(name), func() (*http.Request, error) {
+		body, err := open()
+		if err != nil {
+			return nil, err
+		}
+		req, err := http.NewRequestWithContext(ctx, http.MethodPut, location.String(), body)
+		if err != nil {
+			body.Close()
+			return nil, err
+		}
+		req.ContentLength = size
+		req.Header.Set("Content-Type", "application/octet-stream")
+		return req, nil
+	})
+	if err != nil {
+		return err
+	}
+	resp.Body.Close()
+	return checkResponse(resp, http.StatusCreated, ErrUploadNotFound)
+}
+
+// PutManifest pushes a manifest to the remote registry under a tag or digest reference.
+func (c *Client) PutManifest(ctx context.Context, name, reference, contentType string, data []byte) error {
+	resp, err := c.do(ctx, pushScope(name), func() (*http.Request, error) {
+		req, err := http.NewRequestWithContext(ctx, http.MethodPut, c.url(name, "manifests", reference), bytes.NewReader(data))
+		if err == nil {
+			req.Header.Set("Content-Type", contentType)
+		}
+		return req, err
+	})
+	if err != nil {
+		return err
+	}
+	resp.Body.Close()
+	return checkResponse(resp, http.StatusCreated, nil)
+}
+
+// getBlob issues a blob GET, optionally with a Range header.
+func (c *Client) getBlob(ctx context.Context, name string, digest DigestInfo, rangeHeader string) (io.ReadCloser, int64, error) {
+	resp, err := c.do(ctx, pullScope(name), func() (*http.Request, error) {
+		req, err := http.NewRequestWithContext(ctx, http.MethodGet, c.url(name, "blobs", digest.String()), nil)
+		if err == nil && rangeHeader != "" {
+			req.Header.Set("Range", rangeHeader)
+		}
+		return req, err
+	})
+	if err != nil {
+		return nil, 0, err
+	}
+
+	want := http.StatusOK
+	if rangeHeader != "" {
+		want = http.StatusPartialContent
+	}
+	if err := checkPullResponse(resp, want, ErrBlobNotFound); err != nil {
+		resp.Body.Close()
+		return nil, 0, err
+	}
+	return resp.Body, resp.ContentLength, nil
+}
+
+// url builds the URL of a repository endpoint on the remote registry.
+func (c *Client) url(name, kind, reference string) string {
+	return fmt.Sprintf("%s/v2/%s/%s/%s", c.baseURL, name, kind, reference)
+}
+
+// do sends the request built by newRequest, authorizing it with a cached
+// token for scope. On a 401 challenge it authenticates and retries once, so
+// newRequest must be able to build the request again.
+func (c *Client) do(ctx context.Context, scope string, newRequest func() (*http.Request, error)) (*http.Response, error) {
+	req, err := newRequest()
+	if err != nil {
+		return nil, fmt.Errorf("failed to build remote request: %w", err)
+	}
+	c.authorize(req, scope)
+
+	resp, err := c.http.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("remote request failed: %w", err)
+	}
+	if resp.StatusCode != http.StatusUnauthorized {
+		return resp, nil
+	}
+	challenge := resp.Header.Get("WWW-Authenticate")
+	resp.Body.Close()
+
+	if err := c.authenticate(ctx, challenge, scope); err != nil {
+		return nil, err
+	}
+
+	req, err = newRequest()
+	if err != nil {
+		return nil, fmt.Errorf("failed to build remote request: %w", err)
+	}
+	c.authorize(req, scope)
+
+	resp, err = c.http.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("remote request failed: %w", err)
+	}
+	return resp, nil
+}
+
+// authorize adds credentials for scope to the request: a cached bearer token
+// if one is valid, otherwise Basic credentials when configured.
+func (c *Client) authorize(req *http.Request, scope string) {
+	c.mu.Lock()
+	token, ok := c.tokens[scope]
+	c.mu.Unlock()
+
+	if ok && time.Now().Before(token.expires) {
+		req.Header.Set("Authorization", "Bearer "+token.value)
+		return
+	}
+	if c.username != "" {
+		req.SetBasicAuth(c.username, c.password)
+	}
+}
+
+// authenticate answers a WWW-Authenticate challenge. Basic challenges are met
+// by the credentials authorize already sends; Bearer challenges fetch a token
+// for scope from the realm.
+func (c *Client) authenticate(ctx context.Context, challenge, scope string) error {
+	scheme, params := parseChallenge(challenge)
+	switch scheme {
+	case "basic":
+		if c.username == "" {
+			return fmt.Errorf("remote registry requires credentials")
+		}
+		return fmt.Errorf("remote registry rejected the configured credentials")
+	case "bearer":
+	default:
+		return fmt.Errorf("remote registry sent unsupported challenge %q", challenge)
+	}
+
+	realm := params["realm"]
+	if realm == "" {
+		return fmt.Errorf("remote registry sent a bearer challenge without a realm")
+	}
+	tokenURL, err := url.Parse(realm)
+	if err != nil {
+		return fmt.Errorf("invalid token realm %q: %w", realm, err)
+	}
+	query := tokenURL.Query()
+	if service := params["service"]; service != "" {
+		query.Set("service", service)
+	}
+	query.Set("scope", scope)
+	tokenURL.RawQuery = query.Encode()
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, tokenURL.String(), nil)
+	if err != nil {
+		return fmt.Errorf("failed to build token request: %w", err)
+	}
+	if c.username != "" {
+		req.SetBasicAuth(c.username, c.password)
+	}
+
+	resp, err := c.http.Do(req)
+	if err != nil {
+		return fmt.Errorf("token request failed: %w", err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("token request failed with status %d", resp.StatusCode)
+	}
+
+	var body struct {
+		Token       string `json:"token"`
+		AccessToken string `json:"access_token"`
+		ExpiresIn   int    `json:"expires_in"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&body); err != nil {
+		return fmt.Errorf("failed to decode token response: %w", err)
+	}
+	value := body.Token
+	if value == "" {
+		value = body.AccessToken
+	}
+	if value == "" {
+		return fmt.Errorf("token response contained no token")
+	}
+	// Tokens without an expiry are valid for at least 60 seconds per the token spec
+	expiresIn := body.ExpiresIn
+	if expiresIn <= 0 {
+		expiresIn = 60
+	}
+
+	c.mu.Lock()
+	c.tokens[scope] = bearerToken{value: value, expires: time.Now().Add(time.Duration(expiresIn) * time.Second)}
+	c.mu.Unlock()
+	return nil
+}
+
+// pullScope returns the token scope for reading a repository.
+func pullScope(name string) string {
+	return "repository:" + name + ":pull"
+}
+
+// pushScope returns the token scope for writing to a repository.
+func pushScope(name string) string {
+	return "repository:" + name + ":pull,push"
+}
+
+// checkResponse returns an error for any status other than want, using
+// notFound for a 404 when it is not nil.
+func checkResponse(resp *http.Response, want int, notFound error) error {
+	if resp.StatusCode == want {
+		return nil
+	}
+	if resp.StatusCode == http.StatusNotFound && notFound != nil {
+		return notFound
+	}
+	return fmt.Errorf("remote registry returned status %d for %s %s", resp.StatusCode, resp.Request.Method, resp.Request.URL.Path)
+}
+
+// checkPullResponse is checkResponse for pulls. Registries such as Docker Hub
+// answer pulls of repositories that do not exist, or that the credentials
+// cannot see, with 401 or 403 even after authenticating, so those are
+// reported as notFound too.
+func checkPullResponse(resp *http.Response, want int, notFound error) error {
+	if resp.StatusCode == http.StatusUnauthorized || resp.StatusCode == http.StatusForbidden {
+		return notFound
+	}
+	return checkResponse(resp, want, notFound)
+}
+
+// parseChallenge splits a WWW-Authenticate header into its lower-cased scheme
+// and parameters. Quoted values may contain commas, as in "pull,push" scopes.
+func parseChallenge(header string) (string, map[string]string) {
+	scheme, rest, _ := strings.Cut(strings.TrimSpace(header), " ")
+	params := make(map[string]string)
+
+	for rest != "" {
+		rest = strings.TrimLeft(rest, ", ")
+		key, value, ok := strings.Cut(rest, "=")
+		if !ok {
+			break
+		}
+		key = strings.ToLower(strings.TrimSpace(key))
+
+		if strings.HasPrefix(value, `"`) {
+			end := strings.Index(value[1:], `"`)
+			if end < 0 {
+				params[key] = value[1:]
+				break
+			}
+			params[key] = value[1 : end+1]
+			rest = value[end+2:]
+			continue
+		}
+
+		value, rest, _ = strings.Cut(value, ",")
+		params[key] = strings.TrimSpace(value)
+	}
+	return strings.ToLower(scheme), params
+}
@@ -0,0 +1,102 @@
+package oci
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+func TestClient_Timeout(t *testing.T) {
+	release := make(chan struct{})
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		<-release
+	}))
+	defer server.Close()
+	defer close(release)
+
+	client := NewClient(server.URL, "", "", 50*time.Millisecond)
+
+	done := make(chan error, 1)
+	go func() {
+		_, _, err := client.GetManifest(context.Background(), "library/nginx", "latest")
+		done <- err
+	}()
+
+	select {
+	case err := <-done:
+		if err == nil {
+			t.Error("expected an error from a remote that does not respond")
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("request to an unresponsive remote did not time out")
+	}
+}
+
+func TestClient_HeadBlobUnknownLength(t *testing.T) {
+	digest := computeSHA256([]byte("layer"))
+
+	tests := []struct {
+		name    string
+		get     func(w http.ResponseWriter, r *http.Request)
+		want    int64
+		wantErr bool
+	}{
+		{
+			name: "size from content range",
+			get: func(w http.ResponseWriter, r *http.Request) {
+				if r.Header.Get("Range") != "bytes=0-0" {
+					t.Errorf("Range = %q, want bytes=0-0", r.Header.Get("Range"))
+				}
+				w.Header().Set("Content-Range", "bytes 0-0/1234")
+				w.WriteHeader(http.StatusPartialContent)
+				w.Write([]byte("l"))
+			},
+			want: 1234,
+		},
+		{
+			name: "range ignored",
+			get: func(w http.ResponseWriter, r *http.Request) {
+				w.Header().Set("Content-Length", "5")
+				w.Write([]byte("layer"))
+			},
+			want: 5,
+		},
+		{
+			name: "size never reported",
+			get: func(w http.ResponseWriter, r *http.Request) {
+				w.WriteHeader(http.StatusPartialContent)
+			},
+			wantErr: true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				if r.Method == http.MethodHead {
+					// A chunked response reports no length
+					w.WriteHeader(http.StatusOK)
+					return
+				}
+				tt.get(w, r)
+			}))
+			defer server.Close()
+
+			size, err := NewClient(server.URL, "", "", 0).HeadBlob(context.Background(), "library/nginx", digest)
+			if tt.wantErr {
+				if err == nil {
+					t.Errorf("expected an error, got size %d", size)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("HeadBlob failed: %v", err)
+			}
+			if size != tt.want {
+				t.Errorf("size = %d, want %d", size, tt.want)
+			}
+		})
+	}
+}
@@ -2,6 +2,8 @@ package oci
 
 import (
 	"crypto/sha256"
+	"crypto/sha512"
+	"encoding"
 	"fmt"
 	"hash"
 	"io"
@@ -11,6 +13,40 @@ import (
 
 var digestRegexp = regexp.MustCompile(`^([a-z0-9]+):([a-f0-9]+)$`)
 
+// DefaultDigestAlgorithm is used when a client does not request an algorithm.
+const DefaultDigestAlgorithm = "sha256"
+
+// DigestAlgorithm describes a hash function usable for content addressing.
+type DigestAlgorithm struct {
+	Name      string
+	HexLength int
+	New       func() hash.Hash
+}
+
+var digestAlgorithms = map[string]DigestAlgorithm{
+	"sha256": {Name: "sha256", HexLength: sha256.Size * 2, New: sha256.New},
+	"sha512": {Name: "sha512", HexLength: sha512.Size * 2, New: sha512.New},
+}
+
+// RegisterDigestAlgorithm adds or replaces a supported digest algorithm.
+// It is not safe to call concurrently with digest parsing and should be used during startup.
+func RegisterDigestAlgorithm(alg DigestAlgorithm) {
+	digestAlgorithms[alg.Name] = alg
+}
+
+// LookupDigestAlgorithm returns the registered algorithm with the given name.
+// An empty name selects DefaultDigestAlgorithm.
+func LookupDigestAlgorithm(name string) (DigestAlgorithm, error) {
+	if name == "" {
+		name = DefaultDigestAlgorithm
+	}
+	alg, ok := digestAlgorithms[name]
+	if !ok {
+		return DigestAlgorithm{}, fmt.Errorf("%w: unsupported algorithm %q", ErrInvalidDigest, name)
+	}
+	return alg, nil
+}
+
 // DigestInfo holds a parsed digest in algorithm:hex format.
 type DigestInfo struct {
 	Algorithm string
@@ -30,33 +66,54 @@ func (d DigestInfo) ShortHex() string {
 	return d.Hex[:2]
 }
 
-// ParseDigest parses a digest string in algorithm:hex format.
+// ParseDigest parses a digest string in algorithm:hex format. The algorithm
+// must be registered and the hex part must have that algorithm's length.
 func ParseDigest(s string) (DigestInfo, error) {
 	s = strings.TrimSpace(s)
 	matches := digestRegexp.FindStringSubmatch(s)
 	if matches == nil {
 		return DigestInfo{}, fmt.Errorf("%w: %q", ErrInvalidDigest, s)
 	}
+	alg, err := LookupDigestAlgorithm(matches[1])
+	if err != nil {
+		return DigestInfo{}, err
+	}
+	if len(matches[2]) != alg.HexLength {
+		return DigestInfo{}, fmt.Errorf("%w: %q has wrong length for %s", ErrInvalidDigest, s, alg.Name)
+	}
 	return DigestInfo{
 		Algorithm: matches[1],
 		Hex:       matches[2],
 	}, nil
 }
 
-// VerifyingReader wraps a reader and computes sha256 as data is read.
+// VerifyingReader wraps a reader and computes a digest as data is read.
 type VerifyingReader struct {
-	reader io.Reader
-	hash   hash.Hash
-	size   int64
+	reader    io.Reader
+	algorithm string
+	hash      hash.Hash
+	size      int64
 }
 
 // NewVerifyingReader creates a new VerifyingReader that computes sha256.
 func NewVerifyingReader(r io.Reader) *VerifyingReader {
-	h := sha256.New()
-	return &VerifyingReader{
-		reader: io.TeeReader(r, h),
-		hash:   h,
+	vr, _ := NewVerifyingReaderWithAlgorithm(r, DefaultDigestAlgorithm)
+	return vr
+}
+
+// NewVerifyingReaderWithAlgorithm creates a new VerifyingReader that computes
+// the named algorithm.
+func NewVerifyingReaderWithAlgorithm(r io.Reader, algorithm string) (*VerifyingReader, error) {
+	alg, err := LookupDigestAlgorithm(algorithm)
+	if err != nil {
+		return nil, err
 	}
+	h := alg.New()
+	return &VerifyingReader{
+		reader:    io.TeeReader(r, h),
+		algorithm: alg.Name,
+		hash:      h,
+	}, nil
 }
 
 // Read implements io.Reader.
@@ -66,10 +123,10 @@ func (vr *VerifyingReader) Read(p []byte) (int, error) {
 	return n, err
 }
 
-// Digest returns the computed sha256 digest after all data has been read.
+// Digest returns the computed digest after all data has been read.
 func (vr *VerifyingReader) Digest() DigestInfo {
 	return DigestInfo{
-		Algorithm: "sha256",
+		Algorithm: vr.algorithm,
 		Hex:       fmt.Sprintf("%x", vr.hash.Sum(nil)),
 	}
 }
@@ -87,3 +144,52 @@ func (vr *VerifyingReader) Verify(expected DigestInfo) error {
 	}
 	return nil
 }
+
+// ResumableDigester computes a digest across several writes whose state
+// can be saved between them, so chunked uploads never re-read earlier data.
+type ResumableDigester struct {
+	algorithm string
+	hash      hash.Hash
+}
+
+// NewResumableDigester creates a digester for the named algorithm, restoring it
+// from state when non-empty. The algorithm's hash must support binary marshaling.
+func NewResumableDigester(algorithm string, state []byte) (*ResumableDigester, error) {
+	alg, err := LookupDigestAlgorithm(algorithm)
+	if err != nil {
+		return nil, err
+	}
+	h := alg.New()
+	unmarshaler, ok := h.(encoding.BinaryUnmarshaler)
+	if !ok {
+		return nil, fmt.Errorf("digest algorithm %s cannot be resumed", alg.Name)
+	}
+	if len(state) > 0 {
+		if err := unmarshaler.UnmarshalBinary(state); err != nil {
+			return nil, fmt.Errorf("failed to restore digest state: %w", err)
+		}
+	}
+	return &ResumableDigester{algorithm: alg.Name, hash: h}, nil
+}
+
+// Write implements io.Writer.
+func (d *ResumableDigester) Write(p []byte) (int, error) {
+	return d.hash.Write(p)
+}
+
+// State returns the marshaled digest state.
+func (d *ResumableDigester) State() ([]byte, error) {
+	marshaler, ok := d.hash.(encoding.BinaryMarshaler)
+	if !ok {
+		return nil, fmt.Errorf("digest algorithm %s cannot be resumed", d.algorithm)
+	}
+	return marshaler.MarshalBinary()
+}
+
+// Digest returns the digest of all data written so far.
+func (d *ResumableDigester) Digest() DigestInfo {
+	return DigestInfo{
+		Algorithm: d.algorithm,
+		Hex:       fmt.Sprintf("%x", d.hash.Sum(nil)),
+	}
+}
@@ -1,6 +1,7 @@
 package oci
 
 import (
+	"errors"
 	"io"
 	"strings"
 	"testing"
@@ -46,10 +47,25 @@ func TestParseDigest(t *testing.T) {
 			wantError: true,
 		},
 		{
-			name:     "short hex",
-			input:    "sha256:abcd",
-			wantAlgo: "sha256",
-			wantHex:  "abcd",
+			name:      "short hex",
+			input:     "sha256:abcd",
+			wantError: true,
+		},
+		{
+			name:     "valid sha512",
+			input:    "sha512:" + strings.Repeat("ab", 64),
+			wantAlgo: "sha512",
+			wantHex:  strings.Repeat("ab", 64),
+		},
+		{
+			name:      "sha512 with sha256 length",
+			input:     "sha512:abcdef1234567890abcdef1234567890abcdef1234567890abcdef1234567890",
+			wantError: true,
+		},
+		{
+			name:      "unsupported algorithm",
+			input:     "md5:d41d8cd98f00b204e9800998ecf8427e",
+			wantError: true,
 		},
 	}
 
@@ -127,6 +143,32 @@ func TestVerifyingReader(t *testing.T) {
 	}
 }
 
+func TestVerifyingReaderWithAlgorithm(t *testing.T) {
+	vr, err := NewVerifyingReaderWithAlgorithm(strings.NewReader("hello world"), "sha512")
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	io.ReadAll(vr)
+
+	// SHA512 of "hello world"
+	want := "sha512:309ecc489c12d6eb4cc40f50c902f2b4d0ed77ee511a7c7a9bcd3ca86d4cd86f989dd35bc5ff499670da34255b45b0cfd830e81f605dcf7dc5542e93ae9cd76f"
+	if vr.Digest().String() != want {
+		t.Errorf("digest = %s, want %s", vr.Digest().String(), want)
+	}
+
+	expected, err := ParseDigest(want)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if err := vr.Verify(expected); err != nil {
+		t.Errorf("unexpected error: %v", err)
+	}
+
+	if _, err := NewVerifyingReaderWithAlgorithm(strings.NewReader(""), "md5"); !errors.Is(err, ErrInvalidDigest) {
+		t.Errorf("expected ErrInvalidDigest, got %v", err)
+	}
+}
+
 func TestVerifyingReader_Verify(t *testing.T) {
 	data := "hello world"
 	vr := NewVerifyingReader(strings.NewReader(data))
@@ -153,3 +195,55 @@ func TestVerifyingReader_Verify(t *testing.T) {
 		}
 	})
 }
+
+func TestResumableDigester(t *testing.T) {
+	chunks := []string{"hello", " ", "world"}
+
+	var state []byte
+	for _, chunk := range chunks {
+		d, err := NewResumableDigester("sha256", state)
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		io.WriteString(d, chunk)
+		state, err = d.State()
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+	}
+
+	d, err := NewResumableDigester("sha256", state)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	want := "sha256:b94d27b9934d3e08a52e52d7da7dabfac484efe37a5380ee9088f7ace2efcde9"
+	if d.Digest().String() != want {
+		t.Errorf("digest = %s, want %s", d.Digest().String(), want)
+	}
+
+	if _, err := NewResumableDigester("sha256", []byte("garbage")); err == nil {
+		t.Error("expected error for invalid state")
+	}
+
+	t.Run("sha512", func(t *testing.T) {
+		d, err := NewResumableDigester("sha512", nil)
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		io.WriteString(d, "hello ")
+		state, err := d.State()
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+
+		d, err = NewResumableDigester("sha512", state)
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		io.WriteString(d, "world")
+		want := "sha512:309ecc489c12d6eb4cc40f50c902f2b4d0ed77ee511a7c7a9bcd3ca86d4cd86f989dd35bc5ff499670da34255b45b0cfd830e81f605dcf7dc5542e93ae9cd76f"
+		if d.Digest().String() != want {
+			t.Errorf("digest = %s, want %s", d.Digest().String(), want)
+		}
+	})
+}
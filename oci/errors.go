@@ -20,4 +20,16 @@ var (
 
 	// ErrManifestTooLarge is returned when a manifest exceeds the max size.
 	ErrManifestTooLarge = errors.New("manifest too large")
+
+	// ErrManifestInvalid is returned when a manifest is malformed or of an unsupported type.
+	ErrManifestInvalid = errors.New("manifest invalid")
+
+	// ErrManifestBlobUnknown is returned when a manifest references a blob or manifest that does not exist.
+	ErrManifestBlobUnknown = errors.New("manifest references unknown blob")
+
+	// ErrTagImmutable is returned when pushing or deleting would move or remove an immutable tag.
+	ErrTagImmutable = errors.New("tag is immutable")
+
+	// ErrQuotaExceeded is returned when storing content would exceed a repository or namespace quota.
+	ErrQuotaExceeded = errors.New("quota exceeded")
 )
@@ -0,0 +1,378 @@
+package oci
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"path"
+	"time"
+
+	"github.com/hairizuanbinnoorazman/package-universe/storage"
+)
+
+// GCOptions controls a garbage collection run.
+type GCOptions struct {
+	// DryRun reports what would be removed without deleting anything.
+	DryRun bool
+
+	// DeleteUntagged removes manifests that no tag reaches, either directly or
+	// through an index, unless they are referrers of a manifest that is kept.
+	DeleteUntagged bool
+
+	// GracePeriod protects blobs, uploads and untagged manifests written more
+	// recently than this, so uploads interrupted by stopping the registry can
+	// still be resumed. It does not make collection safe alongside pushes: a
+	// manifest pushed after marking can reference an older blob being swept.
+	GracePeriod time.Duration
+}
+
+// GCResult summarises a garbage collection run.
+type GCResult struct {
+	ManifestsDeleted int
+	BlobsDeleted     int
+	UploadsDeleted   int
+	BytesReclaimed   int64
+
+	// UnreadableManifests lists manifests, as name@digest, that could not be
+	// parsed. Every blob linked into their repository is kept, since the
+	// blobs they reference are unknown.
+	UnreadableManifests []string
+}
+
+// GarbageCollect removes blobs no longer referenced by any manifest and
+// abandoned upload data. It marks every manifest reachable from each
+// repository's revisions (or only from its tags with DeleteUntagged), along
+// with their configs, layers and child manifests, then sweeps unmarked blobs
+// and the repository links that pointed at them. Nothing may be pushed to the
+// storage while it runs.
+func (s *OCIStorage) GarbageCollect(ctx context.Context, opts GCOptions) (_ *GCResult, err error) {
+	ctx, span := startSpan(ctx, "GarbageCollect", dryRunAttr(opts.DryRun))
+	defer func() { endSpan(span, err) }()
+
+	result := &GCResult{}
+	marked := make(map[DigestInfo]bool)
+
+	repositories, err := s.ListRepositories(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	for _, name := range repositories {
+		if err := s.markRepository(ctx, name, opts, marked, result); err != nil {
+			return nil, fmt.Errorf("failed to mark repository %s: %w", name, err)
+		}
+	}
+
+	if err := s.sweepBlobs(ctx, opts, marked, result); err != nil {
+		return nil, err
+	}
+	if !opts.DryRun {
+		for _, name := range repositories {
+			if err := s.pruneLayerLinks(ctx, name, marked); err != nil {
+				return nil, fmt.Errorf("failed to prune links in repository %s: %w", name, err)
+			}
+		}
+	}
+	if err := s.sweepUploads(ctx, opts, result); err != nil {
+		return nil, err
+	}
+	if !opts.DryRun {
+		s.resetUsage()
+	}
+
+	return result, nil
+}
+
+// markRepository marks the blobs of every manifest kept in a repository and,
+// with DeleteUntagged, deletes the manifests that are not kept.
+func (s *OCIStorage) markRepository(ctx context.Context, name string, opts GCOptions, marked map[DigestInfo]bool, result *GCResult) error {
+	revisions, err := s.listRevisions(ctx, name)
+	if err != nil {
+		return err
+	}
+
+	kept := make(map[DigestInfo]bool)
+	if !opts.DeleteUntagged {
+		for _, digest := range revisions {
+			kept[digest] = true
+		}
+	} else {
+		tags, err := s.ListTags(ctx, name)
+		if err != nil {
+			return err
+		}
+		for _, tag := range tags {
+			digest, _, err := s.readTagLink(ctx, name, tag)
+			if err != nil {
+				if err == ErrManifestNotFound {
+					continue
+				}
+				return err
+			}
+			kept[digest] = true
+		}
+
+		// Leave recently pushed manifests alone; an index may be about to reference them
+		for _, digest := range revisions {
+			info, err := s.store.Stat(ctx, ManifestRevisionLinkPath(name, digest))
+			if err != nil && err != storage.ErrFileNotFound {
+				return fmt.Errorf("failed to stat manifest revision: %w", err)
+			}
+			if err == nil && time.Since(info.ModTime) < opts.GracePeriod {
+				kept[digest] = true
+			}
+		}
+	}
+
+	// Expand the kept set through index children and referrers until it stops growing
+	pending := make([]DigestInfo, 0, len(kept))
+	for digest := range kept {
+		pending = append(pending, digest)
+	}
+	for len(pending) > 0 {
+		digest := pending[len(pending)-1]
+		pending = pending[:len(pending)-1]
+
+		children, err := s.markManifest(ctx, name, digest, marked, result)
+		if err != nil {
+			return err
+		}
+
+		referrers, err := s.ListReferrers(ctx, name, digest, "")
+		if err != nil {
+			return err
+		}
+		for _, desc := range referrers {
+			if d, err := ParseDigest(desc.Digest); err == nil {
+				children = append(children, d)
+			}
+		}
+
+		for _, child := range children {
+			if !kept[child] {
+				kept[child] = true
+				pending = append(pending, child)
+			}
+		}
+	}
+
+	for _, digest := range revisions {
+		if kept[digest] {
+			continue
+		}
+
+		result.ManifestsDeleted++
+		if opts.DryRun {
+			continue
+		}
+		if err := s.DeleteManifest(ctx, name, digest.String()); err != nil && err != ErrManifestNotFound {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// markManifest marks a manifest blob together with the config and layers it
+// references, and returns the digests of any child manifests of an index. A
+// manifest that cannot be parsed is recorded in the result and every blob
+// linked into repository name is marked in its place.
+func (s *OCIStorage) markManifest(ctx context.Context, name string, digest DigestInfo, marked map[DigestInfo]bool, result *GCResult) ([]DigestInfo, error) {
+	marked[digest] = true
+
+	rc, err := s.store.Download(ctx, BlobDataPath(digest))
+	if err != nil {
+		if err == storage.ErrFileNotFound {
+			// Dangling revision link; nothing further to mark
+			return nil, nil
+		}
+		return nil, fmt.Errorf("failed to read manifest %s: %w", digest, err)
+	}
+	data, err := io.ReadAll(rc)
+	rc.Close()
+	if err != nil {
+		return nil, fmt.Errorf("failed to read manifest %s: %w", digest, err)
+	}
+
+	m, err := ParseManifest(data)
+	if err != nil {
+		result.UnreadableManifests = append(result.UnreadableManifests, name+"@"+digest.String())
+		return nil, s.markLayerLinks(ctx, name, marked)
+	}
+
+	var children []DigestInfo
+	descriptors := m.Layers
+	if m.Config != nil {
+		descriptors = append(descriptors, *m.Config)
+	}
+	for _, desc := range descriptors {
+		if d, err := ParseDigest(desc.Digest); err == nil {
+			marked[d] = true
+		}
+	}
+	for _, desc := range m.Manifests {
+		if d, err := ParseDigest(desc.Digest); err == nil {
+			children = append(children, d)
+		}
+	}
+	return children, nil
+}
+
+// markLayerLinks marks every blob linked into a repository.
+func (s *OCIStorage) markLayerLinks(ctx context.Context, name string, marked map[DigestInfo]bool) error {
+	layersDir := LayersDir(name)
+	algorithms, err := s.store.List(ctx, layersDir)
+	if err != nil {
+		return fmt.Errorf("failed to list blob links: %w", err)
+	}
+	for _, algorithm := range algorithms {
+		hexes, err := s.store.List(ctx, path.Join(layersDir, algorithm))
+		if err != nil {
+			return fmt.Errorf("failed to list blob links: %w", err)
+		}
+		for _, hex := range hexes {
+			marked[DigestInfo{Algorithm: algorithm, Hex: hex}] = true
+		}
+	}
+	return nil
+}
+
+// sweepBlobs deletes every blob that was not marked.
+func (s *OCIStorage) sweepBlobs(ctx context.Context, opts GCOptions, marked map[DigestInfo]bool, result *GCResult) error {
+	algorithms, err := s.store.List(ctx, BlobsDir())
+	if err != nil {
+		return fmt.Errorf("failed to list blobs: %w", err)
+	}
+
+	for _, algorithm := range algorithms {
+		shards, err := s.store.List(ctx, path.Join(BlobsDir(), algorithm))
+		if err != nil {
+			return fmt.Errorf("failed to list blobs: %w", err)
+		}
+		for _, shard := range shards {
+			hexes, err := s.store.List(ctx, path.Join(BlobsDir(), algorithm, shard))
+			if err != nil {
+				return fmt.Errorf("failed to list blobs: %w", err)
+			}
+			for _, hex := range hexes {
+				digest := DigestInfo{Algorithm: algorithm, Hex: hex}
+				if marked[digest] {
+					continue
+				}
+				reclaimed, err := s.sweepPath(ctx, BlobDataPath(digest), opts)
+				if err != nil {
+					return err
+				}
+				if reclaimed >= 0 {
+					result.BlobsDeleted++
+					result.BytesReclaimed += reclaimed
+				}
+			}
+		}
+	}
+	return nil
+}
+
+// sweepUploads deletes upload data that has not been written to within the grace period.
+func (s *OCIStorage) sweepUploads(ctx context.Context, opts GCOptions, result *GCResult) error {
+	uuids, err := s.store.List(ctx, UploadsDir())
+	if err != nil {
+		return fmt.Errorf("failed to list uploads: %w", err)
+	}
+
+	for _, uuid := range uuids {
+		reclaimed, err := s.sweepPath(ctx, UploadDataPath(uuid), opts)
+		if err != nil {
+			return err
+		}
+		// The session record goes with its data so the upload cannot be resumed
+		sessionReclaimed, err := s.sweepPath(ctx, UploadSessionPath(uuid), opts)
+		if err != nil {
+			return err
+		}
+		if reclaimed >= 0 || sessionReclaimed >= 0 {
+			result.UploadsDeleted++
+			result.BytesReclaimed += max(reclaimed, 0)
+		}
+	}
+	return nil
+}
+
+// sweepPath deletes the data at p unless it is within the grace period, and
+// returns the number of bytes reclaimed, or -1 if the data was left in place.
+func (s *OCIStorage) sweepPath(ctx context.Context, p string, opts GCOptions) (int64, error) {
+	info, err := s.store.Stat(ctx, p)
+	if err != nil {
+		if err == storage.ErrFileNotFound {
+			return -1, nil
+		}
+		return -1, fmt.Errorf("failed to stat %s: %w", p, err)
+	}
+	if time.Since(info.ModTime) < opts.GracePeriod {
+		return -1, nil
+	}
+
+	if !opts.DryRun {
+		err := s.store.Delete(ctx, p)
+		if err != nil && err != storage.ErrFileNotFound {
+			return -1, fmt.Errorf("failed to delete %s: %w", p, err)
+		}
+	}
+	return info.Size, nil
+}
+
+// pruneLayerLinks removes a repository's links to blobs that are unmarked and
+// whose data no longer exists.
+func (s *OCIStorage) pruneLayerLinks(ctx context.Context, name string, marked map[DigestInfo]bool) error {
+	layersDir := LayersDir(name)
+	algorithms, err := s.store.List(ctx, layersDir)
+	if err != nil {
+		return err
+	}
+
+	for _, algorithm := range algorithms {
+		hexes, err := s.store.List(ctx, path.Join(layersDir, algorithm))
+		if err != nil {
+			return err
+		}
+		for _, hex := range hexes {
+			digest := DigestInfo{Algorithm: algorithm, Hex: hex}
+			if marked[digest] {
+				continue
+			}
+			exists, err := s.store.Exists(ctx, BlobDataPath(digest))
+			if err != nil {
+				return err
+			}
+			if exists {
+				continue
+			}
+			err = s.store.Delete(ctx, LayerLinkPath(name, digest))
+			if err != nil && err != storage.ErrFileNotFound {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// listRevisions returns the digest of every manifest revision in a repository.
+func (s *OCIStorage) listRevisions(ctx context.Context, name string) ([]DigestInfo, error) {
+	revisionsDir := ManifestRevisionsDir(name)
+	algorithms, err := s.store.List(ctx, revisionsDir)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list revisions: %w", err)
+	}
+
+	var digests []DigestInfo
+	for _, algorithm := range algorithms {
+		hexes, err := s.store.List(ctx, path.Join(revisionsDir, algorithm))
+		if err != nil {
+			return nil, fmt.Errorf("failed to list revisions: %w", err)
+		}
+		for _, hex := range hexes {
+			digests = append(digests, DigestInfo{Algorithm: algorithm, Hex: hex})
+		}
+	}
+	return digests, nil
+}
@@ -0,0 +1,176 @@
+package oci
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"reflect"
+	"testing"
+	"time"
+)
+
+// pushTestImage stores an image manifest with a unique layer and returns the
+// manifest digest and the layer digest. An empty reference pushes by digest.
+func pushTestImage(t *testing.T, s *OCIStorage, name, reference, seed string) (DigestInfo, DigestInfo) {
+	t.Helper()
+	ctx := context.Background()
+
+	layer := putTestBlob(t, s, name, []byte("layer-"+seed))
+	config := putTestBlob(t, s, name, []byte(`{"seed":"`+seed+`"}`))
+	manifest := []byte(fmt.Sprintf(`{"schemaVersion":2,"mediaType":%q,"config":{"mediaType":"application/vnd.oci.image.config.v1+json","digest":%q,"size":%d},"layers":[{"mediaType":"application/vnd.oci.image.layer.v1.tar","digest":%q,"size":%d}]}`,
+		MediaTypeImageManifest, config, len(`{"seed":"`+seed+`"}`), layer, len("layer-"+seed)))
+
+	if reference == "" {
+		reference = computeSHA256(manifest).String()
+	}
+	digest, err := s.PutManifest(ctx, name, reference, MediaTypeImageManifest, manifest)
+	if err != nil {
+		t.Fatalf("PutManifest failed: %v", err)
+	}
+	return digest, layer
+}
+
+func TestOCIStorage_GarbageCollect(t *testing.T) {
+	ctx := context.Background()
+	s := setupTestOCIStorage(t)
+
+	_, keptLayer := pushTestImage(t, s, "myrepo", "v1", "kept")
+	untagged, untaggedLayer := pushTestImage(t, s, "myrepo", "v2", "untagged")
+	// Re-pointing v2 leaves the previous manifest untagged
+	pushTestImage(t, s, "myrepo", "v2", "replacement")
+	orphan := putTestBlob(t, s, "myrepo", []byte("orphaned layer"))
+
+	uuid, _ := s.InitiateUpload(ctx, "myrepo", "")
+	s.WriteUploadChunk(ctx, uuid, bytes.NewReader([]byte("abandoned")))
+
+	t.Run("dry run deletes nothing", func(t *testing.T) {
+		result, err := s.GarbageCollect(ctx, GCOptions{DryRun: true})
+		if err != nil {
+			t.Fatalf("GarbageCollect failed: %v", err)
+		}
+		if result.BlobsDeleted != 1 || result.UploadsDeleted != 1 {
+			t.Errorf("result = %+v, want 1 blob and 1 upload", result)
+		}
+		if exists, _ := s.BlobExists(ctx, "myrepo", orphan); !exists {
+			t.Error("dry run should not delete blobs")
+		}
+	})
+
+	t.Run("grace period protects recent content", func(t *testing.T) {
+		result, err := s.GarbageCollect(ctx, GCOptions{DeleteUntagged: true, GracePeriod: time.Hour})
+		if err != nil {
+			t.Fatalf("GarbageCollect failed: %v", err)
+		}
+		if result.BlobsDeleted != 0 || result.UploadsDeleted != 0 || result.ManifestsDeleted != 0 {
+			t.Errorf("result = %+v, want nothing deleted", result)
+		}
+	})
+
+	t.Run("unreferenced blobs and uploads are deleted", func(t *testing.T) {
+		result, err := s.GarbageCollect(ctx, GCOptions{})
+		if err != nil {
+			t.Fatalf("GarbageCollect failed: %v", err)
+		}
+		if result.BlobsDeleted != 1 || result.UploadsDeleted != 1 || result.ManifestsDeleted != 0 {
+			t.Errorf("result = %+v, want 1 blob and 1 upload", result)
+		}
+		wantBytes := int64(len("orphaned layer") + len("abandoned"))
+		if result.BytesReclaimed != wantBytes {
+			t.Errorf("bytes reclaimed = %d, want %d", result.BytesReclaimed, wantBytes)
+		}
+		if exists, _ := s.BlobExists(ctx, "myrepo", orphan); exists {
+			t.Error("orphaned blob should be deleted")
+		}
+		if linked, _ := s.store.Exists(ctx, LayerLinkPath("myrepo", orphan)); linked {
+			t.Error("link to deleted blob should be pruned")
+		}
+		if _, err := s.GetUploadOffset(ctx, uuid); err != ErrUploadNotFound {
+			t.Errorf("abandoned upload session should be deleted, got %v", err)
+		}
+		if exists, _ := s.BlobExists(ctx, "myrepo", untaggedLayer); !exists {
+			t.Error("layers of untagged manifests are kept without DeleteUntagged")
+		}
+	})
+
+	t.Run("delete untagged", func(t *testing.T) {
+		result, err := s.GarbageCollect(ctx, GCOptions{DeleteUntagged: true})
+		if err != nil {
+			t.Fatalf("GarbageCollect failed: %v", err)
+		}
+		if result.ManifestsDeleted != 1 {
+			t.Errorf("manifests deleted = %d, want 1", result.ManifestsDeleted)
+		}
+		if _, _, _, err := s.GetManifest(ctx, "myrepo", untagged.String()); err != ErrManifestNotFound {
+			t.Errorf("expected untagged manifest to be removed, got %v", err)
+		}
+		if exists, _ := s.BlobExists(ctx, "myrepo", untaggedLayer); exists {
+			t.Error("layer of untagged manifest should be deleted")
+		}
+		if exists, _ := s.BlobExists(ctx, "myrepo", keptLayer); !exists {
+			t.Error("layer of tagged manifest should be kept")
+		}
+	})
+}
+
+func TestOCIStorage_GarbageCollectKeepsIndexChildrenAndReferrers(t *testing.T) {
+	ctx := context.Background()
+	s := setupTestOCIStorage(t)
+
+	child, childLayer := pushTestImage(t, s, "myrepo", "", "child")
+	index := []byte(fmt.Sprintf(`{"schemaVersion":2,"mediaType":%q,"manifests":[{"mediaType":%q,"digest":%q,"size":1}]}`,
+		MediaTypeImageIndex, MediaTypeImageManifest, child))
+	if _, err := s.PutManifest(ctx, "myrepo", "latest", MediaTypeImageIndex, index); err != nil {
+		t.Fatalf("PutManifest index failed: %v", err)
+	}
+
+	empty := putTestBlob(t, s, "myrepo", []byte("{}"))
+	signature := []byte(fmt.Sprintf(`{"schemaVersion":2,"mediaType":%q,"artifactType":"application/vnd.example.sig","config":{"mediaType":"application/vnd.oci.empty.v1+json","digest":%q,"size":2},"layers":[],"subject":{"mediaType":%q,"digest":%q,"size":1}}`,
+		MediaTypeImageManifest, empty, MediaTypeImageManifest, child))
+	sigDigest := computeSHA256(signature)
+	if _, err := s.PutManifest(ctx, "myrepo", sigDigest.String(), MediaTypeImageManifest, signature); err != nil {
+		t.Fatalf("PutManifest signature failed: %v", err)
+	}
+
+	result, err := s.GarbageCollect(ctx, GCOptions{DeleteUntagged: true})
+	if err != nil {
+		t.Fatalf("GarbageCollect failed: %v", err)
+	}
+	if result.ManifestsDeleted != 0 || result.BlobsDeleted != 0 {
+		t.Errorf("result = %+v, want nothing deleted", result)
+	}
+	if exists, _ := s.BlobExists(ctx, "myrepo", childLayer); !exists {
+		t.Error("layer of index child should be kept")
+	}
+	if _, _, _, err := s.GetManifest(ctx, "myrepo", sigDigest.String()); err != nil {
+		t.Errorf("referrer of kept manifest should survive: %v", err)
+	}
+}
+
+func TestOCIStorage_GarbageCollectUnreadableManifest(t *testing.T) {
+	ctx := context.Background()
+	s := setupTestOCIStorage(t)
+
+	broken, layer := pushTestImage(t, s, "broken", "v1", "broken")
+	if err := s.store.Upload(ctx, BlobDataPath(broken), bytes.NewReader([]byte("not a manifest"))); err != nil {
+		t.Fatalf("failed to corrupt manifest: %v", err)
+	}
+	_, otherLayer := pushTestImage(t, s, "other", "v1", "other")
+	orphan := putTestBlob(t, s, "other", []byte("orphaned layer"))
+
+	result, err := s.GarbageCollect(ctx, GCOptions{})
+	if err != nil {
+		t.Fatalf("GarbageCollect failed: %v", err)
+	}
+	if want := []string{"broken@" + broken.String()}; !reflect.DeepEqual(result.UnreadableManifests, want) {
+		t.Errorf("unreadable manifests = %v, want %v", result.UnreadableManifests, want)
+	}
+	if exists, _ := s.BlobExists(ctx, "broken", layer); !exists {
+		t.Error("blobs of a repository with an unreadable manifest should be kept")
+	}
+	if exists, _ := s.BlobExists(ctx, "other", otherLayer); !exists {
+		t.Error("referenced layer in another repository should be kept")
+	}
+	if exists, _ := s.BlobExists(ctx, "other", orphan); exists {
+		t.Error("orphaned blob in another repository should still be deleted")
+	}
+}
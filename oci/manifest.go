@@ -0,0 +1,151 @@
+package oci
+
+import (
+	"encoding/json"
+	"fmt"
+)
+
+// Manifest media types understood by the registry.
+const (
+	MediaTypeImageManifest      = "application/vnd.oci.image.manifest.v1+json"
+	MediaTypeImageIndex         = "application/vnd.oci.image.index.v1+json"
+	MediaTypeDockerManifest     = "application/vnd.docker.distribution.manifest.v2+json"
+	MediaTypeDockerManifestList = "application/vnd.docker.distribution.manifest.list.v2+json"
+)
+
+// Descriptor describes content referenced from a manifest or index.
+type Descriptor struct {
+	MediaType    string            `json:"mediaType"`
+	Digest       string            `json:"digest"`
+	Size         int64             `json:"size"`
+	URLs         []string          `json:"urls,omitempty"`
+	ArtifactType string            `json:"artifactType,omitempty"`
+	Annotations  map[string]string `json:"annotations,omitempty"`
+}
+
+// Manifest holds the fields of an image manifest or image index that the
+// registry inspects. Unknown fields are ignored.
+type Manifest struct {
+	SchemaVersion int               `json:"schemaVersion"`
+	MediaType     string            `json:"mediaType,omitempty"`
+	ArtifactType  string            `json:"artifactType,omitempty"`
+	Config        *Descriptor       `json:"config,omitempty"`
+	Layers        []Descriptor      `json:"layers,omitempty"`
+	Manifests     []Descriptor      `json:"manifests,omitempty"`
+	Subject       *Descriptor       `json:"subject,omitempty"`
+	Annotations   map[string]string `json:"annotations,omitempty"`
+}
+
+// ParseManifest decodes a manifest or index from its JSON representation.
+func ParseManifest(data []byte) (*Manifest, error) {
+	var m Manifest
+	if err := json.Unmarshal(data, &m); err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrManifestInvalid, err)
+	}
+	return &m, nil
+}
+
+// IsIndexMediaType reports whether the media type describes a list of manifests
+// rather than a single image manifest.
+func IsIndexMediaType(mediaType string) bool {
+	return mediaType == MediaTypeImageIndex || mediaType == MediaTypeDockerManifestList
+}
+
+// isSupportedMediaType reports whether the registry accepts manifests of the given media type.
+func isSupportedMediaType(mediaType string) bool {
+	switch mediaType {
+	case MediaTypeImageManifest, MediaTypeImageIndex, MediaTypeDockerManifest, MediaTypeDockerManifestList:
+		return true
+	}
+	return false
+}
+
+// ResolveMediaType determines the media type of the manifest from the
+// Content-Type supplied by the client and the mediaType field in the body.
+// When neither is set the manifest is treated as an OCI image manifest.
+func (m *Manifest) ResolveMediaType(contentType string) (string, error) {
+	mediaType := contentType
+	if mediaType == "" {
+		mediaType = m.MediaType
+	}
+	if mediaType == "" {
+		mediaType = MediaTypeImageManifest
+	}
+
+	if !isSupportedMediaType(mediaType) {
+		return "", fmt.Errorf("%w: unsupported media type %q", ErrManifestInvalid, mediaType)
+	}
+	if m.MediaType != "" && m.MediaType != mediaType {
+		return "", fmt.Errorf("%w: mediaType %q does not match content type %q", ErrManifestInvalid, m.MediaType, mediaType)
+	}
+	return mediaType, nil
+}
+
+// Validate checks that the manifest is well formed for the given media type.
+func (m *Manifest) Validate(mediaType string) error {
+	if m.SchemaVersion != 2 {
+		return fmt.Errorf("%w: unsupported schemaVersion %d", ErrManifestInvalid, m.SchemaVersion)
+	}
+
+	if IsIndexMediaType(mediaType) {
+		for i, desc := range m.Manifests {
+			if err := validateDescriptor(desc); err != nil {
+				return fmt.Errorf("%w: manifests[%d]: %v", ErrManifestInvalid, i, err)
+			}
+		}
+		return nil
+	}
+
+	if m.Config == nil {
+		return fmt.Errorf("%w: config descriptor is required", ErrManifestInvalid)
+	}
+	if err := validateDescriptor(*m.Config); err != nil {
+		return fmt.Errorf("%w: config: %v", ErrManifestInvalid, err)
+	}
+	for i, desc := range m.Layers {
+		if err := validateDescriptor(desc); err != nil {
+			return fmt.Errorf("%w: layers[%d]: %v", ErrManifestInvalid, i, err)
+		}
+	}
+	return nil
+}
+
+// validateDescriptor checks a single descriptor's digest and size.
+func validateDescriptor(desc Descriptor) error {
+	if _, err := ParseDigest(desc.Digest); err != nil {
+		return err
+	}
+	if desc.Size < 0 {
+		return fmt.Errorf("negative size %d", desc.Size)
+	}
+	return nil
+}
+
+// SubjectDigest returns the parsed digest of the manifest's subject, if any.
+func (m *Manifest) SubjectDigest() (DigestInfo, bool) {
+	if m.Subject == nil {
+		return DigestInfo{}, false
+	}
+	d, err := ParseDigest(m.Subject.Digest)
+	if err != nil {
+		return DigestInfo{}, false
+	}
+	return d, true
+}
+
+// referrerDescriptor builds the descriptor advertised for this manifest in a
+// referrers listing. Image manifests without an explicit artifactType fall back
+// to their config media type, as required by the distribution spec.
+func (m *Manifest) referrerDescriptor(contentType string, digest DigestInfo, size int64) Descriptor {
+	artifactType := m.ArtifactType
+	if artifactType == "" && m.Config != nil {
+		artifactType = m.Config.MediaType
+	}
+	return Descriptor{
+		MediaType:    contentType,
+		Digest:       digest.String(),
+		Size:         size,
+		ArtifactType: artifactType,
+		Annotations:  m.Annotations,
+	}
+}
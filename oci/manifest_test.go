@@ -0,0 +1,150 @@
+package oci
+
+import (
+	"errors"
+	"testing"
+)
+
+const testConfigDescriptor = `{"mediaType":"application/vnd.oci.image.config.v1+json","digest":"sha256:44136fa355b3678a1146ad16f7e8649e94fb4fc21fe77e8310c060f61caaff8a","size":2}`
+
+func TestParseManifest_InvalidJSON(t *testing.T) {
+	_, err := ParseManifest([]byte(`{not json`))
+	if !errors.Is(err, ErrManifestInvalid) {
+		t.Errorf("expected ErrManifestInvalid, got %v", err)
+	}
+}
+
+func TestManifest_ResolveMediaType(t *testing.T) {
+	tests := []struct {
+		name        string
+		contentType string
+		bodyType    string
+		want        string
+		wantError   bool
+	}{
+		{
+			name:        "content type only",
+			contentType: MediaTypeDockerManifest,
+			want:        MediaTypeDockerManifest,
+		},
+		{
+			name:     "body media type only",
+			bodyType: MediaTypeImageIndex,
+			want:     MediaTypeImageIndex,
+		},
+		{
+			name: "neither defaults to OCI image manifest",
+			want: MediaTypeImageManifest,
+		},
+		{
+			name:        "matching content type and body",
+			contentType: MediaTypeDockerManifestList,
+			bodyType:    MediaTypeDockerManifestList,
+			want:        MediaTypeDockerManifestList,
+		},
+		{
+			name:        "mismatched content type and body",
+			contentType: MediaTypeImageManifest,
+			bodyType:    MediaTypeImageIndex,
+			wantError:   true,
+		},
+		{
+			name:        "unsupported media type",
+			contentType: "application/vnd.docker.distribution.manifest.v1+prettyjws",
+			wantError:   true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			m := &Manifest{MediaType: tt.bodyType}
+			got, err := m.ResolveMediaType(tt.contentType)
+			if tt.wantError {
+				if !errors.Is(err, ErrManifestInvalid) {
+					t.Errorf("expected ErrManifestInvalid, got %v", err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if got != tt.want {
+				t.Errorf("media type = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestManifest_Validate(t *testing.T) {
+	tests := []struct {
+		name      string
+		mediaType string
+		data      string
+		wantError bool
+	}{
+		{
+			name:      "valid image manifest",
+			mediaType: MediaTypeImageManifest,
+			data:      `{"schemaVersion":2,"config":` + testConfigDescriptor + `,"layers":[` + testConfigDescriptor + `]}`,
+		},
+		{
+			name:      "valid docker manifest",
+			mediaType: MediaTypeDockerManifest,
+			data:      `{"schemaVersion":2,"mediaType":"application/vnd.docker.distribution.manifest.v2+json","config":` + testConfigDescriptor + `,"layers":[]}`,
+		},
+		{
+			name:      "valid index",
+			mediaType: MediaTypeImageIndex,
+			data:      `{"schemaVersion":2,"manifests":[` + testConfigDescriptor + `]}`,
+		},
+		{
+			name:      "wrong schema version",
+			mediaType: MediaTypeImageManifest,
+			data:      `{"schemaVersion":1,"config":` + testConfigDescriptor + `}`,
+			wantError: true,
+		},
+		{
+			name:      "missing config",
+			mediaType: MediaTypeImageManifest,
+			data:      `{"schemaVersion":2,"layers":[]}`,
+			wantError: true,
+		},
+		{
+			name:      "invalid layer digest",
+			mediaType: MediaTypeImageManifest,
+			data:      `{"schemaVersion":2,"config":` + testConfigDescriptor + `,"layers":[{"mediaType":"x","digest":"bogus","size":1}]}`,
+			wantError: true,
+		},
+		{
+			name:      "negative size",
+			mediaType: MediaTypeImageManifest,
+			data:      `{"schemaVersion":2,"config":{"mediaType":"x","digest":"sha256:44136fa355b3678a1146ad16f7e8649e94fb4fc21fe77e8310c060f61caaff8a","size":-1}}`,
+			wantError: true,
+		},
+		{
+			name:      "invalid index entry digest",
+			mediaType: MediaTypeDockerManifestList,
+			data:      `{"schemaVersion":2,"manifests":[{"mediaType":"x","digest":"","size":1}]}`,
+			wantError: true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			m, err := ParseManifest([]byte(tt.data))
+			if err != nil {
+				t.Fatalf("unexpected parse error: %v", err)
+			}
+			err = m.Validate(tt.mediaType)
+			if tt.wantError {
+				if !errors.Is(err, ErrManifestInvalid) {
+					t.Errorf("expected ErrManifestInvalid, got %v", err)
+				}
+				return
+			}
+			if err != nil {
+				t.Errorf("unexpected error: %v", err)
+			}
+		})
+	}
+}
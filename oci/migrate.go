@@ -0,0 +1,143 @@
+package oci
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"strings"
+
+	"github.com/hairizuanbinnoorazman/package-universe/storage"
+)
+
+// LinkResult summarises a LinkManifestBlobs run.
+type LinkResult struct {
+	// LinksCreated lists the blobs linked into a repository, as name@digest.
+	LinksCreated []string
+
+	// UnreadableManifests lists manifests, as name@digest, that could not be
+	// parsed. Only the manifest blob itself is linked for them.
+	UnreadableManifests []string
+}
+
+// LinkManifestBlobs links every blob referenced by a repository's manifest
+// revisions into that repository: the manifests themselves, their configs,
+// layers and child manifests. Blob reads require these links, and registries
+// written before they were introduced have none, so this must run once after
+// upgrading one. Existing links are left alone, blobs whose data is missing
+// are skipped and blobs that no manifest references cannot be recovered.
+// With dryRun the missing links are only reported.
+func (s *OCIStorage) LinkManifestBlobs(ctx context.Context, dryRun bool) (_ *LinkResult, err error) {
+	ctx, span := startSpan(ctx, "LinkManifestBlobs", dryRunAttr(dryRun))
+	defer func() { endSpan(span, err) }()
+
+	result := &LinkResult{LinksCreated: []string{}}
+
+	repositories, err := s.ListRepositories(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	for _, name := range repositories {
+		if err := s.linkRepositoryBlobs(ctx, name, dryRun, result); err != nil {
+			return nil, fmt.Errorf("failed to link blobs in repository %s: %w", name, err)
+		}
+	}
+	if !dryRun && len(result.LinksCreated) > 0 {
+		s.resetUsage()
+	}
+
+	return result, nil
+}
+
+// linkRepositoryBlobs links the blobs referenced by each manifest revision of
+// repository name.
+func (s *OCIStorage) linkRepositoryBlobs(ctx context.Context, name string, dryRun bool, result *LinkResult) error {
+	revisions, err := s.listRevisions(ctx, name)
+	if err != nil {
+		return err
+	}
+
+	seen := make(map[DigestInfo]bool)
+	for _, digest := range revisions {
+		referenced, err := s.manifestBlobs(ctx, name, digest, result)
+		if err != nil {
+			return err
+		}
+		for _, blob := range referenced {
+			if seen[blob] {
+				continue
+			}
+			seen[blob] = true
+			if err := s.linkMissingBlob(ctx, name, blob, dryRun, result); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// manifestBlobs returns a manifest's own digest followed by the digests of
+// the config, layers and child manifests it references. A manifest whose data
+// is missing references nothing.
+func (s *OCIStorage) manifestBlobs(ctx context.Context, name string, digest DigestInfo, result *LinkResult) ([]DigestInfo, error) {
+	rc, err := s.store.Download(ctx, BlobDataPath(digest))
+	if err != nil {
+		if err == storage.ErrFileNotFound {
+			return nil, nil
+		}
+		return nil, fmt.Errorf("failed to read manifest %s: %w", digest, err)
+	}
+	data, err := io.ReadAll(rc)
+	rc.Close()
+	if err != nil {
+		return nil, fmt.Errorf("failed to read manifest %s: %w", digest, err)
+	}
+
+	blobs := []DigestInfo{digest}
+	m, err := ParseManifest(data)
+	if err != nil {
+		result.UnreadableManifests = append(result.UnreadableManifests, name+"@"+digest.String())
+		return blobs, nil
+	}
+
+	descriptors := append([]Descriptor{}, m.Layers...)
+	if m.Config != nil {
+		descriptors = append(descriptors, *m.Config)
+	}
+	descriptors = append(descriptors, m.Manifests...)
+	for _, desc := range descriptors {
+		if d, err := ParseDigest(desc.Digest); err == nil {
+			blobs = append(blobs, d)
+		}
+	}
+	return blobs, nil
+}
+
+// linkMissingBlob links a blob into repository name unless it is already
+// linked or its data does not exist. Quotas are not checked, since the blob is
+// already stored.
+func (s *OCIStorage) linkMissingBlob(ctx context.Context, name string, digest DigestInfo, dryRun bool, result *LinkResult) error {
+	linkPath := LayerLinkPath(name, digest)
+	linked, err := s.store.Exists(ctx, linkPath)
+	if err != nil {
+		return fmt.Errorf("failed to check blob link: %w", err)
+	}
+	if linked {
+		return nil
+	}
+	exists, err := s.store.Exists(ctx, BlobDataPath(digest))
+	if err != nil {
+		return fmt.Errorf("failed to check blob: %w", err)
+	}
+	if !exists {
+		return nil
+	}
+
+	if !dryRun {
+		if err := s.store.Upload(ctx, linkPath, strings.NewReader(digest.String())); err != nil {
+			return fmt.Errorf("failed to store blob link: %w", err)
+		}
+	}
+	result.LinksCreated = append(result.LinksCreated, name+"@"+digest.String())
+	return nil
+}
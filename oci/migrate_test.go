@@ -0,0 +1,67 @@
+package oci
+
+import (
+	"context"
+	"testing"
+)
+
+func TestOCIStorage_LinkManifestBlobs(t *testing.T) {
+	ctx := context.Background()
+	s := setupTestOCIStorage(t)
+
+	manifest, layer := pushTestImage(t, s, "team/app", "v1", "legacy")
+	config := computeSHA256([]byte(`{"seed":"legacy"}`))
+	orphan := putTestBlob(t, s, "team/app", []byte("unreferenced"))
+
+	// Registries written before blob links existed have none
+	blobs := []DigestInfo{manifest, config, layer, orphan}
+	for _, digest := range blobs {
+		if err := s.store.Delete(ctx, LayerLinkPath("team/app", digest)); err != nil {
+			t.Fatalf("failed to remove link: %v", err)
+		}
+	}
+	if exists, _ := s.BlobExists(ctx, "team/app", layer); exists {
+		t.Fatal("layer should be unknown without its link")
+	}
+
+	t.Run("dry run only reports", func(t *testing.T) {
+		result, err := s.LinkManifestBlobs(ctx, true)
+		if err != nil {
+			t.Fatalf("LinkManifestBlobs failed: %v", err)
+		}
+		if len(result.LinksCreated) != 3 {
+			t.Errorf("links = %v, want 3", result.LinksCreated)
+		}
+		if exists, _ := s.BlobExists(ctx, "team/app", layer); exists {
+			t.Error("dry run should not create links")
+		}
+	})
+
+	t.Run("referenced blobs are linked", func(t *testing.T) {
+		result, err := s.LinkManifestBlobs(ctx, false)
+		if err != nil {
+			t.Fatalf("LinkManifestBlobs failed: %v", err)
+		}
+		if len(result.LinksCreated) != 3 {
+			t.Errorf("links = %v, want 3", result.LinksCreated)
+		}
+		for _, digest := range []DigestInfo{manifest, config, layer} {
+			if exists, err := s.BlobExists(ctx, "team/app", digest); err != nil || !exists {
+				t.Errorf("BlobExists(%s) = %v, %v; want true", digest, exists, err)
+			}
+		}
+		if exists, _ := s.BlobExists(ctx, "team/app", orphan); exists {
+			t.Error("blobs no manifest references should stay unlinked")
+		}
+	})
+
+	t.Run("second run links nothing", func(t *testing.T) {
+		result, err := s.LinkManifestBlobs(ctx, false)
+		if err != nil {
+			t.Fatalf("LinkManifestBlobs failed: %v", err)
+		}
+		if len(result.LinksCreated) != 0 {
+			t.Errorf("links = %v, want none", result.LinksCreated)
+		}
+	})
+}
@@ -3,9 +3,14 @@ package oci
 import (
 	"bytes"
 	"context"
+	"encoding/json"
+	"errors"
 	"fmt"
 	"io"
+	"path"
+	"sort"
 	"strings"
+	"time"
 
 	"github.com/hairizuanbinnoorazman/package-universe/storage"
 )
@@ -19,31 +24,63 @@ type ManifestInfo struct {
 
 // BlobInfo holds metadata about a stored blob.
 type BlobInfo struct {
-	Digest DigestInfo
-	Size   int64
+	Digest  DigestInfo
+	Size    int64
+	ModTime time.Time
 }
 
 // OCIStorage provides OCI-specific storage operations on top of BlobStorage.
 type OCIStorage struct {
-	store    storage.BlobStorage
-	sessions *SessionManager
+	store          storage.BlobStorage
+	sessions       *SessionManager
+	immutableTags  []ImmutableTagRule
+	upstream       *Client
+	upstreamTagTTL time.Duration
+	quotas         []Quota
+	usage          *usageCache
 }
 
+// Option configures an OCIStorage.
+type Option func(*OCIStorage)
+
 // NewOCIStorage creates a new OCIStorage wrapping the given BlobStorage.
-func NewOCIStorage(store storage.BlobStorage, sessions *SessionManager) *OCIStorage {
-	return &OCIStorage{
+func NewOCIStorage(store storage.BlobStorage, sessions *SessionManager, opts ...Option) *OCIStorage {
+	s := &OCIStorage{
 		store:    store,
 		sessions: sessions,
 	}
+	for _, opt := range opts {
+		opt(s)
+	}
+	return s
 }
 
-// BlobExists checks if a blob with the given digest exists.
-func (s *OCIStorage) BlobExists(ctx context.Context, digest DigestInfo) (bool, error) {
+// BlobExists checks if a blob with the given digest is available in the repository.
+// A blob is only visible through repositories that hold a link to it.
+func (s *OCIStorage) BlobExists(ctx context.Context, name string, digest DigestInfo) (_ bool, err error) {
+	ctx, span := startSpan(ctx, "BlobExists", repositoryAttr(name), digestAttr(digest))
+	defer func() { endSpan(span, err) }()
+
+	linked, err := s.store.Exists(ctx, LayerLinkPath(name, digest))
+	if err != nil || !linked {
+		return false, err
+	}
 	return s.store.Exists(ctx, BlobDataPath(digest))
 }
 
-// GetBlob retrieves a blob by digest.
-func (s *OCIStorage) GetBlob(ctx context.Context, digest DigestInfo) (io.ReadCloser, error) {
+// GetBlob retrieves a blob by digest from the repository. In pull-through
+// mode a blob the repository does not hold is fetched from the upstream.
+func (s *OCIStorage) GetBlob(ctx context.Context, name string, digest DigestInfo) (_ io.ReadCloser, err error) {
+	ctx, span := startSpan(ctx, "GetBlob", repositoryAttr(name), digestAttr(digest))
+	defer func() { endSpan(span, err) }()
+
+	if err := s.checkBlobLink(ctx, name, digest); err != nil {
+		if err == ErrBlobNotFound && s.upstream != nil {
+			return s.proxyBlob(ctx, name, digest)
+		}
+		return nil, err
+	}
+
 	rc, err := s.store.Download(ctx, BlobDataPath(digest))
 	if err != nil {
 		if err == storage.ErrFileNotFound {
@@ -54,164 +91,401 @@ func (s *OCIStorage) GetBlob(ctx context.Context, digest DigestInfo) (io.ReadClo
 	return rc, nil
 }
 
-// GetBlobInfo returns size information for a blob.
-func (s *OCIStorage) GetBlobInfo(ctx context.Context, digest DigestInfo) (*BlobInfo, error) {
-	exists, err := s.BlobExists(ctx, digest)
-	if err != nil {
+// GetBlobRange retrieves length bytes of a blob starting at offset. A negative
+// length reads to the end of the blob. In pull-through mode ranges of blobs
+// the repository does not hold are read from the upstream without caching.
+func (s *OCIStorage) GetBlobRange(ctx context.Context, name string, digest DigestInfo, offset, length int64) (_ io.ReadCloser, err error) {
+	ctx, span := startSpan(ctx, "GetBlobRange", repositoryAttr(name), digestAttr(digest))
+	defer func() { endSpan(span, err) }()
+
+	if err := s.checkBlobLink(ctx, name, digest); err != nil {
+		if err == ErrBlobNotFound && s.upstream != nil {
+			return s.upstream.GetBlobRange(ctx, s.upstreamName(name), digest, offset, length)
+		}
 		return nil, err
 	}
-	if !exists {
-		return nil, ErrBlobNotFound
-	}
 
-	// Read to determine size
-	rc, err := s.store.Download(ctx, BlobDataPath(digest))
+	rc, err := s.store.DownloadRange(ctx, BlobDataPath(digest), offset, length)
 	if err != nil {
-		return nil, fmt.Errorf("failed to get blob info: %w", err)
+		if err == storage.ErrFileNotFound {
+			return nil, ErrBlobNotFound
+		}
+		return nil, fmt.Errorf("failed to get blob range: %w", err)
 	}
-	defer rc.Close()
+	return rc, nil
+}
+
+// GetBlobInfo returns size information for a blob without reading its content.
+// In pull-through mode blobs the repository does not hold are described by the upstream.
+func (s *OCIStorage) GetBlobInfo(ctx context.Context, name string, digest DigestInfo) (_ *BlobInfo, err error) {
+	ctx, span := startSpan(ctx, "GetBlobInfo", repositoryAttr(name), digestAttr(digest))
+	defer func() { endSpan(span, err) }()
 
-	size, err := io.Copy(io.Discard, rc)
+	if err := s.checkBlobLink(ctx, name, digest); err != nil {
+		if err == ErrBlobNotFound && s.upstream != nil {
+			return s.proxyBlobInfo(ctx, name, digest)
+		}
+		return nil, err
+	}
+
+	info, err := s.store.Stat(ctx, BlobDataPath(digest))
 	if err != nil {
-		return nil, fmt.Errorf("failed to read blob size: %w", err)
+		if err == storage.ErrFileNotFound {
+			return nil, ErrBlobNotFound
+		}
+		return nil, fmt.Errorf("failed to get blob info: %w", err)
 	}
 
 	return &BlobInfo{
-		Digest: digest,
-		Size:   size,
+		Digest:  digest,
+		Size:    info.Size,
+		ModTime: info.ModTime,
 	}, nil
 }
 
+// MountBlob links an existing blob into the repository without copying it and
+// returns the repository it was mounted from. When from is empty, any
+// repository holding the blob is used. Returns ErrBlobNotFound if no suitable
+// source holds the blob.
+func (s *OCIStorage) MountBlob(ctx context.Context, name, from string, digest DigestInfo) (_ string, err error) {
+	ctx, span := startSpan(ctx, "MountBlob", repositoryAttr(name), digestAttr(digest), mountFromAttr(from))
+	defer func() { endSpan(span, err) }()
+
+	sources := []string{from}
+	if from == "" {
+		repositories, err := s.ListRepositories(ctx)
+		if err != nil {
+			return "", err
+		}
+		sources = repositories
+	}
+
+	for _, source := range sources {
+		exists, err := s.BlobExists(ctx, source, digest)
+		if err != nil {
+			return "", fmt.Errorf("failed to check blob for mount: %w", err)
+		}
+		if !exists {
+			continue
+		}
+		if len(s.quotas) > 0 {
+			info, err := s.store.Stat(ctx, BlobDataPath(digest))
+			if err != nil {
+				return "", fmt.Errorf("failed to stat blob for mount: %w", err)
+			}
+			if err := s.checkLinkQuota(ctx, name, digest, info.Size); err != nil {
+				return "", err
+			}
+		}
+		if err := s.linkBlob(ctx, name, digest); err != nil {
+			return "", err
+		}
+		return source, nil
+	}
+
+	return "", ErrBlobNotFound
+}
+
 // InitiateUpload starts a new blob upload session and returns the UUID.
-func (s *OCIStorage) InitiateUpload(ctx context.Context, repository string) (string, error) {
-	uuid, err := s.sessions.Create(repository)
+// The running digest uses algorithm, or DefaultDigestAlgorithm when empty.
+func (s *OCIStorage) InitiateUpload(ctx context.Context, repository, algorithm string) (_ string, err error) {
+	ctx, span := startSpan(ctx, "InitiateUpload", repositoryAttr(repository))
+	defer func() { endSpan(span, err) }()
+
+	alg, err := LookupDigestAlgorithm(algorithm)
+	if err != nil {
+		return "", err
+	}
+
+	uuid, err := s.sessions.Create(ctx, repository, alg.Name)
 	if err != nil {
 		return "", fmt.Errorf("failed to create upload session: %w", err)
 	}
 
 	// Create an empty upload data file
-	err = s.store.Upload(ctx, UploadDataPath(uuid), strings.NewReader(""))
+	_, err = s.store.Append(ctx, UploadDataPath(uuid), strings.NewReader(""))
 	if err != nil {
-		s.sessions.Delete(uuid)
+		s.sessions.Delete(ctx, uuid)
 		return "", fmt.Errorf("failed to initialize upload: %w", err)
 	}
 
 	return uuid, nil
 }
 
-// WriteUploadChunk appends data to an in-progress upload.
-func (s *OCIStorage) WriteUploadChunk(ctx context.Context, uuid string, data io.Reader) (int64, error) {
-	session, err := s.sessions.Get(uuid)
+// GetUploadRepository returns the repository an in-progress upload was started in.
+func (s *OCIStorage) GetUploadRepository(ctx context.Context, uuid string) (_ string, err error) {
+	ctx, span := startSpan(ctx, "GetUploadRepository", uploadAttr(uuid))
+	defer func() { endSpan(span, err) }()
+
+	session, err := s.sessions.Get(ctx, uuid)
+	if err != nil {
+		return "", err
+	}
+	return session.Repository, nil
+}
+
+// GetUploadOffset returns the number of bytes written so far to an in-progress upload.
+func (s *OCIStorage) GetUploadOffset(ctx context.Context, uuid string) (_ int64, err error) {
+	ctx, span := startSpan(ctx, "GetUploadOffset", uploadAttr(uuid))
+	defer func() { endSpan(span, err) }()
+
+	session, err := s.sessions.Get(ctx, uuid)
 	if err != nil {
 		return 0, err
 	}
+	return session.BytesWritten, nil
+}
 
-	uploadPath := UploadDataPath(uuid)
+// CheckUploadQuota returns ErrQuotaExceeded if appending size more bytes to an
+// in-progress upload would take its repository over quota, so a chunk of known
+// size can be refused before any of it is written.
+func (s *OCIStorage) CheckUploadQuota(ctx context.Context, uuid string, size int64) (err error) {
+	ctx, span := startSpan(ctx, "CheckUploadQuota", uploadAttr(uuid))
+	defer func() { endSpan(span, err) }()
 
-	// Read existing data if any
-	var existingData []byte
-	if session.BytesWritten > 0 {
-		rc, err := s.store.Download(ctx, uploadPath)
-		if err != nil && err != storage.ErrFileNotFound {
-			return 0, fmt.Errorf("failed to read existing upload: %w", err)
+	if len(s.quotas) == 0 {
+		return nil
+	}
+	session, err := s.sessions.Get(ctx, uuid)
+	if err != nil {
+		return err
+	}
+	return s.checkQuota(ctx, session.Repository, session.BytesWritten+size)
+}
+
+// WriteUploadChunk streams data onto the end of an in-progress upload and
+// returns the total number of bytes written so far. The running digest is
+// carried in the session so earlier chunks are never re-read.
+func (s *OCIStorage) WriteUploadChunk(ctx context.Context, uuid string, data io.Reader) (_ int64, err error) {
+	ctx, span := startSpan(ctx, "WriteUploadChunk", uploadAttr(uuid))
+	defer func() { endSpan(span, err) }()
+
+	session, err := s.sessions.Get(ctx, uuid)
+	if err != nil {
+		return 0, err
+	}
+
+	digester, err := NewResumableDigester(session.DigestAlgorithm, session.HashState)
+	if err != nil {
+		return 0, err
+	}
+
+	// Stop reading the chunk once the upload no longer fits within quota. The
+	// digest is not known yet, so the upload counts in full even if the
+	// repository already holds the blob.
+	reader := io.TeeReader(data, digester)
+	var limited *quotaReader
+	if len(s.quotas) > 0 {
+		headroom, err := s.quotaHeadroom(ctx, session.Repository)
+		if err != nil {
+			return 0, err
 		}
-		if rc != nil {
-			existingData, err = io.ReadAll(rc)
-			rc.Close()
-			if err != nil {
-				return 0, fmt.Errorf("failed to read existing upload data: %w", err)
-			}
+		if headroom >= 0 {
+			limited = &quotaReader{r: reader, remaining: headroom - session.BytesWritten}
+			reader = limited
 		}
 	}
 
-	// Read new chunk
-	newData, err := io.ReadAll(data)
+	n, err := s.store.Append(ctx, UploadDataPath(uuid), reader)
+	if limited != nil && limited.exceeded {
+		// Abandon uploads that could not be stored within quota
+		s.CancelUpload(ctx, uuid)
+		if err := s.checkQuota(ctx, session.Repository, session.BytesWritten+limited.read); err != nil {
+			return 0, err
+		}
+		return 0, ErrQuotaExceeded
+	}
 	if err != nil {
-		return 0, fmt.Errorf("failed to read upload chunk: %w", err)
+		return 0, fmt.Errorf("failed to write upload chunk: %w", err)
 	}
 
-	// Combine and write back
-	combined := append(existingData, newData...)
-	err = s.store.Upload(ctx, uploadPath, bytes.NewReader(combined))
+	state, err := digester.State()
 	if err != nil {
-		return 0, fmt.Errorf("failed to write upload chunk: %w", err)
+		return 0, fmt.Errorf("failed to save digest state: %w", err)
 	}
 
-	totalSize := int64(len(combined))
-	s.sessions.UpdateBytes(uuid, totalSize)
+	totalSize := session.BytesWritten + n
+	if err := s.sessions.UpdateProgress(ctx, uuid, totalSize, state); err != nil {
+		return 0, err
+	}
 
 	return totalSize, nil
 }
 
 // CompleteUpload finalizes an upload, verifying the digest and moving to content-addressable storage.
-func (s *OCIStorage) CompleteUpload(ctx context.Context, uuid string, expectedDigest DigestInfo) (DigestInfo, error) {
-	_, err := s.sessions.Get(uuid)
+// The digest comes from the session's running hash, so the upload data is not read again
+// unless the expected digest uses a different algorithm than the session was started with.
+func (s *OCIStorage) CompleteUpload(ctx context.Context, uuid string, expectedDigest DigestInfo) (_ DigestInfo, err error) {
+	ctx, span := startSpan(ctx, "CompleteUpload", uploadAttr(uuid), digestAttr(expectedDigest))
+	defer func() { endSpan(span, err) }()
+
+	session, err := s.sessions.Get(ctx, uuid)
 	if err != nil {
 		return DigestInfo{}, err
 	}
 
-	uploadPath := UploadDataPath(uuid)
-
-	// Download the upload data
-	rc, err := s.store.Download(ctx, uploadPath)
+	// Verify digest
+	computed, err := s.uploadDigest(ctx, session, expectedDigest.Algorithm)
 	if err != nil {
-		return DigestInfo{}, fmt.Errorf("failed to read upload: %w", err)
+		return DigestInfo{}, err
 	}
-
-	// Read through verifying reader
-	vr := NewVerifyingReader(rc)
-	data, err := io.ReadAll(vr)
-	rc.Close()
-	if err != nil {
-		return DigestInfo{}, fmt.Errorf("failed to read upload data: %w", err)
+	if computed.Algorithm != expectedDigest.Algorithm || computed.Hex != expectedDigest.Hex {
+		return DigestInfo{}, fmt.Errorf("%w: expected %s, got %s", ErrDigestMismatch, expectedDigest.String(), computed.String())
 	}
 
-	// Verify digest
-	if err := vr.Verify(expectedDigest); err != nil {
+	if err := s.checkLinkQuota(ctx, session.Repository, expectedDigest, session.BytesWritten); err != nil {
+		if errors.Is(err, ErrQuotaExceeded) {
+			s.CancelUpload(ctx, uuid)
+		}
 		return DigestInfo{}, err
 	}
 
-	// Store at content-addressable path
-	blobPath := BlobDataPath(expectedDigest)
-	err = s.store.Upload(ctx, blobPath, bytes.NewReader(data))
+	// Move to content-addressable path
+	err = s.store.Move(ctx, UploadDataPath(uuid), BlobDataPath(expectedDigest))
 	if err != nil {
 		return DigestInfo{}, fmt.Errorf("failed to store blob: %w", err)
 	}
 
-	// Clean up upload
-	s.store.Delete(ctx, uploadPath)
-	s.sessions.Delete(uuid)
+	if err := s.linkBlob(ctx, session.Repository, expectedDigest); err != nil {
+		return DigestInfo{}, err
+	}
+
+	s.sessions.Delete(ctx, uuid)
 
 	return expectedDigest, nil
 }
 
+// uploadDigest returns the digest of an upload's data using algorithm. The
+// session's saved state is used when it matches; otherwise the data is re-read.
+func (s *OCIStorage) uploadDigest(ctx context.Context, session *UploadSession, algorithm string) (DigestInfo, error) {
+	if algorithm == session.DigestAlgorithm {
+		digester, err := NewResumableDigester(session.DigestAlgorithm, session.HashState)
+		if err != nil {
+			return DigestInfo{}, err
+		}
+		return digester.Digest(), nil
+	}
+
+	reader, err := s.store.Download(ctx, UploadDataPath(session.UUID))
+	if err != nil {
+		return DigestInfo{}, fmt.Errorf("failed to read upload data: %w", err)
+	}
+	defer reader.Close()
+
+	vr, err := NewVerifyingReaderWithAlgorithm(reader, algorithm)
+	if err != nil {
+		return DigestInfo{}, err
+	}
+	if _, err := io.Copy(io.Discard, vr); err != nil {
+		return DigestInfo{}, fmt.Errorf("failed to digest upload data: %w", err)
+	}
+	return vr.Digest(), nil
+}
+
 // CancelUpload removes an in-progress upload.
-func (s *OCIStorage) CancelUpload(ctx context.Context, uuid string) error {
-	_, err := s.sessions.Get(uuid)
+func (s *OCIStorage) CancelUpload(ctx context.Context, uuid string) (err error) {
+	ctx, span := startSpan(ctx, "CancelUpload", uploadAttr(uuid))
+	defer func() { endSpan(span, err) }()
+
+	_, err = s.sessions.Get(ctx, uuid)
 	if err != nil {
 		return err
 	}
 
 	s.store.Delete(ctx, UploadDataPath(uuid))
-	s.sessions.Delete(uuid)
+	s.sessions.Delete(ctx, uuid)
 	return nil
 }
 
-// PutManifest stores a manifest by digest, and if reference is a tag, creates a tag link.
-func (s *OCIStorage) PutManifest(ctx context.Context, name, reference string, contentType string, data []byte) (DigestInfo, error) {
+// PutManifest validates and stores a manifest by digest, and if reference is a tag, creates a tag link.
+// The manifest must be a supported image manifest or index whose referenced blobs
+// (or child manifests) already exist. When reference is a digest, the manifest
+// content must hash to it and is addressed with that digest's algorithm; tagged
+// manifests use DefaultDigestAlgorithm. Tags covered by an immutability rule
+// cannot be moved to a different digest.
+func (s *OCIStorage) PutManifest(ctx context.Context, name, reference string, contentType string, data []byte) (_ DigestInfo, err error) {
+	ctx, span := startSpan(ctx, "PutManifest", repositoryAttr(name), referenceAttr(reference))
+	defer func() { endSpan(span, err) }()
+
+	var expected *DigestInfo
+	algorithm := DefaultDigestAlgorithm
+	if isDigestReference(reference) {
+		d, err := ParseDigest(reference)
+		if err != nil {
+			return DigestInfo{}, err
+		}
+		expected = &d
+		algorithm = d.Algorithm
+	}
+
 	// Compute digest
-	vr := NewVerifyingReader(bytes.NewReader(data))
-	_, err := io.Copy(io.Discard, vr)
+	vr, err := NewVerifyingReaderWithAlgorithm(bytes.NewReader(data), algorithm)
 	if err != nil {
+		return DigestInfo{}, err
+	}
+	if _, err := io.Copy(io.Discard, vr); err != nil {
 		return DigestInfo{}, fmt.Errorf("failed to compute manifest digest: %w", err)
 	}
 	digest := vr.Digest()
 
+	if expected != nil {
+		if err := vr.Verify(*expected); err != nil {
+			return DigestInfo{}, err
+		}
+	} else if s.isTagImmutable(name, reference) {
+		// Immutable tags may only be re-pushed with the content they already point at
+		current, _, err := s.readTagLink(ctx, name, reference)
+		if err != nil && err != ErrManifestNotFound {
+			return DigestInfo{}, err
+		}
+		if err == nil && current != digest {
+			return DigestInfo{}, fmt.Errorf("%w: %s:%s already points at %s", ErrTagImmutable, name, reference, current)
+		}
+	}
+
+	m, err := ParseManifest(data)
+	if err != nil {
+		return DigestInfo{}, err
+	}
+	contentType, err = m.ResolveMediaType(contentType)
+	if err != nil {
+		return DigestInfo{}, err
+	}
+	if err := m.Validate(contentType); err != nil {
+		return DigestInfo{}, err
+	}
+	if err := s.verifyManifestReferences(ctx, name, contentType, m); err != nil {
+		return DigestInfo{}, err
+	}
+
+	if err := s.checkLinkQuota(ctx, name, digest, int64(len(data))); err != nil {
+		return DigestInfo{}, err
+	}
+
+	tag := ""
+	if !isDigestReference(reference) {
+		tag = reference
+	}
+	if err := s.storeManifest(ctx, name, tag, digest, contentType, m, data); err != nil {
+		return DigestInfo{}, err
+	}
+
+	return digest, nil
+}
+
+// storeManifest writes a validated manifest, its revision and blob links and
+// its referrer index entry, then points tag at it when tag is not empty.
+func (s *OCIStorage) storeManifest(ctx context.Context, name, tag string, digest DigestInfo, contentType string, m *Manifest, data []byte) error {
 	// Store the manifest blob
 	blobPath := BlobDataPath(digest)
-	err = s.store.Upload(ctx, blobPath, bytes.NewReader(data))
+	err := s.store.Upload(ctx, blobPath, bytes.NewReader(data))
 	if err != nil {
-		return DigestInfo{}, fmt.Errorf("failed to store manifest: %w", err)
+		return fmt.Errorf("failed to store manifest: %w", err)
+	}
+
+	// Link the manifest blob so it is also readable through the blobs endpoint
+	if err := s.linkBlob(ctx, name, digest); err != nil {
+		return err
 	}
 
 	// Store content-type metadata as a small file alongside the manifest
@@ -219,104 +493,494 @@ func (s *OCIStorage) PutManifest(ctx context.Context, name, reference string, co
 	metaContent := digest.String() + "\n" + contentType
 	err = s.store.Upload(ctx, metaPath, strings.NewReader(metaContent))
 	if err != nil {
-		return DigestInfo{}, fmt.Errorf("failed to store manifest revision link: %w", err)
+		return fmt.Errorf("failed to store manifest revision link: %w", err)
 	}
 
-	// If reference looks like a tag (not a digest), create tag link
-	if !isDigestReference(reference) {
-		tagPath := ManifestTagCurrentLinkPath(name, reference)
+	// Index manifests that declare a subject so they can be found via the referrers API
+	if subject, ok := m.SubjectDigest(); ok {
+		desc := m.referrerDescriptor(contentType, digest, int64(len(data)))
+		if err := s.putReferrer(ctx, name, subject, desc); err != nil {
+			return err
+		}
+	}
+
+	if tag != "" {
+		tagPath := ManifestTagCurrentLinkPath(name, tag)
 		err = s.store.Upload(ctx, tagPath, strings.NewReader(metaContent))
 		if err != nil {
-			return DigestInfo{}, fmt.Errorf("failed to store tag link: %w", err)
+			return fmt.Errorf("failed to store tag link: %w", err)
 		}
 	}
 
-	return digest, nil
+	return nil
 }
 
 // GetManifest retrieves a manifest by tag or digest reference.
-func (s *OCIStorage) GetManifest(ctx context.Context, name, reference string) ([]byte, DigestInfo, string, error) {
+func (s *OCIStorage) GetManifest(ctx context.Context, name, reference string) (_ []byte, _ DigestInfo, _ string, err error) {
+	ctx, span := startSpan(ctx, "GetManifest", repositoryAttr(name), referenceAttr(reference))
+	defer func() { endSpan(span, err) }()
+
+	digest, contentType, err := s.lookupManifest(ctx, name, reference)
+	if err != nil {
+		return nil, DigestInfo{}, "", err
+	}
+
+	// Read manifest data from blob storage
+	rc, err := s.store.Download(ctx, BlobDataPath(digest))
+	if err != nil {
+		if err == storage.ErrFileNotFound {
+			return nil, DigestInfo{}, "", ErrManifestNotFound
+		}
+		return nil, DigestInfo{}, "", fmt.Errorf("failed to read manifest: %w", err)
+	}
+	data, err := io.ReadAll(rc)
+	rc.Close()
+	if err != nil {
+		return nil, DigestInfo{}, "", fmt.Errorf("failed to read manifest data: %w", err)
+	}
+
+	return data, digest, contentType, nil
+}
+
+// ManifestExists checks if a manifest exists by tag or digest reference.
+func (s *OCIStorage) ManifestExists(ctx context.Context, name, reference string) (_ DigestInfo, _ string, _ int64, err error) {
+	ctx, span := startSpan(ctx, "ManifestExists", repositoryAttr(name), referenceAttr(reference))
+	defer func() { endSpan(span, err) }()
+
+	digest, contentType, err := s.lookupManifest(ctx, name, reference)
+	if err != nil {
+		return DigestInfo{}, "", 0, err
+	}
+
+	info, err := s.store.Stat(ctx, BlobDataPath(digest))
+	if err != nil {
+		if err == storage.ErrFileNotFound {
+			return DigestInfo{}, "", 0, ErrManifestNotFound
+		}
+		return DigestInfo{}, "", 0, fmt.Errorf("failed to stat manifest: %w", err)
+	}
+
+	return digest, contentType, info.Size, nil
+}
+
+// ListTags returns all tags for a repository, sorted lexically.
+func (s *OCIStorage) ListTags(ctx context.Context, name string) (_ []string, err error) {
+	ctx, span := startSpan(ctx, "ListTags", repositoryAttr(name))
+	defer func() { endSpan(span, err) }()
+
+	tagsDir := ManifestTagsDir(name)
+	entries, err := s.store.List(ctx, tagsDir)
+	if err != nil {
+		return []string{}, nil
+	}
+	sort.Strings(entries)
+	return entries, nil
+}
+
+// DeleteManifest removes a manifest reference from a repository. Deleting by tag
+// only unlinks the tag; deleting by digest removes the manifest revision along
+// with every tag that points at it, and unlinks the manifest blob so it stops
+// counting towards quotas. The blobs it references stay linked, and all data
+// is left for garbage collection.
+// Immutable tags cannot be deleted, either directly or through their digest,
+// since a deleted tag could be pushed again with different content.
+func (s *OCIStorage) DeleteManifest(ctx context.Context, name, reference string) (err error) {
+	ctx, span := startSpan(ctx, "DeleteManifest", repositoryAttr(name), referenceAttr(reference))
+	defer func() { endSpan(span, err) }()
+
+	if !isDigestReference(reference) {
+		if s.isTagImmutable(name, reference) {
+			if _, _, err := s.readTagLink(ctx, name, reference); err != nil {
+				return err
+			}
+			return fmt.Errorf("%w: %s:%s cannot be deleted", ErrTagImmutable, name, reference)
+		}
+		tagPath := ManifestTagCurrentLinkPath(name, reference)
+		if err := s.store.Delete(ctx, tagPath); err != nil {
+			if err == storage.ErrFileNotFound {
+				return ErrManifestNotFound
+			}
+			return fmt.Errorf("failed to delete tag link: %w", err)
+		}
+		return nil
+	}
+
+	digest, err := ParseDigest(reference)
+	if err != nil {
+		return err
+	}
+
+	revisionPath := ManifestRevisionLinkPath(name, digest)
+	exists, err := s.store.Exists(ctx, revisionPath)
+	if err != nil {
+		return fmt.Errorf("failed to check manifest revision: %w", err)
+	}
+	if !exists {
+		return ErrManifestNotFound
+	}
+
+	// Find every tag that currently resolves to this digest
+	tags, err := s.ListTags(ctx, name)
+	if err != nil {
+		return err
+	}
+	var linked []string
+	for _, tag := range tags {
+		tagDigest, _, err := s.readTagLink(ctx, name, tag)
+		if err != nil {
+			if err == ErrManifestNotFound {
+				continue
+			}
+			return err
+		}
+		if tagDigest != digest {
+			continue
+		}
+		if s.isTagImmutable(name, tag) {
+			return fmt.Errorf("%w: %s:%s points at %s", ErrTagImmutable, name, tag, digest)
+		}
+		linked = append(linked, tag)
+	}
+
+	for _, tag := range linked {
+		err = s.store.Delete(ctx, ManifestTagCurrentLinkPath(name, tag))
+		if err != nil && err != storage.ErrFileNotFound {
+			return fmt.Errorf("failed to delete tag link: %w", err)
+		}
+	}
+
+	// Drop the manifest from its subject's referrer index
+	if err := s.deleteReferrer(ctx, name, digest); err != nil {
+		return err
+	}
+
+	err = s.store.Delete(ctx, revisionPath)
+	if err != nil && err != storage.ErrFileNotFound {
+		return fmt.Errorf("failed to delete manifest revision link: %w", err)
+	}
+
+	err = s.store.Delete(ctx, LayerLinkPath(name, digest))
+	if err != nil && err != storage.ErrFileNotFound {
+		return fmt.Errorf("failed to delete manifest blob link: %w", err)
+	}
+	s.invalidateUsage(name)
+
+	return nil
+}
+
+// ListRepositories returns the names of all repositories in the registry, sorted
+// lexically. Nested names such as "team/app/api" are discovered by walking the
+// repositories tree; any directory holding a _manifests or _layers entry is a repository.
+func (s *OCIStorage) ListRepositories(ctx context.Context) (_ []string, err error) {
+	ctx, span := startSpan(ctx, "ListRepositories")
+	defer func() { endSpan(span, err) }()
+
+	repositories := []string{}
+	if err := s.walkRepositories(ctx, RepositoriesDir(), "", &repositories); err != nil {
+		return nil, err
+	}
+	sort.Strings(repositories)
+	return repositories, nil
+}
+
+// ListReferrers returns descriptors of the manifests in a repository whose
+// subject is the given digest, optionally filtered by artifact type.
+func (s *OCIStorage) ListReferrers(ctx context.Context, name string, subject DigestInfo, artifactType string) (_ []Descriptor, err error) {
+	ctx, span := startSpan(ctx, "ListReferrers", repositoryAttr(name), digestAttr(subject))
+	defer func() { endSpan(span, err) }()
+
+	referrersDir := ReferrersDir(name, subject)
+	algorithms, err := s.store.List(ctx, referrersDir)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list referrers: %w", err)
+	}
+
+	descriptors := []Descriptor{}
+	for _, algorithm := range algorithms {
+		hexes, err := s.store.List(ctx, path.Join(referrersDir, algorithm))
+		if err != nil {
+			return nil, fmt.Errorf("failed to list referrers: %w", err)
+		}
+		for _, hex := range hexes {
+			referrer := DigestInfo{Algorithm: algorithm, Hex: hex}
+			desc, err := s.readReferrer(ctx, name, subject, referrer)
+			if err != nil {
+				if err == storage.ErrFileNotFound {
+					continue
+				}
+				return nil, err
+			}
+			if artifactType != "" && desc.ArtifactType != artifactType {
+				continue
+			}
+			descriptors = append(descriptors, desc)
+		}
+	}
+
+	sort.Slice(descriptors, func(i, j int) bool {
+		return descriptors[i].Digest < descriptors[j].Digest
+	})
+	return descriptors, nil
+}
+
+// DeleteBlob removes a blob from the repository by deleting its link. The data
+// itself is shared and is reclaimed by garbage collection once no repository
+// references it.
+func (s *OCIStorage) DeleteBlob(ctx context.Context, name string, digest DigestInfo) (err error) {
+	ctx, span := startSpan(ctx, "DeleteBlob", repositoryAttr(name), digestAttr(digest))
+	defer func() { endSpan(span, err) }()
+
+	if err := s.checkBlobLink(ctx, name, digest); err != nil {
+		return err
+	}
+
+	err = s.store.Delete(ctx, LayerLinkPath(name, digest))
+	if err != nil {
+		if err == storage.ErrFileNotFound {
+			return ErrBlobNotFound
+		}
+		return fmt.Errorf("failed to delete blob link: %w", err)
+	}
+	s.invalidateUsage(name)
+	return nil
+}
+
+// linkBlob records that a repository holds a blob. With quotas, a new link
+// adds the blob's size to the repository's usage.
+func (s *OCIStorage) linkBlob(ctx context.Context, name string, digest DigestInfo) error {
+	linked := true
+	if s.usage != nil {
+		var err error
+		linked, err = s.store.Exists(ctx, LayerLinkPath(name, digest))
+		if err != nil {
+			return fmt.Errorf("failed to check blob link: %w", err)
+		}
+	}
+
+	err := s.store.Upload(ctx, LayerLinkPath(name, digest), strings.NewReader(digest.String()))
+	if err != nil {
+		return fmt.Errorf("failed to store blob link: %w", err)
+	}
+	if !linked {
+		s.addUsage(ctx, name, digest)
+	}
+	return nil
+}
+
+// checkBlobLink returns ErrBlobNotFound unless the repository links the blob.
+func (s *OCIStorage) checkBlobLink(ctx context.Context, name string, digest DigestInfo) error {
+	linked, err := s.store.Exists(ctx, LayerLinkPath(name, digest))
+	if err != nil {
+		return fmt.Errorf("failed to check blob link: %w", err)
+	}
+	if !linked {
+		return ErrBlobNotFound
+	}
+	return nil
+}
+
+// lookupManifest resolves a reference for a pull. In pull-through mode the
+// manifest is first fetched or revalidated from the upstream.
+func (s *OCIStorage) lookupManifest(ctx context.Context, name, reference string) (DigestInfo, string, error) {
+	if s.upstream != nil {
+		if err := s.refreshManifest(ctx, name, reference); err != nil {
+			return DigestInfo{}, "", err
+		}
+	}
+	return s.resolveManifest(ctx, name, reference)
+}
+
+// resolveManifest resolves a tag or digest reference to the manifest digest and
+// its content type.
+func (s *OCIStorage) resolveManifest(ctx context.Context, name, reference string) (DigestInfo, string, error) {
 	var digest DigestInfo
 	var contentType string
 
 	if isDigestReference(reference) {
 		d, err := ParseDigest(reference)
 		if err != nil {
-			return nil, DigestInfo{}, "", err
+			return DigestInfo{}, "", err
 		}
 		digest = d
 
 		// Read content type from revision link
 		ct, err := s.readManifestMeta(ctx, name, digest)
 		if err != nil {
-			return nil, DigestInfo{}, "", err
+			return DigestInfo{}, "", err
 		}
 		contentType = ct
 	} else {
 		// Look up tag
-		tagPath := ManifestTagCurrentLinkPath(name, reference)
-		rc, err := s.store.Download(ctx, tagPath)
+		d, ct, err := s.readTagLink(ctx, name, reference)
 		if err != nil {
-			if err == storage.ErrFileNotFound {
-				return nil, DigestInfo{}, "", ErrManifestNotFound
+			return DigestInfo{}, "", err
+		}
+		digest = d
+		contentType = ct
+	}
+
+	if contentType == "" {
+		contentType = MediaTypeImageManifest
+	}
+
+	return digest, contentType, nil
+}
+
+// readTagLink reads the digest and content type a tag currently points at.
+func (s *OCIStorage) readTagLink(ctx context.Context, name, tag string) (DigestInfo, string, error) {
+	rc, err := s.store.Download(ctx, ManifestTagCurrentLinkPath(name, tag))
+	if err != nil {
+		if err == storage.ErrFileNotFound {
+			return DigestInfo{}, "", ErrManifestNotFound
+		}
+		return DigestInfo{}, "", fmt.Errorf("failed to read tag: %w", err)
+	}
+	linkData, err := io.ReadAll(rc)
+	rc.Close()
+	if err != nil {
+		return DigestInfo{}, "", fmt.Errorf("failed to read tag link: %w", err)
+	}
+
+	parts := strings.SplitN(string(linkData), "\n", 2)
+	digest, err := ParseDigest(strings.TrimSpace(parts[0]))
+	if err != nil {
+		return DigestInfo{}, "", fmt.Errorf("invalid digest in tag link: %w", err)
+	}
+
+	var contentType string
+	if len(parts) > 1 {
+		contentType = strings.TrimSpace(parts[1])
+	}
+	return digest, contentType, nil
+}
+
+// walkRepositories recursively collects repository names below dir.
+func (s *OCIStorage) walkRepositories(ctx context.Context, dir, name string, repositories *[]string) error {
+	entries, err := s.store.List(ctx, dir)
+	if err != nil {
+		return fmt.Errorf("failed to list repositories: %w", err)
+	}
+	isRepository := false
+	for _, entry := range entries {
+		if entry == "_manifests" || entry == "_layers" {
+			if !isRepository {
+				*repositories = append(*repositories, name)
+				isRepository = true
 			}
-			return nil, DigestInfo{}, "", fmt.Errorf("failed to read tag: %w", err)
+			continue
+		}
+		// Other underscore-prefixed entries hold repository data, not nested repositories
+		if strings.HasPrefix(entry, "_") {
+			continue
 		}
-		linkData, err := io.ReadAll(rc)
-		rc.Close()
+		err := s.walkRepositories(ctx, path.Join(dir, entry), path.Join(name, entry), repositories)
 		if err != nil {
-			return nil, DigestInfo{}, "", fmt.Errorf("failed to read tag link: %w", err)
+			return err
 		}
+	}
+	return nil
+}
 
-		parts := strings.SplitN(string(linkData), "\n", 2)
-		d, err := ParseDigest(strings.TrimSpace(parts[0]))
+// verifyManifestReferences checks that everything a manifest points at is
+// already present: config and layer blobs for image manifests, child manifests
+// in the same repository for indexes. Layers with external URLs (foreign
+// layers) are not required to be stored locally.
+func (s *OCIStorage) verifyManifestReferences(ctx context.Context, name, mediaType string, m *Manifest) error {
+	if IsIndexMediaType(mediaType) {
+		for _, desc := range m.Manifests {
+			d, _ := ParseDigest(desc.Digest)
+			exists, err := s.store.Exists(ctx, ManifestRevisionLinkPath(name, d))
+			if err != nil {
+				return fmt.Errorf("failed to check child manifest: %w", err)
+			}
+			if !exists {
+				return fmt.Errorf("%w: manifest %s", ErrManifestBlobUnknown, d)
+			}
+		}
+		return nil
+	}
+
+	descriptors := append([]Descriptor{*m.Config}, m.Layers...)
+	for _, desc := range descriptors {
+		if len(desc.URLs) > 0 {
+			continue
+		}
+		d, _ := ParseDigest(desc.Digest)
+		exists, err := s.BlobExists(ctx, name, d)
 		if err != nil {
-			return nil, DigestInfo{}, "", fmt.Errorf("invalid digest in tag link: %w", err)
+			return fmt.Errorf("failed to check referenced blob: %w", err)
 		}
-		digest = d
-		if len(parts) > 1 {
-			contentType = strings.TrimSpace(parts[1])
+		if !exists {
+			return fmt.Errorf("%w: blob %s", ErrManifestBlobUnknown, d)
 		}
 	}
+	return nil
+}
 
-	// Read manifest data from blob storage
-	rc, err := s.store.Download(ctx, BlobDataPath(digest))
+// putReferrer records a referrer descriptor in the subject's referrer index.
+func (s *OCIStorage) putReferrer(ctx context.Context, name string, subject DigestInfo, desc Descriptor) error {
+	referrer, err := ParseDigest(desc.Digest)
 	if err != nil {
-		if err == storage.ErrFileNotFound {
-			return nil, DigestInfo{}, "", ErrManifestNotFound
-		}
-		return nil, DigestInfo{}, "", fmt.Errorf("failed to read manifest: %w", err)
+		return err
 	}
-	data, err := io.ReadAll(rc)
-	rc.Close()
+	data, err := json.Marshal(desc)
 	if err != nil {
-		return nil, DigestInfo{}, "", fmt.Errorf("failed to read manifest data: %w", err)
+		return fmt.Errorf("failed to encode referrer: %w", err)
 	}
+	err = s.store.Upload(ctx, ReferrerLinkPath(name, subject, referrer), bytes.NewReader(data))
+	if err != nil {
+		return fmt.Errorf("failed to store referrer link: %w", err)
+	}
+	return nil
+}
 
-	if contentType == "" {
-		contentType = "application/vnd.oci.image.manifest.v1+json"
+// readReferrer reads a single referrer descriptor from the subject's referrer index.
+func (s *OCIStorage) readReferrer(ctx context.Context, name string, subject, referrer DigestInfo) (Descriptor, error) {
+	rc, err := s.store.Download(ctx, ReferrerLinkPath(name, subject, referrer))
+	if err != nil {
+		if err == storage.ErrFileNotFound {
+			return Descriptor{}, err
+		}
+		return Descriptor{}, fmt.Errorf("failed to read referrer link: %w", err)
 	}
+	defer rc.Close()
 
-	return data, digest, contentType, nil
+	var desc Descriptor
+	if err := json.NewDecoder(rc).Decode(&desc); err != nil {
+		return Descriptor{}, fmt.Errorf("failed to decode referrer link: %w", err)
+	}
+	return desc, nil
 }
 
-// ManifestExists checks if a manifest exists by tag or digest reference.
-func (s *OCIStorage) ManifestExists(ctx context.Context, name, reference string) (DigestInfo, string, int64, error) {
-	data, digest, contentType, err := s.GetManifest(ctx, name, reference)
+// deleteReferrer removes a manifest from its subject's referrer index, if it has a subject.
+func (s *OCIStorage) deleteReferrer(ctx context.Context, name string, digest DigestInfo) error {
+	rc, err := s.store.Download(ctx, BlobDataPath(digest))
 	if err != nil {
-		return DigestInfo{}, "", 0, err
+		if err == storage.ErrFileNotFound {
+			return nil
+		}
+		return fmt.Errorf("failed to read manifest: %w", err)
+	}
+	data, err := io.ReadAll(rc)
+	rc.Close()
+	if err != nil {
+		return fmt.Errorf("failed to read manifest data: %w", err)
 	}
-	return digest, contentType, int64(len(data)), nil
-}
 
-// ListTags returns all tags for a repository.
-func (s *OCIStorage) ListTags(ctx context.Context, name string) ([]string, error) {
-	tagsDir := ManifestTagsDir(name)
-	entries, err := s.store.List(ctx, tagsDir)
+	m, err := ParseManifest(data)
 	if err != nil {
-		return []string{}, nil
+		return nil
 	}
-	return entries, nil
+	subject, ok := m.SubjectDigest()
+	if !ok {
+		return nil
+	}
+
+	err = s.store.Delete(ctx, ReferrerLinkPath(name, subject, digest))
+	if err != nil && err != storage.ErrFileNotFound {
+		return fmt.Errorf("failed to delete referrer link: %w", err)
+	}
+	return nil
 }
 
 // readManifestMeta reads the content type from a manifest revision link.
@@ -4,7 +4,10 @@ import (
 	"bytes"
 	"context"
 	"crypto/sha256"
+	"crypto/sha512"
+	"errors"
 	"fmt"
+	"io"
 	"testing"
 	"time"
 
@@ -18,7 +21,7 @@ func setupTestOCIStorage(t *testing.T) *OCIStorage {
 	if err != nil {
 		t.Fatalf("failed to create local storage: %v", err)
 	}
-	sessions := NewSessionManager(30 * time.Minute)
+	sessions := NewSessionManager(store, 30*time.Minute)
 	return NewOCIStorage(store, sessions)
 }
 
@@ -30,6 +33,34 @@ func computeSHA256(data []byte) DigestInfo {
 	}
 }
 
+// putTestBlob uploads data as a blob and returns its digest.
+func putTestBlob(t *testing.T, s *OCIStorage, name string, data []byte) DigestInfo {
+	t.Helper()
+	ctx := context.Background()
+	digest := computeSHA256(data)
+
+	uuid, err := s.InitiateUpload(ctx, name, "")
+	if err != nil {
+		t.Fatalf("InitiateUpload failed: %v", err)
+	}
+	if _, err := s.WriteUploadChunk(ctx, uuid, bytes.NewReader(data)); err != nil {
+		t.Fatalf("WriteUploadChunk failed: %v", err)
+	}
+	if _, err := s.CompleteUpload(ctx, uuid, digest); err != nil {
+		t.Fatalf("CompleteUpload failed: %v", err)
+	}
+	return digest
+}
+
+// newTestManifest uploads a config blob derived from seed and returns an image
+// manifest referencing it. Different seeds produce different manifests.
+func newTestManifest(t *testing.T, s *OCIStorage, name, seed string) []byte {
+	t.Helper()
+	config := []byte(fmt.Sprintf(`{"seed":%q}`, seed))
+	digest := putTestBlob(t, s, name, config)
+	return []byte(fmt.Sprintf(`{"schemaVersion":2,"mediaType":"application/vnd.oci.image.manifest.v1+json","config":{"mediaType":"application/vnd.oci.image.config.v1+json","digest":"%s","size":%d},"layers":[]}`, digest, len(config)))
+}
+
 func TestOCIStorage_MonolithicUpload(t *testing.T) {
 	ctx := context.Background()
 	s := setupTestOCIStorage(t)
@@ -38,7 +69,7 @@ func TestOCIStorage_MonolithicUpload(t *testing.T) {
 	expectedDigest := computeSHA256(blobData)
 
 	// Initiate upload
-	uuid, err := s.InitiateUpload(ctx, "myrepo")
+	uuid, err := s.InitiateUpload(ctx, "myrepo", "")
 	if err != nil {
 		t.Fatalf("InitiateUpload failed: %v", err)
 	}
@@ -59,7 +90,7 @@ func TestOCIStorage_MonolithicUpload(t *testing.T) {
 	}
 
 	// Verify blob exists
-	exists, err := s.BlobExists(ctx, expectedDigest)
+	exists, err := s.BlobExists(ctx, "myrepo", expectedDigest)
 	if err != nil {
 		t.Fatalf("BlobExists failed: %v", err)
 	}
@@ -68,7 +99,7 @@ func TestOCIStorage_MonolithicUpload(t *testing.T) {
 	}
 
 	// Download and verify
-	rc, err := s.GetBlob(ctx, expectedDigest)
+	rc, err := s.GetBlob(ctx, "myrepo", expectedDigest)
 	if err != nil {
 		t.Fatalf("GetBlob failed: %v", err)
 	}
@@ -91,7 +122,7 @@ func TestOCIStorage_ChunkedUpload(t *testing.T) {
 	expectedDigest := computeSHA256(fullData)
 
 	// Initiate upload
-	uuid, err := s.InitiateUpload(ctx, "myrepo")
+	uuid, err := s.InitiateUpload(ctx, "myrepo", "")
 	if err != nil {
 		t.Fatalf("InitiateUpload failed: %v", err)
 	}
@@ -103,10 +134,13 @@ func TestOCIStorage_ChunkedUpload(t *testing.T) {
 	}
 
 	// Write chunk 2
-	_, err = s.WriteUploadChunk(ctx, uuid, bytes.NewReader(chunk2))
+	total, err := s.WriteUploadChunk(ctx, uuid, bytes.NewReader(chunk2))
 	if err != nil {
 		t.Fatalf("WriteUploadChunk chunk2 failed: %v", err)
 	}
+	if total != int64(len(fullData)) {
+		t.Errorf("total = %d, want %d", total, len(fullData))
+	}
 
 	// Complete upload
 	digest, err := s.CompleteUpload(ctx, uuid, expectedDigest)
@@ -118,13 +152,25 @@ func TestOCIStorage_ChunkedUpload(t *testing.T) {
 	}
 
 	// Verify blob exists and content
-	info, err := s.GetBlobInfo(ctx, expectedDigest)
+	info, err := s.GetBlobInfo(ctx, "myrepo", expectedDigest)
 	if err != nil {
 		t.Fatalf("GetBlobInfo failed: %v", err)
 	}
 	if info.Size != int64(len(fullData)) {
 		t.Errorf("size = %d, want %d", info.Size, len(fullData))
 	}
+	if info.ModTime.IsZero() {
+		t.Error("modification time should be set")
+	}
+
+	// The upload data is moved, not copied
+	exists, err := s.store.Exists(ctx, UploadDataPath(uuid))
+	if err != nil {
+		t.Fatalf("Exists failed: %v", err)
+	}
+	if exists {
+		t.Error("upload data should be removed after completion")
+	}
 }
 
 func TestOCIStorage_DigestMismatch(t *testing.T) {
@@ -134,7 +180,7 @@ func TestOCIStorage_DigestMismatch(t *testing.T) {
 	blobData := []byte("some data")
 	wrongDigest := DigestInfo{Algorithm: "sha256", Hex: "0000000000000000000000000000000000000000000000000000000000000000"}
 
-	uuid, _ := s.InitiateUpload(ctx, "myrepo")
+	uuid, _ := s.InitiateUpload(ctx, "myrepo", "")
 	s.WriteUploadChunk(ctx, uuid, bytes.NewReader(blobData))
 
 	_, err := s.CompleteUpload(ctx, uuid, wrongDigest)
@@ -147,7 +193,7 @@ func TestOCIStorage_CancelUpload(t *testing.T) {
 	ctx := context.Background()
 	s := setupTestOCIStorage(t)
 
-	uuid, _ := s.InitiateUpload(ctx, "myrepo")
+	uuid, _ := s.InitiateUpload(ctx, "myrepo", "")
 	s.WriteUploadChunk(ctx, uuid, bytes.NewReader([]byte("data")))
 
 	err := s.CancelUpload(ctx, uuid)
@@ -166,7 +212,7 @@ func TestOCIStorage_ManifestPushPull(t *testing.T) {
 	ctx := context.Background()
 	s := setupTestOCIStorage(t)
 
-	manifestData := []byte(`{"schemaVersion":2,"mediaType":"application/vnd.oci.image.manifest.v1+json"}`)
+	manifestData := newTestManifest(t, s, "myrepo/myimage", "push-pull")
 	contentType := "application/vnd.oci.image.manifest.v1+json"
 
 	// Push manifest with tag
@@ -232,8 +278,8 @@ func TestOCIStorage_ListTags(t *testing.T) {
 	ctx := context.Background()
 	s := setupTestOCIStorage(t)
 
-	manifest1 := []byte(`{"schemaVersion":2,"tag":"v1"}`)
-	manifest2 := []byte(`{"schemaVersion":2,"tag":"v2"}`)
+	manifest1 := newTestManifest(t, s, "myrepo", "v1")
+	manifest2 := newTestManifest(t, s, "myrepo", "v2")
 	ct := "application/vnd.oci.image.manifest.v1+json"
 
 	s.PutManifest(ctx, "myrepo", "v1.0", ct, manifest1)
@@ -279,7 +325,7 @@ func TestOCIStorage_BlobNotFound(t *testing.T) {
 
 	d := DigestInfo{Algorithm: "sha256", Hex: "0000000000000000000000000000000000000000000000000000000000000000"}
 
-	exists, err := s.BlobExists(ctx, d)
+	exists, err := s.BlobExists(ctx, "myrepo", d)
 	if err != nil {
 		t.Fatalf("BlobExists failed: %v", err)
 	}
@@ -287,13 +333,488 @@ func TestOCIStorage_BlobNotFound(t *testing.T) {
 		t.Error("blob should not exist")
 	}
 
-	_, err = s.GetBlob(ctx, d)
+	_, err = s.GetBlob(ctx, "myrepo", d)
+	if err != ErrBlobNotFound {
+		t.Errorf("expected ErrBlobNotFound, got %v", err)
+	}
+
+	_, err = s.GetBlobInfo(ctx, "myrepo", d)
 	if err != ErrBlobNotFound {
 		t.Errorf("expected ErrBlobNotFound, got %v", err)
 	}
 
-	_, err = s.GetBlobInfo(ctx, d)
+	_, err = s.GetBlobRange(ctx, "myrepo", d, 0, 1)
 	if err != ErrBlobNotFound {
 		t.Errorf("expected ErrBlobNotFound, got %v", err)
 	}
 }
+
+func TestOCIStorage_GetBlobRange(t *testing.T) {
+	ctx := context.Background()
+	s := setupTestOCIStorage(t)
+
+	d := putTestBlob(t, s, "myrepo", []byte("0123456789"))
+
+	rc, err := s.GetBlobRange(ctx, "myrepo", d, 3, 4)
+	if err != nil {
+		t.Fatalf("GetBlobRange failed: %v", err)
+	}
+	defer rc.Close()
+
+	data, _ := io.ReadAll(rc)
+	if string(data) != "3456" {
+		t.Errorf("range = %q, want %q", data, "3456")
+	}
+}
+
+func TestOCIStorage_DeleteManifestByTag(t *testing.T) {
+	ctx := context.Background()
+	s := setupTestOCIStorage(t)
+
+	manifestData := newTestManifest(t, s, "myrepo", "v1")
+	ct := "application/vnd.oci.image.manifest.v1+json"
+
+	digest, _ := s.PutManifest(ctx, "myrepo", "v1.0", ct, manifestData)
+	s.PutManifest(ctx, "myrepo", "stable", ct, manifestData)
+
+	if err := s.DeleteManifest(ctx, "myrepo", "v1.0"); err != nil {
+		t.Fatalf("DeleteManifest failed: %v", err)
+	}
+
+	_, _, _, err := s.GetManifest(ctx, "myrepo", "v1.0")
+	if err != ErrManifestNotFound {
+		t.Errorf("expected ErrManifestNotFound for deleted tag, got %v", err)
+	}
+
+	// Other tags and the digest itself remain resolvable
+	if _, _, _, err := s.GetManifest(ctx, "myrepo", "stable"); err != nil {
+		t.Errorf("GetManifest by remaining tag failed: %v", err)
+	}
+	if _, _, _, err := s.GetManifest(ctx, "myrepo", digest.String()); err != nil {
+		t.Errorf("GetManifest by digest failed: %v", err)
+	}
+
+	tags, _ := s.ListTags(ctx, "myrepo")
+	if len(tags) != 1 || tags[0] != "stable" {
+		t.Errorf("expected only tag stable, got %v", tags)
+	}
+}
+
+func TestOCIStorage_DeleteManifestByDigest(t *testing.T) {
+	ctx := context.Background()
+	s := setupTestOCIStorage(t)
+
+	manifest1 := newTestManifest(t, s, "myrepo", "v1")
+	manifest2 := newTestManifest(t, s, "myrepo", "v2")
+	ct := "application/vnd.oci.image.manifest.v1+json"
+
+	digest, _ := s.PutManifest(ctx, "myrepo", "v1.0", ct, manifest1)
+	s.PutManifest(ctx, "myrepo", "latest", ct, manifest1)
+	s.PutManifest(ctx, "myrepo", "v2.0", ct, manifest2)
+
+	if err := s.DeleteManifest(ctx, "myrepo", digest.String()); err != nil {
+		t.Fatalf("DeleteManifest failed: %v", err)
+	}
+
+	_, _, _, err := s.GetManifest(ctx, "myrepo", digest.String())
+	if err != ErrManifestNotFound {
+		t.Errorf("expected ErrManifestNotFound by digest, got %v", err)
+	}
+
+	// Tags pointing at the deleted digest are removed, others are kept
+	tags, _ := s.ListTags(ctx, "myrepo")
+	if len(tags) != 1 || tags[0] != "v2.0" {
+		t.Errorf("expected only tag v2.0, got %v", tags)
+	}
+}
+
+func TestOCIStorage_DeleteManifestNotFound(t *testing.T) {
+	ctx := context.Background()
+	s := setupTestOCIStorage(t)
+
+	err := s.DeleteManifest(ctx, "myrepo", "latest")
+	if err != ErrManifestNotFound {
+		t.Errorf("expected ErrManifestNotFound for tag, got %v", err)
+	}
+
+	err = s.DeleteManifest(ctx, "myrepo", "sha256:0000000000000000000000000000000000000000000000000000000000000000")
+	if err != ErrManifestNotFound {
+		t.Errorf("expected ErrManifestNotFound for digest, got %v", err)
+	}
+}
+
+func TestOCIStorage_DeleteBlob(t *testing.T) {
+	ctx := context.Background()
+	s := setupTestOCIStorage(t)
+
+	blobData := []byte("blob to delete")
+	expectedDigest := computeSHA256(blobData)
+
+	uuid, _ := s.InitiateUpload(ctx, "myrepo", "")
+	s.WriteUploadChunk(ctx, uuid, bytes.NewReader(blobData))
+	if _, err := s.CompleteUpload(ctx, uuid, expectedDigest); err != nil {
+		t.Fatalf("CompleteUpload failed: %v", err)
+	}
+
+	if err := s.DeleteBlob(ctx, "myrepo", expectedDigest); err != nil {
+		t.Fatalf("DeleteBlob failed: %v", err)
+	}
+
+	exists, _ := s.BlobExists(ctx, "myrepo", expectedDigest)
+	if exists {
+		t.Error("blob should not exist after delete")
+	}
+
+	if err := s.DeleteBlob(ctx, "myrepo", expectedDigest); err != ErrBlobNotFound {
+		t.Errorf("expected ErrBlobNotFound on second delete, got %v", err)
+	}
+}
+
+func TestOCIStorage_Referrers(t *testing.T) {
+	ctx := context.Background()
+	s := setupTestOCIStorage(t)
+
+	subjectData := newTestManifest(t, s, "myrepo", "subject")
+	putTestBlob(t, s, "myrepo", []byte("{}"))
+	subject, err := s.PutManifest(ctx, "myrepo", "latest", MediaTypeImageManifest, subjectData)
+	if err != nil {
+		t.Fatalf("PutManifest subject failed: %v", err)
+	}
+
+	signature := []byte(fmt.Sprintf(`{"schemaVersion":2,"mediaType":"application/vnd.oci.image.manifest.v1+json","artifactType":"application/vnd.dev.cosign.artifact.sig.v1+json","config":{"mediaType":"application/vnd.oci.empty.v1+json","digest":"sha256:44136fa355b3678a1146ad16f7e8649e94fb4fc21fe77e8310c060f61caaff8a","size":2},"subject":{"mediaType":"application/vnd.oci.image.manifest.v1+json","digest":"%s","size":%d}}`, subject.String(), len(subjectData)))
+	sbom := []byte(fmt.Sprintf(`{"schemaVersion":2,"mediaType":"application/vnd.oci.image.manifest.v1+json","config":{"mediaType":"application/spdx+json","digest":"sha256:44136fa355b3678a1146ad16f7e8649e94fb4fc21fe77e8310c060f61caaff8a","size":2},"subject":{"mediaType":"application/vnd.oci.image.manifest.v1+json","digest":"%s","size":%d},"annotations":{"org.example":"sbom"}}`, subject.String(), len(subjectData)))
+
+	sigDigest, err := s.PutManifest(ctx, "myrepo", computeSHA256(signature).String(), MediaTypeImageManifest, signature)
+	if err != nil {
+		t.Fatalf("PutManifest signature failed: %v", err)
+	}
+	sbomDigest, err := s.PutManifest(ctx, "myrepo", computeSHA256(sbom).String(), MediaTypeImageManifest, sbom)
+	if err != nil {
+		t.Fatalf("PutManifest sbom failed: %v", err)
+	}
+
+	referrers, err := s.ListReferrers(ctx, "myrepo", subject, "")
+	if err != nil {
+		t.Fatalf("ListReferrers failed: %v", err)
+	}
+	if len(referrers) != 2 {
+		t.Fatalf("expected 2 referrers, got %d: %v", len(referrers), referrers)
+	}
+
+	byDigest := make(map[string]Descriptor)
+	for _, desc := range referrers {
+		byDigest[desc.Digest] = desc
+	}
+	if got := byDigest[sigDigest.String()].ArtifactType; got != "application/vnd.dev.cosign.artifact.sig.v1+json" {
+		t.Errorf("signature artifactType = %q", got)
+	}
+	sbomDesc := byDigest[sbomDigest.String()]
+	if sbomDesc.ArtifactType != "application/spdx+json" {
+		t.Errorf("sbom artifactType = %q, want config media type", sbomDesc.ArtifactType)
+	}
+	if sbomDesc.Size != int64(len(sbom)) {
+		t.Errorf("sbom size = %d, want %d", sbomDesc.Size, len(sbom))
+	}
+	if sbomDesc.Annotations["org.example"] != "sbom" {
+		t.Errorf("sbom annotations not preserved: %v", sbomDesc.Annotations)
+	}
+
+	// Filter by artifact type
+	filtered, err := s.ListReferrers(ctx, "myrepo", subject, "application/spdx+json")
+	if err != nil {
+		t.Fatalf("ListReferrers filtered failed: %v", err)
+	}
+	if len(filtered) != 1 || filtered[0].Digest != sbomDigest.String() {
+		t.Errorf("expected only sbom referrer, got %v", filtered)
+	}
+
+	// Referrers are scoped per repository
+	other, err := s.ListReferrers(ctx, "otherrepo", subject, "")
+	if err != nil {
+		t.Fatalf("ListReferrers other repo failed: %v", err)
+	}
+	if len(other) != 0 {
+		t.Errorf("expected no referrers in other repo, got %v", other)
+	}
+
+	// Deleting a referrer removes it from the index
+	if err := s.DeleteManifest(ctx, "myrepo", sigDigest.String()); err != nil {
+		t.Fatalf("DeleteManifest failed: %v", err)
+	}
+	referrers, _ = s.ListReferrers(ctx, "myrepo", subject, "")
+	if len(referrers) != 1 || referrers[0].Digest != sbomDigest.String() {
+		t.Errorf("expected only sbom referrer after delete, got %v", referrers)
+	}
+}
+
+func TestOCIStorage_ListRepositories(t *testing.T) {
+	ctx := context.Background()
+	s := setupTestOCIStorage(t)
+
+	ct := "application/vnd.oci.image.manifest.v1+json"
+	for _, name := range []string{"team/app/api", "team/app", "zeta", "alpha"} {
+		manifest := newTestManifest(t, s, name, name)
+		if _, err := s.PutManifest(ctx, name, "latest", ct, manifest); err != nil {
+			t.Fatalf("PutManifest %s failed: %v", name, err)
+		}
+	}
+
+	repos, err := s.ListRepositories(ctx)
+	if err != nil {
+		t.Fatalf("ListRepositories failed: %v", err)
+	}
+
+	want := []string{"alpha", "team/app", "team/app/api", "zeta"}
+	if len(repos) != len(want) {
+		t.Fatalf("repositories = %v, want %v", repos, want)
+	}
+	for i := range want {
+		if repos[i] != want[i] {
+			t.Errorf("repositories[%d] = %q, want %q", i, repos[i], want[i])
+		}
+	}
+}
+
+func TestOCIStorage_PutManifestValidation(t *testing.T) {
+	ctx := context.Background()
+	s := setupTestOCIStorage(t)
+
+	config := []byte(`{"architecture":"amd64"}`)
+	configDigest := putTestBlob(t, s, "myrepo", config)
+	missing := computeSHA256([]byte("missing layer"))
+
+	t.Run("malformed JSON", func(t *testing.T) {
+		_, err := s.PutManifest(ctx, "myrepo", "latest", MediaTypeImageManifest, []byte(`{"schemaVersion":`))
+		if !errors.Is(err, ErrManifestInvalid) {
+			t.Errorf("expected ErrManifestInvalid, got %v", err)
+		}
+	})
+
+	t.Run("unsupported content type", func(t *testing.T) {
+		data := []byte(fmt.Sprintf(`{"schemaVersion":2,"config":{"mediaType":"x","digest":"%s","size":%d}}`, configDigest, len(config)))
+		_, err := s.PutManifest(ctx, "myrepo", "latest", "text/plain", data)
+		if !errors.Is(err, ErrManifestInvalid) {
+			t.Errorf("expected ErrManifestInvalid, got %v", err)
+		}
+	})
+
+	t.Run("missing layer blob", func(t *testing.T) {
+		data := []byte(fmt.Sprintf(`{"schemaVersion":2,"config":{"mediaType":"x","digest":"%s","size":%d},"layers":[{"mediaType":"y","digest":"%s","size":13}]}`, configDigest, len(config), missing))
+		_, err := s.PutManifest(ctx, "myrepo", "latest", MediaTypeImageManifest, data)
+		if !errors.Is(err, ErrManifestBlobUnknown) {
+			t.Errorf("expected ErrManifestBlobUnknown, got %v", err)
+		}
+		if _, _, _, err := s.GetManifest(ctx, "myrepo", "latest"); err != ErrManifestNotFound {
+			t.Errorf("rejected manifest should not be stored, got %v", err)
+		}
+	})
+
+	t.Run("foreign layer with urls is not required locally", func(t *testing.T) {
+		data := []byte(fmt.Sprintf(`{"schemaVersion":2,"mediaType":"application/vnd.docker.distribution.manifest.v2+json","config":{"mediaType":"x","digest":"%s","size":%d},"layers":[{"mediaType":"application/vnd.docker.image.rootfs.foreign.diff.tar.gzip","digest":"%s","size":13,"urls":["https://example.com/layer"]}]}`, configDigest, len(config), missing))
+		_, err := s.PutManifest(ctx, "myrepo", "foreign", "", data)
+		if err != nil {
+			t.Errorf("unexpected error: %v", err)
+		}
+		_, _, ct, _ := s.GetManifest(ctx, "myrepo", "foreign")
+		if ct != MediaTypeDockerManifest {
+			t.Errorf("content type = %q, want %q", ct, MediaTypeDockerManifest)
+		}
+	})
+
+	t.Run("digest reference mismatch", func(t *testing.T) {
+		data := newTestManifest(t, s, "myrepo", "mismatch")
+		wrong := computeSHA256([]byte("something else"))
+		_, err := s.PutManifest(ctx, "myrepo", wrong.String(), MediaTypeImageManifest, data)
+		if !errors.Is(err, ErrDigestMismatch) {
+			t.Errorf("expected ErrDigestMismatch, got %v", err)
+		}
+	})
+
+	t.Run("digest reference match", func(t *testing.T) {
+		data := newTestManifest(t, s, "myrepo", "match")
+		digest, err := s.PutManifest(ctx, "myrepo", computeSHA256(data).String(), MediaTypeImageManifest, data)
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		if digest != computeSHA256(data) {
+			t.Errorf("digest = %s, want %s", digest, computeSHA256(data))
+		}
+	})
+
+	t.Run("index requires child manifests", func(t *testing.T) {
+		child := newTestManifest(t, s, "myrepo", "child")
+		childDigest := computeSHA256(child)
+		index := []byte(fmt.Sprintf(`{"schemaVersion":2,"mediaType":"application/vnd.oci.image.index.v1+json","manifests":[{"mediaType":"application/vnd.oci.image.manifest.v1+json","digest":"%s","size":%d}]}`, childDigest, len(child)))
+
+		_, err := s.PutManifest(ctx, "myrepo", "multiarch", MediaTypeImageIndex, index)
+		if !errors.Is(err, ErrManifestBlobUnknown) {
+			t.Errorf("expected ErrManifestBlobUnknown before child push, got %v", err)
+		}
+
+		if _, err := s.PutManifest(ctx, "myrepo", childDigest.String(), MediaTypeImageManifest, child); err != nil {
+			t.Fatalf("failed to push child: %v", err)
+		}
+		if _, err := s.PutManifest(ctx, "myrepo", "multiarch", MediaTypeImageIndex, index); err != nil {
+			t.Errorf("unexpected error after child push: %v", err)
+		}
+
+		// Child manifests must live in the same repository
+		if _, err := s.PutManifest(ctx, "otherrepo", "multiarch", MediaTypeImageIndex, index); !errors.Is(err, ErrManifestBlobUnknown) {
+			t.Errorf("expected ErrManifestBlobUnknown in other repo, got %v", err)
+		}
+	})
+}
+
+func TestOCIStorage_BlobRepositoryIsolation(t *testing.T) {
+	ctx := context.Background()
+	s := setupTestOCIStorage(t)
+
+	d := putTestBlob(t, s, "repo-a", []byte("private layer"))
+
+	exists, err := s.BlobExists(ctx, "repo-b", d)
+	if err != nil {
+		t.Fatalf("BlobExists failed: %v", err)
+	}
+	if exists {
+		t.Error("blob should not be visible through another repository")
+	}
+	if _, err := s.GetBlob(ctx, "repo-b", d); err != ErrBlobNotFound {
+		t.Errorf("GetBlob: expected ErrBlobNotFound, got %v", err)
+	}
+	if _, err := s.GetBlobInfo(ctx, "repo-b", d); err != ErrBlobNotFound {
+		t.Errorf("GetBlobInfo: expected ErrBlobNotFound, got %v", err)
+	}
+	if err := s.DeleteBlob(ctx, "repo-b", d); err != ErrBlobNotFound {
+		t.Errorf("DeleteBlob: expected ErrBlobNotFound, got %v", err)
+	}
+
+	// A manifest cannot reference blobs held only by another repository
+	manifest := []byte(fmt.Sprintf(`{"schemaVersion":2,"config":{"mediaType":"application/vnd.oci.image.config.v1+json","digest":%q,"size":13},"layers":[]}`, d))
+	_, err = s.PutManifest(ctx, "repo-b", "latest", MediaTypeImageManifest, manifest)
+	if !errors.Is(err, ErrManifestBlobUnknown) {
+		t.Errorf("PutManifest: expected ErrManifestBlobUnknown, got %v", err)
+	}
+
+	// Deleting from one repository leaves the other's copy intact
+	s.MountBlob(ctx, "repo-c", "repo-a", d)
+	if err := s.DeleteBlob(ctx, "repo-c", d); err != nil {
+		t.Fatalf("DeleteBlob failed: %v", err)
+	}
+	if exists, _ := s.BlobExists(ctx, "repo-a", d); !exists {
+		t.Error("blob should remain available in repo-a")
+	}
+}
+
+func TestOCIStorage_MountBlob(t *testing.T) {
+	ctx := context.Background()
+	s := setupTestOCIStorage(t)
+
+	d := putTestBlob(t, s, "base", []byte("base layer"))
+
+	if _, err := s.MountBlob(ctx, "derived", "unrelated", d); err != ErrBlobNotFound {
+		t.Errorf("mount from repository without the blob: expected ErrBlobNotFound, got %v", err)
+	}
+
+	source, err := s.MountBlob(ctx, "derived", "base", d)
+	if err != nil {
+		t.Fatalf("MountBlob failed: %v", err)
+	}
+	if source != "base" {
+		t.Errorf("source = %q, want %q", source, "base")
+	}
+	if exists, _ := s.BlobExists(ctx, "derived", d); !exists {
+		t.Error("mounted blob should be visible in derived")
+	}
+
+	source, err = s.MountBlob(ctx, "other", "", d)
+	if err != nil {
+		t.Fatalf("MountBlob with discovery failed: %v", err)
+	}
+	if source != "base" && source != "derived" {
+		t.Errorf("source = %q, want a repository holding the blob", source)
+	}
+
+	missing := computeSHA256([]byte("missing"))
+	if _, err := s.MountBlob(ctx, "other", "", missing); err != ErrBlobNotFound {
+		t.Errorf("expected ErrBlobNotFound, got %v", err)
+	}
+}
+
+func TestOCIStorage_SHA512Upload(t *testing.T) {
+	ctx := context.Background()
+	s := setupTestOCIStorage(t)
+
+	data := []byte("sha512 blob content")
+	sum := sha512.Sum512(data)
+	expected := DigestInfo{Algorithm: "sha512", Hex: fmt.Sprintf("%x", sum[:])}
+
+	t.Run("completes with sha512", func(t *testing.T) {
+		uuid, err := s.InitiateUpload(ctx, "myrepo", "sha512")
+		if err != nil {
+			t.Fatalf("InitiateUpload failed: %v", err)
+		}
+		s.WriteUploadChunk(ctx, uuid, bytes.NewReader(data[:5]))
+		s.WriteUploadChunk(ctx, uuid, bytes.NewReader(data[5:]))
+		digest, err := s.CompleteUpload(ctx, uuid, expected)
+		if err != nil {
+			t.Fatalf("CompleteUpload failed: %v", err)
+		}
+		if digest.String() != expected.String() {
+			t.Errorf("digest = %s, want %s", digest, expected)
+		}
+		if exists, _ := s.BlobExists(ctx, "myrepo", expected); !exists {
+			t.Error("sha512 blob should exist after upload")
+		}
+	})
+
+	t.Run("algorithm differs from session", func(t *testing.T) {
+		uuid, _ := s.InitiateUpload(ctx, "otherrepo", "")
+		s.WriteUploadChunk(ctx, uuid, bytes.NewReader(data))
+		if _, err := s.CompleteUpload(ctx, uuid, expected); err != nil {
+			t.Fatalf("CompleteUpload failed: %v", err)
+		}
+		if exists, _ := s.BlobExists(ctx, "otherrepo", expected); !exists {
+			t.Error("sha512 blob should exist after upload")
+		}
+	})
+
+	t.Run("unsupported algorithm", func(t *testing.T) {
+		if _, err := s.InitiateUpload(ctx, "myrepo", "md5"); !errors.Is(err, ErrInvalidDigest) {
+			t.Errorf("expected ErrInvalidDigest, got %v", err)
+		}
+	})
+}
+
+func TestOCIStorage_PutManifestSHA512(t *testing.T) {
+	ctx := context.Background()
+	s := setupTestOCIStorage(t)
+
+	manifest := newTestManifest(t, s, "myrepo", "sha512")
+	sum := sha512.Sum512(manifest)
+	reference := fmt.Sprintf("sha512:%x", sum[:])
+
+	digest, err := s.PutManifest(ctx, "myrepo", reference, MediaTypeImageManifest, manifest)
+	if err != nil {
+		t.Fatalf("PutManifest failed: %v", err)
+	}
+	if digest.String() != reference {
+		t.Errorf("digest = %s, want %s", digest, reference)
+	}
+
+	data, _, _, err := s.GetManifest(ctx, "myrepo", reference)
+	if err != nil {
+		t.Fatalf("GetManifest failed: %v", err)
+	}
+	if !bytes.Equal(data, manifest) {
+		t.Error("manifest content mismatch")
+	}
+
+	// Tagged pushes keep the default algorithm
+	tagged, err := s.PutManifest(ctx, "myrepo", "latest", MediaTypeImageManifest, manifest)
+	if err != nil {
+		t.Fatalf("PutManifest failed: %v", err)
+	}
+	if tagged.Algorithm != DefaultDigestAlgorithm {
+		t.Errorf("algorithm = %s, want %s", tagged.Algorithm, DefaultDigestAlgorithm)
+	}
+}
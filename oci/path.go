@@ -2,18 +2,48 @@ package oci
 
 import "path"
 
+// BlobsDir returns the storage path under which all blob data lives.
+// Layout: v2/blobs
+func BlobsDir() string {
+	return "v2/blobs"
+}
+
 // BlobDataPath returns the storage path for a blob's data file.
 // Layout: v2/blobs/<algorithm>/<first-2-hex>/<full-hex>/data
 func BlobDataPath(d DigestInfo) string {
 	return path.Join("v2/blobs", d.Algorithm, d.ShortHex(), d.Hex, "data")
 }
 
+// RepositoriesDir returns the storage path under which all repositories live.
+// Layout: v2/repositories
+func RepositoriesDir() string {
+	return "v2/repositories"
+}
+
+// LayerLinkPath returns the storage path for a repository's link to a blob.
+// Layout: v2/repositories/<name>/_layers/<algorithm>/<hex>/link
+func LayerLinkPath(name string, d DigestInfo) string {
+	return path.Join("v2/repositories", name, "_layers", d.Algorithm, d.Hex, "link")
+}
+
+// LayersDir returns the storage path for the blob links of a repository.
+// Layout: v2/repositories/<name>/_layers
+func LayersDir(name string) string {
+	return path.Join("v2/repositories", name, "_layers")
+}
+
 // ManifestRevisionLinkPath returns the storage path for a manifest revision link.
 // Layout: v2/repositories/<name>/_manifests/revisions/<algorithm>/<hex>/link
 func ManifestRevisionLinkPath(name string, d DigestInfo) string {
 	return path.Join("v2/repositories", name, "_manifests/revisions", d.Algorithm, d.Hex, "link")
 }
 
+// ManifestRevisionsDir returns the storage path for the revisions directory of a repository.
+// Layout: v2/repositories/<name>/_manifests/revisions
+func ManifestRevisionsDir(name string) string {
+	return path.Join("v2/repositories", name, "_manifests/revisions")
+}
+
 // ManifestTagCurrentLinkPath returns the storage path for a tag's current link.
 // Layout: v2/repositories/<name>/_manifests/tags/<tag>/current/link
 func ManifestTagCurrentLinkPath(name, tag string) string {
@@ -26,8 +56,32 @@ func ManifestTagsDir(name string) string {
 	return path.Join("v2/repositories", name, "_manifests/tags")
 }
 
+// ReferrersDir returns the storage path for the referrer index of a subject manifest.
+// Layout: v2/repositories/<name>/_manifests/referrers/<algorithm>/<hex>
+func ReferrersDir(name string, subject DigestInfo) string {
+	return path.Join("v2/repositories", name, "_manifests/referrers", subject.Algorithm, subject.Hex)
+}
+
+// ReferrerLinkPath returns the storage path for a single referrer entry of a subject manifest.
+// Layout: v2/repositories/<name>/_manifests/referrers/<algorithm>/<hex>/<referrer-algorithm>/<referrer-hex>/link
+func ReferrerLinkPath(name string, subject, referrer DigestInfo) string {
+	return path.Join(ReferrersDir(name, subject), referrer.Algorithm, referrer.Hex, "link")
+}
+
+// UploadsDir returns the storage path under which in-progress uploads live.
+// Layout: v2/uploads
+func UploadsDir() string {
+	return "v2/uploads"
+}
+
 // UploadDataPath returns the storage path for an in-progress upload's data.
 // Layout: v2/uploads/<uuid>/data
 func UploadDataPath(uuid string) string {
 	return path.Join("v2/uploads", uuid, "data")
 }
+
+// UploadSessionPath returns the storage path for an in-progress upload's session state.
+// Layout: v2/uploads/<uuid>/session
+func UploadSessionPath(uuid string) string {
+	return path.Join("v2/uploads", uuid, "session")
+}
@@ -11,6 +11,14 @@ func TestBlobDataPath(t *testing.T) {
 	}
 }
 
+func TestRepositoriesDir(t *testing.T) {
+	got := RepositoriesDir()
+	want := "v2/repositories"
+	if got != want {
+		t.Errorf("RepositoriesDir() = %q, want %q", got, want)
+	}
+}
+
 func TestManifestRevisionLinkPath(t *testing.T) {
 	d := DigestInfo{Algorithm: "sha256", Hex: "abcdef1234567890"}
 	got := ManifestRevisionLinkPath("myrepo/myimage", d)
@@ -36,6 +44,25 @@ func TestManifestTagsDir(t *testing.T) {
 	}
 }
 
+func TestReferrersDir(t *testing.T) {
+	d := DigestInfo{Algorithm: "sha256", Hex: "abcdef1234567890"}
+	got := ReferrersDir("myrepo/myimage", d)
+	want := "v2/repositories/myrepo/myimage/_manifests/referrers/sha256/abcdef1234567890"
+	if got != want {
+		t.Errorf("ReferrersDir() = %q, want %q", got, want)
+	}
+}
+
+func TestReferrerLinkPath(t *testing.T) {
+	subject := DigestInfo{Algorithm: "sha256", Hex: "abcdef1234567890"}
+	referrer := DigestInfo{Algorithm: "sha256", Hex: "1234567890abcdef"}
+	got := ReferrerLinkPath("myrepo/myimage", subject, referrer)
+	want := "v2/repositories/myrepo/myimage/_manifests/referrers/sha256/abcdef1234567890/sha256/1234567890abcdef/link"
+	if got != want {
+		t.Errorf("ReferrerLinkPath() = %q, want %q", got, want)
+	}
+}
+
 func TestUploadDataPath(t *testing.T) {
 	got := UploadDataPath("550e8400-e29b-41d4-a716-446655440000")
 	want := "v2/uploads/550e8400-e29b-41d4-a716-446655440000/data"
@@ -43,3 +70,48 @@ func TestUploadDataPath(t *testing.T) {
 		t.Errorf("UploadDataPath() = %q, want %q", got, want)
 	}
 }
+
+func TestUploadSessionPath(t *testing.T) {
+	got := UploadSessionPath("550e8400-e29b-41d4-a716-446655440000")
+	want := "v2/uploads/550e8400-e29b-41d4-a716-446655440000/session"
+	if got != want {
+		t.Errorf("UploadSessionPath() = %q, want %q", got, want)
+	}
+}
+
+func TestBlobsDir(t *testing.T) {
+	if got := BlobsDir(); got != "v2/blobs" {
+		t.Errorf("BlobsDir() = %q, want %q", got, "v2/blobs")
+	}
+}
+
+func TestManifestRevisionsDir(t *testing.T) {
+	got := ManifestRevisionsDir("myrepo/myimage")
+	want := "v2/repositories/myrepo/myimage/_manifests/revisions"
+	if got != want {
+		t.Errorf("ManifestRevisionsDir() = %q, want %q", got, want)
+	}
+}
+
+func TestUploadsDir(t *testing.T) {
+	if got := UploadsDir(); got != "v2/uploads" {
+		t.Errorf("UploadsDir() = %q, want %q", got, "v2/uploads")
+	}
+}
+
+func TestLayerLinkPath(t *testing.T) {
+	d := DigestInfo{Algorithm: "sha256", Hex: "abcdef1234567890"}
+	got := LayerLinkPath("myrepo/myimage", d)
+	want := "v2/repositories/myrepo/myimage/_layers/sha256/abcdef1234567890/link"
+	if got != want {
+		t.Errorf("LayerLinkPath() = %q, want %q", got, want)
+	}
+}
+
+func TestLayersDir(t *testing.T) {
+	got := LayersDir("myrepo/myimage")
+	want := "v2/repositories/myrepo/myimage/_layers"
+	if got != want {
+		t.Errorf("LayersDir() = %q, want %q", got, want)
+	}
+}
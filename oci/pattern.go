@@ -0,0 +1,35 @@
+package oci
+
+import (
+	"path"
+	"strings"
+)
+
+// MatchRepository reports whether repository name matches pattern. A pattern
+// ending in "/**" matches every repository nested below a namespace matching
+// the rest of the pattern, so "apps/**" covers both apps/web and
+// apps/web/api; other patterns are path.Match globs, where "*" matches a
+// single name segment.
+func MatchRepository(pattern, name string) bool {
+	namespace, ok := strings.CutSuffix(pattern, "/**")
+	if !ok {
+		matched, _ := path.Match(pattern, name)
+		return matched
+	}
+	for i := range name {
+		if name[i] != '/' {
+			continue
+		}
+		if matched, _ := path.Match(namespace, name[:i]); matched {
+			return true
+		}
+	}
+	return false
+}
+
+// ValidateRepositoryPattern returns an error if pattern is not a valid
+// MatchRepository pattern.
+func ValidateRepositoryPattern(pattern string) error {
+	_, err := path.Match(strings.TrimSuffix(pattern, "/**"), "")
+	return err
+}
@@ -0,0 +1,37 @@
+package oci
+
+import "testing"
+
+func TestMatchRepository(t *testing.T) {
+	tests := []struct {
+		pattern string
+		name    string
+		want    bool
+	}{
+		{pattern: "apps/*", name: "apps/web", want: true},
+		{pattern: "apps/*", name: "apps/web/api", want: false},
+		{pattern: "apps/**", name: "apps/web", want: true},
+		{pattern: "apps/**", name: "apps/web/api", want: true},
+		{pattern: "apps/**", name: "apps", want: false},
+		{pattern: "apps/**", name: "other/apps/web", want: false},
+		{pattern: "*/**", name: "team/app/api", want: true},
+		{pattern: "team/app", name: "team/app", want: true},
+	}
+
+	for _, tt := range tests {
+		if got := MatchRepository(tt.pattern, tt.name); got != tt.want {
+			t.Errorf("MatchRepository(%q, %q) = %v, want %v", tt.pattern, tt.name, got, tt.want)
+		}
+	}
+}
+
+func TestValidateRepositoryPattern(t *testing.T) {
+	for _, pattern := range []string{"apps/*", "apps/**", "team/app"} {
+		if err := ValidateRepositoryPattern(pattern); err != nil {
+			t.Errorf("ValidateRepositoryPattern(%q) = %v, want nil", pattern, err)
+		}
+	}
+	if err := ValidateRepositoryPattern("apps/[**"); err == nil {
+		t.Error("expected an error for a malformed pattern")
+	}
+}
@@ -0,0 +1,308 @@
+package oci
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"strings"
+	"time"
+
+	"github.com/hairizuanbinnoorazman/package-universe/storage"
+)
+
+// dockerHubHosts are the registry hosts that serve official images under library/.
+var dockerHubHosts = map[string]bool{
+	"registry-1.docker.io": true,
+	"index.docker.io":      true,
+	"docker.io":            true,
+}
+
+// WithUpstream turns the registry into a pull-through cache of the registry
+// behind client. Manifests and blobs missing locally are fetched from the
+// upstream and stored; manifests pulled by tag are revalidated against the
+// upstream once their tag link is older than tagTTL.
+func WithUpstream(client *Client, tagTTL time.Duration) Option {
+	return func(s *OCIStorage) {
+		s.upstream = client
+		s.upstreamTagTTL = tagTTL
+	}
+}
+
+// upstreamName maps a repository name to its name on the upstream registry.
+// Single-segment names on Docker Hub live under library/, as the docker CLI assumes.
+func (s *OCIStorage) upstreamName(name string) string {
+	if !strings.Contains(name, "/") && dockerHubHosts[s.upstream.Host()] {
+		return "library/" + name
+	}
+	return name
+}
+
+// refreshManifest makes sure a manifest reference is cached locally. Digest
+// references are fetched once; tag references are revalidated when their link
+// is older than the tag TTL. A stale tag is still served if the upstream
+// cannot be reached or no longer has it. Cached immutable tags are never revalidated, so the
+// upstream cannot move them.
+func (s *OCIStorage) refreshManifest(ctx context.Context, name, reference string) error {
+	if isDigestReference(reference) {
+		digest, err := ParseDigest(reference)
+		if err != nil {
+			return err
+		}
+		exists, err := s.store.Exists(ctx, ManifestRevisionLinkPath(name, digest))
+		if err != nil {
+			return fmt.Errorf("failed to check manifest revision: %w", err)
+		}
+		if exists {
+			return nil
+		}
+		return s.fetchManifest(ctx, name, reference, &digest)
+	}
+
+	info, err := s.store.Stat(ctx, ManifestTagCurrentLinkPath(name, reference))
+	if err != nil && err != storage.ErrFileNotFound {
+		return fmt.Errorf("failed to stat tag link: %w", err)
+	}
+	cached := err == nil
+	if cached && (time.Since(info.ModTime) < s.upstreamTagTTL || s.isTagImmutable(name, reference)) {
+		return nil
+	}
+
+	if err := s.revalidateTag(ctx, name, reference); err != nil {
+		if cached {
+			return nil
+		}
+		return err
+	}
+	return nil
+}
+
+// revalidateTag asks the upstream which manifest a tag points at. If the
+// local tag already points there its link is rewritten to restart the TTL;
+// otherwise the manifest is fetched and the tag moved.
+func (s *OCIStorage) revalidateTag(ctx context.Context, name, tag string) error {
+	remote, err := s.upstream.HeadManifest(ctx, s.upstreamName(name), tag)
+	if err != nil {
+		return err
+	}
+
+	current, contentType, err := s.readTagLink(ctx, name, tag)
+	if err != nil && err != ErrManifestNotFound {
+		return err
+	}
+	if err == nil && current == remote {
+		link := current.String() + "\n" + contentType
+		if err := s.store.Upload(ctx, ManifestTagCurrentLinkPath(name, tag), strings.NewReader(link)); err != nil {
+			return fmt.Errorf("failed to store tag link: %w", err)
+		}
+		return nil
+	}
+
+	return s.fetchManifest(ctx, name, tag, &remote)
+}
+
+// fetchManifest downloads a manifest from the upstream, verifies it against
+// expected and stores it, tagging it when reference is a tag. The blobs it
+// references are fetched lazily when they are pulled. Manifests are stored
+// even over quota, since the pull cannot be answered without them.
+func (s *OCIStorage) fetchManifest(ctx context.Context, name, reference string, expected *DigestInfo) error {
+	data, contentType, err := s.upstream.GetManifest(ctx, s.upstreamName(name), reference)
+	if err != nil {
+		return err
+	}
+
+	algorithm := DefaultDigestAlgorithm
+	if expected != nil {
+		algorithm = expected.Algorithm
+	}
+	vr, err := NewVerifyingReaderWithAlgorithm(bytes.NewReader(data), algorithm)
+	if err != nil {
+		return err
+	}
+	if _, err := io.Copy(io.Discard, vr); err != nil {
+		return fmt.Errorf("failed to compute manifest digest: %w", err)
+	}
+	digest := vr.Digest()
+	if expected != nil {
+		if err := vr.Verify(*expected); err != nil {
+			return fmt.Errorf("upstream manifest %s: %w", reference, err)
+		}
+	}
+
+	m, err := ParseManifest(data)
+	if err != nil {
+		return err
+	}
+	contentType, err = m.ResolveMediaType(contentType)
+	if err != nil {
+		return err
+	}
+
+	tag := ""
+	if !isDigestReference(reference) {
+		tag = reference
+	}
+	return s.storeManifest(ctx, name, tag, digest, contentType, m, data)
+}
+
+// proxyBlobInfo describes a blob that is not linked into the repository by
+// asking the upstream for it.
+func (s *OCIStorage) proxyBlobInfo(ctx context.Context, name string, digest DigestInfo) (*BlobInfo, error) {
+	size, err := s.upstream.HeadBlob(ctx, s.upstreamName(name), digest)
+	if err != nil {
+		return nil, err
+	}
+	return &BlobInfo{Digest: digest, Size: size, ModTime: time.Now()}, nil
+}
+
+// proxyBlob serves a blob that is not linked into the repository from the
+// upstream. If another repository already stored the data it is linked once
+// the upstream confirms this repository holds it; otherwise the upstream
+// content is streamed to the caller while being written to storage. Blobs
+// that would take the repository over quota are served without being linked
+// or cached.
+func (s *OCIStorage) proxyBlob(ctx context.Context, name string, digest DigestInfo) (io.ReadCloser, error) {
+	stored, err := s.store.Exists(ctx, BlobDataPath(digest))
+	if err != nil {
+		return nil, fmt.Errorf("failed to check blob: %w", err)
+	}
+	if stored {
+		size, err := s.upstream.HeadBlob(ctx, s.upstreamName(name), digest)
+		if err != nil {
+			return nil, err
+		}
+		if err := s.checkLinkQuota(ctx, name, digest, size); errors.Is(err, ErrQuotaExceeded) {
+			return s.store.Download(ctx, BlobDataPath(digest))
+		}
+		if err := s.linkBlob(ctx, name, digest); err != nil {
+			return nil, err
+		}
+		return s.GetBlob(ctx, name, digest)
+	}
+
+	body, size, err := s.upstream.GetBlob(ctx, s.upstreamName(name), digest)
+	if err != nil {
+		return nil, err
+	}
+	if size >= 0 {
+		if err := s.checkLinkQuota(ctx, name, digest, size); errors.Is(err, ErrQuotaExceeded) {
+			return body, nil
+		}
+	}
+	return s.newCachingReader(ctx, name, digest, body)
+}
+
+// cachingReader passes an upstream blob through to its reader while copying
+// it into a temporary upload. Once the whole blob has been read and its
+// digest verified, the upload is moved into place and linked into the
+// repository. Closing the reader early discards the partial copy.
+type cachingReader struct {
+	ctx      context.Context
+	s        *OCIStorage
+	name     string
+	digest   DigestInfo
+	tempPath string
+
+	body     io.ReadCloser
+	verifier *VerifyingReader
+	pipe     *io.PipeWriter
+	uploaded chan error
+	done     bool
+}
+
+// newCachingReader starts copying body into temporary storage as it is read.
+func (s *OCIStorage) newCachingReader(ctx context.Context, name string, digest DigestInfo, body io.ReadCloser) (*cachingReader, error) {
+	verifier, err := NewVerifyingReaderWithAlgorithm(body, digest.Algorithm)
+	if err != nil {
+		body.Close()
+		return nil, err
+	}
+	uuid, err := generateUUID()
+	if err != nil {
+		body.Close()
+		return nil, fmt.Errorf("failed to generate UUID: %w", err)
+	}
+
+	pr, pw := io.Pipe()
+	cr := &cachingReader{
+		ctx:      ctx,
+		s:        s,
+		name:     name,
+		digest:   digest,
+		tempPath: UploadDataPath(uuid),
+		body:     body,
+		verifier: verifier,
+		pipe:     pw,
+		uploaded: make(chan error, 1),
+	}
+
+	// Orphaned temporary data is removed by the upload reaper if the commit never happens
+	go func() {
+		err := s.store.Upload(ctx, cr.tempPath, pr)
+		pr.CloseWithError(err)
+		cr.uploaded <- err
+	}()
+	return cr, nil
+}
+
+// Read implements io.Reader. A failure to cache does not interrupt the caller.
+func (cr *cachingReader) Read(p []byte) (int, error) {
+	n, err := cr.verifier.Read(p)
+	if n > 0 && cr.pipe != nil {
+		if _, werr := cr.pipe.Write(p[:n]); werr != nil {
+			cr.pipe = nil
+		}
+	}
+	if err == io.EOF && !cr.done {
+		cr.done = true
+		if cerr := cr.commit(); cerr != nil {
+			return n, cerr
+		}
+	}
+	return n, err
+}
+
+// Close implements io.Closer, discarding the copy if the blob was not read to the end.
+func (cr *cachingReader) Close() error {
+	if !cr.done {
+		cr.done = true
+		if cr.pipe != nil {
+			cr.pipe.CloseWithError(io.ErrUnexpectedEOF)
+		}
+		<-cr.uploaded
+		cr.s.store.Delete(cr.ctx, cr.tempPath)
+	}
+	return cr.body.Close()
+}
+
+// commit finishes the temporary upload and moves it into place if the
+// content matches the digest.
+func (cr *cachingReader) commit() error {
+	caching := cr.pipe != nil
+	if caching {
+		cr.pipe.Close()
+	}
+	uploadErr := <-cr.uploaded
+
+	if err := cr.verifier.Verify(cr.digest); err != nil {
+		cr.s.store.Delete(cr.ctx, cr.tempPath)
+		return fmt.Errorf("upstream blob %s: %w", cr.digest, err)
+	}
+	if !caching || uploadErr != nil {
+		cr.s.store.Delete(cr.ctx, cr.tempPath)
+		return nil
+	}
+
+	// Check again now that the size is certain; usage may have grown while streaming
+	if err := cr.s.checkLinkQuota(cr.ctx, cr.name, cr.digest, cr.verifier.Size()); err != nil {
+		cr.s.store.Delete(cr.ctx, cr.tempPath)
+		return nil
+	}
+	if err := cr.s.store.Move(cr.ctx, cr.tempPath, BlobDataPath(cr.digest)); err != nil {
+		cr.s.store.Delete(cr.ctx, cr.tempPath)
+		return nil
+	}
+	cr.s.linkBlob(cr.ctx, cr.name, cr.digest)
+	return nil
+}
@@ -0,0 +1,416 @@
+package oci
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/hairizuanbinnoorazman/package-universe/storage"
+)
+
+// fakeUpstream is a minimal token-authenticated registry serving fixed content.
+type fakeUpstream struct {
+	server *httptest.Server
+
+	mu        sync.Mutex
+	manifests map[string][]byte // "<name>:<reference>" -> manifest
+	blobs     map[string][]byte // digest -> content
+	requests  map[string]int    // "<method> <path>" -> count
+	denied    map[string]bool   // repositories answered with 401 even with a token
+}
+
+func newFakeUpstream(t *testing.T) *fakeUpstream {
+	t.Helper()
+	u := &fakeUpstream{
+		manifests: make(map[string][]byte),
+		blobs:     make(map[string][]byte),
+		requests:  make(map[string]int),
+		denied:    make(map[string]bool),
+	}
+
+	mux := http.NewServeMux()
+	mux.HandleFunc("/token", func(w http.ResponseWriter, r *http.Request) {
+		user, pass, ok := r.BasicAuth()
+		if !ok || user != "mirror" || pass != "secret" {
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+		if r.URL.Query().Get("service") != "fake" {
+			w.WriteHeader(http.StatusBadRequest)
+			return
+		}
+		fmt.Fprintf(w, `{"token":"token-for-%s","expires_in":300}`, r.URL.Query().Get("scope"))
+	})
+	mux.HandleFunc("/v2/", func(w http.ResponseWriter, r *http.Request) {
+		u.mu.Lock()
+		u.requests[r.Method+" "+r.URL.Path]++
+		u.mu.Unlock()
+
+		rest := strings.TrimPrefix(r.URL.Path, "/v2/")
+		kind := "/manifests/"
+		if strings.Contains(rest, "/blobs/") {
+			kind = "/blobs/"
+		}
+		name, reference, _ := strings.Cut(rest, kind)
+
+		u.mu.Lock()
+		denied := u.denied[name]
+		u.mu.Unlock()
+		if denied || r.Header.Get("Authorization") != "Bearer token-for-"+pullScope(name) {
+			w.Header().Set("WWW-Authenticate", fmt.Sprintf(`Bearer realm="%s/token",service="fake",scope="%s"`, u.server.URL, pullScope(name)))
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+
+		u.mu.Lock()
+		var data []byte
+		if kind == "/blobs/" {
+			data = u.blobs[reference]
+		} else {
+			data = u.manifests[name+":"+reference]
+		}
+		u.mu.Unlock()
+		if data == nil {
+			w.WriteHeader(http.StatusNotFound)
+			return
+		}
+
+		if kind == "/manifests/" {
+			w.Header().Set("Content-Type", MediaTypeImageManifest)
+			w.Header().Set("Docker-Content-Digest", computeSHA256(data).String())
+		}
+		w.Header().Set("Content-Length", fmt.Sprint(len(data)))
+		if r.Method == http.MethodGet {
+			w.Write(data)
+		}
+	})
+
+	u.server = httptest.NewServer(mux)
+	t.Cleanup(u.server.Close)
+	return u
+}
+
+// addImage serves a manifest under name:tag (and by digest) referencing a
+// config blob derived from seed, returning the manifest and config digest.
+func (u *fakeUpstream) addImage(name, tag, seed string) ([]byte, DigestInfo) {
+	config := []byte(fmt.Sprintf(`{"upstream":%q}`, seed))
+	configDigest := computeSHA256(config)
+	manifest := []byte(fmt.Sprintf(`{"schemaVersion":2,"mediaType":"application/vnd.oci.image.manifest.v1+json","config":{"mediaType":"application/vnd.oci.image.config.v1+json","digest":"%s","size":%d},"layers":[]}`, configDigest, len(config)))
+
+	u.mu.Lock()
+	defer u.mu.Unlock()
+	u.blobs[configDigest.String()] = config
+	u.manifests[name+":"+tag] = manifest
+	u.manifests[name+":"+computeSHA256(manifest).String()] = manifest
+	return manifest, configDigest
+}
+
+func (u *fakeUpstream) count(method, path string) int {
+	u.mu.Lock()
+	defer u.mu.Unlock()
+	return u.requests[method+" "+path]
+}
+
+func setupTestProxyStorage(t *testing.T, upstream *fakeUpstream, tagTTL time.Duration) *OCIStorage {
+	t.Helper()
+	store, err := storage.NewLocalStorage(t.TempDir())
+	if err != nil {
+		t.Fatalf("failed to create local storage: %v", err)
+	}
+	client := NewClient(upstream.server.URL, "mirror", "secret", 0)
+	return NewOCIStorage(store, NewSessionManager(store, 30*time.Minute), WithUpstream(client, tagTTL))
+}
+
+func TestOCIStorage_ProxyManifest(t *testing.T) {
+	ctx := context.Background()
+	upstream := newFakeUpstream(t)
+	manifest, _ := upstream.addImage("team/app", "latest", "v1")
+	s := setupTestProxyStorage(t, upstream, time.Hour)
+
+	data, digest, contentType, err := s.GetManifest(ctx, "team/app", "latest")
+	if err != nil {
+		t.Fatalf("GetManifest failed: %v", err)
+	}
+	if !bytes.Equal(data, manifest) {
+		t.Error("proxied manifest does not match upstream")
+	}
+	if digest != computeSHA256(manifest) || contentType != MediaTypeImageManifest {
+		t.Errorf("got %s %s", digest, contentType)
+	}
+
+	// A fresh tag is served from the cache without asking the upstream
+	if _, _, _, err := s.GetManifest(ctx, "team/app", "latest"); err != nil {
+		t.Fatalf("cached GetManifest failed: %v", err)
+	}
+	if n := upstream.count("GET", "/v2/team/app/manifests/latest"); n != 1 {
+		t.Errorf("upstream manifest fetched %d times, want 1", n)
+	}
+
+	// Digest references are cached by the tag pull
+	if _, _, _, err := s.ManifestExists(ctx, "team/app", digest.String()); err != nil {
+		t.Errorf("ManifestExists by digest failed: %v", err)
+	}
+
+	if _, _, _, err := s.GetManifest(ctx, "team/app", "missing"); err != ErrManifestNotFound {
+		t.Errorf("err = %v, want ErrManifestNotFound", err)
+	}
+}
+
+func TestOCIStorage_ProxyTagRevalidation(t *testing.T) {
+	ctx := context.Background()
+	upstream := newFakeUpstream(t)
+	upstream.addImage("app", "latest", "v1")
+	s := setupTestProxyStorage(t, upstream, 0)
+
+	if _, _, _, err := s.GetManifest(ctx, "app", "latest"); err != nil {
+		t.Fatalf("GetManifest failed: %v", err)
+	}
+
+	// Unchanged tags are revalidated with HEAD only
+	if _, _, _, err := s.GetManifest(ctx, "app", "latest"); err != nil {
+		t.Fatalf("GetManifest failed: %v", err)
+	}
+	if n := upstream.count("GET", "/v2/app/manifests/latest"); n != 1 {
+		t.Errorf("upstream manifest fetched %d times, want 1", n)
+	}
+
+	// A moved tag is followed
+	moved, _ := upstream.addImage("app", "latest", "v2")
+	data, _, _, err := s.GetManifest(ctx, "app", "latest")
+	if err != nil {
+		t.Fatalf("GetManifest failed: %v", err)
+	}
+	if !bytes.Equal(data, moved) {
+		t.Error("tag was not revalidated against the upstream")
+	}
+
+	// A stale tag is served after the upstream removes it
+	upstream.mu.Lock()
+	delete(upstream.manifests, "app:latest")
+	upstream.mu.Unlock()
+	data, _, _, err = s.GetManifest(ctx, "app", "latest")
+	if err != nil {
+		t.Fatalf("GetManifest after upstream delete failed: %v", err)
+	}
+	if !bytes.Equal(data, moved) {
+		t.Error("stale manifest should be served when the upstream no longer has the tag")
+	}
+
+	// A stale tag is served while the upstream is down
+	upstream.server.Close()
+	data, _, _, err = s.GetManifest(ctx, "app", "latest")
+	if err != nil {
+		t.Fatalf("GetManifest with upstream down failed: %v", err)
+	}
+	if !bytes.Equal(data, moved) {
+		t.Error("stale manifest should be served when the upstream is unreachable")
+	}
+}
+
+func TestOCIStorage_ProxyUpstreamDenied(t *testing.T) {
+	ctx := context.Background()
+	upstream := newFakeUpstream(t)
+	_, config := upstream.addImage("private/app", "latest", "v1")
+	upstream.denied["private/app"] = true
+	s := setupTestProxyStorage(t, upstream, time.Hour)
+
+	// Repositories the upstream will not show are reported as unknown
+	if _, _, _, err := s.GetManifest(ctx, "private/app", "latest"); err != ErrManifestNotFound {
+		t.Errorf("GetManifest err = %v, want ErrManifestNotFound", err)
+	}
+	if _, err := s.GetBlobInfo(ctx, "private/app", config); err != ErrBlobNotFound {
+		t.Errorf("GetBlobInfo err = %v, want ErrBlobNotFound", err)
+	}
+	if _, err := s.GetBlob(ctx, "private/app", config); err != ErrBlobNotFound {
+		t.Errorf("GetBlob err = %v, want ErrBlobNotFound", err)
+	}
+}
+
+func TestOCIStorage_ProxyImmutableTag(t *testing.T) {
+	ctx := context.Background()
+	upstream := newFakeUpstream(t)
+	original, _ := upstream.addImage("app", "v1.0.0", "v1")
+	store, err := storage.NewLocalStorage(t.TempDir())
+	if err != nil {
+		t.Fatalf("failed to create local storage: %v", err)
+	}
+	rule, _ := NewImmutableTagRule("", `^v\d+\.\d+\.\d+$`)
+	client := NewClient(upstream.server.URL, "mirror", "secret", 0)
+	s := NewOCIStorage(store, NewSessionManager(store, 30*time.Minute), WithUpstream(client, 0), WithImmutableTags(rule))
+
+	if _, _, _, err := s.GetManifest(ctx, "app", "v1.0.0"); err != nil {
+		t.Fatalf("GetManifest failed: %v", err)
+	}
+
+	// A cached immutable tag is not moved when the upstream repoints it
+	heads := upstream.count("HEAD", "/v2/app/manifests/v1.0.0")
+	upstream.addImage("app", "v1.0.0", "v2")
+	data, _, _, err := s.GetManifest(ctx, "app", "v1.0.0")
+	if err != nil {
+		t.Fatalf("GetManifest failed: %v", err)
+	}
+	if !bytes.Equal(data, original) {
+		t.Error("immutable tag was moved by the upstream")
+	}
+	if n := upstream.count("HEAD", "/v2/app/manifests/v1.0.0"); n != heads {
+		t.Errorf("immutable tag revalidated %d times, want 0", n-heads)
+	}
+}
+
+func TestOCIStorage_ProxyBlob(t *testing.T) {
+	ctx := context.Background()
+	upstream := newFakeUpstream(t)
+	_, configDigest := upstream.addImage("app", "latest", "blob")
+	s := setupTestProxyStorage(t, upstream, time.Hour)
+
+	info, err := s.GetBlobInfo(ctx, "app", configDigest)
+	if err != nil {
+		t.Fatalf("GetBlobInfo failed: %v", err)
+	}
+	want := upstream.blobs[configDigest.String()]
+	if info.Size != int64(len(want)) {
+		t.Errorf("size = %d, want %d", info.Size, len(want))
+	}
+
+	rc, err := s.GetBlob(ctx, "app", configDigest)
+	if err != nil {
+		t.Fatalf("GetBlob failed: %v", err)
+	}
+	data, err := io.ReadAll(rc)
+	rc.Close()
+	if err != nil {
+		t.Fatalf("reading proxied blob failed: %v", err)
+	}
+	if !bytes.Equal(data, want) {
+		t.Error("proxied blob does not match upstream")
+	}
+
+	// The streamed copy is now stored and linked
+	exists, err := s.BlobExists(ctx, "app", configDigest)
+	if err != nil || !exists {
+		t.Fatalf("blob should be cached after a full read, exists=%v err=%v", exists, err)
+	}
+	rc, err = s.GetBlob(ctx, "app", configDigest)
+	if err != nil {
+		t.Fatalf("cached GetBlob failed: %v", err)
+	}
+	rc.Close()
+	if n := upstream.count("GET", "/v2/app/blobs/"+configDigest.String()); n != 1 {
+		t.Errorf("upstream blob fetched %d times, want 1", n)
+	}
+
+	if _, err := s.GetBlob(ctx, "app", computeSHA256([]byte("missing"))); err != ErrBlobNotFound {
+		t.Errorf("err = %v, want ErrBlobNotFound", err)
+	}
+}
+
+func TestOCIStorage_ProxyBlobOverQuota(t *testing.T) {
+	ctx := context.Background()
+	upstream := newFakeUpstream(t)
+	_, configDigest := upstream.addImage("team/app", "latest", "blob")
+	store, err := storage.NewLocalStorage(t.TempDir())
+	if err != nil {
+		t.Fatalf("failed to create local storage: %v", err)
+	}
+	client := NewClient(upstream.server.URL, "mirror", "secret", 0)
+	s := NewOCIStorage(store, NewSessionManager(store, 30*time.Minute),
+		WithUpstream(client, time.Hour), WithQuotas(Quota{Namespace: "team", Limit: 1}))
+
+	rc, err := s.GetBlob(ctx, "team/app", configDigest)
+	if err != nil {
+		t.Fatalf("GetBlob failed: %v", err)
+	}
+	data, _ := io.ReadAll(rc)
+	rc.Close()
+	if !bytes.Equal(data, upstream.blobs[configDigest.String()]) {
+		t.Error("blob over quota should still be served from the upstream")
+	}
+	if exists, _ := s.BlobExists(ctx, "team/app", configDigest); exists {
+		t.Error("blob over quota should not be cached")
+	}
+	if used, _ := s.RepositoryUsage(ctx, "team/app"); used != 0 {
+		t.Errorf("usage = %d, want 0", used)
+	}
+}
+
+func TestOCIStorage_ProxyBlobNotCached(t *testing.T) {
+	ctx := context.Background()
+	upstream := newFakeUpstream(t)
+	_, configDigest := upstream.addImage("app", "latest", "partial")
+	s := setupTestProxyStorage(t, upstream, time.Hour)
+
+	t.Run("abandoned read", func(t *testing.T) {
+		rc, err := s.GetBlob(ctx, "app", configDigest)
+		if err != nil {
+			t.Fatalf("GetBlob failed: %v", err)
+		}
+		rc.Read(make([]byte, 4))
+		rc.Close()
+		if exists, _ := s.BlobExists(ctx, "app", configDigest); exists {
+			t.Error("a partially read blob should not be cached")
+		}
+	})
+
+	t.Run("digest mismatch", func(t *testing.T) {
+		bogus := computeSHA256([]byte("bogus"))
+		upstream.mu.Lock()
+		upstream.blobs[bogus.String()] = []byte("not the content")
+		upstream.mu.Unlock()
+
+		rc, err := s.GetBlob(ctx, "app", bogus)
+		if err != nil {
+			t.Fatalf("GetBlob failed: %v", err)
+		}
+		_, err = io.ReadAll(rc)
+		rc.Close()
+		if err == nil {
+			t.Error("expected a digest error at the end of the blob")
+		}
+		if exists, _ := s.BlobExists(ctx, "app", bogus); exists {
+			t.Error("a blob with the wrong digest should not be cached")
+		}
+	})
+}
+
+func TestOCIStorage_UpstreamName(t *testing.T) {
+	s := &OCIStorage{upstream: NewClient("https://registry-1.docker.io", "", "", 0)}
+	if got := s.upstreamName("nginx"); got != "library/nginx" {
+		t.Errorf("upstreamName(nginx) = %q", got)
+	}
+	if got := s.upstreamName("bitnami/nginx"); got != "bitnami/nginx" {
+		t.Errorf("upstreamName(bitnami/nginx) = %q", got)
+	}
+	s.upstream = NewClient("https://ghcr.io", "", "", 0)
+	if got := s.upstreamName("nginx"); got != "nginx" {
+		t.Errorf("upstreamName on ghcr = %q", got)
+	}
+}
+
+func TestParseChallenge(t *testing.T) {
+	scheme, params := parseChallenge(`Bearer realm="https://auth.example.com/token",service="registry.example.com",scope="repository:app:pull,push"`)
+	if scheme != "bearer" {
+		t.Errorf("scheme = %q", scheme)
+	}
+	want := map[string]string{
+		"realm":   "https://auth.example.com/token",
+		"service": "registry.example.com",
+		"scope":   "repository:app:pull,push",
+	}
+	for k, v := range want {
+		if params[k] != v {
+			t.Errorf("%s = %q, want %q", k, params[k], v)
+		}
+	}
+
+	scheme, params = parseChallenge(`Basic realm=registry`)
+	if scheme != "basic" || params["realm"] != "registry" {
+		t.Errorf("got %q %v", scheme, params)
+	}
+}
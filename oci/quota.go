@@ -0,0 +1,360 @@
+package oci
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"path"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/hairizuanbinnoorazman/package-universe/storage"
+)
+
+// Quota limits the bytes stored in a single repository, or in every
+// repository under a namespace prefix. A repository's usage is the size of
+// every blob and manifest it links; a blob shared by several repositories
+// counts once towards each of them.
+type Quota struct {
+	Repository string `json:"repository,omitempty"` // Exact repository name
+	Namespace  string `json:"namespace,omitempty"`  // Prefix such as "team" covering "team" and "team/..."
+	Limit      int64  `json:"limit"`                // Bytes
+}
+
+// QuotaUsage is a quota with the bytes currently counted against it.
+type QuotaUsage struct {
+	Quota
+	Used int64 `json:"used"`
+}
+
+// NewQuota creates a quota on either a repository or a namespace.
+func NewQuota(repository, namespace string, limit int64) (Quota, error) {
+	if (repository == "") == (namespace == "") {
+		return Quota{}, fmt.Errorf("quota needs exactly one of repository or namespace")
+	}
+	if limit <= 0 {
+		return Quota{}, fmt.Errorf("quota limit must be positive")
+	}
+	return Quota{Repository: repository, Namespace: strings.TrimSuffix(namespace, "/"), Limit: limit}, nil
+}
+
+// Matches reports whether the quota applies to repository name.
+func (q Quota) Matches(name string) bool {
+	if q.Repository != "" {
+		return q.Repository == name
+	}
+	return inNamespace(q.Namespace, name)
+}
+
+// String describes the quota's scope for error messages.
+func (q Quota) String() string {
+	if q.Repository != "" {
+		return fmt.Sprintf("repository %q", q.Repository)
+	}
+	return fmt.Sprintf("namespace %q", q.Namespace)
+}
+
+// WithQuotas enforces storage quotas on uploads, mounts and manifest pushes.
+// In pull-through mode, upstream blobs that do not fit are served without
+// being cached; upstream manifests are always cached and count towards usage.
+func WithQuotas(quotas ...Quota) Option {
+	return func(s *OCIStorage) {
+		s.quotas = append(s.quotas, quotas...)
+		if s.usage == nil {
+			s.usage = newUsageCache()
+		}
+	}
+}
+
+// usageRefreshInterval bounds how long cached usage is trusted, so links
+// removed by another process (such as a gc run) are eventually reflected.
+const usageRefreshInterval = 5 * time.Minute
+
+// usageCache holds the usage of repositories and namespaces computed so far.
+// Entries are loaded by scanning storage on first use, kept up to date as
+// blobs are linked and dropped when links are removed.
+type usageCache struct {
+	mu         sync.Mutex
+	repos      map[string]int64
+	namespaces map[string]int64
+	loaded     time.Time
+}
+
+func newUsageCache() *usageCache {
+	return &usageCache{repos: make(map[string]int64), namespaces: make(map[string]int64), loaded: time.Now()}
+}
+
+// clear drops every entry. c.mu must be held.
+func (c *usageCache) clear() {
+	c.repos = make(map[string]int64)
+	c.namespaces = make(map[string]int64)
+	c.loaded = time.Now()
+}
+
+// expire clears the cache once it is older than usageRefreshInterval. c.mu must be held.
+func (c *usageCache) expire() {
+	if time.Since(c.loaded) > usageRefreshInterval {
+		c.clear()
+	}
+}
+
+// QuotaUsage returns every configured quota with its current usage.
+func (s *OCIStorage) QuotaUsage(ctx context.Context) (_ []QuotaUsage, err error) {
+	ctx, span := startSpan(ctx, "QuotaUsage")
+	defer func() { endSpan(span, err) }()
+
+	usages := make([]QuotaUsage, 0, len(s.quotas))
+	for _, quota := range s.quotas {
+		used, err := s.quotaUsed(ctx, quota)
+		if err != nil {
+			return nil, err
+		}
+		usages = append(usages, QuotaUsage{Quota: quota, Used: used})
+	}
+	return usages, nil
+}
+
+// RepositoryUsage returns the bytes of every blob and manifest linked in a repository.
+func (s *OCIStorage) RepositoryUsage(ctx context.Context, name string) (_ int64, err error) {
+	ctx, span := startSpan(ctx, "RepositoryUsage", repositoryAttr(name))
+	defer func() { endSpan(span, err) }()
+
+	if s.usage != nil {
+		s.usage.mu.Lock()
+		s.usage.expire()
+		used, ok := s.usage.repos[name]
+		s.usage.mu.Unlock()
+		if ok {
+			return used, nil
+		}
+	}
+
+	used, err := s.scanUsage(ctx, name)
+	if err != nil {
+		return 0, err
+	}
+	if s.usage != nil {
+		s.usage.mu.Lock()
+		s.usage.repos[name] = used
+		s.usage.mu.Unlock()
+	}
+	return used, nil
+}
+
+// namespaceUsage returns the total usage of the repositories under namespace.
+func (s *OCIStorage) namespaceUsage(ctx context.Context, namespace string) (int64, error) {
+	s.usage.mu.Lock()
+	s.usage.expire()
+	used, ok := s.usage.namespaces[namespace]
+	s.usage.mu.Unlock()
+	if ok {
+		return used, nil
+	}
+
+	repositories := []string{}
+	if err := s.walkRepositories(ctx, path.Join(RepositoriesDir(), namespace), namespace, &repositories); err != nil {
+		return 0, err
+	}
+	used = 0
+	for _, name := range repositories {
+		n, err := s.RepositoryUsage(ctx, name)
+		if err != nil {
+			return 0, err
+		}
+		used += n
+	}
+
+	s.usage.mu.Lock()
+	s.usage.namespaces[namespace] = used
+	s.usage.mu.Unlock()
+	return used, nil
+}
+
+// quotaUsed returns the bytes counted against quota.
+func (s *OCIStorage) quotaUsed(ctx context.Context, quota Quota) (int64, error) {
+	if quota.Repository != "" {
+		return s.RepositoryUsage(ctx, quota.Repository)
+	}
+	return s.namespaceUsage(ctx, quota.Namespace)
+}
+
+// checkQuota returns ErrQuotaExceeded if storing additional bytes in
+// repository name would take it or one of its namespaces over quota.
+func (s *OCIStorage) checkQuota(ctx context.Context, name string, additional int64) error {
+	for _, quota := range s.quotas {
+		if !quota.Matches(name) {
+			continue
+		}
+		used, err := s.quotaUsed(ctx, quota)
+		if err != nil {
+			return fmt.Errorf("failed to compute quota usage: %w", err)
+		}
+		if used+additional > quota.Limit {
+			return fmt.Errorf("%w: %s would use %s of its %s limit (%s already stored)",
+				ErrQuotaExceeded, quota, formatBytes(used+additional), formatBytes(quota.Limit), formatBytes(used))
+		}
+	}
+	return nil
+}
+
+// quotaHeadroom returns how many more bytes repository name can store before
+// one of its quotas is exceeded, or -1 when no quota applies to it.
+func (s *OCIStorage) quotaHeadroom(ctx context.Context, name string) (int64, error) {
+	headroom := int64(-1)
+	for _, quota := range s.quotas {
+		if !quota.Matches(name) {
+			continue
+		}
+		used, err := s.quotaUsed(ctx, quota)
+		if err != nil {
+			return 0, fmt.Errorf("failed to compute quota usage: %w", err)
+		}
+		if remaining := max(quota.Limit-used, 0); headroom < 0 || remaining < headroom {
+			headroom = remaining
+		}
+	}
+	return headroom, nil
+}
+
+// quotaReader reads at most remaining bytes from r. Once more data is
+// available it sets exceeded and fails, so the caller stores nothing beyond
+// the quota.
+type quotaReader struct {
+	r         io.Reader
+	remaining int64
+	read      int64
+	exceeded  bool
+}
+
+// Read implements io.Reader.
+func (q *quotaReader) Read(p []byte) (int, error) {
+	if q.remaining <= 0 {
+		// Probe for one more byte to tell a chunk that fits exactly from one that does not
+		var probe [1]byte
+		n, err := q.r.Read(probe[:])
+		if n > 0 {
+			q.read += int64(n)
+			q.exceeded = true
+			return 0, ErrQuotaExceeded
+		}
+		return 0, err
+	}
+	if int64(len(p)) > q.remaining {
+		p = p[:q.remaining]
+	}
+	n, err := q.r.Read(p)
+	q.remaining -= int64(n)
+	q.read += int64(n)
+	return n, err
+}
+
+// checkLinkQuota checks the quota for linking a blob of size bytes into
+// repository name. Blobs the repository already links are free.
+func (s *OCIStorage) checkLinkQuota(ctx context.Context, name string, digest DigestInfo, size int64) error {
+	if len(s.quotas) == 0 {
+		return nil
+	}
+	linked, err := s.store.Exists(ctx, LayerLinkPath(name, digest))
+	if err != nil {
+		return fmt.Errorf("failed to check blob link: %w", err)
+	}
+	if linked {
+		return nil
+	}
+	return s.checkQuota(ctx, name, size)
+}
+
+// addUsage counts a newly linked blob towards repository name and its namespaces.
+func (s *OCIStorage) addUsage(ctx context.Context, name string, digest DigestInfo) {
+	info, err := s.store.Stat(ctx, BlobDataPath(digest))
+	if err != nil {
+		s.invalidateUsage(name)
+		return
+	}
+
+	s.usage.mu.Lock()
+	defer s.usage.mu.Unlock()
+	if _, ok := s.usage.repos[name]; ok {
+		s.usage.repos[name] += info.Size
+	}
+	for namespace := range s.usage.namespaces {
+		if inNamespace(namespace, name) {
+			s.usage.namespaces[namespace] += info.Size
+		}
+	}
+}
+
+// invalidateUsage drops the cached usage of repository name and its
+// namespaces so it is recomputed on next use.
+func (s *OCIStorage) invalidateUsage(name string) {
+	if s.usage == nil {
+		return
+	}
+	s.usage.mu.Lock()
+	defer s.usage.mu.Unlock()
+	delete(s.usage.repos, name)
+	for namespace := range s.usage.namespaces {
+		if inNamespace(namespace, name) {
+			delete(s.usage.namespaces, namespace)
+		}
+	}
+}
+
+// resetUsage drops every cached usage.
+func (s *OCIStorage) resetUsage() {
+	if s.usage == nil {
+		return
+	}
+	s.usage.mu.Lock()
+	defer s.usage.mu.Unlock()
+	s.usage.clear()
+}
+
+// scanUsage sums the sizes of the blobs a repository links.
+func (s *OCIStorage) scanUsage(ctx context.Context, name string) (int64, error) {
+	layersDir := LayersDir(name)
+	algorithms, err := s.store.List(ctx, layersDir)
+	if err != nil {
+		return 0, fmt.Errorf("failed to list blob links: %w", err)
+	}
+
+	var used int64
+	for _, algorithm := range algorithms {
+		hexes, err := s.store.List(ctx, path.Join(layersDir, algorithm))
+		if err != nil {
+			return 0, fmt.Errorf("failed to list blob links: %w", err)
+		}
+		for _, hex := range hexes {
+			info, err := s.store.Stat(ctx, BlobDataPath(DigestInfo{Algorithm: algorithm, Hex: hex}))
+			if err != nil {
+				// Links whose data was collected take no space
+				if err == storage.ErrFileNotFound {
+					continue
+				}
+				return 0, fmt.Errorf("failed to stat blob: %w", err)
+			}
+			used += info.Size
+		}
+	}
+	return used, nil
+}
+
+// inNamespace reports whether repository name is namespace or below it.
+func inNamespace(namespace, name string) bool {
+	return name == namespace || strings.HasPrefix(name, namespace+"/")
+}
+
+// formatBytes formats a byte count with a binary unit, e.g. "1.5 GiB".
+func formatBytes(n int64) string {
+	const unit = 1024
+	if n < unit {
+		return fmt.Sprintf("%d B", n)
+	}
+	div, exp := int64(unit), 0
+	for m := n / unit; m >= unit; m /= unit {
+		div *= unit
+		exp++
+	}
+	return fmt.Sprintf("%.1f %ciB", float64(n)/float64(div), "KMGTPE"[exp])
+}
@@ -0,0 +1,204 @@
+package oci
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/hairizuanbinnoorazman/package-universe/storage"
+)
+
+func setupTestQuotaStorage(t *testing.T, quotas ...Quota) *OCIStorage {
+	t.Helper()
+	store, err := storage.NewLocalStorage(t.TempDir())
+	if err != nil {
+		t.Fatalf("failed to create local storage: %v", err)
+	}
+	return NewOCIStorage(store, NewSessionManager(store, 30*time.Minute), WithQuotas(quotas...))
+}
+
+func TestOCIStorage_QuotaUsage(t *testing.T) {
+	ctx := context.Background()
+	s := setupTestQuotaStorage(t, Quota{Namespace: "team", Limit: 1 << 20})
+
+	shared := bytes.Repeat([]byte("s"), 100)
+	putTestBlob(t, s, "team/app", shared)
+	putTestBlob(t, s, "team/app", shared) // Re-uploading a linked blob is not counted again
+	putTestBlob(t, s, "team/app", bytes.Repeat([]byte("a"), 50))
+	putTestBlob(t, s, "team/api", shared)
+	putTestBlob(t, s, "other", bytes.Repeat([]byte("o"), 1000))
+
+	if used, _ := s.RepositoryUsage(ctx, "team/app"); used != 150 {
+		t.Errorf("team/app usage = %d, want 150", used)
+	}
+	if used, _ := s.RepositoryUsage(ctx, "team/api"); used != 100 {
+		t.Errorf("team/api usage = %d, want 100", used)
+	}
+
+	usages, err := s.QuotaUsage(ctx)
+	if err != nil {
+		t.Fatalf("QuotaUsage failed: %v", err)
+	}
+	if len(usages) != 1 || usages[0].Used != 250 {
+		t.Fatalf("quota usage = %+v, want 250 bytes used", usages)
+	}
+
+	// Cached usage follows new links and deletions
+	putTestBlob(t, s, "team/new", bytes.Repeat([]byte("n"), 10))
+	if err := s.DeleteBlob(ctx, "team/app", computeSHA256(shared)); err != nil {
+		t.Fatalf("DeleteBlob failed: %v", err)
+	}
+	usages, _ = s.QuotaUsage(ctx)
+	if usages[0].Used != 160 {
+		t.Errorf("quota usage after changes = %d, want 160", usages[0].Used)
+	}
+
+	// A fresh instance computes the same usage from storage
+	fresh := NewOCIStorage(s.store, s.sessions, WithQuotas(s.quotas...))
+	usages, _ = fresh.QuotaUsage(ctx)
+	if usages[0].Used != 160 {
+		t.Errorf("recomputed quota usage = %d, want 160", usages[0].Used)
+	}
+}
+
+func TestOCIStorage_QuotaExceeded(t *testing.T) {
+	ctx := context.Background()
+	s := setupTestQuotaStorage(t,
+		Quota{Repository: "small", Limit: 100},
+		Quota{Namespace: "team", Limit: 200},
+	)
+
+	t.Run("upload", func(t *testing.T) {
+		putTestBlob(t, s, "small", bytes.Repeat([]byte("a"), 80))
+
+		uuid, err := s.InitiateUpload(ctx, "small", "")
+		if err != nil {
+			t.Fatalf("InitiateUpload failed: %v", err)
+		}
+		_, err = s.WriteUploadChunk(ctx, uuid, bytes.NewReader(bytes.Repeat([]byte("b"), 30)))
+		if !errors.Is(err, ErrQuotaExceeded) {
+			t.Fatalf("WriteUploadChunk err = %v, want ErrQuotaExceeded", err)
+		}
+		if _, err := s.GetUploadOffset(ctx, uuid); err != ErrUploadNotFound {
+			t.Errorf("rejected upload should be removed, got %v", err)
+		}
+	})
+
+	t.Run("upload is cut off at the quota", func(t *testing.T) {
+		uuid, _ := s.InitiateUpload(ctx, "small", "")
+		if err := s.CheckUploadQuota(ctx, uuid, 21); !errors.Is(err, ErrQuotaExceeded) {
+			t.Errorf("CheckUploadQuota err = %v, want ErrQuotaExceeded", err)
+		}
+		if err := s.CheckUploadQuota(ctx, uuid, 20); err != nil {
+			t.Errorf("CheckUploadQuota for a chunk that fits: %v", err)
+		}
+
+		// The reader is never drained past the remaining quota
+		data := bytes.NewReader(bytes.Repeat([]byte("c"), 1000))
+		_, err := s.WriteUploadChunk(ctx, uuid, data)
+		if !errors.Is(err, ErrQuotaExceeded) {
+			t.Fatalf("WriteUploadChunk err = %v, want ErrQuotaExceeded", err)
+		}
+		if read := 1000 - data.Len(); read > 21 {
+			t.Errorf("read %d bytes of the chunk, want at most 21", read)
+		}
+		if exists, _ := s.store.Exists(ctx, UploadDataPath(uuid)); exists {
+			t.Error("rejected upload data should be removed")
+		}
+
+		// A chunk that exactly fills the quota is accepted
+		uuid, _ = s.InitiateUpload(ctx, "small", "")
+		if n, err := s.WriteUploadChunk(ctx, uuid, bytes.NewReader(bytes.Repeat([]byte("c"), 20))); err != nil || n != 20 {
+			t.Errorf("WriteUploadChunk = %d, %v, want 20 bytes written", n, err)
+		}
+		s.CancelUpload(ctx, uuid)
+	})
+
+	t.Run("namespace", func(t *testing.T) {
+		putTestBlob(t, s, "team/a", bytes.Repeat([]byte("a"), 150))
+		uuid, _ := s.InitiateUpload(ctx, "team/b", "")
+		_, err := s.WriteUploadChunk(ctx, uuid, bytes.NewReader(bytes.Repeat([]byte("b"), 60)))
+		if !errors.Is(err, ErrQuotaExceeded) {
+			t.Fatalf("WriteUploadChunk err = %v, want ErrQuotaExceeded", err)
+		}
+	})
+
+	t.Run("mount", func(t *testing.T) {
+		big := putTestBlob(t, s, "other", bytes.Repeat([]byte("c"), 90))
+		if _, err := s.MountBlob(ctx, "team/b", "other", big); !errors.Is(err, ErrQuotaExceeded) {
+			t.Errorf("MountBlob err = %v, want ErrQuotaExceeded", err)
+		}
+	})
+
+	t.Run("manifest", func(t *testing.T) {
+		manifest := newTestManifest(t, s, "team/c", "seed")
+		// Fill the namespace to just below its limit
+		used, _ := s.namespaceUsage(ctx, "team")
+		putTestBlob(t, s, "team/c", bytes.Repeat([]byte("d"), int(200-used-1)))
+
+		_, err := s.PutManifest(ctx, "team/c", "v1", MediaTypeImageManifest, manifest)
+		if !errors.Is(err, ErrQuotaExceeded) {
+			t.Errorf("PutManifest err = %v, want ErrQuotaExceeded", err)
+		}
+	})
+}
+
+func TestOCIStorage_DeleteManifestFreesQuota(t *testing.T) {
+	ctx := context.Background()
+	s := setupTestQuotaStorage(t, Quota{Repository: "team/app", Limit: 1 << 20})
+
+	digest, _ := pushTestImage(t, s, "team/app", "v1", "deleted")
+	before, _ := s.RepositoryUsage(ctx, "team/app")
+	info, err := s.store.Stat(ctx, BlobDataPath(digest))
+	if err != nil {
+		t.Fatalf("failed to stat manifest: %v", err)
+	}
+
+	if err := s.DeleteManifest(ctx, "team/app", digest.String()); err != nil {
+		t.Fatalf("DeleteManifest failed: %v", err)
+	}
+	if used, _ := s.RepositoryUsage(ctx, "team/app"); used != before-info.Size {
+		t.Errorf("usage = %d, want %d without the manifest", used, before-info.Size)
+	}
+	if exists, _ := s.BlobExists(ctx, "team/app", digest); exists {
+		t.Error("manifest blob should no longer be linked")
+	}
+}
+
+func TestNewQuota(t *testing.T) {
+	if _, err := NewQuota("app", "team", 10); err == nil {
+		t.Error("expected quota with repository and namespace to be rejected")
+	}
+	if _, err := NewQuota("", "", 10); err == nil {
+		t.Error("expected quota without scope to be rejected")
+	}
+	if _, err := NewQuota("app", "", 0); err == nil {
+		t.Error("expected quota without limit to be rejected")
+	}
+
+	quota, err := NewQuota("", "team/", 10)
+	if err != nil {
+		t.Fatalf("NewQuota failed: %v", err)
+	}
+	for name, want := range map[string]bool{"team": true, "team/app": true, "team/app/api": true, "teams/app": false} {
+		if got := quota.Matches(name); got != want {
+			t.Errorf("Matches(%q) = %v, want %v", name, got, want)
+		}
+	}
+}
+
+func TestFormatBytes(t *testing.T) {
+	tests := map[int64]string{
+		512:              "512 B",
+		1536:             "1.5 KiB",
+		10 * 1024 * 1024: "10.0 MiB",
+		5 << 30:          "5.0 GiB",
+	}
+	for n, want := range tests {
+		if got := formatBytes(n); got != want {
+			t.Errorf("formatBytes(%d) = %q, want %q", n, got, want)
+		}
+	}
+}
@@ -0,0 +1,162 @@
+package oci
+
+import (
+	"context"
+	"fmt"
+	"regexp"
+	"sort"
+	"time"
+
+	"github.com/hairizuanbinnoorazman/package-universe/storage"
+)
+
+// RetentionRule selects tags in repositories matching Repository whose names
+// match Tag, and deletes those beyond the KeepLast most recently pushed or
+// older than MaxAge. A zero KeepLast or MaxAge disables that limit.
+type RetentionRule struct {
+	// Repository is a MatchRepository pattern; empty matches every repository.
+	Repository string
+	Tag        *regexp.Regexp
+	KeepLast   int
+	MaxAge     time.Duration
+}
+
+// NewRetentionRule compiles a rule from a repository glob, a tag regular
+// expression (empty matches every tag) and its limits.
+func NewRetentionRule(repository, tag string, keepLast int, maxAge time.Duration) (RetentionRule, error) {
+	if repository != "" {
+		if err := ValidateRepositoryPattern(repository); err != nil {
+			return RetentionRule{}, fmt.Errorf("invalid repository pattern %q: %w", repository, err)
+		}
+	}
+	re, err := regexp.Compile(tag)
+	if err != nil {
+		return RetentionRule{}, fmt.Errorf("invalid tag pattern %q: %w", tag, err)
+	}
+	if keepLast < 0 || maxAge < 0 {
+		return RetentionRule{}, fmt.Errorf("retention limits must not be negative")
+	}
+	if keepLast == 0 && maxAge == 0 {
+		return RetentionRule{}, fmt.Errorf("retention rule for %q needs keep_last or max_age", tag)
+	}
+	return RetentionRule{Repository: repository, Tag: re, KeepLast: keepLast, MaxAge: maxAge}, nil
+}
+
+// RetentionPolicy is a set of retention rules. Tags matching any Protect
+// pattern are never deleted and do not count towards KeepLast. The same holds
+// for tags covered by the storage's immutable tag rules.
+type RetentionPolicy struct {
+	Rules   []RetentionRule
+	Protect []*regexp.Regexp
+}
+
+// RetentionResult lists the tags removed by ApplyRetention, as name:tag.
+type RetentionResult struct {
+	TagsDeleted []string
+}
+
+// taggedAt is a tag and the time its link was last written.
+type taggedAt struct {
+	tag      string
+	pushedAt time.Time
+}
+
+// ApplyRetention untags every tag selected for deletion by the policy. The
+// manifests stay as untagged revisions for GarbageCollect to remove. With
+// dryRun the tags are only reported.
+func (s *OCIStorage) ApplyRetention(ctx context.Context, policy RetentionPolicy, dryRun bool) (_ RetentionResult, err error) {
+	ctx, span := startSpan(ctx, "ApplyRetention", dryRunAttr(dryRun))
+	defer func() { endSpan(span, err) }()
+
+	result := RetentionResult{TagsDeleted: []string{}}
+	if len(policy.Rules) == 0 {
+		return result, nil
+	}
+
+	repositories, err := s.ListRepositories(ctx)
+	if err != nil {
+		return result, err
+	}
+
+	now := time.Now()
+	for _, name := range repositories {
+		expired, err := s.expiredTags(ctx, name, policy, now)
+		if err != nil {
+			return result, err
+		}
+		for _, tag := range expired {
+			if !dryRun {
+				if err := s.DeleteManifest(ctx, name, tag); err != nil && err != ErrManifestNotFound {
+					return result, fmt.Errorf("failed to delete tag %s:%s: %w", name, tag, err)
+				}
+			}
+			result.TagsDeleted = append(result.TagsDeleted, name+":"+tag)
+		}
+	}
+	return result, nil
+}
+
+// expiredTags returns the tags of a repository that any rule of the policy selects for deletion.
+func (s *OCIStorage) expiredTags(ctx context.Context, name string, policy RetentionPolicy, now time.Time) ([]string, error) {
+	tags, err := s.ListTags(ctx, name)
+	if err != nil {
+		return nil, err
+	}
+
+	var candidates []taggedAt
+	for _, tag := range tags {
+		if isProtected(policy.Protect, tag) || s.isTagImmutable(name, tag) {
+			continue
+		}
+		info, err := s.store.Stat(ctx, ManifestTagCurrentLinkPath(name, tag))
+		if err != nil {
+			if err == storage.ErrFileNotFound {
+				continue
+			}
+			return nil, fmt.Errorf("failed to stat tag %s:%s: %w", name, tag, err)
+		}
+		candidates = append(candidates, taggedAt{tag: tag, pushedAt: info.ModTime})
+	}
+	// Newest first, so KeepLast keeps the head of each rule's selection
+	sort.SliceStable(candidates, func(i, j int) bool {
+		return candidates[i].pushedAt.After(candidates[j].pushedAt)
+	})
+
+	expired := map[string]bool{}
+	for _, rule := range policy.Rules {
+		if rule.Repository != "" {
+			if !MatchRepository(rule.Repository, name) {
+				continue
+			}
+		}
+		seen := 0
+		for _, c := range candidates {
+			if !rule.Tag.MatchString(c.tag) {
+				continue
+			}
+			seen++
+			if (rule.KeepLast > 0 && seen > rule.KeepLast) || (rule.MaxAge > 0 && now.Sub(c.pushedAt) > rule.MaxAge) {
+				expired[c.tag] = true
+			}
+		}
+	}
+
+	result := []string{}
+	for _, c := range candidates {
+		if expired[c.tag] {
+			result = append(result, c.tag)
+		}
+	}
+	sort.Strings(result)
+	return result, nil
+}
+
+// isProtected reports whether tag matches any of the protect patterns.
+func isProtected(protect []*regexp.Regexp, tag string) bool {
+	for _, re := range protect {
+		if re.MatchString(tag) {
+			return true
+		}
+	}
+	return false
+}
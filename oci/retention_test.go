@@ -0,0 +1,154 @@
+package oci
+
+import (
+	"context"
+	"reflect"
+	"regexp"
+	"testing"
+	"time"
+
+	"github.com/hairizuanbinnoorazman/package-universe/storage"
+)
+
+func TestNewRetentionRule_Invalid(t *testing.T) {
+	tests := []struct {
+		name       string
+		repository string
+		tag        string
+		keepLast   int
+		maxAge     time.Duration
+	}{
+		{"no limits", "", ".*", 0, 0},
+		{"negative keep", "", ".*", -1, 0},
+		{"bad glob", "[", ".*", 1, 0},
+		{"bad regex", "", "(", 1, 0},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if _, err := NewRetentionRule(tt.repository, tt.tag, tt.keepLast, tt.maxAge); err == nil {
+				t.Error("expected error but got none")
+			}
+		})
+	}
+}
+
+func TestOCIStorage_ApplyRetention(t *testing.T) {
+	ctx := context.Background()
+	s := setupTestOCIStorage(t)
+
+	// Push nightlies oldest first so link modification times are ordered
+	for _, tag := range []string{"nightly-1", "nightly-2", "nightly-3", "nightly-4", "latest", "v1.0.0"} {
+		pushTestImage(t, s, "nightly/app", tag, tag)
+		time.Sleep(10 * time.Millisecond)
+	}
+	pushTestImage(t, s, "other", "nightly-1", "other")
+
+	keepTwo, _ := NewRetentionRule("nightly/*", "^nightly-", 2, 0)
+	policy := RetentionPolicy{
+		Rules:   []RetentionRule{keepTwo},
+		Protect: []*regexp.Regexp{regexp.MustCompile(`^latest$`)},
+	}
+
+	t.Run("dry run reports without deleting", func(t *testing.T) {
+		result, err := s.ApplyRetention(ctx, policy, true)
+		if err != nil {
+			t.Fatalf("ApplyRetention failed: %v", err)
+		}
+		want := []string{"nightly/app:nightly-1", "nightly/app:nightly-2"}
+		if !reflect.DeepEqual(result.TagsDeleted, want) {
+			t.Errorf("tags deleted = %v, want %v", result.TagsDeleted, want)
+		}
+		tags, _ := s.ListTags(ctx, "nightly/app")
+		if len(tags) != 6 {
+			t.Errorf("dry run left %d tags, want 6", len(tags))
+		}
+	})
+
+	t.Run("keep last", func(t *testing.T) {
+		if _, err := s.ApplyRetention(ctx, policy, false); err != nil {
+			t.Fatalf("ApplyRetention failed: %v", err)
+		}
+		tags, _ := s.ListTags(ctx, "nightly/app")
+		want := []string{"latest", "nightly-3", "nightly-4", "v1.0.0"}
+		if !reflect.DeepEqual(tags, want) {
+			t.Errorf("tags = %v, want %v", tags, want)
+		}
+		if tags, _ := s.ListTags(ctx, "other"); len(tags) != 1 {
+			t.Errorf("repositories outside the rule should be untouched, got %v", tags)
+		}
+	})
+
+	t.Run("max age respects protect list", func(t *testing.T) {
+		expireAll, _ := NewRetentionRule("", "", 0, time.Nanosecond)
+		policy := RetentionPolicy{
+			Rules:   []RetentionRule{expireAll},
+			Protect: []*regexp.Regexp{regexp.MustCompile(`^latest$`), regexp.MustCompile(`^v\d`)},
+		}
+		if _, err := s.ApplyRetention(ctx, policy, false); err != nil {
+			t.Fatalf("ApplyRetention failed: %v", err)
+		}
+		tags, _ := s.ListTags(ctx, "nightly/app")
+		want := []string{"latest", "v1.0.0"}
+		if !reflect.DeepEqual(tags, want) {
+			t.Errorf("tags = %v, want %v", tags, want)
+		}
+		if tags, _ := s.ListTags(ctx, "other"); len(tags) != 0 {
+			t.Errorf("tags = %v, want none", tags)
+		}
+	})
+}
+
+func TestOCIStorage_ApplyRetentionNestedRepositories(t *testing.T) {
+	ctx := context.Background()
+	s := setupTestOCIStorage(t)
+
+	for _, tag := range []string{"nightly-1", "nightly-2", "nightly-3"} {
+		pushTestImage(t, s, "nightly/team/app", tag, tag)
+		time.Sleep(10 * time.Millisecond)
+	}
+
+	single, _ := NewRetentionRule("nightly/*", "", 1, 0)
+	result, err := s.ApplyRetention(ctx, RetentionPolicy{Rules: []RetentionRule{single}}, false)
+	if err != nil {
+		t.Fatalf("ApplyRetention failed: %v", err)
+	}
+	if len(result.TagsDeleted) != 0 {
+		t.Errorf("a single-segment glob should not reach nested repositories, deleted %v", result.TagsDeleted)
+	}
+
+	nested, _ := NewRetentionRule("nightly/**", "", 1, 0)
+	if _, err := s.ApplyRetention(ctx, RetentionPolicy{Rules: []RetentionRule{nested}}, false); err != nil {
+		t.Fatalf("ApplyRetention failed: %v", err)
+	}
+	tags, _ := s.ListTags(ctx, "nightly/team/app")
+	if want := []string{"nightly-3"}; !reflect.DeepEqual(tags, want) {
+		t.Errorf("tags = %v, want %v", tags, want)
+	}
+}
+
+func TestOCIStorage_ApplyRetentionSkipsImmutableTags(t *testing.T) {
+	ctx := context.Background()
+	store, err := storage.NewLocalStorage(t.TempDir())
+	if err != nil {
+		t.Fatalf("failed to create local storage: %v", err)
+	}
+	immutable, _ := NewImmutableTagRule("", `^v\d+\.\d+\.\d+$`)
+	s := NewOCIStorage(store, NewSessionManager(store, 30*time.Minute), WithImmutableTags(immutable))
+
+	for _, tag := range []string{"v1.0.0", "v1.1.0", "dev"} {
+		pushTestImage(t, s, "app", tag, tag)
+	}
+
+	expireAll, _ := NewRetentionRule("", "", 0, time.Nanosecond)
+	result, err := s.ApplyRetention(ctx, RetentionPolicy{Rules: []RetentionRule{expireAll}}, false)
+	if err != nil {
+		t.Fatalf("ApplyRetention failed: %v", err)
+	}
+	if want := []string{"app:dev"}; !reflect.DeepEqual(result.TagsDeleted, want) {
+		t.Errorf("tags deleted = %v, want %v", result.TagsDeleted, want)
+	}
+	tags, _ := s.ListTags(ctx, "app")
+	if want := []string{"v1.0.0", "v1.1.0"}; !reflect.DeepEqual(tags, want) {
+		t.Errorf("tags = %v, want %v", tags, want)
+	}
+}
@@ -1,92 +1,261 @@
 package oci
 
 import (
+	"bytes"
+	"context"
 	"crypto/rand"
+	"encoding/json"
 	"fmt"
+	"io"
 	"sync"
 	"time"
+
+	"github.com/hairizuanbinnoorazman/package-universe/storage"
 )
 
 // UploadSession tracks an in-progress blob upload.
 type UploadSession struct {
-	UUID       string
-	Repository string
-	StartedAt  time.Time
-	BytesWritten int64
+	UUID         string    `json:"uuid"`
+	Repository   string    `json:"repository"`
+	StartedAt    time.Time `json:"startedAt"`
+	BytesWritten int64     `json:"bytesWritten"`
+	// DigestAlgorithm is the algorithm the running digest is computed with.
+	DigestAlgorithm string `json:"digestAlgorithm"`
+	// HashState is the marshaled digest state of the bytes written so far,
+	// letting each chunk resume hashing without re-reading earlier data.
+	HashState []byte `json:"hashState,omitempty"`
 }
 
-// SessionManager manages upload sessions in memory.
+// SessionManager manages upload sessions. Each session is persisted through
+// storage next to its upload data, so any instance sharing the storage can
+// resume an upload and sessions survive restarts. The in-memory index holds
+// the sessions this instance knows about; storage is always authoritative.
 type SessionManager struct {
+	store    storage.BlobStorage
 	mu       sync.RWMutex
 	sessions map[string]*UploadSession
 	timeout  time.Duration
 }
 
-// NewSessionManager creates a new session manager with the given timeout.
-func NewSessionManager(timeout time.Duration) *SessionManager {
+// NewSessionManager creates a new session manager persisting to store with the given timeout.
+func NewSessionManager(store storage.BlobStorage, timeout time.Duration) *SessionManager {
 	return &SessionManager{
+		store:    store,
 		sessions: make(map[string]*UploadSession),
 		timeout:  timeout,
 	}
 }
 
-// Create creates a new upload session and returns the UUID.
-func (sm *SessionManager) Create(repository string) (string, error) {
+// Rehydrate loads every persisted session into memory, removing expired
+// session records, and returns the number of sessions loaded.
+func (sm *SessionManager) Rehydrate(ctx context.Context) (int, error) {
+	uuids, err := sm.store.List(ctx, UploadsDir())
+	if err != nil {
+		return 0, fmt.Errorf("failed to list upload sessions: %w", err)
+	}
+
+	loaded := 0
+	for _, uuid := range uuids {
+		_, err := sm.Get(ctx, uuid)
+		if err == ErrUploadNotFound {
+			continue
+		}
+		if err != nil {
+			return loaded, err
+		}
+		loaded++
+	}
+	return loaded, nil
+}
+
+// Create creates a new upload session hashing with the given digest algorithm and returns the UUID.
+func (sm *SessionManager) Create(ctx context.Context, repository, algorithm string) (string, error) {
 	uuid, err := generateUUID()
 	if err != nil {
 		return "", fmt.Errorf("failed to generate UUID: %w", err)
 	}
 
 	session := &UploadSession{
-		UUID:       uuid,
-		Repository: repository,
-		StartedAt:  time.Now(),
+		UUID:            uuid,
+		Repository:      repository,
+		StartedAt:       time.Now(),
+		DigestAlgorithm: algorithm,
+	}
+	if err := sm.save(ctx, session); err != nil {
+		return "", err
 	}
-
-	sm.mu.Lock()
-	sm.sessions[uuid] = session
-	sm.mu.Unlock()
 
 	return uuid, nil
 }
 
 // Get retrieves a session by UUID. Returns ErrUploadNotFound if not found or expired.
-func (sm *SessionManager) Get(uuid string) (*UploadSession, error) {
-	sm.mu.RLock()
-	session, ok := sm.sessions[uuid]
-	sm.mu.RUnlock()
-
-	if !ok {
-		return nil, ErrUploadNotFound
+func (sm *SessionManager) Get(ctx context.Context, uuid string) (*UploadSession, error) {
+	session, err := sm.load(ctx, uuid)
+	if err != nil {
+		return nil, err
 	}
 
-	if time.Since(session.StartedAt) > sm.timeout {
-		sm.Delete(uuid)
+	if sm.expired(session) {
+		sm.removeUpload(ctx, uuid)
 		return nil, ErrUploadNotFound
 	}
 
 	return session, nil
 }
 
-// UpdateBytes updates the bytes written count for a session.
-func (sm *SessionManager) UpdateBytes(uuid string, bytesWritten int64) error {
-	sm.mu.Lock()
-	defer sm.mu.Unlock()
+// ReapResult summarizes the uploads removed by Reap.
+type ReapResult struct {
+	SessionsExpired int
+	OrphansDeleted  int
+	BytesReclaimed  int64
+}
+
+// Reap removes expired sessions together with their upload data, and upload
+// data that has had no session for longer than the session timeout.
+func (sm *SessionManager) Reap(ctx context.Context) (ReapResult, error) {
+	var result ReapResult
+
+	uuids, err := sm.store.List(ctx, UploadsDir())
+	if err != nil {
+		return result, fmt.Errorf("failed to list uploads: %w", err)
+	}
 
-	session, ok := sm.sessions[uuid]
-	if !ok {
-		return ErrUploadNotFound
+	for _, uuid := range uuids {
+		session, err := sm.load(ctx, uuid)
+		switch {
+		case err == nil:
+			if !sm.expired(session) {
+				continue
+			}
+			size, err := sm.removeUpload(ctx, uuid)
+			if err != nil {
+				return result, err
+			}
+			result.SessionsExpired++
+			result.BytesReclaimed += size
+		case err == ErrUploadNotFound:
+			// Sessions are written before their data, so only stale data is orphaned
+			info, err := sm.store.Stat(ctx, UploadDataPath(uuid))
+			if err == storage.ErrFileNotFound || (err == nil && time.Since(info.ModTime) <= sm.timeout) {
+				continue
+			}
+			if err != nil {
+				return result, fmt.Errorf("failed to stat upload %s: %w", uuid, err)
+			}
+			size, err := sm.removeUpload(ctx, uuid)
+			if err != nil {
+				return result, err
+			}
+			result.OrphansDeleted++
+			result.BytesReclaimed += size
+		default:
+			return result, err
+		}
+	}
+	return result, nil
+}
+
+// Active returns the number of unexpired sessions in the in-memory index,
+// i.e. uploads in progress that this instance has seen.
+func (sm *SessionManager) Active() int {
+	sm.mu.RLock()
+	defer sm.mu.RUnlock()
+
+	active := 0
+	for _, session := range sm.sessions {
+		if !sm.expired(session) {
+			active++
+		}
+	}
+	return active
+}
+
+// UpdateProgress records the bytes written and digest state after a chunk is appended.
+func (sm *SessionManager) UpdateProgress(ctx context.Context, uuid string, bytesWritten int64, hashState []byte) error {
+	session, err := sm.load(ctx, uuid)
+	if err != nil {
+		return err
 	}
 
 	session.BytesWritten = bytesWritten
-	return nil
+	session.HashState = hashState
+	return sm.save(ctx, session)
 }
 
-// Delete removes a session by UUID.
-func (sm *SessionManager) Delete(uuid string) {
+// Delete removes a session by UUID. The upload data is left to the caller.
+func (sm *SessionManager) Delete(ctx context.Context, uuid string) {
 	sm.mu.Lock()
 	delete(sm.sessions, uuid)
 	sm.mu.Unlock()
+
+	sm.store.Delete(ctx, UploadSessionPath(uuid))
+}
+
+// expired reports whether a session has outlived the timeout.
+func (sm *SessionManager) expired(session *UploadSession) bool {
+	return time.Since(session.StartedAt) > sm.timeout
+}
+
+// removeUpload deletes an upload's data and session record and returns the
+// size of the data removed.
+func (sm *SessionManager) removeUpload(ctx context.Context, uuid string) (int64, error) {
+	var size int64
+	if info, err := sm.store.Stat(ctx, UploadDataPath(uuid)); err == nil {
+		size = info.Size
+	}
+
+	err := sm.store.Delete(ctx, UploadDataPath(uuid))
+	if err != nil && err != storage.ErrFileNotFound {
+		return 0, fmt.Errorf("failed to delete upload %s: %w", uuid, err)
+	}
+	sm.Delete(ctx, uuid)
+	return size, nil
+}
+
+// load reads a session from storage and refreshes the in-memory index.
+func (sm *SessionManager) load(ctx context.Context, uuid string) (*UploadSession, error) {
+	reader, err := sm.store.Download(ctx, UploadSessionPath(uuid))
+	if err != nil {
+		if err == storage.ErrFileNotFound {
+			sm.mu.Lock()
+			delete(sm.sessions, uuid)
+			sm.mu.Unlock()
+			return nil, ErrUploadNotFound
+		}
+		return nil, fmt.Errorf("failed to read upload session: %w", err)
+	}
+	defer reader.Close()
+
+	data, err := io.ReadAll(reader)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read upload session: %w", err)
+	}
+	var session UploadSession
+	if err := json.Unmarshal(data, &session); err != nil {
+		return nil, fmt.Errorf("failed to decode upload session: %w", err)
+	}
+
+	sm.mu.Lock()
+	sm.sessions[uuid] = &session
+	sm.mu.Unlock()
+
+	return &session, nil
+}
+
+// save writes a session to storage and records it in the in-memory index.
+func (sm *SessionManager) save(ctx context.Context, session *UploadSession) error {
+	data, err := json.Marshal(session)
+	if err != nil {
+		return fmt.Errorf("failed to encode upload session: %w", err)
+	}
+	if err := sm.store.Upload(ctx, UploadSessionPath(session.UUID), bytes.NewReader(data)); err != nil {
+		return fmt.Errorf("failed to store upload session: %w", err)
+	}
+
+	sm.mu.Lock()
+	sm.sessions[session.UUID] = session
+	sm.mu.Unlock()
+	return nil
 }
 
 // generateUUID generates a random UUID v4.
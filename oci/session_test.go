@@ -1,14 +1,28 @@
 package oci
 
 import (
+	"context"
+	"strings"
 	"testing"
 	"time"
+
+	"github.com/hairizuanbinnoorazman/package-universe/storage"
 )
 
+func newTestSessionManager(t *testing.T, timeout time.Duration) (*SessionManager, storage.BlobStorage) {
+	t.Helper()
+	store, err := storage.NewLocalStorage(t.TempDir())
+	if err != nil {
+		t.Fatalf("failed to create local storage: %v", err)
+	}
+	return NewSessionManager(store, timeout), store
+}
+
 func TestSessionManager_CreateAndGet(t *testing.T) {
-	sm := NewSessionManager(30 * time.Minute)
+	ctx := context.Background()
+	sm, _ := newTestSessionManager(t, 30*time.Minute)
 
-	uuid, err := sm.Create("myrepo")
+	uuid, err := sm.Create(ctx, "myrepo", DefaultDigestAlgorithm)
 	if err != nil {
 		t.Fatalf("failed to create session: %v", err)
 	}
@@ -16,7 +30,7 @@ func TestSessionManager_CreateAndGet(t *testing.T) {
 		t.Fatal("UUID should not be empty")
 	}
 
-	session, err := sm.Get(uuid)
+	session, err := sm.Get(ctx, uuid)
 	if err != nil {
 		t.Fatalf("failed to get session: %v", err)
 	}
@@ -29,60 +43,39 @@ func TestSessionManager_CreateAndGet(t *testing.T) {
 }
 
 func TestSessionManager_GetNotFound(t *testing.T) {
-	sm := NewSessionManager(30 * time.Minute)
+	ctx := context.Background()
+	sm, _ := newTestSessionManager(t, 30*time.Minute)
 
-	_, err := sm.Get("nonexistent")
+	_, err := sm.Get(ctx, "nonexistent")
 	if err != ErrUploadNotFound {
 		t.Errorf("expected ErrUploadNotFound, got %v", err)
 	}
 }
 
 func TestSessionManager_Delete(t *testing.T) {
-	sm := NewSessionManager(30 * time.Minute)
+	ctx := context.Background()
+	sm, _ := newTestSessionManager(t, 30*time.Minute)
 
-	uuid, _ := sm.Create("myrepo")
-	sm.Delete(uuid)
+	uuid, _ := sm.Create(ctx, "myrepo", DefaultDigestAlgorithm)
+	sm.Delete(ctx, uuid)
 
-	_, err := sm.Get(uuid)
+	_, err := sm.Get(ctx, uuid)
 	if err != ErrUploadNotFound {
 		t.Errorf("expected ErrUploadNotFound after delete, got %v", err)
 	}
 }
 
-func TestSessionManager_UpdateBytes(t *testing.T) {
-	sm := NewSessionManager(30 * time.Minute)
-
-	uuid, _ := sm.Create("myrepo")
-	err := sm.UpdateBytes(uuid, 1024)
-	if err != nil {
-		t.Fatalf("failed to update bytes: %v", err)
-	}
-
-	session, _ := sm.Get(uuid)
-	if session.BytesWritten != 1024 {
-		t.Errorf("BytesWritten = %d, want 1024", session.BytesWritten)
-	}
-}
-
-func TestSessionManager_UpdateBytesNotFound(t *testing.T) {
-	sm := NewSessionManager(30 * time.Minute)
-
-	err := sm.UpdateBytes("nonexistent", 1024)
-	if err != ErrUploadNotFound {
-		t.Errorf("expected ErrUploadNotFound, got %v", err)
-	}
-}
-
 func TestSessionManager_Expiry(t *testing.T) {
 	// Create with very short timeout
-	sm := NewSessionManager(1 * time.Millisecond)
+	ctx := context.Background()
+	sm, _ := newTestSessionManager(t, 1*time.Millisecond)
 
-	uuid, _ := sm.Create("myrepo")
+	uuid, _ := sm.Create(ctx, "myrepo", DefaultDigestAlgorithm)
 
 	// Wait for expiry
 	time.Sleep(5 * time.Millisecond)
 
-	_, err := sm.Get(uuid)
+	_, err := sm.Get(ctx, uuid)
 	if err != ErrUploadNotFound {
 		t.Errorf("expected ErrUploadNotFound for expired session, got %v", err)
 	}
@@ -105,3 +98,126 @@ func TestGenerateUUID(t *testing.T) {
 		t.Errorf("UUID length = %d, want 36", len(uuid1))
 	}
 }
+
+func TestSessionManager_UpdateProgress(t *testing.T) {
+	ctx := context.Background()
+	sm, _ := newTestSessionManager(t, time.Hour)
+	uuid, _ := sm.Create(ctx, "myrepo", DefaultDigestAlgorithm)
+
+	if err := sm.UpdateProgress(ctx, uuid, 42, []byte("state")); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	session, _ := sm.Get(ctx, uuid)
+	if session.BytesWritten != 42 || string(session.HashState) != "state" {
+		t.Errorf("session = %+v, want 42 bytes and saved state", session)
+	}
+
+	if err := sm.UpdateProgress(ctx, "nonexistent", 1, nil); err != ErrUploadNotFound {
+		t.Errorf("expected ErrUploadNotFound, got %v", err)
+	}
+}
+
+func TestSessionManager_Persistence(t *testing.T) {
+	ctx := context.Background()
+	sm, store := newTestSessionManager(t, time.Hour)
+
+	uuid, _ := sm.Create(ctx, "myrepo", DefaultDigestAlgorithm)
+	if err := sm.UpdateProgress(ctx, uuid, 42, []byte("state")); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	// A second manager sharing the storage sees the session, as after a restart
+	restarted := NewSessionManager(store, time.Hour)
+	n, err := restarted.Rehydrate(ctx)
+	if err != nil {
+		t.Fatalf("Rehydrate failed: %v", err)
+	}
+	if n != 1 {
+		t.Errorf("rehydrated %d sessions, want 1", n)
+	}
+
+	session, err := restarted.Get(ctx, uuid)
+	if err != nil {
+		t.Fatalf("failed to get session: %v", err)
+	}
+	if session.Repository != "myrepo" || session.BytesWritten != 42 || string(session.HashState) != "state" || session.DigestAlgorithm != DefaultDigestAlgorithm {
+		t.Errorf("session = %+v, want persisted progress", session)
+	}
+
+	// Deleting through one manager is visible to the other
+	sm.Delete(ctx, uuid)
+	if _, err := restarted.Get(ctx, uuid); err != ErrUploadNotFound {
+		t.Errorf("expected ErrUploadNotFound after delete, got %v", err)
+	}
+}
+
+func TestSessionManager_RehydrateDropsExpired(t *testing.T) {
+	ctx := context.Background()
+	sm, store := newTestSessionManager(t, time.Millisecond)
+
+	uuid, _ := sm.Create(ctx, "myrepo", DefaultDigestAlgorithm)
+	time.Sleep(5 * time.Millisecond)
+
+	n, err := NewSessionManager(store, time.Millisecond).Rehydrate(ctx)
+	if err != nil {
+		t.Fatalf("Rehydrate failed: %v", err)
+	}
+	if n != 0 {
+		t.Errorf("rehydrated %d sessions, want 0", n)
+	}
+	if exists, _ := store.Exists(ctx, UploadSessionPath(uuid)); exists {
+		t.Error("expired session record should be removed")
+	}
+}
+
+func TestSessionManager_Reap(t *testing.T) {
+	ctx := context.Background()
+	sm, store := newTestSessionManager(t, 50*time.Millisecond)
+
+	expired, _ := sm.Create(ctx, "myrepo", DefaultDigestAlgorithm)
+	store.Append(ctx, UploadDataPath(expired), strings.NewReader("abandoned"))
+	store.Append(ctx, UploadDataPath("orphan"), strings.NewReader("orphan"))
+
+	time.Sleep(60 * time.Millisecond)
+	active, _ := sm.Create(ctx, "myrepo", DefaultDigestAlgorithm)
+	store.Append(ctx, UploadDataPath(active), strings.NewReader("in progress"))
+
+	result, err := sm.Reap(ctx)
+	if err != nil {
+		t.Fatalf("Reap failed: %v", err)
+	}
+	if result.SessionsExpired != 1 || result.OrphansDeleted != 1 {
+		t.Errorf("result = %+v, want 1 expired session and 1 orphan", result)
+	}
+	if want := int64(len("abandoned") + len("orphan")); result.BytesReclaimed != want {
+		t.Errorf("bytes reclaimed = %d, want %d", result.BytesReclaimed, want)
+	}
+
+	for _, uuid := range []string{expired, "orphan"} {
+		if exists, _ := store.Exists(ctx, UploadDataPath(uuid)); exists {
+			t.Errorf("upload data for %s should be deleted", uuid)
+		}
+	}
+	if _, err := sm.Get(ctx, active); err != nil {
+		t.Errorf("active session should be kept, got %v", err)
+	}
+	if exists, _ := store.Exists(ctx, UploadDataPath(active)); !exists {
+		t.Error("active upload data should be kept")
+	}
+}
+
+func TestSessionManager_ExpiryRemovesData(t *testing.T) {
+	ctx := context.Background()
+	sm, store := newTestSessionManager(t, time.Millisecond)
+
+	uuid, _ := sm.Create(ctx, "myrepo", DefaultDigestAlgorithm)
+	store.Append(ctx, UploadDataPath(uuid), strings.NewReader("data"))
+	time.Sleep(5 * time.Millisecond)
+
+	if _, err := sm.Get(ctx, uuid); err != ErrUploadNotFound {
+		t.Fatalf("expected ErrUploadNotFound, got %v", err)
+	}
+	if exists, _ := store.Exists(ctx, UploadDataPath(uuid)); exists {
+		t.Error("expired upload data should be deleted")
+	}
+}
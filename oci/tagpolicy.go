@@ -0,0 +1,55 @@
+package oci
+
+import (
+	"fmt"
+	"regexp"
+)
+
+// ImmutableTagRule makes tags matching Tag immutable in repositories matching
+// Repository. Once such a tag exists it can only be re-pushed with the same digest.
+type ImmutableTagRule struct {
+	// Repository is a MatchRepository pattern; empty matches every repository.
+	Repository string
+	Tag        *regexp.Regexp
+}
+
+// NewImmutableTagRule compiles a rule from a repository glob and a tag regular expression.
+func NewImmutableTagRule(repository, tag string) (ImmutableTagRule, error) {
+	if repository != "" {
+		if err := ValidateRepositoryPattern(repository); err != nil {
+			return ImmutableTagRule{}, fmt.Errorf("invalid repository pattern %q: %w", repository, err)
+		}
+	}
+	re, err := regexp.Compile(tag)
+	if err != nil {
+		return ImmutableTagRule{}, fmt.Errorf("invalid tag pattern %q: %w", tag, err)
+	}
+	return ImmutableTagRule{Repository: repository, Tag: re}, nil
+}
+
+// Matches reports whether the rule applies to tag in repository name.
+func (r ImmutableTagRule) Matches(name, tag string) bool {
+	if r.Repository != "" {
+		if !MatchRepository(r.Repository, name) {
+			return false
+		}
+	}
+	return r.Tag.MatchString(tag)
+}
+
+// WithImmutableTags rejects overwrites of tags matching any of the rules.
+func WithImmutableTags(rules ...ImmutableTagRule) Option {
+	return func(s *OCIStorage) {
+		s.immutableTags = append(s.immutableTags, rules...)
+	}
+}
+
+// isTagImmutable reports whether any configured rule applies to tag in repository name.
+func (s *OCIStorage) isTagImmutable(name, tag string) bool {
+	for _, rule := range s.immutableTags {
+		if rule.Matches(name, tag) {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,126 @@
+package oci
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/hairizuanbinnoorazman/package-universe/storage"
+)
+
+func TestImmutableTagRule_Matches(t *testing.T) {
+	semver, err := NewImmutableTagRule("", `^v?\d+\.\d+\.\d+$`)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	scoped, err := NewImmutableTagRule("prod/*", `.*`)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	nested, err := NewImmutableTagRule("prod/**", `.*`)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	tests := []struct {
+		rule ImmutableTagRule
+		name string
+		tag  string
+		want bool
+	}{
+		{semver, "myrepo", "v1.4.2", true},
+		{semver, "team/app", "1.4.2", true},
+		{semver, "myrepo", "latest", false},
+		{semver, "myrepo", "v1.4", false},
+		{scoped, "prod/app", "latest", true},
+		{scoped, "dev/app", "latest", false},
+		{scoped, "prod/team/app", "latest", false},
+		{nested, "prod/app", "latest", true},
+		{nested, "prod/team/app", "latest", true},
+		{nested, "dev/team/app", "latest", false},
+	}
+	for _, tt := range tests {
+		if got := tt.rule.Matches(tt.name, tt.tag); got != tt.want {
+			t.Errorf("rule(%q, %q).Matches(%q, %q) = %v, want %v", tt.rule.Repository, tt.rule.Tag, tt.name, tt.tag, got, tt.want)
+		}
+	}
+}
+
+func TestNewImmutableTagRule_Invalid(t *testing.T) {
+	if _, err := NewImmutableTagRule("[", ".*"); err == nil {
+		t.Error("expected error for invalid repository glob")
+	}
+	if _, err := NewImmutableTagRule("", "("); err == nil {
+		t.Error("expected error for invalid tag regex")
+	}
+}
+
+func TestOCIStorage_ImmutableTags(t *testing.T) {
+	ctx := context.Background()
+	store, err := storage.NewLocalStorage(t.TempDir())
+	if err != nil {
+		t.Fatalf("failed to create local storage: %v", err)
+	}
+	rule, _ := NewImmutableTagRule("", `^v\d+\.\d+\.\d+$`)
+	s := NewOCIStorage(store, NewSessionManager(store, 30*time.Minute), WithImmutableTags(rule))
+
+	first := newTestManifest(t, s, "myrepo", "first")
+	second := newTestManifest(t, s, "myrepo", "second")
+
+	digest, err := s.PutManifest(ctx, "myrepo", "v1.0.0", MediaTypeImageManifest, first)
+	if err != nil {
+		t.Fatalf("PutManifest failed: %v", err)
+	}
+
+	t.Run("identical re-push is allowed", func(t *testing.T) {
+		if _, err := s.PutManifest(ctx, "myrepo", "v1.0.0", MediaTypeImageManifest, first); err != nil {
+			t.Errorf("unexpected error: %v", err)
+		}
+	})
+
+	t.Run("overwrite is rejected", func(t *testing.T) {
+		_, err := s.PutManifest(ctx, "myrepo", "v1.0.0", MediaTypeImageManifest, second)
+		if !errors.Is(err, ErrTagImmutable) {
+			t.Fatalf("expected ErrTagImmutable, got %v", err)
+		}
+		_, got, _, err := s.GetManifest(ctx, "myrepo", "v1.0.0")
+		if err != nil {
+			t.Fatalf("GetManifest failed: %v", err)
+		}
+		if got != digest {
+			t.Errorf("tag points at %s, want %s", got, digest)
+		}
+	})
+
+	t.Run("delete is rejected", func(t *testing.T) {
+		if err := s.DeleteManifest(ctx, "myrepo", "v1.0.0"); !errors.Is(err, ErrTagImmutable) {
+			t.Errorf("delete by tag: expected ErrTagImmutable, got %v", err)
+		}
+		if err := s.DeleteManifest(ctx, "myrepo", digest.String()); !errors.Is(err, ErrTagImmutable) {
+			t.Errorf("delete by digest: expected ErrTagImmutable, got %v", err)
+		}
+		if err := s.DeleteManifest(ctx, "myrepo", "v9.9.9"); err != ErrManifestNotFound {
+			t.Errorf("delete missing tag: expected ErrManifestNotFound, got %v", err)
+		}
+		if _, err := s.PutManifest(ctx, "myrepo", "v1.0.0", MediaTypeImageManifest, second); !errors.Is(err, ErrTagImmutable) {
+			t.Errorf("re-push after delete: expected ErrTagImmutable, got %v", err)
+		}
+		_, got, _, err := s.GetManifest(ctx, "myrepo", "v1.0.0")
+		if err != nil {
+			t.Fatalf("GetManifest failed: %v", err)
+		}
+		if got != digest {
+			t.Errorf("tag points at %s, want %s", got, digest)
+		}
+	})
+
+	t.Run("mutable tags can move", func(t *testing.T) {
+		if _, err := s.PutManifest(ctx, "myrepo", "latest", MediaTypeImageManifest, first); err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		if _, err := s.PutManifest(ctx, "myrepo", "latest", MediaTypeImageManifest, second); err != nil {
+			t.Errorf("unexpected error: %v", err)
+		}
+	})
+}
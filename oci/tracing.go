@@ -0,0 +1,95 @@
+package oci
+
+import (
+	"context"
+	"errors"
+	"net/http"
+
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/propagation"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// tracer creates the spans of OCIStorage operations and Client requests. It
+// follows the global tracer provider, so spans are only exported once the
+// server has configured one.
+var tracer = otel.Tracer("github.com/hairizuanbinnoorazman/package-universe/oci")
+
+// startSpan starts the span of an OCIStorage operation.
+func startSpan(ctx context.Context, operation string, attrs ...attribute.KeyValue) (context.Context, trace.Span) {
+	return tracer.Start(ctx, "oci."+operation, trace.WithAttributes(attrs...))
+}
+
+// endSpan ends span, marking it failed when err is set. Lookups of content
+// that does not exist are answers rather than failures and are not marked.
+func endSpan(span trace.Span, err error) {
+	if err != nil && !errors.Is(err, ErrBlobNotFound) && !errors.Is(err, ErrManifestNotFound) && !errors.Is(err, ErrUploadNotFound) {
+		span.RecordError(err)
+		span.SetStatus(codes.Error, err.Error())
+	}
+	span.End()
+}
+
+// repositoryAttr labels a span with the repository it operates on.
+func repositoryAttr(name string) attribute.KeyValue {
+	return attribute.String("oci.repository", name)
+}
+
+// digestAttr labels a span with the digest of the blob or manifest it operates on.
+func digestAttr(digest DigestInfo) attribute.KeyValue {
+	return attribute.String("oci.digest", digest.String())
+}
+
+// referenceAttr labels a span with the tag or digest a manifest was addressed by.
+func referenceAttr(reference string) attribute.KeyValue {
+	return attribute.String("oci.reference", reference)
+}
+
+// uploadAttr labels a span with the upload session it operates on.
+func uploadAttr(uuid string) attribute.KeyValue {
+	return attribute.String("oci.upload_id", uuid)
+}
+
+// mountFromAttr labels a mount span with the repository the blob is mounted from.
+func mountFromAttr(from string) attribute.KeyValue {
+	return attribute.String("oci.mount_from", from)
+}
+
+// dryRunAttr labels a span of a cleanup that only reports what it would remove.
+func dryRunAttr(dryRun bool) attribute.KeyValue {
+	return attribute.Bool("oci.dry_run", dryRun)
+}
+
+// tracingTransport wraps each remote request in a client span and passes the
+// trace context on to the remote registry in W3C traceparent headers.
+type tracingTransport struct {
+	base http.RoundTripper
+}
+
+func (t tracingTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	ctx, span := tracer.Start(req.Context(), "HTTP "+req.Method,
+		trace.WithSpanKind(trace.SpanKindClient),
+		trace.WithAttributes(
+			attribute.String("http.request.method", req.Method),
+			attribute.String("server.address", req.URL.Host),
+			attribute.String("url.full", req.URL.Redacted()),
+		))
+	defer span.End()
+
+	req = req.Clone(ctx)
+	otel.GetTextMapPropagator().Inject(ctx, propagation.HeaderCarrier(req.Header))
+
+	resp, err := t.base.RoundTrip(req)
+	if err != nil {
+		span.RecordError(err)
+		span.SetStatus(codes.Error, err.Error())
+		return nil, err
+	}
+	span.SetAttributes(attribute.Int("http.response.status_code", resp.StatusCode))
+	if resp.StatusCode >= 500 {
+		span.SetStatus(codes.Error, resp.Status)
+	}
+	return resp, nil
+}
@@ -0,0 +1,321 @@
+package replication
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"path"
+	"sort"
+	"time"
+
+	"github.com/hairizuanbinnoorazman/package-universe/oci"
+	"github.com/hairizuanbinnoorazman/package-universe/storage"
+)
+
+// QueueDir returns the storage path under which pending replications live.
+// Layout: v2/replication/queue
+func QueueDir() string {
+	return "v2/replication/queue"
+}
+
+// TaskPath returns the storage path of a pending replication.
+// Layout: v2/replication/queue/<id>
+func TaskPath(id string) string {
+	return path.Join("v2/replication/queue", id)
+}
+
+// StatusPath returns the storage path of a target's replication status.
+// Layout: v2/replication/status/<target>
+func StatusPath(target string) string {
+	return path.Join("v2/replication/status", target)
+}
+
+// Task is a pending copy of one manifest reference to one target.
+type Task struct {
+	ID          string    `json:"id"`
+	Target      string    `json:"target"`
+	Repository  string    `json:"repository"`
+	Reference   string    `json:"reference"`
+	Attempts    int       `json:"attempts"`
+	NextAttempt time.Time `json:"nextAttempt"`
+	LastError   string    `json:"lastError,omitempty"`
+	CreatedAt   time.Time `json:"createdAt"`
+}
+
+// TargetStatus summarizes replication to one target.
+type TargetStatus struct {
+	Target      string     `json:"target"`
+	Pending     int        `json:"pending"`
+	Replicated  int64      `json:"replicated"`
+	Failed      int64      `json:"failed"`
+	LastSuccess *time.Time `json:"lastSuccess,omitempty"`
+	LastFailure *time.Time `json:"lastFailure,omitempty"`
+	LastError   string     `json:"lastError,omitempty"`
+}
+
+// taskID identifies the task for a reference on a target, so pushing the
+// same reference again before it is replicated replaces the pending task.
+func taskID(target, name, reference string) string {
+	sum := sha256.Sum256([]byte(target + "\x00" + name + "\x00" + reference))
+	return fmt.Sprintf("%x", sum[:16])
+}
+
+// Enqueue records that name:reference should be copied to every target whose
+// patterns match the repository, and wakes the worker.
+func (r *Replicator) Enqueue(ctx context.Context, name, reference string) error {
+	queued := false
+	for _, target := range r.targets {
+		if !target.Matches(name) {
+			continue
+		}
+		now := time.Now()
+		task := &Task{
+			ID:          taskID(target.Name, name, reference),
+			Target:      target.Name,
+			Repository:  name,
+			Reference:   reference,
+			NextAttempt: now,
+			CreatedAt:   now,
+		}
+		if err := r.saveTask(ctx, task); err != nil {
+			return err
+		}
+		queued = true
+	}
+
+	if queued {
+		select {
+		case r.wake <- struct{}{}:
+		default:
+		}
+	}
+	return nil
+}
+
+// Run processes the queue until ctx is cancelled, whenever something is
+// enqueued and every RetryInterval for retries.
+func (r *Replicator) Run(ctx context.Context) {
+	ticker := time.NewTicker(r.opts.RetryInterval)
+	defer ticker.Stop()
+
+	for {
+		if _, err := r.ProcessQueue(ctx); err != nil && ctx.Err() == nil {
+			r.logger.Error(ctx, "failed to process replication queue", map[string]interface{}{"error": err.Error()})
+		}
+		select {
+		case <-ctx.Done():
+			return
+		case <-r.wake:
+		case <-ticker.C:
+		}
+	}
+}
+
+// ProcessQueue attempts every task that is due and returns how many
+// replications succeeded. Failed tasks are rescheduled with backoff, and
+// dropped once they reach MaxAttempts or their manifest no longer exists.
+func (r *Replicator) ProcessQueue(ctx context.Context) (int, error) {
+	tasks, err := r.pendingTasks(ctx)
+	if err != nil {
+		return 0, err
+	}
+
+	targets := make(map[string]Target, len(r.targets))
+	for _, target := range r.targets {
+		targets[target.Name] = target
+	}
+
+	replicated := 0
+	now := time.Now()
+	for _, task := range tasks {
+		if ctx.Err() != nil {
+			return replicated, ctx.Err()
+		}
+		if task.NextAttempt.After(now) {
+			continue
+		}
+
+		fields := map[string]interface{}{
+			"target":     task.Target,
+			"repository": task.Repository,
+			"reference":  task.Reference,
+		}
+		target, ok := targets[task.Target]
+		if !ok {
+			// The target was removed from the configuration
+			r.deleteTask(ctx, task.ID)
+			continue
+		}
+
+		err := r.Replicate(ctx, target, task.Repository, task.Reference)
+		if err == nil {
+			r.deleteTask(ctx, task.ID)
+			r.recordResult(ctx, task.Target, nil)
+			r.logger.Info(ctx, "image replicated", fields)
+			replicated++
+			continue
+		}
+		if ctx.Err() != nil {
+			return replicated, ctx.Err()
+		}
+
+		fields["error"] = err.Error()
+		fields["attempts"] = task.Attempts + 1
+		r.recordResult(ctx, task.Target, err)
+
+		if errors.Is(err, oci.ErrManifestNotFound) || task.Attempts+1 >= r.opts.MaxAttempts {
+			r.deleteTask(ctx, task.ID)
+			r.logger.Error(ctx, "replication abandoned", fields)
+			continue
+		}
+
+		task.Attempts++
+		task.LastError = err.Error()
+		task.NextAttempt = time.Now().Add(r.backoff(task.Attempts))
+		if err := r.saveTask(ctx, task); err != nil {
+			return replicated, err
+		}
+		r.logger.Warn(ctx, "replication failed, will retry", fields)
+	}
+	return replicated, nil
+}
+
+// Status returns the replication status of every target, including the
+// number of tasks still queued for it.
+func (r *Replicator) Status(ctx context.Context) ([]TargetStatus, error) {
+	tasks, err := r.pendingTasks(ctx)
+	if err != nil {
+		return nil, err
+	}
+	pending := make(map[string]int)
+	for _, task := range tasks {
+		pending[task.Target]++
+	}
+
+	statuses := make([]TargetStatus, 0, len(r.targets))
+	for _, target := range r.targets {
+		status, err := r.loadStatus(ctx, target.Name)
+		if err != nil {
+			return nil, err
+		}
+		status.Pending = pending[target.Name]
+		statuses = append(statuses, *status)
+	}
+	return statuses, nil
+}
+
+// backoff returns the delay before the given attempt is retried.
+func (r *Replicator) backoff(attempts int) time.Duration {
+	delay := r.opts.RetryInterval
+	for i := 1; i < attempts && delay < MaxRetryInterval; i++ {
+		delay *= 2
+	}
+	if delay > MaxRetryInterval {
+		delay = MaxRetryInterval
+	}
+	return delay
+}
+
+// pendingTasks loads every queued task, oldest first.
+func (r *Replicator) pendingTasks(ctx context.Context) ([]*Task, error) {
+	ids, err := r.store.List(ctx, QueueDir())
+	if err != nil {
+		return nil, fmt.Errorf("failed to list replication queue: %w", err)
+	}
+
+	tasks := make([]*Task, 0, len(ids))
+	for _, id := range ids {
+		var task Task
+		if err := r.readJSON(ctx, TaskPath(id), &task); err != nil {
+			if err == storage.ErrFileNotFound {
+				continue
+			}
+			return nil, err
+		}
+		tasks = append(tasks, &task)
+	}
+	sort.Slice(tasks, func(i, j int) bool {
+		return tasks[i].CreatedAt.Before(tasks[j].CreatedAt)
+	})
+	return tasks, nil
+}
+
+// saveTask writes a task to the queue.
+func (r *Replicator) saveTask(ctx context.Context, task *Task) error {
+	data, err := json.Marshal(task)
+	if err != nil {
+		return fmt.Errorf("failed to encode replication task: %w", err)
+	}
+	if err := r.store.Upload(ctx, TaskPath(task.ID), bytes.NewReader(data)); err != nil {
+		return fmt.Errorf("failed to store replication task: %w", err)
+	}
+	return nil
+}
+
+// deleteTask removes a task from the queue.
+func (r *Replicator) deleteTask(ctx context.Context, id string) {
+	r.store.Delete(ctx, TaskPath(id))
+}
+
+// recordResult updates a target's status after an attempt; err is nil on success.
+func (r *Replicator) recordResult(ctx context.Context, target string, err error) {
+	r.statusMu.Lock()
+	defer r.statusMu.Unlock()
+
+	status, loadErr := r.loadStatus(ctx, target)
+	if loadErr != nil {
+		r.logger.Error(ctx, "failed to read replication status", map[string]interface{}{"target": target, "error": loadErr.Error()})
+		return
+	}
+
+	now := time.Now()
+	if err == nil {
+		status.Replicated++
+		status.LastSuccess = &now
+	} else {
+		status.Failed++
+		status.LastFailure = &now
+		status.LastError = err.Error()
+	}
+
+	data, _ := json.Marshal(status)
+	if err := r.store.Upload(ctx, StatusPath(target), bytes.NewReader(data)); err != nil {
+		r.logger.Error(ctx, "failed to store replication status", map[string]interface{}{"target": target, "error": err.Error()})
+	}
+}
+
+// loadStatus reads a target's status, which is empty before its first attempt.
+func (r *Replicator) loadStatus(ctx context.Context, target string) (*TargetStatus, error) {
+	status := &TargetStatus{Target: target}
+	err := r.readJSON(ctx, StatusPath(target), status)
+	if err != nil && err != storage.ErrFileNotFound {
+		return nil, err
+	}
+	status.Pending = 0
+	return status, nil
+}
+
+// readJSON decodes the JSON document stored at p into v.
+func (r *Replicator) readJSON(ctx context.Context, p string, v interface{}) error {
+	rc, err := r.store.Download(ctx, p)
+	if err != nil {
+		if err == storage.ErrFileNotFound {
+			return err
+		}
+		return fmt.Errorf("failed to read %s: %w", p, err)
+	}
+	defer rc.Close()
+
+	data, err := io.ReadAll(rc)
+	if err != nil {
+		return fmt.Errorf("failed to read %s: %w", p, err)
+	}
+	if err := json.Unmarshal(data, v); err != nil {
+		return fmt.Errorf("failed to decode %s: %w", p, err)
+	}
+	return nil
+}
@@ -0,0 +1,163 @@
+// Package replication copies images pushed to the registry to downstream OCI
+// registries. Pushes are recorded in a queue persisted through BlobStorage,
+// so replication survives restarts and failed copies are retried with
+// exponential backoff.
+package replication
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"path"
+	"sync"
+	"time"
+
+	"github.com/hairizuanbinnoorazman/package-universe/logger"
+	"github.com/hairizuanbinnoorazman/package-universe/oci"
+	"github.com/hairizuanbinnoorazman/package-universe/storage"
+)
+
+// Target is a downstream registry that receives copies of pushed images.
+type Target struct {
+	Name   string
+	Client *oci.Client
+	// Repositories are path.Match globs selecting the repositories replicated
+	// to this target; empty replicates every repository.
+	Repositories []string
+}
+
+// NewTarget creates a target after checking its repository patterns.
+func NewTarget(name string, client *oci.Client, repositories []string) (Target, error) {
+	if name == "" || path.Base(name) != name {
+		return Target{}, fmt.Errorf("invalid replication target name %q", name)
+	}
+	for _, pattern := range repositories {
+		if _, err := path.Match(pattern, ""); err != nil {
+			return Target{}, fmt.Errorf("invalid repository pattern %q: %w", pattern, err)
+		}
+	}
+	return Target{Name: name, Client: client, Repositories: repositories}, nil
+}
+
+// Matches reports whether repository name is replicated to the target.
+func (t Target) Matches(name string) bool {
+	if len(t.Repositories) == 0 {
+		return true
+	}
+	for _, pattern := range t.Repositories {
+		if ok, _ := path.Match(pattern, name); ok {
+			return true
+		}
+	}
+	return false
+}
+
+// Options tunes retries of failed replications.
+type Options struct {
+	// RetryInterval is the delay before the first retry; each further attempt
+	// doubles it up to MaxRetryInterval. It is also how often the queue is polled.
+	RetryInterval time.Duration
+	// MaxAttempts is how many times a replication is tried before it is dropped.
+	MaxAttempts int
+}
+
+// MaxRetryInterval caps the backoff between attempts.
+const MaxRetryInterval = time.Hour
+
+// Replicator pushes manifests and the blobs they reference to targets.
+type Replicator struct {
+	registry *oci.OCIStorage
+	store    storage.BlobStorage
+	logger   logger.Logger
+	targets  []Target
+	opts     Options
+
+	wake     chan struct{}
+	statusMu sync.Mutex
+}
+
+// NewReplicator creates a replicator reading images from registry and keeping
+// its queue and status in store.
+func NewReplicator(registry *oci.OCIStorage, store storage.BlobStorage, log logger.Logger, targets []Target, opts Options) *Replicator {
+	if opts.RetryInterval <= 0 {
+		opts.RetryInterval = 30 * time.Second
+	}
+	if opts.MaxAttempts <= 0 {
+		opts.MaxAttempts = 10
+	}
+	return &Replicator{
+		registry: registry,
+		store:    store,
+		logger:   log,
+		targets:  targets,
+		opts:     opts,
+		wake:     make(chan struct{}, 1),
+	}
+}
+
+// Targets returns the configured targets.
+func (r *Replicator) Targets() []Target {
+	return r.targets
+}
+
+// Replicate synchronously copies name:reference to target. Blobs the target
+// already has are not sent again; indexes are copied together with their
+// child manifests.
+func (r *Replicator) Replicate(ctx context.Context, target Target, name, reference string) error {
+	data, _, contentType, err := r.registry.GetManifest(ctx, name, reference)
+	if err != nil {
+		return err
+	}
+	m, err := oci.ParseManifest(data)
+	if err != nil {
+		return err
+	}
+
+	if oci.IsIndexMediaType(contentType) {
+		for _, child := range m.Manifests {
+			if err := r.Replicate(ctx, target, name, child.Digest); err != nil {
+				return fmt.Errorf("child manifest %s: %w", child.Digest, err)
+			}
+		}
+	} else {
+		descriptors := m.Layers
+		if m.Config != nil {
+			descriptors = append([]oci.Descriptor{*m.Config}, m.Layers...)
+		}
+		for _, desc := range descriptors {
+			// Foreign layers are fetched from their URLs, not the registry
+			if len(desc.URLs) > 0 {
+				continue
+			}
+			digest, err := oci.ParseDigest(desc.Digest)
+			if err != nil {
+				return err
+			}
+			if err := r.pushBlob(ctx, target, name, digest); err != nil {
+				return fmt.Errorf("blob %s: %w", digest, err)
+			}
+		}
+	}
+
+	return target.Client.PutManifest(ctx, name, reference, contentType, data)
+}
+
+// pushBlob sends a blob to the target unless it already holds it.
+func (r *Replicator) pushBlob(ctx context.Context, target Target, name string, digest oci.DigestInfo) error {
+	_, err := target.Client.HeadBlob(ctx, name, digest)
+	if err == nil {
+		return nil
+	}
+	if !errors.Is(err, oci.ErrBlobNotFound) {
+		return err
+	}
+
+	info, err := r.registry.GetBlobInfo(ctx, name, digest)
+	if err != nil {
+		return err
+	}
+	return target.Client.PushBlob(ctx, name, digest, info.Size, func() (io.ReadCloser, error) {
+		return r.registry.GetBlob(ctx, name, digest)
+	})
+}
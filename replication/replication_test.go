@@ -0,0 +1,293 @@
+package replication
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/hairizuanbinnoorazman/package-universe/logger"
+	"github.com/hairizuanbinnoorazman/package-universe/oci"
+	"github.com/hairizuanbinnoorazman/package-universe/storage"
+)
+
+// fakeRegistry is an in-memory downstream registry accepting monolithic pushes.
+type fakeRegistry struct {
+	server *httptest.Server
+
+	mu        sync.Mutex
+	blobs     map[string][]byte // digest -> content
+	manifests map[string][]byte // "<name>:<reference>" -> manifest
+	pushes    []string          // manifest references in push order
+	uploads   int
+	failing   bool
+}
+
+func newFakeRegistry(t *testing.T) *fakeRegistry {
+	t.Helper()
+	f := &fakeRegistry{
+		blobs:     make(map[string][]byte),
+		manifests: make(map[string][]byte),
+	}
+	f.server = httptest.NewServer(http.HandlerFunc(f.serveHTTP))
+	t.Cleanup(f.server.Close)
+	return f
+}
+
+func (f *fakeRegistry) serveHTTP(w http.ResponseWriter, r *http.Request) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	if f.failing {
+		w.WriteHeader(http.StatusServiceUnavailable)
+		return
+	}
+
+	p := strings.TrimPrefix(r.URL.Path, "/v2/")
+	switch {
+	case r.Method == http.MethodPost && strings.HasSuffix(p, "/blobs/uploads/"):
+		w.Header().Set("Location", "/v2/"+p+"session")
+		w.WriteHeader(http.StatusAccepted)
+	case r.Method == http.MethodPut && strings.HasSuffix(p, "/blobs/uploads/session"):
+		data, _ := io.ReadAll(r.Body)
+		f.blobs[r.URL.Query().Get("digest")] = data
+		f.uploads++
+		w.WriteHeader(http.StatusCreated)
+	case r.Method == http.MethodHead && strings.Contains(p, "/blobs/"):
+		_, digest, _ := strings.Cut(p, "/blobs/")
+		data, ok := f.blobs[digest]
+		if !ok {
+			w.WriteHeader(http.StatusNotFound)
+			return
+		}
+		w.Header().Set("Content-Length", strconv.Itoa(len(data)))
+		w.WriteHeader(http.StatusOK)
+	case r.Method == http.MethodPut && strings.Contains(p, "/manifests/"):
+		name, reference, _ := strings.Cut(p, "/manifests/")
+		data, _ := io.ReadAll(r.Body)
+		f.manifests[name+":"+reference] = data
+		f.pushes = append(f.pushes, name+":"+reference)
+		w.WriteHeader(http.StatusCreated)
+	default:
+		w.WriteHeader(http.StatusNotFound)
+	}
+}
+
+func setupTestReplicator(t *testing.T, targets ...Target) (*Replicator, *oci.OCIStorage) {
+	t.Helper()
+	store, err := storage.NewLocalStorage(t.TempDir())
+	if err != nil {
+		t.Fatalf("failed to create local storage: %v", err)
+	}
+	registry := oci.NewOCIStorage(store, oci.NewSessionManager(store, 30*time.Minute))
+	replicator := NewReplicator(registry, store, logger.NewTestLogger(), targets, Options{RetryInterval: time.Minute, MaxAttempts: 2})
+	return replicator, registry
+}
+
+func newTestTarget(t *testing.T, name string, downstream *fakeRegistry, repositories ...string) Target {
+	t.Helper()
+	target, err := NewTarget(name, oci.NewClient(downstream.server.URL, "", "", 0), repositories)
+	if err != nil {
+		t.Fatalf("NewTarget failed: %v", err)
+	}
+	return target
+}
+
+func digestOf(data []byte) oci.DigestInfo {
+	return oci.DigestInfo{Algorithm: "sha256", Hex: fmt.Sprintf("%x", sha256.Sum256(data))}
+}
+
+// pushTestImage stores an image with a config and one layer under name:tag.
+func pushTestImage(t *testing.T, registry *oci.OCIStorage, name, tag, seed string) []byte {
+	t.Helper()
+	ctx := context.Background()
+
+	var descriptors []string
+	for _, blob := range []string{`{"seed":"` + seed + `"}`, "layer-" + seed} {
+		data := []byte(blob)
+		uuid, err := registry.InitiateUpload(ctx, name, "")
+		if err != nil {
+			t.Fatalf("InitiateUpload failed: %v", err)
+		}
+		if _, err := registry.WriteUploadChunk(ctx, uuid, bytes.NewReader(data)); err != nil {
+			t.Fatalf("WriteUploadChunk failed: %v", err)
+		}
+		if _, err := registry.CompleteUpload(ctx, uuid, digestOf(data)); err != nil {
+			t.Fatalf("CompleteUpload failed: %v", err)
+		}
+		descriptors = append(descriptors, fmt.Sprintf(`{"mediaType":"application/octet-stream","digest":"%s","size":%d}`, digestOf(data), len(data)))
+	}
+
+	manifest := []byte(fmt.Sprintf(`{"schemaVersion":2,"mediaType":"%s","config":%s,"layers":[%s]}`, oci.MediaTypeImageManifest, descriptors[0], descriptors[1]))
+	if _, err := registry.PutManifest(ctx, name, tag, oci.MediaTypeImageManifest, manifest); err != nil {
+		t.Fatalf("PutManifest failed: %v", err)
+	}
+	return manifest
+}
+
+func TestReplicator_Replicate(t *testing.T) {
+	ctx := context.Background()
+	downstream := newFakeRegistry(t)
+	target := newTestTarget(t, "dr", downstream)
+	replicator, registry := setupTestReplicator(t, target)
+
+	manifest := pushTestImage(t, registry, "apps/web", "v1", "web")
+	if err := replicator.Replicate(ctx, target, "apps/web", "v1"); err != nil {
+		t.Fatalf("Replicate failed: %v", err)
+	}
+	if !bytes.Equal(downstream.manifests["apps/web:v1"], manifest) {
+		t.Error("manifest was not replicated")
+	}
+	if downstream.uploads != 2 {
+		t.Errorf("uploaded %d blobs, want 2", downstream.uploads)
+	}
+
+	// Blobs already on the target are not sent again
+	if err := replicator.Replicate(ctx, target, "apps/web", "v1"); err != nil {
+		t.Fatalf("second Replicate failed: %v", err)
+	}
+	if downstream.uploads != 2 {
+		t.Errorf("uploaded %d blobs after re-replicating, want 2", downstream.uploads)
+	}
+}
+
+func TestReplicator_ReplicateIndex(t *testing.T) {
+	ctx := context.Background()
+	downstream := newFakeRegistry(t)
+	target := newTestTarget(t, "dr", downstream)
+	replicator, registry := setupTestReplicator(t, target)
+
+	child := pushTestImage(t, registry, "apps/multi", "amd64", "amd64")
+	index := []byte(fmt.Sprintf(`{"schemaVersion":2,"mediaType":"%s","manifests":[{"mediaType":"%s","digest":"%s","size":%d}]}`,
+		oci.MediaTypeImageIndex, oci.MediaTypeImageManifest, digestOf(child), len(child)))
+	if _, err := registry.PutManifest(ctx, "apps/multi", "latest", oci.MediaTypeImageIndex, index); err != nil {
+		t.Fatalf("PutManifest failed: %v", err)
+	}
+
+	if err := replicator.Replicate(ctx, target, "apps/multi", "latest"); err != nil {
+		t.Fatalf("Replicate failed: %v", err)
+	}
+	want := []string{"apps/multi:" + digestOf(child).String(), "apps/multi:latest"}
+	if fmt.Sprint(downstream.pushes) != fmt.Sprint(want) {
+		t.Errorf("pushes = %v, want children before the index %v", downstream.pushes, want)
+	}
+}
+
+func TestReplicator_Queue(t *testing.T) {
+	ctx := context.Background()
+	apps := newFakeRegistry(t)
+	everything := newFakeRegistry(t)
+	replicator, registry := setupTestReplicator(t,
+		newTestTarget(t, "apps", apps, "apps/*"),
+		newTestTarget(t, "all", everything),
+	)
+
+	pushTestImage(t, registry, "apps/api", "v1", "api")
+	pushTestImage(t, registry, "tools/lint", "v1", "lint")
+	for _, name := range []string{"apps/api", "tools/lint"} {
+		if err := replicator.Enqueue(ctx, name, "v1"); err != nil {
+			t.Fatalf("Enqueue failed: %v", err)
+		}
+	}
+
+	replicated, err := replicator.ProcessQueue(ctx)
+	if err != nil {
+		t.Fatalf("ProcessQueue failed: %v", err)
+	}
+	if replicated != 3 {
+		t.Errorf("replicated = %d, want 3", replicated)
+	}
+	if _, ok := apps.manifests["tools/lint:v1"]; ok {
+		t.Error("repositories outside the target's patterns should not be replicated")
+	}
+	if _, ok := everything.manifests["tools/lint:v1"]; !ok {
+		t.Error("target without patterns should receive every repository")
+	}
+
+	statuses, err := replicator.Status(ctx)
+	if err != nil {
+		t.Fatalf("Status failed: %v", err)
+	}
+	if statuses[0].Replicated != 1 || statuses[1].Replicated != 2 || statuses[0].Pending != 0 {
+		t.Errorf("unexpected status %+v", statuses)
+	}
+}
+
+func TestReplicator_QueueRetry(t *testing.T) {
+	ctx := context.Background()
+	downstream := newFakeRegistry(t)
+	replicator, registry := setupTestReplicator(t, newTestTarget(t, "dr", downstream))
+
+	pushTestImage(t, registry, "app", "v1", "retry")
+	downstream.failing = true
+	if err := replicator.Enqueue(ctx, "app", "v1"); err != nil {
+		t.Fatalf("Enqueue failed: %v", err)
+	}
+
+	if _, err := replicator.ProcessQueue(ctx); err != nil {
+		t.Fatalf("ProcessQueue failed: %v", err)
+	}
+	tasks, _ := replicator.pendingTasks(ctx)
+	if len(tasks) != 1 || tasks[0].Attempts != 1 || !tasks[0].NextAttempt.After(time.Now()) {
+		t.Fatalf("failed task should be rescheduled, got %+v", tasks)
+	}
+	statuses, _ := replicator.Status(ctx)
+	if statuses[0].Failed != 1 || statuses[0].Pending != 1 || statuses[0].LastError == "" {
+		t.Errorf("unexpected status %+v", statuses[0])
+	}
+
+	// Not yet due, so nothing is attempted
+	downstream.failing = false
+	if replicated, _ := replicator.ProcessQueue(ctx); replicated != 0 {
+		t.Errorf("replicated = %d before the retry is due", replicated)
+	}
+
+	// The queue is persisted, so a new replicator picks the task up
+	tasks[0].NextAttempt = time.Now()
+	replicator.saveTask(ctx, tasks[0])
+	restarted := NewReplicator(replicator.registry, replicator.store, logger.NewTestLogger(), replicator.targets, replicator.opts)
+	if replicated, _ := restarted.ProcessQueue(ctx); replicated != 1 {
+		t.Errorf("replicated = %d after restart, want 1", replicated)
+	}
+
+	// Tasks are dropped after MaxAttempts
+	downstream.failing = true
+	replicator.Enqueue(ctx, "app", "v1")
+	for i := 0; i < 2; i++ {
+		tasks, _ := replicator.pendingTasks(ctx)
+		for _, task := range tasks {
+			task.NextAttempt = time.Now()
+			replicator.saveTask(ctx, task)
+		}
+		replicator.ProcessQueue(ctx)
+	}
+	if tasks, _ := replicator.pendingTasks(ctx); len(tasks) != 0 {
+		t.Errorf("task should be dropped after max attempts, %d pending", len(tasks))
+	}
+}
+
+func TestReplicator_Backoff(t *testing.T) {
+	r := &Replicator{opts: Options{RetryInterval: 30 * time.Second}}
+	tests := []struct {
+		attempts int
+		want     time.Duration
+	}{
+		{1, 30 * time.Second},
+		{2, time.Minute},
+		{4, 4 * time.Minute},
+		{20, MaxRetryInterval},
+	}
+	for _, tt := range tests {
+		if got := r.backoff(tt.attempts); got != tt.want {
+			t.Errorf("backoff(%d) = %v, want %v", tt.attempts, got, tt.want)
+		}
+	}
+}
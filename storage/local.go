@@ -7,6 +7,7 @@ import (
 	"io"
 	"os"
 	"path/filepath"
+	"strings"
 )
 
 var (
@@ -48,14 +49,12 @@ func (s *LocalStorage) Upload(ctx context.Context, path string, reader io.Reader
 		return err
 	}
 
-	// Create parent directory if it doesn't exist
-	dir := filepath.Dir(fullPath)
-	if err := os.MkdirAll(dir, 0755); err != nil {
-		return fmt.Errorf("failed to create directory: %w", err)
-	}
-
-	// Create the file
-	file, err := os.Create(fullPath)
+	// Create the file, along with its parent directory if it doesn't exist
+	var file *os.File
+	err = s.inDir(filepath.Dir(fullPath), func() (err error) {
+		file, err = os.Create(fullPath)
+		return err
+	})
 	if err != nil {
 		return fmt.Errorf("failed to create file: %w", err)
 	}
@@ -89,6 +88,35 @@ func (s *LocalStorage) Download(ctx context.Context, path string) (io.ReadCloser
 	return file, nil
 }
 
+// DownloadRange retrieves length bytes starting at offset from the specified path.
+func (s *LocalStorage) DownloadRange(ctx context.Context, path string, offset, length int64) (io.ReadCloser, error) {
+	if offset < 0 {
+		return nil, fmt.Errorf("invalid range offset %d", offset)
+	}
+
+	rc, err := s.Download(ctx, path)
+	if err != nil {
+		return nil, err
+	}
+	file := rc.(*os.File)
+
+	if _, err := file.Seek(offset, io.SeekStart); err != nil {
+		file.Close()
+		return nil, fmt.Errorf("failed to seek file: %w", err)
+	}
+	if length < 0 {
+		return file, nil
+	}
+
+	return &limitedReadCloser{Reader: io.LimitReader(file, length), Closer: file}, nil
+}
+
+// limitedReadCloser pairs a limited reader with the closer of its underlying file.
+type limitedReadCloser struct {
+	io.Reader
+	io.Closer
+}
+
 // Delete removes the data at the specified path.
 func (s *LocalStorage) Delete(ctx context.Context, path string) error {
 	fullPath, err := s.validateAndJoinPath(path)
@@ -103,6 +131,9 @@ func (s *LocalStorage) Delete(ctx context.Context, path string) error {
 		return fmt.Errorf("failed to delete file: %w", err)
 	}
 
+	// Prune directories left empty so listings match S3 prefix semantics
+	s.removeEmptyParents(filepath.Dir(fullPath))
+
 	return nil
 }
 
@@ -124,6 +155,28 @@ func (s *LocalStorage) Exists(ctx context.Context, path string) (bool, error) {
 	return true, nil
 }
 
+// Stat returns file metadata using os.Stat.
+func (s *LocalStorage) Stat(ctx context.Context, path string) (*FileInfo, error) {
+	fullPath, err := s.validateAndJoinPath(path)
+	if err != nil {
+		return nil, err
+	}
+
+	info, err := os.Stat(fullPath)
+	if err != nil {
+		if errors.Is(err, os.ErrNotExist) {
+			return nil, ErrFileNotFound
+		}
+		return nil, fmt.Errorf("failed to stat file: %w", err)
+	}
+
+	return &FileInfo{
+		Size:    info.Size(),
+		ModTime: info.ModTime(),
+		ETag:    fmt.Sprintf("%q", fmt.Sprintf("%x-%x", info.ModTime().UnixNano(), info.Size())),
+	}, nil
+}
+
 // GetURL returns a relative path for accessing the file.
 func (s *LocalStorage) GetURL(ctx context.Context, path string) (string, error) {
 	fullPath, err := s.validateAndJoinPath(path)
@@ -169,6 +222,105 @@ func (s *LocalStorage) List(ctx context.Context, prefix string) ([]string, error
 	return names, nil
 }
 
+// Append writes data from the reader to the end of the file at the specified path.
+// If the write fails the file is truncated back to its previous size.
+func (s *LocalStorage) Append(ctx context.Context, path string, reader io.Reader) (int64, error) {
+	fullPath, err := s.validateAndJoinPath(path)
+	if err != nil {
+		return 0, err
+	}
+
+	// Open the file, creating it and its parent directory if they don't exist
+	var file *os.File
+	err = s.inDir(filepath.Dir(fullPath), func() (err error) {
+		file, err = os.OpenFile(fullPath, os.O_WRONLY|os.O_CREATE|os.O_APPEND, 0644)
+		return err
+	})
+	if err != nil {
+		return 0, fmt.Errorf("failed to open file: %w", err)
+	}
+	defer file.Close()
+
+	info, err := file.Stat()
+	if err != nil {
+		return 0, fmt.Errorf("failed to stat file: %w", err)
+	}
+
+	n, err := io.Copy(file, reader)
+	if err != nil {
+		// Drop the partial write so the file only ever holds complete appends
+		file.Truncate(info.Size())
+		return 0, fmt.Errorf("failed to append to file: %w", err)
+	}
+
+	return n, nil
+}
+
+// Move renames the file at src to dst, replacing any existing file at dst.
+func (s *LocalStorage) Move(ctx context.Context, src, dst string) error {
+	srcPath, err := s.validateAndJoinPath(src)
+	if err != nil {
+		return err
+	}
+	dstPath, err := s.validateAndJoinPath(dst)
+	if err != nil {
+		return err
+	}
+
+	err = s.inDir(filepath.Dir(dstPath), func() error {
+		return os.Rename(srcPath, dstPath)
+	})
+	if err != nil {
+		if errors.Is(err, os.ErrNotExist) {
+			return ErrFileNotFound
+		}
+		return fmt.Errorf("failed to move file: %w", err)
+	}
+
+	s.removeEmptyParents(filepath.Dir(srcPath))
+
+	return nil
+}
+
+// inDirAttempts bounds how often inDir recreates a directory that keeps
+// being pruned.
+const inDirAttempts = 10
+
+// inDir creates dir if it doesn't exist and runs create, which makes an entry
+// in it. A concurrent Delete or Move can prune dir, or one of its parents,
+// once it is empty, so both steps are retried while they fail because a
+// directory is gone.
+func (s *LocalStorage) inDir(dir string, create func() error) error {
+	var err error
+	for attempt := 0; attempt < inDirAttempts; attempt++ {
+		if err = os.MkdirAll(dir, 0755); err != nil {
+			if errors.Is(err, os.ErrNotExist) {
+				continue
+			}
+			return fmt.Errorf("failed to create directory: %w", err)
+		}
+		if err = create(); err == nil || !errors.Is(err, os.ErrNotExist) {
+			return err
+		}
+		if _, statErr := os.Stat(dir); statErr == nil {
+			// The directory is still there, so something else is missing
+			return err
+		}
+	}
+	return err
+}
+
+// removeEmptyParents removes dir and its ancestors up to (but excluding) baseDir
+// for as long as they are empty.
+func (s *LocalStorage) removeEmptyParents(dir string) {
+	for dir != s.baseDir && strings.HasPrefix(dir, s.baseDir) {
+		if err := os.Remove(dir); err != nil {
+			return
+		}
+		dir = filepath.Dir(dir)
+	}
+}
+
 // validateAndJoinPath validates the path and joins it with the base directory.
 // It prevents path traversal attacks by ensuring the final path is within baseDir.
 func (s *LocalStorage) validateAndJoinPath(path string) (string, error) {
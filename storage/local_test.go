@@ -3,10 +3,12 @@ package storage
 import (
 	"bytes"
 	"context"
+	"fmt"
 	"io"
 	"os"
 	"path/filepath"
 	"strings"
+	"sync"
 	"testing"
 )
 
@@ -188,6 +190,54 @@ func TestLocalStorage_Download(t *testing.T) {
 	})
 }
 
+func TestLocalStorage_DownloadRange(t *testing.T) {
+	ctx := context.Background()
+	storage, err := NewLocalStorage(t.TempDir())
+	if err != nil {
+		t.Fatalf("failed to create storage: %v", err)
+	}
+	storage.Upload(ctx, "blob", strings.NewReader("0123456789"))
+
+	tests := []struct {
+		name   string
+		offset int64
+		length int64
+		want   string
+	}{
+		{name: "bounded range", offset: 2, length: 3, want: "234"},
+		{name: "open-ended range", offset: 7, length: -1, want: "789"},
+		{name: "range past end", offset: 8, length: 10, want: "89"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			rc, err := storage.DownloadRange(ctx, "blob", tt.offset, tt.length)
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			defer rc.Close()
+			got, _ := io.ReadAll(rc)
+			if string(got) != tt.want {
+				t.Errorf("content = %q, want %q", got, tt.want)
+			}
+		})
+	}
+
+	t.Run("non-existent file", func(t *testing.T) {
+		_, err := storage.DownloadRange(ctx, "missing", 0, 1)
+		if err != ErrFileNotFound {
+			t.Errorf("expected ErrFileNotFound but got: %v", err)
+		}
+	})
+
+	t.Run("negative offset", func(t *testing.T) {
+		_, err := storage.DownloadRange(ctx, "blob", -1, 1)
+		if err == nil {
+			t.Error("expected error but got none")
+		}
+	})
+}
+
 func TestLocalStorage_Delete(t *testing.T) {
 	ctx := context.Background()
 	baseDir := t.TempDir()
@@ -219,6 +269,26 @@ func TestLocalStorage_Delete(t *testing.T) {
 		}
 	})
 
+	t.Run("delete prunes empty parent directories", func(t *testing.T) {
+		storage.Upload(ctx, "parent/keep.txt", strings.NewReader("keep"))
+		storage.Upload(ctx, "parent/child/leaf/file.txt", strings.NewReader("content"))
+
+		if err := storage.Delete(ctx, "parent/child/leaf/file.txt"); err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+
+		names, err := storage.List(ctx, "parent")
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		if len(names) != 1 || names[0] != "keep.txt" {
+			t.Errorf("expected only keep.txt to remain, got %v", names)
+		}
+		if _, err := os.Stat(baseDir); err != nil {
+			t.Errorf("base directory should not be removed: %v", err)
+		}
+	})
+
 	t.Run("delete non-existent file", func(t *testing.T) {
 		err := storage.Delete(ctx, "non-existent.txt")
 		if err != ErrFileNotFound {
@@ -277,6 +347,47 @@ func TestLocalStorage_Exists(t *testing.T) {
 	})
 }
 
+func TestLocalStorage_Stat(t *testing.T) {
+	ctx := context.Background()
+	storage, err := NewLocalStorage(t.TempDir())
+	if err != nil {
+		t.Fatalf("failed to create storage: %v", err)
+	}
+	storage.Upload(ctx, "test-stat.txt", strings.NewReader("content"))
+
+	t.Run("existing file", func(t *testing.T) {
+		info, err := storage.Stat(ctx, "test-stat.txt")
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		if info.Size != int64(len("content")) {
+			t.Errorf("size = %d, want %d", info.Size, len("content"))
+		}
+		if info.ModTime.IsZero() {
+			t.Error("modification time should be set")
+		}
+		if info.ETag == "" {
+			t.Error("etag should be set")
+		}
+	})
+
+	t.Run("etag changes with content", func(t *testing.T) {
+		before, _ := storage.Stat(ctx, "test-stat.txt")
+		storage.Append(ctx, "test-stat.txt", strings.NewReader(" more"))
+		after, _ := storage.Stat(ctx, "test-stat.txt")
+		if before.ETag == after.ETag {
+			t.Errorf("etag should change after modification, got %q", after.ETag)
+		}
+	})
+
+	t.Run("non-existent file", func(t *testing.T) {
+		_, err := storage.Stat(ctx, "non-existent.txt")
+		if err != ErrFileNotFound {
+			t.Errorf("expected ErrFileNotFound but got: %v", err)
+		}
+	})
+}
+
 func TestLocalStorage_GetURL(t *testing.T) {
 	ctx := context.Background()
 	baseDir := t.TempDir()
@@ -362,6 +473,114 @@ func TestLocalStorage_List(t *testing.T) {
 	})
 }
 
+func TestLocalStorage_AppendAndMove(t *testing.T) {
+	ctx := context.Background()
+	baseDir := t.TempDir()
+	storage, err := NewLocalStorage(baseDir)
+	if err != nil {
+		t.Fatalf("failed to create storage: %v", err)
+	}
+
+	t.Run("append creates and extends file", func(t *testing.T) {
+		for _, chunk := range []string{"hello ", "", "world"} {
+			n, err := storage.Append(ctx, "uploads/abc/data", strings.NewReader(chunk))
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if n != int64(len(chunk)) {
+				t.Errorf("append wrote %d bytes, want %d", n, len(chunk))
+			}
+		}
+
+		content, err := os.ReadFile(filepath.Join(baseDir, "uploads/abc/data"))
+		if err != nil {
+			t.Fatalf("failed to read appended file: %v", err)
+		}
+		if string(content) != "hello world" {
+			t.Errorf("content mismatch: got %q, want %q", string(content), "hello world")
+		}
+	})
+
+	t.Run("move relocates file and prunes source directories", func(t *testing.T) {
+		if err := storage.Move(ctx, "uploads/abc/data", "blobs/ab/final"); err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+
+		content, err := os.ReadFile(filepath.Join(baseDir, "blobs/ab/final"))
+		if err != nil {
+			t.Fatalf("failed to read moved file: %v", err)
+		}
+		if string(content) != "hello world" {
+			t.Errorf("content mismatch: got %q, want %q", string(content), "hello world")
+		}
+		if _, err := os.Stat(filepath.Join(baseDir, "uploads")); !os.IsNotExist(err) {
+			t.Errorf("source directories should be removed, got: %v", err)
+		}
+	})
+
+	t.Run("move non-existent file", func(t *testing.T) {
+		err := storage.Move(ctx, "missing", "dst")
+		if err != ErrFileNotFound {
+			t.Errorf("expected ErrFileNotFound but got: %v", err)
+		}
+	})
+
+	t.Run("path traversal attempt", func(t *testing.T) {
+		if _, err := storage.Append(ctx, "../outside.txt", strings.NewReader("x")); err == nil {
+			t.Error("expected error but got none")
+		}
+		if err := storage.Move(ctx, "blobs/ab/final", "../outside.txt"); err == nil {
+			t.Error("expected error but got none")
+		}
+	})
+}
+
+func TestLocalStorage_ConcurrentPruning(t *testing.T) {
+	ctx := context.Background()
+	storage, err := NewLocalStorage(t.TempDir())
+	if err != nil {
+		t.Fatalf("failed to create storage: %v", err)
+	}
+
+	// Each writer deletes its file straight away, pruning the shared directory
+	// while the others are creating theirs in it
+	var wg sync.WaitGroup
+	errs := make(chan error, 8)
+	for i := 0; i < 8; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			path := fmt.Sprintf("uploads/shared/%d", i)
+			for j := 0; j < 200; j++ {
+				var err error
+				switch j % 3 {
+				case 0:
+					err = storage.Upload(ctx, path, strings.NewReader("data"))
+				case 1:
+					_, err = storage.Append(ctx, path, strings.NewReader("data"))
+				default:
+					if err = storage.Upload(ctx, path+".src", strings.NewReader("data")); err == nil {
+						err = storage.Move(ctx, path+".src", path)
+					}
+				}
+				if err == nil {
+					err = storage.Delete(ctx, path)
+				}
+				if err != nil {
+					errs <- err
+					return
+				}
+			}
+		}(i)
+	}
+	wg.Wait()
+	close(errs)
+
+	for err := range errs {
+		t.Errorf("unexpected error: %v", err)
+	}
+}
+
 func TestLocalStorage_UploadLargeFile(t *testing.T) {
 	ctx := context.Background()
 	baseDir := t.TempDir()
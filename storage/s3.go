@@ -1,10 +1,12 @@
 package storage
 
 import (
+	"bytes"
 	"context"
 	"errors"
 	"fmt"
 	"io"
+	"net/url"
 	"path/filepath"
 	"strings"
 	"time"
@@ -12,15 +14,37 @@ import (
 	"github.com/aws/aws-sdk-go-v2/aws"
 	"github.com/aws/aws-sdk-go-v2/config"
 	"github.com/aws/aws-sdk-go-v2/service/s3"
+	"github.com/aws/aws-sdk-go-v2/service/s3/types"
 	"github.com/aws/smithy-go"
 )
 
+// s3PartsSuffix is appended to an object key to form the prefix under which
+// Append stores the parts of an object until it is moved.
+const s3PartsSuffix = ".parts/"
+
+// s3PartsMarker names the object Append keeps under an object's parts prefix
+// while it is held as parts. Lookups check for it with HeadObject, so objects
+// that were never appended to are not listed on every miss or Delete.
+const s3PartsMarker = "appending"
+
+// s3MinPartSize is the smallest size S3 accepts for every part of a multipart
+// upload except the last.
+const s3MinPartSize = 5 * 1024 * 1024
+
 // S3Storage implements BlobStorage using AWS S3.
 type S3Storage struct {
 	client            *s3.Client
 	presignClient     *s3.PresignClient
 	bucket            string
 	presignExpiration time.Duration
+	minPartSize       int64
+}
+
+// s3Part identifies one object written by Append.
+type s3Part struct {
+	key     string
+	size    int64
+	modTime time.Time
 }
 
 // NewS3Storage creates a new S3 storage client.
@@ -48,6 +72,7 @@ func NewS3Storage(bucket, region string) (*S3Storage, error) {
 		presignClient:     s3.NewPresignClient(client),
 		bucket:            bucket,
 		presignExpiration: 15 * time.Minute,
+		minPartSize:       s3MinPartSize,
 	}, nil
 }
 
@@ -85,11 +110,64 @@ func (s *S3Storage) Download(ctx context.Context, path string) (io.ReadCloser, e
 		Bucket: aws.String(s.bucket),
 		Key:    aws.String(cleanPath),
 	})
+	if err != nil {
+		if !isS3NotFoundError(err) {
+			return nil, fmt.Errorf("failed to download from S3: %w", err)
+		}
+
+		// The object may still be held as parts written by Append
+		parts, _, err := s.appendedParts(ctx, cleanPath)
+		if err != nil {
+			return nil, err
+		}
+		if len(parts) == 0 {
+			return nil, ErrFileNotFound
+		}
+		return &s3PartsReader{ctx: ctx, storage: s, parts: parts}, nil
+	}
+
+	return result.Body, nil
+}
+
+// DownloadRange retrieves length bytes starting at offset using a ranged GetObject.
+// Objects still held as parts by Append are not supported.
+func (s *S3Storage) DownloadRange(ctx context.Context, path string, offset, length int64) (io.ReadCloser, error) {
+	if err := validatePath(path); err != nil {
+		return nil, err
+	}
+	if offset < 0 {
+		return nil, fmt.Errorf("invalid range offset %d", offset)
+	}
+
+	// Clean the path for S3 key
+	cleanPath := filepath.ToSlash(filepath.Clean(path))
+
+	byteRange := fmt.Sprintf("bytes=%d-", offset)
+	if length >= 0 {
+		if length == 0 {
+			// S3 cannot express an empty range, so only confirm the object exists
+			exists, err := s.Exists(ctx, path)
+			if err != nil {
+				return nil, err
+			}
+			if !exists {
+				return nil, ErrFileNotFound
+			}
+			return io.NopCloser(strings.NewReader("")), nil
+		}
+		byteRange = fmt.Sprintf("bytes=%d-%d", offset, offset+length-1)
+	}
+
+	result, err := s.client.GetObject(ctx, &s3.GetObjectInput{
+		Bucket: aws.String(s.bucket),
+		Key:    aws.String(cleanPath),
+		Range:  aws.String(byteRange),
+	})
 	if err != nil {
 		if isS3NotFoundError(err) {
 			return nil, ErrFileNotFound
 		}
-		return nil, fmt.Errorf("failed to download from S3: %w", err)
+		return nil, fmt.Errorf("failed to download range from S3: %w", err)
 	}
 
 	return result.Body, nil
@@ -104,7 +182,17 @@ func (s *S3Storage) Delete(ctx context.Context, path string) error {
 	// Clean the path for S3 key
 	cleanPath := filepath.ToSlash(filepath.Clean(path))
 
-	_, err := s.client.DeleteObject(ctx, &s3.DeleteObjectInput{
+	parts, appending, err := s.appendedParts(ctx, cleanPath)
+	if err != nil {
+		return err
+	}
+	if appending {
+		if err := s.deleteParts(ctx, cleanPath, parts); err != nil {
+			return err
+		}
+	}
+
+	_, err = s.client.DeleteObject(ctx, &s3.DeleteObjectInput{
 		Bucket: aws.String(s.bucket),
 		Key:    aws.String(cleanPath),
 	})
@@ -133,7 +221,11 @@ func (s *S3Storage) Exists(ctx context.Context, path string) (bool, error) {
 	})
 	if err != nil {
 		if isS3NotFoundError(err) {
-			return false, nil
+			parts, _, err := s.appendedParts(ctx, cleanPath)
+			if err != nil {
+				return false, err
+			}
+			return len(parts) > 0, nil
 		}
 		return false, fmt.Errorf("failed to check S3 object existence: %w", err)
 	}
@@ -141,6 +233,46 @@ func (s *S3Storage) Exists(ctx context.Context, path string) (bool, error) {
 	return true, nil
 }
 
+// Stat returns object metadata using HeadObject. For an object still held as
+// parts by Append, the size is the total of its parts and the ETag is empty.
+func (s *S3Storage) Stat(ctx context.Context, path string) (*FileInfo, error) {
+	if err := validatePath(path); err != nil {
+		return nil, err
+	}
+
+	// Clean the path for S3 key
+	cleanPath := filepath.ToSlash(filepath.Clean(path))
+
+	result, err := s.client.HeadObject(ctx, &s3.HeadObjectInput{
+		Bucket: aws.String(s.bucket),
+		Key:    aws.String(cleanPath),
+	})
+	if err != nil {
+		if !isS3NotFoundError(err) {
+			return nil, fmt.Errorf("failed to stat S3 object: %w", err)
+		}
+
+		parts, _, err := s.appendedParts(ctx, cleanPath)
+		if err != nil {
+			return nil, err
+		}
+		if len(parts) == 0 {
+			return nil, ErrFileNotFound
+		}
+		info := &FileInfo{ModTime: parts[len(parts)-1].modTime}
+		for _, part := range parts {
+			info.Size += part.size
+		}
+		return info, nil
+	}
+
+	return &FileInfo{
+		Size:    aws.ToInt64(result.ContentLength),
+		ModTime: aws.ToTime(result.LastModified),
+		ETag:    aws.ToString(result.ETag),
+	}, nil
+}
+
 // GetURL returns a presigned URL for accessing the data at the specified path.
 func (s *S3Storage) GetURL(ctx context.Context, path string) (string, error) {
 	if err := validatePath(path); err != nil {
@@ -174,6 +306,7 @@ func (s *S3Storage) GetURL(ctx context.Context, path string) (string, error) {
 }
 
 // List returns the names of objects with the given prefix using S3 ListObjectsV2 with delimiter.
+// Results are followed across continuation tokens so listings are not truncated at 1000 keys.
 func (s *S3Storage) List(ctx context.Context, prefix string) ([]string, error) {
 	if err := validatePath(prefix); err != nil {
 		return nil, err
@@ -185,35 +318,404 @@ func (s *S3Storage) List(ctx context.Context, prefix string) ([]string, error) {
 	}
 
 	delimiter := "/"
-	result, err := s.client.ListObjectsV2(ctx, &s3.ListObjectsV2Input{
+	paginator := s3.NewListObjectsV2Paginator(s.client, &s3.ListObjectsV2Input{
 		Bucket:    aws.String(s.bucket),
 		Prefix:    aws.String(cleanPrefix),
 		Delimiter: &delimiter,
 	})
+
+	var names []string
+	for paginator.HasMorePages() {
+		result, err := paginator.NextPage(ctx)
+		if err != nil {
+			return nil, fmt.Errorf("failed to list S3 objects: %w", err)
+		}
+
+		for _, prefix := range result.CommonPrefixes {
+			if prefix.Prefix != nil {
+				name := strings.TrimPrefix(*prefix.Prefix, cleanPrefix)
+				name = strings.TrimSuffix(name, "/")
+				if name != "" {
+					names = append(names, name)
+				}
+			}
+		}
+		for _, obj := range result.Contents {
+			if obj.Key != nil {
+				name := strings.TrimPrefix(*obj.Key, cleanPrefix)
+				if name != "" {
+					names = append(names, name)
+				}
+			}
+		}
+	}
+
+	return names, nil
+}
+
+// Append stores data from the reader as one or more part objects under
+// <path>.parts/, each no larger than the minimum multipart part size, so memory
+// use is bounded regardless of how much data the reader holds. The first
+// append also writes the parts marker. The parts are assembled into a single
+// object by Move.
+func (s *S3Storage) Append(ctx context.Context, path string, reader io.Reader) (int64, error) {
+	if err := validatePath(path); err != nil {
+		return 0, err
+	}
+
+	cleanPath := filepath.ToSlash(filepath.Clean(path))
+
+	parts, appending, err := s.appendedParts(ctx, cleanPath)
 	if err != nil {
-		return nil, fmt.Errorf("failed to list S3 objects: %w", err)
+		return 0, err
+	}
+	if !appending {
+		_, err := s.client.PutObject(ctx, &s3.PutObjectInput{
+			Bucket: aws.String(s.bucket),
+			Key:    aws.String(s.markerKey(cleanPath)),
+			Body:   bytes.NewReader(nil),
+		})
+		if err != nil {
+			return 0, fmt.Errorf("failed to append to S3: %w", err)
+		}
 	}
 
-	var names []string
-	for _, prefix := range result.CommonPrefixes {
-		if prefix.Prefix != nil {
-			name := strings.TrimPrefix(*prefix.Prefix, cleanPrefix)
-			name = strings.TrimSuffix(name, "/")
-			if name != "" {
-				names = append(names, name)
+	buf := make([]byte, s.partSize())
+	next := len(parts)
+	var written []string
+	var total int64
+	for {
+		n, readErr := io.ReadFull(reader, buf)
+		if readErr != nil && readErr != io.EOF && readErr != io.ErrUnexpectedEOF {
+			s.deleteKeys(ctx, written)
+			return 0, fmt.Errorf("failed to read append data: %w", readErr)
+		}
+
+		// Always write at least one part so an empty object exists after the first append
+		if n > 0 || next == 0 {
+			key := s.partKey(cleanPath, next)
+			_, err := s.client.PutObject(ctx, &s3.PutObjectInput{
+				Bucket: aws.String(s.bucket),
+				Key:    aws.String(key),
+				Body:   bytes.NewReader(buf[:n]),
+			})
+			if err != nil {
+				s.deleteKeys(ctx, written)
+				return 0, fmt.Errorf("failed to append to S3: %w", err)
+			}
+			written = append(written, key)
+			next++
+			total += int64(n)
+		}
+
+		if readErr != nil {
+			break
+		}
+	}
+
+	return total, nil
+}
+
+// Move copies the object at src to dst and removes src. Objects built with
+// Append are assembled server-side with UploadPartCopy; parts smaller than the
+// minimum part size are streamed through a bounded buffer instead.
+func (s *S3Storage) Move(ctx context.Context, src, dst string) error {
+	if err := validatePath(src); err != nil {
+		return err
+	}
+	if err := validatePath(dst); err != nil {
+		return err
+	}
+
+	srcKey := filepath.ToSlash(filepath.Clean(src))
+	dstKey := filepath.ToSlash(filepath.Clean(dst))
+
+	parts, _, err := s.appendedParts(ctx, srcKey)
+	if err != nil {
+		return err
+	}
+
+	switch len(parts) {
+	case 0:
+		if err := s.copyObject(ctx, srcKey, dstKey); err != nil {
+			return err
+		}
+		_, err = s.client.DeleteObject(ctx, &s3.DeleteObjectInput{
+			Bucket: aws.String(s.bucket),
+			Key:    aws.String(srcKey),
+		})
+		if err != nil {
+			return fmt.Errorf("failed to delete moved S3 object: %w", err)
+		}
+		return nil
+	case 1:
+		err = s.copyObject(ctx, parts[0].key, dstKey)
+	default:
+		err = s.composeParts(ctx, parts, dstKey)
+	}
+	if err != nil {
+		return err
+	}
+
+	return s.deleteParts(ctx, srcKey, parts)
+}
+
+// copyObject performs a server-side copy of a single object.
+func (s *S3Storage) copyObject(ctx context.Context, srcKey, dstKey string) error {
+	_, err := s.client.CopyObject(ctx, &s3.CopyObjectInput{
+		Bucket:     aws.String(s.bucket),
+		Key:        aws.String(dstKey),
+		CopySource: aws.String(s.copySource(srcKey)),
+	})
+	if err != nil {
+		if isS3NotFoundError(err) {
+			return ErrFileNotFound
+		}
+		return fmt.Errorf("failed to copy S3 object: %w", err)
+	}
+	return nil
+}
+
+// composeParts assembles parts into dstKey with a multipart upload. Parts that
+// meet the minimum part size are copied server-side; smaller ones are
+// accumulated in a buffer until they do, or until the final part is reached.
+func (s *S3Storage) composeParts(ctx context.Context, parts []s3Part, dstKey string) error {
+	created, err := s.client.CreateMultipartUpload(ctx, &s3.CreateMultipartUploadInput{
+		Bucket: aws.String(s.bucket),
+		Key:    aws.String(dstKey),
+	})
+	if err != nil {
+		return fmt.Errorf("failed to create multipart upload: %w", err)
+	}
+	uploadID := created.UploadId
+
+	var completed []types.CompletedPart
+	var buf bytes.Buffer
+
+	flush := func() error {
+		partNumber := aws.Int32(int32(len(completed) + 1))
+		result, err := s.client.UploadPart(ctx, &s3.UploadPartInput{
+			Bucket:     aws.String(s.bucket),
+			Key:        aws.String(dstKey),
+			UploadId:   uploadID,
+			PartNumber: partNumber,
+			Body:       bytes.NewReader(buf.Bytes()),
+		})
+		if err != nil {
+			return fmt.Errorf("failed to upload part: %w", err)
+		}
+		completed = append(completed, types.CompletedPart{ETag: result.ETag, PartNumber: partNumber})
+		buf.Reset()
+		return nil
+	}
+
+	compose := func() error {
+		for i, part := range parts {
+			last := i == len(parts)-1
+
+			switch {
+			case part.size == 0:
+				// Nothing to add
+			case buf.Len() == 0 && (part.size >= s.partSize() || last):
+				partNumber := aws.Int32(int32(len(completed) + 1))
+				result, err := s.client.UploadPartCopy(ctx, &s3.UploadPartCopyInput{
+					Bucket:     aws.String(s.bucket),
+					Key:        aws.String(dstKey),
+					UploadId:   uploadID,
+					PartNumber: partNumber,
+					CopySource: aws.String(s.copySource(part.key)),
+				})
+				if err != nil {
+					return fmt.Errorf("failed to copy part: %w", err)
+				}
+				completed = append(completed, types.CompletedPart{ETag: result.CopyPartResult.ETag, PartNumber: partNumber})
+				continue
+			default:
+				result, err := s.client.GetObject(ctx, &s3.GetObjectInput{
+					Bucket: aws.String(s.bucket),
+					Key:    aws.String(part.key),
+				})
+				if err != nil {
+					return fmt.Errorf("failed to read part: %w", err)
+				}
+				_, err = io.Copy(&buf, result.Body)
+				result.Body.Close()
+				if err != nil {
+					return fmt.Errorf("failed to read part: %w", err)
+				}
 			}
+
+			if int64(buf.Len()) >= s.partSize() || (last && (buf.Len() > 0 || len(completed) == 0)) {
+				if err := flush(); err != nil {
+					return err
+				}
+			}
+		}
+
+		_, err := s.client.CompleteMultipartUpload(ctx, &s3.CompleteMultipartUploadInput{
+			Bucket:          aws.String(s.bucket),
+			Key:             aws.String(dstKey),
+			UploadId:        uploadID,
+			MultipartUpload: &types.CompletedMultipartUpload{Parts: completed},
+		})
+		if err != nil {
+			return fmt.Errorf("failed to complete multipart upload: %w", err)
 		}
+		return nil
+	}
+
+	if err := compose(); err != nil {
+		s.client.AbortMultipartUpload(ctx, &s3.AbortMultipartUploadInput{
+			Bucket:   aws.String(s.bucket),
+			Key:      aws.String(dstKey),
+			UploadId: uploadID,
+		})
+		return err
 	}
-	for _, obj := range result.Contents {
-		if obj.Key != nil {
-			name := strings.TrimPrefix(*obj.Key, cleanPrefix)
-			if name != "" {
-				names = append(names, name)
+	return nil
+}
+
+// appendedParts returns the parts written by Append for key, in append order,
+// and whether its parts marker exists. The parts are only listed when it does.
+func (s *S3Storage) appendedParts(ctx context.Context, key string) ([]s3Part, bool, error) {
+	_, err := s.client.HeadObject(ctx, &s3.HeadObjectInput{
+		Bucket: aws.String(s.bucket),
+		Key:    aws.String(s.markerKey(key)),
+	})
+	if err != nil {
+		if isS3NotFoundError(err) {
+			return nil, false, nil
+		}
+		return nil, false, fmt.Errorf("failed to check S3 object parts: %w", err)
+	}
+
+	parts, err := s.listParts(ctx, key)
+	if err != nil {
+		return nil, false, err
+	}
+	return parts, true, nil
+}
+
+// listParts returns the parts written by Append for key, in append order.
+func (s *S3Storage) listParts(ctx context.Context, key string) ([]s3Part, error) {
+	paginator := s3.NewListObjectsV2Paginator(s.client, &s3.ListObjectsV2Input{
+		Bucket: aws.String(s.bucket),
+		Prefix: aws.String(key + s3PartsSuffix),
+	})
+
+	var parts []s3Part
+	for paginator.HasMorePages() {
+		result, err := paginator.NextPage(ctx)
+		if err != nil {
+			return nil, fmt.Errorf("failed to list S3 object parts: %w", err)
+		}
+		for _, obj := range result.Contents {
+			if obj.Key != nil && *obj.Key != s.markerKey(key) {
+				parts = append(parts, s3Part{
+					key:     *obj.Key,
+					size:    aws.ToInt64(obj.Size),
+					modTime: aws.ToTime(obj.LastModified),
+				})
 			}
 		}
 	}
+	return parts, nil
+}
 
-	return names, nil
+// deleteParts removes the parts of key, then its parts marker.
+func (s *S3Storage) deleteParts(ctx context.Context, key string, parts []s3Part) error {
+	keys := make([]string, 0, len(parts)+1)
+	for _, part := range parts {
+		keys = append(keys, part.key)
+	}
+	return s.deleteKeys(ctx, append(keys, s.markerKey(key)))
+}
+
+// deleteKeys removes the given objects.
+func (s *S3Storage) deleteKeys(ctx context.Context, keys []string) error {
+	for _, key := range keys {
+		_, err := s.client.DeleteObject(ctx, &s3.DeleteObjectInput{
+			Bucket: aws.String(s.bucket),
+			Key:    aws.String(key),
+		})
+		if err != nil && !isS3NotFoundError(err) {
+			return fmt.Errorf("failed to delete from S3: %w", err)
+		}
+	}
+	return nil
+}
+
+// partKey returns the key of the n-th part of an appended object. Part numbers
+// are zero padded so that lexical listing order matches append order.
+func (s *S3Storage) partKey(key string, n int) string {
+	return fmt.Sprintf("%s%s%010d", key, s3PartsSuffix, n)
+}
+
+// markerKey returns the key of the parts marker of an appended object.
+func (s *S3Storage) markerKey(key string) string {
+	return key + s3PartsSuffix + s3PartsMarker
+}
+
+// partSize returns the size Append splits data into and the threshold for
+// copying a part server-side.
+func (s *S3Storage) partSize() int64 {
+	if s.minPartSize <= 0 {
+		return s3MinPartSize
+	}
+	return s.minPartSize
+}
+
+// copySource returns the URL-encoded bucket/key pair expected by copy operations.
+func (s *S3Storage) copySource(key string) string {
+	return url.PathEscape(s.bucket + "/" + key)
+}
+
+// s3PartsReader streams the parts of an appended object in order, opening one
+// part at a time.
+type s3PartsReader struct {
+	ctx     context.Context
+	storage *S3Storage
+	parts   []s3Part
+	current io.ReadCloser
+}
+
+// Read implements io.Reader.
+func (r *s3PartsReader) Read(p []byte) (int, error) {
+	for {
+		if r.current == nil {
+			if len(r.parts) == 0 {
+				return 0, io.EOF
+			}
+			result, err := r.storage.client.GetObject(r.ctx, &s3.GetObjectInput{
+				Bucket: aws.String(r.storage.bucket),
+				Key:    aws.String(r.parts[0].key),
+			})
+			if err != nil {
+				return 0, fmt.Errorf("failed to download part from S3: %w", err)
+			}
+			r.current = result.Body
+			r.parts = r.parts[1:]
+		}
+
+		n, err := r.current.Read(p)
+		if err == io.EOF {
+			r.current.Close()
+			r.current = nil
+			if n > 0 {
+				return n, nil
+			}
+			continue
+		}
+		return n, err
+	}
+}
+
+// Close implements io.Closer.
+func (r *s3PartsReader) Close() error {
+	if r.current != nil {
+		return r.current.Close()
+	}
+	return nil
 }
 
 // validatePath validates the path to prevent path traversal attacks.
@@ -2,8 +2,21 @@ package storage
 
 import (
 	"context"
+	"encoding/xml"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"sort"
+	"strconv"
 	"strings"
+	"sync"
 	"testing"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/s3"
 )
 
 func TestNewS3Storage(t *testing.T) {
@@ -327,3 +340,504 @@ func TestIsS3NotFoundError(t *testing.T) {
 		})
 	}
 }
+
+func TestS3Storage_ListPagination(t *testing.T) {
+	ctx := context.Background()
+	fake := newFakeS3()
+	fake.pageSize = 2
+	storage := newTestS3Storage(t, fake)
+
+	for i := 0; i < 5; i++ {
+		key := fmt.Sprintf("tags/tag-%d/current/link", i)
+		if err := storage.Upload(ctx, key, strings.NewReader("link")); err != nil {
+			t.Fatalf("failed to upload %s: %v", key, err)
+		}
+	}
+	storage.Upload(ctx, "tags/file.txt", strings.NewReader("file"))
+
+	names, err := storage.List(ctx, "tags")
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	sort.Strings(names)
+	want := []string{"file.txt", "tag-0", "tag-1", "tag-2", "tag-3", "tag-4"}
+	if strings.Join(names, ",") != strings.Join(want, ",") {
+		t.Errorf("names = %v, want %v", names, want)
+	}
+	if fake.listCalls < 3 {
+		t.Errorf("expected listing to follow continuation tokens, got %d calls", fake.listCalls)
+	}
+}
+
+func TestS3Storage_AppendAndMove(t *testing.T) {
+	ctx := context.Background()
+
+	tests := []struct {
+		name           string
+		chunks         []string
+		wantPartCopies bool
+	}{
+		{name: "single chunk", chunks: []string{"abcd"}},
+		{name: "chunks split into full parts", chunks: []string{"abcdefgh", "ijklmnop", "qr"}, wantPartCopies: true},
+		{name: "small chunks are buffered", chunks: []string{"ab", "cd", "ef", "gh", "i"}},
+		{name: "empty", chunks: []string{""}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			fake := newFakeS3()
+			fake.minPartSize = 4
+			storage := newTestS3Storage(t, fake)
+
+			var want string
+			for _, chunk := range tt.chunks {
+				n, err := storage.Append(ctx, "uploads/abc/data", strings.NewReader(chunk))
+				if err != nil {
+					t.Fatalf("append failed: %v", err)
+				}
+				if n != int64(len(chunk)) {
+					t.Errorf("append wrote %d bytes, want %d", n, len(chunk))
+				}
+				want += chunk
+			}
+
+			// Appended data is readable before the move
+			assertS3Content(t, storage, "uploads/abc/data", want)
+
+			if err := storage.Move(ctx, "uploads/abc/data", "blobs/final"); err != nil {
+				t.Fatalf("move failed: %v", err)
+			}
+			assertS3Content(t, storage, "blobs/final", want)
+
+			exists, err := storage.Exists(ctx, "uploads/abc/data")
+			if err != nil {
+				t.Fatalf("exists failed: %v", err)
+			}
+			if exists {
+				t.Error("source should not exist after move")
+			}
+			if names, _ := storage.List(ctx, "uploads/abc"); len(names) != 0 {
+				t.Errorf("expected parts to be removed, got %v", names)
+			}
+			if tt.wantPartCopies && fake.partCopies == 0 {
+				t.Error("expected full-size parts to be copied server-side")
+			}
+		})
+	}
+
+	t.Run("move plain object", func(t *testing.T) {
+		storage := newTestS3Storage(t, newFakeS3())
+		storage.Upload(ctx, "src", strings.NewReader("plain"))
+
+		if err := storage.Move(ctx, "src", "dst"); err != nil {
+			t.Fatalf("move failed: %v", err)
+		}
+		assertS3Content(t, storage, "dst", "plain")
+	})
+
+	t.Run("move non-existent object", func(t *testing.T) {
+		storage := newTestS3Storage(t, newFakeS3())
+		if err := storage.Move(ctx, "missing", "dst"); err != ErrFileNotFound {
+			t.Errorf("expected ErrFileNotFound but got: %v", err)
+		}
+	})
+
+	t.Run("plain objects are not listed for parts", func(t *testing.T) {
+		fake := newFakeS3()
+		storage := newTestS3Storage(t, fake)
+		storage.Upload(ctx, "plain", strings.NewReader("plain"))
+
+		if exists, err := storage.Exists(ctx, "missing"); err != nil || exists {
+			t.Errorf("exists = %v, %v; want false", exists, err)
+		}
+		if _, err := storage.Stat(ctx, "missing"); err != ErrFileNotFound {
+			t.Errorf("expected ErrFileNotFound but got: %v", err)
+		}
+		if _, err := storage.Download(ctx, "missing"); err != ErrFileNotFound {
+			t.Errorf("expected ErrFileNotFound but got: %v", err)
+		}
+		if err := storage.Delete(ctx, "plain"); err != nil {
+			t.Fatalf("delete failed: %v", err)
+		}
+		if fake.listCalls != 0 {
+			t.Errorf("expected no list calls, got %d", fake.listCalls)
+		}
+	})
+
+	t.Run("delete removes parts", func(t *testing.T) {
+		storage := newTestS3Storage(t, newFakeS3())
+		storage.Append(ctx, "uploads/def/data", strings.NewReader("chunk"))
+
+		if err := storage.Delete(ctx, "uploads/def/data"); err != nil {
+			t.Fatalf("delete failed: %v", err)
+		}
+		if _, err := storage.Download(ctx, "uploads/def/data"); err != ErrFileNotFound {
+			t.Errorf("expected ErrFileNotFound but got: %v", err)
+		}
+		if names, _ := storage.List(ctx, "uploads/def"); len(names) != 0 {
+			t.Errorf("expected parts and marker to be removed, got %v", names)
+		}
+	})
+}
+
+func TestS3Storage_DownloadRange(t *testing.T) {
+	ctx := context.Background()
+	storage := newTestS3Storage(t, newFakeS3())
+	storage.Upload(ctx, "blob", strings.NewReader("0123456789"))
+
+	tests := []struct {
+		name   string
+		offset int64
+		length int64
+		want   string
+	}{
+		{name: "bounded range", offset: 2, length: 3, want: "234"},
+		{name: "open-ended range", offset: 7, length: -1, want: "789"},
+		{name: "empty range", offset: 4, length: 0, want: ""},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			rc, err := storage.DownloadRange(ctx, "blob", tt.offset, tt.length)
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			defer rc.Close()
+			got, _ := io.ReadAll(rc)
+			if string(got) != tt.want {
+				t.Errorf("content = %q, want %q", got, tt.want)
+			}
+		})
+	}
+
+	t.Run("non-existent object", func(t *testing.T) {
+		_, err := storage.DownloadRange(ctx, "missing", 0, 1)
+		if err != ErrFileNotFound {
+			t.Errorf("expected ErrFileNotFound but got: %v", err)
+		}
+	})
+}
+
+func TestS3Storage_Stat(t *testing.T) {
+	ctx := context.Background()
+	fake := newFakeS3()
+	fake.minPartSize = 4
+	storage := newTestS3Storage(t, fake)
+	storage.Upload(ctx, "object", strings.NewReader("0123456789"))
+	storage.Append(ctx, "appended", strings.NewReader("0123456"))
+
+	info, err := storage.Stat(ctx, "object")
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if info.Size != 10 {
+		t.Errorf("size = %d, want 10", info.Size)
+	}
+	if info.ETag != `"etag-10"` {
+		t.Errorf("etag = %q, want %q", info.ETag, `"etag-10"`)
+	}
+	if info.ModTime.IsZero() {
+		t.Error("modification time should be set")
+	}
+
+	info, err = storage.Stat(ctx, "appended")
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if info.Size != 7 {
+		t.Errorf("appended size = %d, want 7", info.Size)
+	}
+
+	if _, err := storage.Stat(ctx, "missing"); err != ErrFileNotFound {
+		t.Errorf("expected ErrFileNotFound but got: %v", err)
+	}
+}
+
+// assertS3Content downloads path and compares it with want.
+func assertS3Content(t *testing.T, storage *S3Storage, path, want string) {
+	t.Helper()
+	rc, err := storage.Download(context.Background(), path)
+	if err != nil {
+		t.Fatalf("download %s failed: %v", path, err)
+	}
+	defer rc.Close()
+	got, err := io.ReadAll(rc)
+	if err != nil {
+		t.Fatalf("read %s failed: %v", path, err)
+	}
+	if string(got) != want {
+		t.Errorf("%s content = %q, want %q", path, got, want)
+	}
+}
+
+// newTestS3Storage returns an S3Storage talking to the given fake S3 server.
+func newTestS3Storage(t *testing.T, fake *fakeS3) *S3Storage {
+	t.Helper()
+	server := httptest.NewServer(fake)
+	t.Cleanup(server.Close)
+
+	client := s3.New(s3.Options{
+		Region:                     "us-east-1",
+		BaseEndpoint:               aws.String(server.URL),
+		UsePathStyle:               true,
+		Credentials:                aws.AnonymousCredentials{},
+		RequestChecksumCalculation: aws.RequestChecksumCalculationWhenRequired,
+		ResponseChecksumValidation: aws.ResponseChecksumValidationWhenRequired,
+	})
+
+	return &S3Storage{
+		client:            client,
+		presignClient:     s3.NewPresignClient(client),
+		bucket:            "test-bucket",
+		presignExpiration: 15 * time.Minute,
+		minPartSize:       int64(fake.minPartSize),
+	}
+}
+
+// fakeS3 is a minimal in-memory S3 server supporting the path-style object
+// operations used by S3Storage.
+type fakeS3 struct {
+	mu          sync.Mutex
+	objects     map[string][]byte
+	modified    map[string]time.Time
+	uploads     map[string]map[int][]byte
+	pageSize    int
+	listCalls   int
+	partCopies  int
+	minPartSize int
+}
+
+func newFakeS3() *fakeS3 {
+	return &fakeS3{
+		objects:  make(map[string][]byte),
+		modified: make(map[string]time.Time),
+		uploads:  make(map[string]map[int][]byte),
+		pageSize: 1000,
+	}
+}
+
+type fakeS3CompleteRequest struct {
+	Parts []struct {
+		PartNumber int `xml:"PartNumber"`
+	} `xml:"Part"`
+}
+
+type fakeS3ListResult struct {
+	XMLName               xml.Name             `xml:"ListBucketResult"`
+	Name                  string               `xml:"Name"`
+	Prefix                string               `xml:"Prefix"`
+	KeyCount              int                  `xml:"KeyCount"`
+	IsTruncated           bool                 `xml:"IsTruncated"`
+	NextContinuationToken string               `xml:"NextContinuationToken,omitempty"`
+	Contents              []fakeS3ListObject   `xml:"Contents"`
+	CommonPrefixes        []fakeS3CommonPrefix `xml:"CommonPrefixes"`
+}
+
+type fakeS3ListObject struct {
+	Key          string `xml:"Key"`
+	Size         int64  `xml:"Size"`
+	LastModified string `xml:"LastModified"`
+}
+
+type fakeS3CommonPrefix struct {
+	Prefix string `xml:"Prefix"`
+}
+
+func (f *fakeS3) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	// Path-style: /<bucket>/<key>
+	parts := strings.SplitN(strings.TrimPrefix(r.URL.Path, "/"), "/", 2)
+	key := ""
+	if len(parts) == 2 {
+		key = parts[1]
+	}
+
+	query := r.URL.Query()
+	copySource := r.Header.Get("X-Amz-Copy-Source")
+
+	switch {
+	case r.Method == http.MethodGet && key == "":
+		f.list(w, r)
+	case r.Method == http.MethodPost && query.Has("uploads"):
+		uploadID := fmt.Sprintf("upload-%d", len(f.uploads)+1)
+		f.uploads[uploadID] = make(map[int][]byte)
+		fmt.Fprintf(w, `<InitiateMultipartUploadResult><Bucket>test-bucket</Bucket><Key>%s</Key><UploadId>%s</UploadId></InitiateMultipartUploadResult>`, key, uploadID)
+	case r.Method == http.MethodPost && query.Has("uploadId"):
+		f.completeMultipart(w, r, key)
+	case r.Method == http.MethodDelete && query.Has("uploadId"):
+		delete(f.uploads, query.Get("uploadId"))
+		w.WriteHeader(http.StatusNoContent)
+	case r.Method == http.MethodPut && copySource != "":
+		source, _ := url.PathUnescape(copySource)
+		data, ok := f.objects[strings.TrimPrefix(source, "test-bucket/")]
+		if !ok {
+			f.notFound(w, r)
+			return
+		}
+		if query.Has("uploadId") {
+			f.partCopies++
+			partNumber, _ := strconv.Atoi(query.Get("partNumber"))
+			f.uploads[query.Get("uploadId")][partNumber] = data
+			fmt.Fprintf(w, `<CopyPartResult><ETag>"part-%d"</ETag></CopyPartResult>`, partNumber)
+			return
+		}
+		f.objects[key] = data
+		f.modified[key] = time.Now().UTC()
+		fmt.Fprint(w, `<CopyObjectResult><ETag>"copy"</ETag></CopyObjectResult>`)
+	case r.Method == http.MethodPut:
+		data, err := io.ReadAll(r.Body)
+		if err != nil {
+			w.WriteHeader(http.StatusInternalServerError)
+			return
+		}
+		if query.Has("uploadId") {
+			partNumber, _ := strconv.Atoi(query.Get("partNumber"))
+			f.uploads[query.Get("uploadId")][partNumber] = data
+			w.Header().Set("ETag", fmt.Sprintf(`"part-%d"`, partNumber))
+			w.WriteHeader(http.StatusOK)
+			return
+		}
+		f.objects[key] = data
+		f.modified[key] = time.Now().UTC()
+		w.Header().Set("ETag", fmt.Sprintf("%q", fmt.Sprintf("etag-%d", len(data))))
+		w.WriteHeader(http.StatusOK)
+	case r.Method == http.MethodGet || r.Method == http.MethodHead:
+		data, ok := f.objects[key]
+		if !ok {
+			f.notFound(w, r)
+			return
+		}
+		w.Header().Set("Last-Modified", f.modified[key].Format(http.TimeFormat))
+		w.Header().Set("ETag", fmt.Sprintf("%q", fmt.Sprintf("etag-%d", len(data))))
+		if byteRange := r.Header.Get("Range"); byteRange != "" && r.Method == http.MethodGet {
+			var start, end int
+			if n, _ := fmt.Sscanf(byteRange, "bytes=%d-%d", &start, &end); n < 2 {
+				end = len(data) - 1
+			}
+			if end >= len(data) {
+				end = len(data) - 1
+			}
+			w.Header().Set("Content-Range", fmt.Sprintf("bytes %d-%d/%d", start, end, len(data)))
+			w.Header().Set("Content-Length", strconv.Itoa(end-start+1))
+			w.WriteHeader(http.StatusPartialContent)
+			w.Write(data[start : end+1])
+			return
+		}
+		w.Header().Set("Content-Length", strconv.Itoa(len(data)))
+		w.WriteHeader(http.StatusOK)
+		if r.Method == http.MethodGet {
+			w.Write(data)
+		}
+	case r.Method == http.MethodDelete:
+		delete(f.objects, key)
+		delete(f.modified, key)
+		w.WriteHeader(http.StatusNoContent)
+	default:
+		w.WriteHeader(http.StatusNotImplemented)
+	}
+}
+
+// completeMultipart assembles an upload's parts, rejecting non-final parts
+// below minPartSize as S3 does.
+func (f *fakeS3) completeMultipart(w http.ResponseWriter, r *http.Request, key string) {
+	parts, ok := f.uploads[r.URL.Query().Get("uploadId")]
+	if !ok {
+		w.WriteHeader(http.StatusNotFound)
+		return
+	}
+
+	var req fakeS3CompleteRequest
+	if err := xml.NewDecoder(r.Body).Decode(&req); err != nil || len(req.Parts) == 0 {
+		w.WriteHeader(http.StatusBadRequest)
+		return
+	}
+
+	var data []byte
+	for i, part := range req.Parts {
+		if i < len(req.Parts)-1 && len(parts[part.PartNumber]) < f.minPartSize {
+			w.WriteHeader(http.StatusBadRequest)
+			fmt.Fprint(w, `<Error><Code>EntityTooSmall</Code><Message>part too small</Message></Error>`)
+			return
+		}
+		data = append(data, parts[part.PartNumber]...)
+	}
+
+	f.objects[key] = data
+	f.modified[key] = time.Now().UTC()
+	delete(f.uploads, r.URL.Query().Get("uploadId"))
+	fmt.Fprintf(w, `<CompleteMultipartUploadResult><Bucket>test-bucket</Bucket><Key>%s</Key><ETag>"complete"</ETag></CompleteMultipartUploadResult>`, key)
+}
+
+func (f *fakeS3) notFound(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/xml")
+	w.WriteHeader(http.StatusNotFound)
+	if r.Method != http.MethodHead {
+		fmt.Fprint(w, `<?xml version="1.0" encoding="UTF-8"?><Error><Code>NoSuchKey</Code><Message>not found</Message></Error>`)
+	}
+}
+
+func (f *fakeS3) list(w http.ResponseWriter, r *http.Request) {
+	f.listCalls++
+	query := r.URL.Query()
+	prefix := query.Get("prefix")
+	delimiter := query.Get("delimiter")
+	token := query.Get("continuation-token")
+
+	// Collect entries in lexical order, rolling keys up into common prefixes
+	type entry struct {
+		name     string
+		isPrefix bool
+	}
+	seen := make(map[string]bool)
+	var entries []entry
+	for key := range f.objects {
+		if !strings.HasPrefix(key, prefix) {
+			continue
+		}
+		rest := strings.TrimPrefix(key, prefix)
+		if delimiter != "" {
+			if idx := strings.Index(rest, delimiter); idx >= 0 {
+				common := prefix + rest[:idx+len(delimiter)]
+				if !seen[common] {
+					seen[common] = true
+					entries = append(entries, entry{name: common, isPrefix: true})
+				}
+				continue
+			}
+		}
+		entries = append(entries, entry{name: key})
+	}
+	sort.Slice(entries, func(i, j int) bool { return entries[i].name < entries[j].name })
+
+	result := fakeS3ListResult{Name: "test-bucket", Prefix: prefix}
+	for _, e := range entries {
+		if token != "" && e.name <= token {
+			continue
+		}
+		if result.KeyCount == f.pageSize {
+			result.IsTruncated = true
+			break
+		}
+		if e.isPrefix {
+			result.CommonPrefixes = append(result.CommonPrefixes, fakeS3CommonPrefix{Prefix: e.name})
+		} else {
+			result.Contents = append(result.Contents, fakeS3ListObject{
+				Key:          e.name,
+				Size:         int64(len(f.objects[e.name])),
+				LastModified: f.modified[e.name].Format(time.RFC3339),
+			})
+		}
+		result.KeyCount++
+		result.NextContinuationToken = e.name
+	}
+	if !result.IsTruncated {
+		result.NextContinuationToken = ""
+	}
+
+	w.Header().Set("Content-Type", "application/xml")
+	w.WriteHeader(http.StatusOK)
+	xml.NewEncoder(w).Encode(result)
+}
@@ -8,6 +8,15 @@ import (
 	"time"
 )
 
+// FileInfo describes stored data without reading it.
+type FileInfo struct {
+	Size    int64
+	ModTime time.Time
+	// ETag identifies the current version of the data. For S3 it is the object's
+	// ETag; for local storage it is derived from the size and modification time.
+	ETag string
+}
+
 // BlobStorage defines the interface for storing and retrieving binary data.
 type BlobStorage interface {
 	// Upload stores data from the reader at the specified path.
@@ -16,12 +25,20 @@ type BlobStorage interface {
 	// Download retrieves data from the specified path.
 	Download(ctx context.Context, path string) (io.ReadCloser, error)
 
+	// DownloadRange retrieves length bytes starting at offset from the specified path.
+	// A negative length reads to the end of the data.
+	DownloadRange(ctx context.Context, path string, offset, length int64) (io.ReadCloser, error)
+
 	// Delete removes the data at the specified path.
 	Delete(ctx context.Context, path string) error
 
 	// Exists checks if data exists at the specified path.
 	Exists(ctx context.Context, path string) (bool, error)
 
+	// Stat returns the size, modification time and ETag of the data at the
+	// specified path. Returns ErrFileNotFound if it doesn't exist.
+	Stat(ctx context.Context, path string) (*FileInfo, error)
+
 	// GetURL returns a URL for accessing the data at the specified path.
 	// For local storage, this returns a file:// URL or relative path.
 	GetURL(ctx context.Context, path string) (string, error)
@@ -30,6 +47,15 @@ type BlobStorage interface {
 	// For local storage, this lists files under the prefix directory.
 	// For S3, this uses ListObjectsV2 with the prefix.
 	List(ctx context.Context, prefix string) ([]string, error)
+
+	// Append writes data from the reader to the end of the object at the specified
+	// path, creating it if it doesn't exist, and returns the number of bytes written.
+	// An object built with Append should not be overwritten with Upload until it is moved.
+	Append(ctx context.Context, path string, reader io.Reader) (int64, error)
+
+	// Move relocates the data at src to dst, replacing any existing data at dst.
+	// For S3, appended objects are assembled server-side where possible.
+	Move(ctx context.Context, src, dst string) error
 }
 
 // NewBlobStorage creates a BlobStorage implementation based on configuration.
@@ -0,0 +1,119 @@
+package tracing
+
+import (
+	"context"
+	"io"
+
+	"github.com/hairizuanbinnoorazman/package-universe/storage"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// tracedStorage decorates a BlobStorage with a span per operation.
+type tracedStorage struct {
+	next    storage.BlobStorage
+	backend string
+	tracer  trace.Tracer
+}
+
+// InstrumentStorage wraps store so every operation is recorded as a client
+// span labelled with the backend, such as "local" or "s3", and the path.
+// Download spans cover opening the data, not reading it. ErrFileNotFound is
+// an answer rather than a failure and does not mark the span failed.
+func InstrumentStorage(store storage.BlobStorage, backend string) storage.BlobStorage {
+	return &tracedStorage{next: store, backend: backend, tracer: otel.Tracer(instrumentationName)}
+}
+
+// start starts the span of an operation on path.
+func (s *tracedStorage) start(ctx context.Context, operation, path string) (context.Context, trace.Span) {
+	return s.tracer.Start(ctx, "storage."+operation,
+		trace.WithSpanKind(trace.SpanKindClient),
+		trace.WithAttributes(
+			attribute.String("storage.backend", s.backend),
+			attribute.String("storage.path", path),
+		))
+}
+
+// end ends span, marking it failed when err is a failure.
+func end(span trace.Span, err error) {
+	if err != nil && err != storage.ErrFileNotFound {
+		span.RecordError(err)
+		span.SetStatus(codes.Error, err.Error())
+	}
+	span.End()
+}
+
+func (s *tracedStorage) Upload(ctx context.Context, path string, reader io.Reader) error {
+	ctx, span := s.start(ctx, "Upload", path)
+	err := s.next.Upload(ctx, path, reader)
+	end(span, err)
+	return err
+}
+
+func (s *tracedStorage) Download(ctx context.Context, path string) (io.ReadCloser, error) {
+	ctx, span := s.start(ctx, "Download", path)
+	rc, err := s.next.Download(ctx, path)
+	end(span, err)
+	return rc, err
+}
+
+func (s *tracedStorage) DownloadRange(ctx context.Context, path string, offset, length int64) (io.ReadCloser, error) {
+	ctx, span := s.start(ctx, "DownloadRange", path)
+	span.SetAttributes(attribute.Int64("storage.offset", offset), attribute.Int64("storage.length", length))
+	rc, err := s.next.DownloadRange(ctx, path, offset, length)
+	end(span, err)
+	return rc, err
+}
+
+func (s *tracedStorage) Delete(ctx context.Context, path string) error {
+	ctx, span := s.start(ctx, "Delete", path)
+	err := s.next.Delete(ctx, path)
+	end(span, err)
+	return err
+}
+
+func (s *tracedStorage) Exists(ctx context.Context, path string) (bool, error) {
+	ctx, span := s.start(ctx, "Exists", path)
+	exists, err := s.next.Exists(ctx, path)
+	end(span, err)
+	return exists, err
+}
+
+func (s *tracedStorage) Stat(ctx context.Context, path string) (*storage.FileInfo, error) {
+	ctx, span := s.start(ctx, "Stat", path)
+	info, err := s.next.Stat(ctx, path)
+	end(span, err)
+	return info, err
+}
+
+func (s *tracedStorage) GetURL(ctx context.Context, path string) (string, error) {
+	ctx, span := s.start(ctx, "GetURL", path)
+	url, err := s.next.GetURL(ctx, path)
+	end(span, err)
+	return url, err
+}
+
+func (s *tracedStorage) List(ctx context.Context, prefix string) ([]string, error) {
+	ctx, span := s.start(ctx, "List", prefix)
+	names, err := s.next.List(ctx, prefix)
+	end(span, err)
+	return names, err
+}
+
+func (s *tracedStorage) Append(ctx context.Context, path string, reader io.Reader) (int64, error) {
+	ctx, span := s.start(ctx, "Append", path)
+	n, err := s.next.Append(ctx, path, reader)
+	span.SetAttributes(attribute.Int64("storage.bytes", n))
+	end(span, err)
+	return n, err
+}
+
+func (s *tracedStorage) Move(ctx context.Context, src, dst string) error {
+	ctx, span := s.start(ctx, "Move", src)
+	span.SetAttributes(attribute.String("storage.destination", dst))
+	err := s.next.Move(ctx, src, dst)
+	end(span, err)
+	return err
+}
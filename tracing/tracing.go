@@ -0,0 +1,79 @@
+// Package tracing configures OpenTelemetry tracing and instruments HTTP
+// routes and blob storage with spans. Trace context is propagated with the
+// W3C traceparent and tracestate headers.
+package tracing
+
+import (
+	"context"
+	"fmt"
+
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/exporters/otlp/otlptrace/otlptracehttp"
+	"go.opentelemetry.io/otel/propagation"
+	"go.opentelemetry.io/otel/sdk/resource"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+)
+
+// instrumentationName identifies the spans created by this package.
+const instrumentationName = "github.com/hairizuanbinnoorazman/package-universe/tracing"
+
+// Exporters accepted in Config.
+const (
+	ExporterNone = "none"
+	ExporterOTLP = "otlp"
+)
+
+// Config selects where spans are sent.
+type Config struct {
+	Exporter    string  // ExporterNone or ExporterOTLP
+	Endpoint    string  // OTLP/HTTP collector host:port; OTEL_EXPORTER_OTLP_ENDPOINT applies when empty
+	Insecure    bool    // Send to the collector over plain HTTP
+	ServiceName string  // service.name resource attribute
+	SampleRatio float64 // Fraction of new traces sampled; traces started upstream follow the caller's decision
+}
+
+// Setup installs the W3C trace-context propagator and, unless the exporter is
+// ExporterNone, a global tracer provider exporting spans over OTLP/HTTP. With
+// ExporterNone spans are not recorded, but incoming trace context is still
+// propagated and logged. The returned function flushes pending spans and
+// stops the exporter.
+func Setup(ctx context.Context, cfg Config, version string) (func(context.Context) error, error) {
+	otel.SetTextMapPropagator(propagation.NewCompositeTextMapPropagator(propagation.TraceContext{}, propagation.Baggage{}))
+
+	switch cfg.Exporter {
+	case "", ExporterNone:
+		return func(context.Context) error { return nil }, nil
+	case ExporterOTLP:
+	default:
+		return nil, fmt.Errorf("unknown tracing exporter %q", cfg.Exporter)
+	}
+
+	var opts []otlptracehttp.Option
+	if cfg.Endpoint != "" {
+		opts = append(opts, otlptracehttp.WithEndpoint(cfg.Endpoint))
+	}
+	if cfg.Insecure {
+		opts = append(opts, otlptracehttp.WithInsecure())
+	}
+	exporter, err := otlptracehttp.New(ctx, opts...)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create OTLP exporter: %w", err)
+	}
+
+	res, err := resource.Merge(resource.Default(), resource.NewSchemaless(
+		attribute.String("service.name", cfg.ServiceName),
+		attribute.String("service.version", version),
+	))
+	if err != nil {
+		return nil, fmt.Errorf("failed to create tracing resource: %w", err)
+	}
+
+	provider := sdktrace.NewTracerProvider(
+		sdktrace.WithBatcher(exporter),
+		sdktrace.WithResource(res),
+		sdktrace.WithSampler(sdktrace.ParentBased(sdktrace.TraceIDRatioBased(cfg.SampleRatio))),
+	)
+	otel.SetTracerProvider(provider)
+	return provider.Shutdown, nil
+}
@@ -0,0 +1,176 @@
+package tracing
+
+import (
+	"bytes"
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"testing"
+	"time"
+
+	"github.com/gorilla/mux"
+	"github.com/hairizuanbinnoorazman/package-universe/logger"
+	"github.com/hairizuanbinnoorazman/package-universe/oci"
+	"github.com/hairizuanbinnoorazman/package-universe/storage"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
+)
+
+// exporter records the spans of every test. The global tracer provider can
+// only be installed once, since tracers obtained before it keep delegating to
+// the first provider set.
+var exporter = tracetest.NewInMemoryExporter()
+
+func TestMain(m *testing.M) {
+	if _, err := Setup(context.Background(), Config{Exporter: ExporterNone}, "test"); err != nil {
+		panic(err)
+	}
+	otel.SetTracerProvider(sdktrace.NewTracerProvider(sdktrace.WithSyncer(exporter)))
+	os.Exit(m.Run())
+}
+
+// resetSpans clears the spans recorded by earlier tests.
+func resetSpans(t *testing.T) {
+	t.Helper()
+	exporter.Reset()
+}
+
+// findSpan returns the recorded span with the given name.
+func findSpan(t *testing.T, spans tracetest.SpanStubs, name string) tracetest.SpanStub {
+	t.Helper()
+	for _, span := range spans {
+		if span.Name == name {
+			return span
+		}
+	}
+	t.Fatalf("no span named %q in %d spans", name, len(spans))
+	return tracetest.SpanStub{}
+}
+
+func spanAttr(span tracetest.SpanStub, key string) attribute.Value {
+	for _, attr := range span.Attributes {
+		if string(attr.Key) == key {
+			return attr.Value
+		}
+	}
+	return attribute.Value{}
+}
+
+func TestMiddleware(t *testing.T) {
+	resetSpans(t)
+	log := logger.NewTestLogger()
+
+	router := mux.NewRouter()
+	router.Use(Middleware)
+	router.HandleFunc("/v2/{name:.+}/manifests/{reference}", func(w http.ResponseWriter, r *http.Request) {
+		log.Info(r.Context(), "manifest requested", nil)
+		w.WriteHeader(http.StatusInternalServerError)
+	}).Methods("GET")
+
+	req := httptest.NewRequest(http.MethodGet, "/v2/team/app/manifests/v1", nil)
+	req.Header.Set("traceparent", "00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01")
+	router.ServeHTTP(httptest.NewRecorder(), req)
+
+	span := findSpan(t, exporter.GetSpans(), "GET /v2/{name:.+}/manifests/{reference}")
+	if got := span.SpanContext.TraceID().String(); got != "4bf92f3577b34da6a3ce929d0e0e4736" {
+		t.Errorf("trace id = %s, want the caller's trace", got)
+	}
+	if got := span.Parent.SpanID().String(); got != "00f067aa0ba902b7" {
+		t.Errorf("parent span id = %s, want the caller's span", got)
+	}
+	if got := spanAttr(span, "http.response.status_code").AsInt64(); got != 500 {
+		t.Errorf("status code attribute = %d, want 500", got)
+	}
+	if span.Status.Code != codes.Error {
+		t.Errorf("span status = %v, want error", span.Status.Code)
+	}
+
+	entries := log.Entries()
+	if len(entries) != 1 {
+		t.Fatalf("expected 1 log entry, got %d", len(entries))
+	}
+	if entries[0].Fields["trace_id"] != "4bf92f3577b34da6a3ce929d0e0e4736" || entries[0].Fields["span_id"] != span.SpanContext.SpanID().String() {
+		t.Errorf("log entry fields = %v, want the request's trace and span ids", entries[0].Fields)
+	}
+}
+
+func TestInstrumentStorage(t *testing.T) {
+	resetSpans(t)
+	ctx := context.Background()
+	local, err := storage.NewLocalStorage(t.TempDir())
+	if err != nil {
+		t.Fatalf("failed to create local storage: %v", err)
+	}
+	store := InstrumentStorage(local, "local")
+
+	if err := store.Upload(ctx, "a/file", bytes.NewReader([]byte("data"))); err != nil {
+		t.Fatalf("Upload failed: %v", err)
+	}
+	if _, err := store.Stat(ctx, "a/missing"); err != storage.ErrFileNotFound {
+		t.Fatalf("Stat err = %v, want ErrFileNotFound", err)
+	}
+
+	spans := exporter.GetSpans()
+	upload := findSpan(t, spans, "storage.Upload")
+	if spanAttr(upload, "storage.backend").AsString() != "local" || spanAttr(upload, "storage.path").AsString() != "a/file" {
+		t.Errorf("upload span attributes = %v", upload.Attributes)
+	}
+	if stat := findSpan(t, spans, "storage.Stat"); stat.Status.Code == codes.Error {
+		t.Error("a missing file should not mark the span failed")
+	}
+}
+
+func TestOCIStorageSpans(t *testing.T) {
+	resetSpans(t)
+	ctx := context.Background()
+	local, err := storage.NewLocalStorage(t.TempDir())
+	if err != nil {
+		t.Fatalf("failed to create local storage: %v", err)
+	}
+	store := InstrumentStorage(local, "local")
+	s := oci.NewOCIStorage(store, oci.NewSessionManager(store, 30*time.Minute))
+
+	uuid, err := s.InitiateUpload(ctx, "app", "")
+	if err != nil {
+		t.Fatalf("InitiateUpload failed: %v", err)
+	}
+	exporter.Reset()
+	if _, err := s.WriteUploadChunk(ctx, uuid, bytes.NewReader([]byte("chunk"))); err != nil {
+		t.Fatalf("WriteUploadChunk failed: %v", err)
+	}
+
+	spans := exporter.GetSpans()
+	write := findSpan(t, spans, "oci.WriteUploadChunk")
+	appendSpan := findSpan(t, spans, "storage.Append")
+	if appendSpan.Parent.SpanID() != write.SpanContext.SpanID() {
+		t.Error("storage.Append should be a child of oci.WriteUploadChunk")
+	}
+	if got := spanAttr(write, "oci.upload_id").AsString(); got != uuid {
+		t.Errorf("upload id attribute = %q, want %q", got, uuid)
+	}
+
+	if _, err := s.GetUploadOffset(ctx, "missing"); err != oci.ErrUploadNotFound {
+		t.Fatalf("GetUploadOffset err = %v, want ErrUploadNotFound", err)
+	}
+	if span := findSpan(t, exporter.GetSpans(), "oci.GetUploadOffset"); span.Status.Code == codes.Error {
+		t.Error("an unknown upload should not mark the span failed")
+	}
+}
+
+func TestSetup(t *testing.T) {
+	shutdown, err := Setup(context.Background(), Config{Exporter: ExporterNone}, "test")
+	if err != nil {
+		t.Fatalf("Setup failed: %v", err)
+	}
+	if err := shutdown(context.Background()); err != nil {
+		t.Errorf("shutdown failed: %v", err)
+	}
+
+	if _, err := Setup(context.Background(), Config{Exporter: "zipkin"}, "test"); err == nil {
+		t.Error("expected unknown exporter to be rejected")
+	}
+}